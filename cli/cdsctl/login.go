@@ -10,11 +10,13 @@ import (
 	"reflect"
 	"regexp"
 	"runtime"
+	"time"
 
 	"github.com/howeyc/gopass"
 	"github.com/naoina/toml"
 
 	"github.com/ovh/cds/cli"
+	"github.com/ovh/cds/sdk"
 	"github.com/ovh/cds/sdk/cdsclient"
 	"github.com/ovh/cds/sdk/keychain"
 )
@@ -42,6 +44,10 @@ var loginCmd = cli.Command{
 			ShortHand: "p",
 			Usage:     "CDS Password",
 			Kind:      reflect.String,
+		}, {
+			Name:  "sso",
+			Usage: "Login with the OpenID Connect provider of CDS, in a browser",
+			Kind:  reflect.Bool,
 		}, {
 			Name:  "env",
 			Usage: "Display the commands to set up the environment for the cds client",
@@ -56,6 +62,13 @@ func loginRun(v cli.Values) error {
 	password := v.GetString("password")
 	env := v.GetBool("env")
 
+	if v.GetBool("sso") {
+		if url == "" {
+			return fmt.Errorf("Please set the host flag to use --sso option")
+		}
+		return doLoginSSO(url, env)
+	}
+
 	if env &&
 		(url == "" || username == "" || password == "") {
 		return fmt.Errorf("Please set flags to use --env option")
@@ -103,7 +116,54 @@ func doLogin(url, username, password string, env bool) error {
 	if !ok {
 		return fmt.Errorf("login failed")
 	}
+	return saveLogin(url, username, token, env)
+}
+
+// doLoginSSO logs in with the device flow: the user logs in on the OpenID Connect provider in a browser,
+// while the command polls the API
+func doLoginSSO(url string, env bool) error {
+	conf := cdsclient.Config{
+		Host:    url,
+		Verbose: os.Getenv("CDS_VERBOSE") == "true",
+	}
+
+	client = cdsclient.New(conf)
+	device, err := client.UserLoginOIDCDevice()
+	if err != nil {
+		return err
+	}
+
+	verificationURI := device.VerificationURIComplete
+	if verificationURI == "" {
+		verificationURI = device.VerificationURI
+	}
+	fmt.Fprintf(os.Stderr, "Open %s in your browser and enter the code %s\n", verificationURI, device.UserCode)
+
+	interval := time.Duration(device.Interval) * time.Second
+	if interval == 0 {
+		interval = 5 * time.Second
+	}
+	expiration := time.Now().Add(time.Duration(device.ExpiresIn) * time.Second)
+	for {
+		time.Sleep(interval)
+		res, err := client.UserLoginOIDCDeviceToken(device.DeviceCode)
+		if err == sdk.ErrOIDCAuthorizationPending {
+			if device.ExpiresIn > 0 && time.Now().After(expiration) {
+				return fmt.Errorf("login expired")
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if !env {
+			fmt.Println("Username:", res.User.Username)
+		}
+		return saveLogin(url, res.User.Username, res.Token, env)
+	}
+}
 
+func saveLogin(url, username, token string, env bool) error {
 	if env && runtime.GOOS == "windows" {
 		fmt.Println("env option is not supported on windows yet")
 		os.Exit(1)
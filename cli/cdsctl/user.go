@@ -2,10 +2,15 @@ package main
 
 import (
 	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/spf13/cobra"
 
 	"github.com/ovh/cds/cli"
+	"github.com/ovh/cds/sdk"
 )
 
 var (
@@ -20,6 +25,19 @@ var (
 			cli.NewGetCommand(userShowCmd, userShowRun, nil),
 			cli.NewCommand(userResetCmd, userResetRun, nil),
 			cli.NewCommand(userConfirmCmd, userConfirmRun, nil),
+			userToken,
+		})
+
+	userTokenCmd = cli.Command{
+		Name:  "token",
+		Short: "Manage CDS personal access tokens",
+	}
+
+	userToken = cli.NewCommand(userTokenCmd, nil,
+		[]*cobra.Command{
+			cli.NewListCommand(userTokenListCmd, userTokenListRun, nil),
+			cli.NewGetCommand(userTokenCreateCmd, userTokenCreateRun, nil),
+			cli.NewCommand(userTokenDeleteCmd, userTokenDeleteRun, nil),
 		})
 )
 
@@ -93,3 +111,96 @@ var userConfirmCmd = cli.Command{
 func userConfirmRun(v cli.Values) error {
 	return nil
 }
+
+// currentUsername returns the user of the configuration: with a personal access token, it is read
+// from the CDS_USER environment variable
+func currentUsername() (string, error) {
+	if cfg == nil || cfg.User == "" {
+		return "", fmt.Errorf("unable to find your username, please login or set CDS_USER")
+	}
+	return cfg.User, nil
+}
+
+var userTokenListCmd = cli.Command{
+	Name:  "list",
+	Short: "List your personal access tokens",
+}
+
+func userTokenListRun(v cli.Values) (cli.ListResult, error) {
+	username, err := currentUsername()
+	if err != nil {
+		return nil, err
+	}
+	tokens, err := client.UserAccessTokenList(username)
+	if err != nil {
+		return nil, err
+	}
+	return cli.AsListResult(tokens), nil
+}
+
+var userTokenCreateCmd = cli.Command{
+	Name:  "create",
+	Short: "Create a personal access token",
+	Long:  "Create a personal access token. Scopes are read, run or admin:<project key>. The token is only displayed once; use it with the CDS_ACCESS_TOKEN environment variable",
+	Args: []cli.Arg{
+		{Name: "scopes"},
+	},
+	Flags: []cli.Flag{
+		{
+			Name:  "description",
+			Usage: "Description of the token",
+			Kind:  reflect.String,
+		},
+		{
+			Name:    "days",
+			Usage:   "Number of days before the token expires, 0 for never",
+			Default: "0",
+			Kind:    reflect.String,
+		},
+	},
+}
+
+func userTokenCreateRun(v cli.Values) (interface{}, error) {
+	username, err := currentUsername()
+	if err != nil {
+		return nil, err
+	}
+	t := &sdk.AccessToken{
+		Description: v.GetString("description"),
+		Scopes:      strings.Split(v["scopes"], ","),
+	}
+
+	days, err := strconv.Atoi(v.GetString("days"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid number of days: %s", v.GetString("days"))
+	}
+	if days > 0 {
+		expireAt := time.Now().Add(time.Duration(days) * 24 * time.Hour)
+		t.ExpireAt = &expireAt
+	}
+
+	if err := client.UserAccessTokenCreate(username, t); err != nil {
+		return nil, err
+	}
+	return *t, nil
+}
+
+var userTokenDeleteCmd = cli.Command{
+	Name:  "delete",
+	Short: "Revoke a personal access token",
+	Args: []cli.Arg{
+		{Name: "id"},
+	},
+}
+
+func userTokenDeleteRun(v cli.Values) error {
+	username, err := currentUsername()
+	if err != nil {
+		return err
+	}
+	id, err := strconv.ParseInt(v["id"], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid token id: %s", v["id"])
+	}
+	return client.UserAccessTokenDelete(username, id)
+}
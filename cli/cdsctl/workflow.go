@@ -1,9 +1,20 @@
 package main
 
 import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/spf13/cobra"
 
 	"github.com/ovh/cds/cli"
+	"github.com/ovh/cds/sdk"
+	"github.com/ovh/cds/sdk/exportentities"
 )
 
 var (
@@ -16,6 +27,15 @@ var (
 		[]*cobra.Command{
 			cli.NewListCommand(workflowListCmd, workflowListRun, nil),
 			cli.NewGetCommand(workflowShowCmd, workflowShowRun, nil),
+			cli.NewListCommand(workflowHistoryCmd, workflowHistoryRun, nil),
+			cli.NewListCommand(workflowVersionsCmd, workflowVersionsRun, nil),
+			cli.NewCommand(workflowDiffCmd, workflowDiffRun, nil),
+			cli.NewCommand(workflowRestoreCmd, workflowRestoreRun, nil),
+			cli.NewCommand(workflowExportCmd, workflowExportRun, nil),
+			cli.NewCommand(workflowImportCmd, workflowImportRun, nil),
+			cli.NewCommand(workflowStopCmd, workflowStopRun, nil),
+			cli.NewCommand(workflowRestartCmd, workflowRestartRun, nil),
+			cli.NewCommand(workflowApproveCmd, workflowApproveRun, nil),
 		})
 )
 
@@ -51,3 +71,342 @@ func workflowShowRun(v cli.Values) (interface{}, error) {
 	}
 	return *w, nil
 }
+
+var workflowHistoryCmd = cli.Command{
+	Name:  "history",
+	Short: "Search the runs of the CDS workflows of a project",
+	Args: []cli.Arg{
+		{Name: "project-key"},
+	},
+	OptionnalArgs: []cli.Arg{
+		{Name: "name"},
+	},
+	Flags: []cli.Flag{
+		{Name: "status", Usage: "Status of the runs", Kind: reflect.String},
+		{Name: "branch", Usage: "Git branch of the runs", Kind: reflect.String},
+		{Name: "hash", Usage: "Git commit of the runs", Kind: reflect.String},
+		{Name: "username", Usage: "User who triggered the runs", Kind: reflect.String},
+		{Name: "node", Usage: "Node or pipeline name run by the runs", Kind: reflect.String},
+		{Name: "tag", Usage: "Comma separated tags of the runs: tag:value,tag2:value2", Kind: reflect.String},
+		{Name: "since", Usage: "Runs started after this date (RFC3339)", Kind: reflect.String},
+		{Name: "until", Usage: "Runs started before this date (RFC3339)", Kind: reflect.String},
+		{Name: "offset", Usage: "Number of runs to skip", Kind: reflect.String},
+		{Name: "limit", Usage: "Maximum number of runs", Default: "10", Kind: reflect.String},
+	},
+}
+
+type workflowHistoryItem struct {
+	Workflow string    `cli:"workflow"`
+	Number   int64     `cli:"number,key"`
+	Status   string    `cli:"status"`
+	Branch   string    `cli:"branch"`
+	Hash     string    `cli:"hash"`
+	Username string    `cli:"username"`
+	Start    time.Time `cli:"start"`
+	Tags     string    `cli:"tags"`
+}
+
+func workflowHistoryRun(v cli.Values) (cli.ListResult, error) {
+	query := url.Values{}
+	for _, k := range []string{"status", "branch", "hash", "username", "node", "since", "until", "offset", "limit"} {
+		if v.GetString(k) != "" {
+			query.Set(k, v.GetString(k))
+		}
+	}
+	query.Set("workflow", v.GetString("name"))
+	if v.GetString("tag") != "" {
+		query["tag"] = strings.Split(v.GetString("tag"), ",")
+	}
+	filter, err := sdk.ParseWorkflowRunFilter(query)
+	if err != nil {
+		return nil, err
+	}
+
+	runs, err := client.WorkflowRunSearch(v["project-key"], filter)
+	if err != nil {
+		return nil, err
+	}
+	items := make([]workflowHistoryItem, len(runs))
+	for i, r := range runs {
+		tags := make([]string, len(r.Tags))
+		for j, t := range r.Tags {
+			tags[j] = t.Tag + ":" + t.Value
+		}
+		items[i] = workflowHistoryItem{
+			Workflow: r.Workflow.Name,
+			Number:   r.Number,
+			Status:   r.Status,
+			Branch:   r.VCSBranch,
+			Hash:     r.VCSHash,
+			Username: r.Username,
+			Start:    r.Start,
+			Tags:     strings.Join(tags, ","),
+		}
+	}
+	return cli.AsListResult(items), nil
+}
+
+var workflowVersionsCmd = cli.Command{
+	Name:  "versions",
+	Short: "List the versions of a CDS workflow",
+	Args: []cli.Arg{
+		{Name: "project-key"},
+		{Name: "name"},
+	},
+}
+
+func workflowVersionsRun(v cli.Values) (cli.ListResult, error) {
+	vs, err := client.WorkflowVersions(v["project-key"], v["name"])
+	if err != nil {
+		return nil, err
+	}
+	return cli.AsListResult(vs), nil
+}
+
+var workflowDiffCmd = cli.Command{
+	Name:  "diff",
+	Short: "Show node by node the changes between two versions of a CDS workflow",
+	Args: []cli.Arg{
+		{Name: "project-key"},
+		{Name: "name"},
+		{Name: "from"},
+		{Name: "to"},
+	},
+}
+
+func workflowDiffRun(v cli.Values) error {
+	from, err := strconv.ParseInt(v["from"], 10, 64)
+	if err != nil {
+		return fmt.Errorf("from parameter must be an integer")
+	}
+	to, err := strconv.ParseInt(v["to"], 10, 64)
+	if err != nil {
+		return fmt.Errorf("to parameter must be an integer")
+	}
+
+	diff, err := client.WorkflowVersionDiff(v["project-key"], v["name"], from, to)
+	if err != nil {
+		return err
+	}
+	for _, c := range diff.Changes {
+		fmt.Printf("~ workflow %s: %q => %q\n", c.Field, c.Before, c.After)
+	}
+	for _, n := range diff.Nodes {
+		fmt.Printf("%s node %s\n", n.Status, n.Name)
+		for _, c := range n.Changes {
+			fmt.Printf("  ~ %s: %q => %q\n", c.Field, c.Before, c.After)
+		}
+	}
+	return nil
+}
+
+var workflowRestoreCmd = cli.Command{
+	Name:  "restore",
+	Short: "Restore a previous version of a CDS workflow",
+	Args: []cli.Arg{
+		{Name: "project-key"},
+		{Name: "name"},
+		{Name: "version"},
+	},
+}
+
+func workflowRestoreRun(v cli.Values) error {
+	version, err := strconv.ParseInt(v["version"], 10, 64)
+	if err != nil {
+		return fmt.Errorf("version parameter must be an integer")
+	}
+	w, err := client.WorkflowVersionRestore(v["project-key"], v["name"], version)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Workflow %s restored from version %d as version %d\n", w.Name, version, w.Version)
+	return nil
+}
+
+var workflowExportCmd = cli.Command{
+	Name:  "export",
+	Short: "Export a CDS workflow",
+	Args: []cli.Arg{
+		{Name: "project-key"},
+		{Name: "name"},
+	},
+	Flags: []cli.Flag{
+		{
+			Name:    "format",
+			Usage:   "Format: yaml|json",
+			Default: "yaml",
+			Kind:    reflect.String,
+		},
+		{
+			Name:  "output",
+			Usage: "Output filename",
+			Kind:  reflect.String,
+		},
+	},
+}
+
+func workflowExportRun(v cli.Values) error {
+	btes, err := client.WorkflowExport(v["project-key"], v["name"], v.GetString("format"))
+	if err != nil {
+		return err
+	}
+
+	if v.GetString("output") == "" {
+		fmt.Println(string(btes))
+		return nil
+	}
+	return ioutil.WriteFile(v.GetString("output"), btes, os.FileMode(0644))
+}
+
+var workflowImportCmd = cli.Command{
+	Name:  "import",
+	Short: "Import a CDS workflow from a yaml or json file",
+	Args: []cli.Arg{
+		{Name: "project-key"},
+		{Name: "filename"},
+	},
+	Flags: []cli.Flag{
+		{
+			Name:  "force",
+			Usage: "Update the workflow if it already exists",
+			Kind:  reflect.Bool,
+		},
+	},
+}
+
+func workflowImportRun(v cli.Values) error {
+	btes, format, err := exportentities.ReadFile(v["filename"])
+	if err != nil {
+		return err
+	}
+
+	formatName := "yaml"
+	switch format {
+	case exportentities.FormatJSON:
+		formatName = "json"
+	case exportentities.FormatHCL:
+		return exportentities.ErrUnsupportedHCLFormat
+	}
+
+	w, err := client.WorkflowImport(v["project-key"], btes, formatName, v.GetBool("force"))
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Workflow %s imported\n", w.Name)
+	return nil
+}
+
+var workflowStopCmd = cli.Command{
+	Name:  "stop",
+	Short: "Stop a CDS workflow run, or only one of its node runs",
+	Args: []cli.Arg{
+		{Name: "project-key"},
+		{Name: "name"},
+		{Name: "number"},
+	},
+	Flags: []cli.Flag{
+		{
+			Name:  "node-run",
+			Usage: "ID of the node run to stop",
+			Kind:  reflect.String,
+		},
+	},
+}
+
+func workflowStopRun(v cli.Values) error {
+	number, err := strconv.ParseInt(v["number"], 10, 64)
+	if err != nil {
+		return fmt.Errorf("number parameter must be an integer")
+	}
+
+	if v.GetString("node-run") == "" {
+		if _, err := client.WorkflowRunStop(v["project-key"], v["name"], number); err != nil {
+			return err
+		}
+		fmt.Printf("Workflow %s #%d stopped\n", v["name"], number)
+		return nil
+	}
+
+	nodeRunID, err := strconv.ParseInt(v.GetString("node-run"), 10, 64)
+	if err != nil {
+		return fmt.Errorf("node-run parameter must be an integer")
+	}
+	if _, err := client.WorkflowNodeRunStop(v["project-key"], v["name"], number, nodeRunID); err != nil {
+		return err
+	}
+	fmt.Printf("Node run %d of workflow %s #%d stopped\n", nodeRunID, v["name"], number)
+	return nil
+}
+
+var workflowRestartCmd = cli.Command{
+	Name:  "restart",
+	Short: "Restart the failed jobs of a CDS workflow node run",
+	Args: []cli.Arg{
+		{Name: "project-key"},
+		{Name: "name"},
+		{Name: "number"},
+		{Name: "node-run"},
+	},
+}
+
+func workflowRestartRun(v cli.Values) error {
+	number, err := strconv.ParseInt(v["number"], 10, 64)
+	if err != nil {
+		return fmt.Errorf("number parameter must be an integer")
+	}
+	nodeRunID, err := strconv.ParseInt(v["node-run"], 10, 64)
+	if err != nil {
+		return fmt.Errorf("node-run parameter must be an integer")
+	}
+
+	if _, err := client.WorkflowNodeRunRestart(v["project-key"], v["name"], number, nodeRunID); err != nil {
+		return err
+	}
+	fmt.Printf("Failed jobs of node run %d of workflow %s #%d restarted\n", nodeRunID, v["name"], number)
+	return nil
+}
+
+var workflowApproveCmd = cli.Command{
+	Name:  "approve",
+	Short: "Approve, or reject, the start of a CDS workflow node waiting for approvals",
+	Args: []cli.Arg{
+		{Name: "project-key"},
+		{Name: "name"},
+		{Name: "number"},
+		{Name: "approval"},
+	},
+	Flags: []cli.Flag{
+		{
+			Name:  "reject",
+			Usage: "Reject instead of approve",
+			Kind:  reflect.Bool,
+		},
+		{
+			Name:  "comment",
+			Usage: "Comment recorded with your vote",
+			Kind:  reflect.String,
+		},
+	},
+}
+
+func workflowApproveRun(v cli.Values) error {
+	number, err := strconv.ParseInt(v["number"], 10, 64)
+	if err != nil {
+		return fmt.Errorf("number parameter must be an integer")
+	}
+	approvalID, err := strconv.ParseInt(v["approval"], 10, 64)
+	if err != nil {
+		return fmt.Errorf("approval parameter must be an integer")
+	}
+
+	approved := !v.GetBool("reject")
+	if _, err := client.WorkflowRunApprovalVote(v["project-key"], v["name"], number, approvalID, approved, v.GetString("comment")); err != nil {
+		return err
+	}
+	if approved {
+		fmt.Printf("Approval %d of workflow %s #%d approved\n", approvalID, v["name"], number)
+	} else {
+		fmt.Printf("Approval %d of workflow %s #%d rejected\n", approvalID, v["name"], number)
+	}
+	return nil
+}
@@ -2,11 +2,17 @@ package main
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/go-gorp/gorp"
 
 	"github.com/ovh/cds/engine/api/businesscontext"
 	"github.com/ovh/cds/engine/api/cache"
+	"github.com/ovh/cds/engine/api/database"
+	"github.com/ovh/cds/engine/api/event"
+	"github.com/ovh/cds/engine/api/secret"
+	"github.com/ovh/cds/engine/api/workflow"
+	"github.com/ovh/cds/sdk"
 	"github.com/ovh/cds/sdk/log"
 )
 
@@ -33,3 +39,144 @@ func deleteAdminMaintenanceHandler(w http.ResponseWriter, r *http.Request, db *g
 	cache.Delete("maintenance")
 	return nil
 }
+
+func getAdminEventDeadLettersHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	limit := 100
+	if l := r.FormValue("limit"); l != "" {
+		var err error
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit <= 0 {
+			return sdk.ErrWrongRequest
+		}
+	}
+
+	ds, err := event.LoadDeadLetters(db, limit)
+	if err != nil {
+		return sdk.WrapError(err, "getAdminEventDeadLettersHandler> Unable to load dead letters")
+	}
+	return WriteJSON(w, r, ds, http.StatusOK)
+}
+
+func postAdminReplayEventDeadLetterHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	id, err := requestVarInt(r, "id")
+	if err != nil {
+		return err
+	}
+
+	if err := event.ReplayDeadLetter(db, id); err != nil {
+		return sdk.WrapError(err, "postAdminReplayEventDeadLetterHandler> Unable to replay dead letter %d", id)
+	}
+	return nil
+}
+
+func postAdminReplayEventDeadLettersHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	ds, err := event.LoadDeadLetters(db, 1000)
+	if err != nil {
+		return sdk.WrapError(err, "postAdminReplayEventDeadLettersHandler> Unable to load dead letters")
+	}
+
+	// Replay the oldest events first
+	var nb int
+	for i := len(ds) - 1; i >= 0; i-- {
+		if err := event.ReplayDeadLetter(db, ds[i].ID); err != nil {
+			log.Warning("postAdminReplayEventDeadLettersHandler> Unable to replay dead letter %d: %s", ds[i].ID, err)
+			continue
+		}
+		nb++
+	}
+	return WriteJSON(w, r, map[string]int{"replayed": nb}, http.StatusOK)
+}
+
+func deleteAdminEventDeadLetterHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	id, err := requestVarInt(r, "id")
+	if err != nil {
+		return err
+	}
+
+	if _, err := event.LoadDeadLetter(db, id); err != nil {
+		return sdk.WrapError(err, "deleteAdminEventDeadLetterHandler> Unable to load dead letter %d", id)
+	}
+	return event.DeleteDeadLetter(db, id)
+}
+
+func getAdminEventMetricsHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	return WriteJSON(w, r, event.Metrics(), http.StatusOK)
+}
+
+func getAdminQueueQuotasHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	qs, err := workflow.LoadQueueQuotas(db)
+	if err != nil {
+		return sdk.WrapError(err, "getAdminQueueQuotasHandler> Unable to load quotas")
+	}
+	return WriteJSON(w, r, qs, http.StatusOK)
+}
+
+func postAdminQueueQuotaHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	var q sdk.QueueQuota
+	if err := UnmarshalBody(r, &q); err != nil {
+		return err
+	}
+
+	if err := workflow.InsertQueueQuota(db, &q); err != nil {
+		return sdk.WrapError(err, "postAdminQueueQuotaHandler> Unable to insert quota")
+	}
+
+	quota, err := workflow.LoadQueueQuota(db, q.ID)
+	if err != nil {
+		return sdk.WrapError(err, "postAdminQueueQuotaHandler> Unable to load quota %d", q.ID)
+	}
+	return WriteJSON(w, r, quota, http.StatusCreated)
+}
+
+func putAdminQueueQuotaHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	id, err := requestVarInt(r, "id")
+	if err != nil {
+		return err
+	}
+
+	var q sdk.QueueQuota
+	if err := UnmarshalBody(r, &q); err != nil {
+		return err
+	}
+
+	// Only the limits can be updated, the scope of a quota is kept
+	quota, err := workflow.LoadQueueQuota(db, id)
+	if err != nil {
+		return sdk.WrapError(err, "putAdminQueueQuotaHandler> Unable to load quota %d", id)
+	}
+	quota.MaxRunning = q.MaxRunning
+	quota.MaxQueued = q.MaxQueued
+
+	if err := workflow.UpdateQueueQuota(db, quota); err != nil {
+		return sdk.WrapError(err, "putAdminQueueQuotaHandler> Unable to update quota %d", id)
+	}
+	return WriteJSON(w, r, quota, http.StatusOK)
+}
+
+func deleteAdminQueueQuotaHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	id, err := requestVarInt(r, "id")
+	if err != nil {
+		return err
+	}
+
+	if _, err := workflow.LoadQueueQuota(db, id); err != nil {
+		return sdk.WrapError(err, "deleteAdminQueueQuotaHandler> Unable to load quota %d", id)
+	}
+	return workflow.DeleteQueueQuota(db, id)
+}
+
+func postAdminSecretsRotationHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	rotation, err := secret.StartRotation(database.GetDBMap)
+	if err != nil {
+		return sdk.WrapError(err, "postAdminSecretsRotationHandler> Unable to start rotation")
+	}
+	return WriteJSON(w, r, rotation, http.StatusAccepted)
+}
+
+func getAdminSecretsRotationHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	rotation := secret.RotationStatus()
+	if rotation == nil {
+		return sdk.ErrNotFound
+	}
+	return WriteJSON(w, r, rotation, http.StatusOK)
+}
@@ -1,6 +1,8 @@
 package application
 
 import (
+	"database/sql"
+
 	"github.com/go-gorp/gorp"
 
 	"github.com/ovh/cds/engine/api/environment"
@@ -14,7 +16,7 @@ import (
 // LoadGroupByApplication loads all the groups on the given application
 func LoadGroupByApplication(db gorp.SqlExecutor, app *sdk.Application) error {
 	app.ApplicationGroups = []sdk.GroupPermission{}
-	query := `SELECT "group".id, "group".name, application_group.role FROM "group"
+	query := `SELECT "group".id, "group".name, application_group.role, application_group.role_name FROM "group"
 	 		  JOIN application_group ON application_group.group_id = "group".id
 	 		  WHERE application_group.application_id = $1 ORDER BY "group".name ASC`
 	rows, errq := db.Query(query, app.ID)
@@ -26,12 +28,14 @@ func LoadGroupByApplication(db gorp.SqlExecutor, app *sdk.Application) error {
 	for rows.Next() {
 		var group sdk.Group
 		var perm int
-		if err := rows.Scan(&group.ID, &group.Name, &perm); err != nil {
+		var role sql.NullString
+		if err := rows.Scan(&group.ID, &group.Name, &perm, &role); err != nil {
 			return err
 		}
 		app.ApplicationGroups = append(app.ApplicationGroups, sdk.GroupPermission{
 			Group:      group,
 			Permission: perm,
+			Role:       role.String,
 		})
 	}
 	return nil
@@ -43,7 +47,7 @@ func LoadPermissions(db gorp.SqlExecutor, group *sdk.Group) error {
 		  SELECT project.projectKey,
 	                 application.name,
 	                 application.id,
-					 application_group.role, application.last_modified
+					 application_group.role, application_group.role_name, application.last_modified
 	      FROM application
 	      JOIN application_group ON application_group.application_id = application.id
 	 	  JOIN project ON application.project_id = project.id
@@ -58,13 +62,15 @@ func LoadPermissions(db gorp.SqlExecutor, group *sdk.Group) error {
 	for rows.Next() {
 		var application sdk.Application
 		var perm int
-		err = rows.Scan(&application.ProjectKey, &application.Name, &application.ID, &perm, &application.LastModified)
+		var role sql.NullString
+		err = rows.Scan(&application.ProjectKey, &application.Name, &application.ID, &perm, &role, &application.LastModified)
 		if err != nil {
 			return sdk.WrapError(err, "LoadPermission %s (%d)", group.Name, group.ID)
 		}
 		group.ApplicationGroups = append(group.ApplicationGroups, sdk.ApplicationGroup{
 			Application: application,
 			Permission:  perm,
+			Role:        role.String,
 		})
 	}
 	return nil
@@ -93,6 +99,9 @@ func AddGroup(db gorp.SqlExecutor, proj *sdk.Project, a *sdk.Application, u *sdk
 			if err := group.InsertGroupInApplication(db, a.ID, g.ID, gp.Permission); err != nil {
 				return sdk.WrapError(err, "AddGroup> Unable to inserting in application_group %d %d %d: %s", a.ID, g.ID, gp.Permission)
 			}
+			if err := group.UpdateGroupRoleNameInApplication(db, a.ID, g.ID, gp.Role); err != nil {
+				return sdk.WrapError(err, "AddGroup> Unable to set role %s in application_group %d %d", gp.Role, a.ID, g.ID)
+			}
 		}
 		// If the group has only read permission on application, let it go with read permission on projet, pipeline and environment
 		// If the group has more than read permission on Application, it will have read & execute permission on projet, pipeline and environment
@@ -100,6 +109,7 @@ func AddGroup(db gorp.SqlExecutor, proj *sdk.Project, a *sdk.Application, u *sdk
 		if gp.Permission == permission.PermissionRead {
 			perm = permission.PermissionRead
 		}
+		role := sdk.InheritedRole(gp.Role)
 
 		//Check association with project
 		groupAttachedToProject, errc := group.CheckGroupInProject(db, proj.ID, g.ID)
@@ -111,6 +121,9 @@ func AddGroup(db gorp.SqlExecutor, proj *sdk.Project, a *sdk.Application, u *sdk
 			if err := group.InsertGroupInProject(db, proj.ID, g.ID, perm); err != nil {
 				return sdk.WrapError(err, "AddGroup> Cannot add group %s in project %s", g.Name, proj.Name)
 			}
+			if err := group.UpdateGroupRoleNameInProject(db, proj.ID, g.ID, role); err != nil {
+				return sdk.WrapError(err, "AddGroup> Cannot set role of group %s in project %s", g.Name, proj.Name)
+			}
 
 			if err := UpdateLastModified(db, a, u); err != nil {
 				return sdk.WrapError(err, "AddGroup> Cannot update application %s", a.Name)
@@ -129,6 +142,9 @@ func AddGroup(db gorp.SqlExecutor, proj *sdk.Project, a *sdk.Application, u *sdk
 				if err := group.InsertGroupInPipeline(db, p.Pipeline.ID, g.ID, perm); err != nil {
 					return sdk.WrapError(err, "AddGroup> Cannot add group %s in pipeline %s", g.Name, p.Pipeline.Name)
 				}
+				if err := group.UpdateGroupRoleNameInPipeline(db, p.Pipeline.ID, g.ID, role); err != nil {
+					return sdk.WrapError(err, "AddGroup> Cannot set role of group %s in pipeline %s", g.Name, p.Pipeline.Name)
+				}
 
 				if err := pipeline.UpdatePipelineLastModified(db, proj, &p.Pipeline, u); err != nil {
 					return sdk.WrapError(err, "AddGroup> Cannot update pipeline %s", p.Pipeline.Name)
@@ -147,6 +163,9 @@ func AddGroup(db gorp.SqlExecutor, proj *sdk.Project, a *sdk.Application, u *sdk
 						if err := group.InsertGroupInEnvironment(db, t.DestEnvironment.ID, g.ID, perm); err != nil {
 							return sdk.WrapError(err, "AddGroup> Cannot add group %s in env %s", g.Name, t.DestEnvironment.Name)
 						}
+						if err := group.UpdateGroupRoleNameInEnvironment(db, t.DestEnvironment.ID, g.ID, role); err != nil {
+							return sdk.WrapError(err, "AddGroup> Cannot set role of group %s in env %s", g.Name, t.DestEnvironment.Name)
+						}
 
 						if err := environment.UpdateLastModified(db, u, &t.DestEnvironment); err != nil {
 							return sdk.WrapError(err, "AddGroup> Cannot update env %s", t.DestEnvironment.Name)
@@ -163,6 +182,9 @@ func AddGroup(db gorp.SqlExecutor, proj *sdk.Project, a *sdk.Application, u *sdk
 						if err := group.InsertGroupInEnvironment(db, t.SrcEnvironment.ID, g.ID, perm); err != nil {
 							return sdk.WrapError(err, "AddGroup> Cannot add group %s in env %s", g.Name, t.SrcEnvironment.Name)
 						}
+						if err := group.UpdateGroupRoleNameInEnvironment(db, t.SrcEnvironment.ID, g.ID, role); err != nil {
+							return sdk.WrapError(err, "AddGroup> Cannot set role of group %s in env %s", g.Name, t.SrcEnvironment.Name)
+						}
 
 						if err := environment.UpdateLastModified(db, u, &t.SrcEnvironment); err != nil {
 							return sdk.WrapError(err, "AddGroup> Cannot update env %s", t.SrcEnvironment.Name)
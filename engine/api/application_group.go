@@ -26,6 +26,9 @@ func updateGroupRoleOnApplicationHandler(w http.ResponseWriter, r *http.Request,
 	if err := UnmarshalBody(r, &groupApplication); err != nil {
 		return err
 	}
+	if err := groupApplication.ApplyRole(); err != nil {
+		return sdk.WrapError(err, "updateGroupRoleOnApplicationHandler> invalid role %s", groupApplication.Role)
+	}
 
 	app, errload := application.LoadByName(db, key, appName, c.User)
 	if errload != nil {
@@ -57,6 +60,9 @@ func updateGroupRoleOnApplicationHandler(w http.ResponseWriter, r *http.Request,
 	if err := group.UpdateGroupRoleInApplication(tx, key, appName, groupName, groupApplication.Permission); err != nil {
 		return sdk.WrapError(err, "updateGroupRoleOnApplicationHandler: Cannot update permission for group %s in application %s", groupName, appName)
 	}
+	if err := group.UpdateGroupRoleNameInApplication(tx, app.ID, g.ID, groupApplication.Role); err != nil {
+		return sdk.WrapError(err, "updateGroupRoleOnApplicationHandler: Cannot set role of group %s in application %s", groupName, appName)
+	}
 
 	if err := application.UpdateLastModified(tx, app, c.User); err != nil {
 		return sdk.WrapError(err, "updateGroupsInApplicationHandler: Cannot update last modified date")
@@ -152,6 +158,9 @@ func addGroupInApplicationHandler(w http.ResponseWriter, r *http.Request, db *go
 	if err := UnmarshalBody(r, &groupPermission); err != nil {
 		return sdk.WrapError(err, "addGroupInApplicationHandler> Cannot unmarshal request")
 	}
+	if err := groupPermission.ApplyRole(); err != nil {
+		return sdk.WrapError(err, "addGroupInApplicationHandler> invalid role %s", groupPermission.Role)
+	}
 
 	proj, err := project.Load(db, key, c.User)
 	if err != nil {
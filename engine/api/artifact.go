@@ -14,6 +14,7 @@ import (
 	"github.com/ovh/cds/engine/api/artifact"
 	"github.com/ovh/cds/engine/api/businesscontext"
 	"github.com/ovh/cds/engine/api/environment"
+	"github.com/ovh/cds/engine/api/objectstore"
 	"github.com/ovh/cds/engine/api/permission"
 	"github.com/ovh/cds/engine/api/pipeline"
 	"github.com/ovh/cds/sdk"
@@ -167,6 +168,42 @@ func downloadArtifactHandler(w http.ResponseWriter, r *http.Request, db *gorp.Db
 	return nil
 }
 
+func getArtifactTemporaryURLHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	artifactID, errAtoi := requestVarInt(r, "id")
+	if errAtoi != nil {
+		return sdk.WrapError(errAtoi, "getArtifactTemporaryURLHandler> Cannot get artifact ID")
+	}
+
+	art, err := artifact.LoadArtifact(db, int64(artifactID))
+	if err != nil {
+		return sdk.WrapError(err, "getArtifactTemporaryURLHandler> Cannot load artifact")
+	}
+
+	u, expires, err := objectstore.FetchTemporaryURL(art, art.Name)
+	if err != nil {
+		return sdk.WrapError(err, "getArtifactTemporaryURLHandler> Cannot get temporary url for artifact %s", art.Name)
+	}
+
+	return WriteJSON(w, r, sdk.ArtifactTemporaryURL{URL: u, Expires: expires}, http.StatusOK)
+}
+
+// downloadObjectTemporaryURLHandler serves the temporary urls signed by the filesystem objectstore driver
+func downloadObjectTemporaryURLHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	f, name, err := objectstore.FetchFromTemporaryURL(r.URL.Query())
+	if err != nil {
+		return sdk.WrapError(err, "downloadObjectTemporaryURLHandler> Cannot fetch object")
+	}
+	defer f.Close()
+
+	w.Header().Add("Content-Type", "application/octet-stream")
+	w.Header().Add("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"", name))
+
+	if err := objectstore.StreamFile(w, f); err != nil {
+		return sdk.WrapError(err, "downloadObjectTemporaryURLHandler> Cannot stream object")
+	}
+	return nil
+}
+
 func listArtifactsBuildHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
 	vars := mux.Vars(r)
 	project := vars["key"]
@@ -14,6 +14,7 @@ import (
 	"github.com/ovh/cds/engine/api/cache"
 	"github.com/ovh/cds/engine/api/hatchery"
 	"github.com/ovh/cds/engine/api/sessionstore"
+	"github.com/ovh/cds/engine/api/token"
 	"github.com/ovh/cds/engine/api/user"
 	"github.com/ovh/cds/engine/api/worker"
 	"github.com/ovh/cds/sdk"
@@ -41,6 +42,8 @@ func GetDriver(c context.Context, mode string, options interface{}, storeOptions
 	switch mode {
 	case "ldap":
 		d = &LDAPClient{}
+	case "oidc":
+		d = &OIDCClient{}
 	default:
 		d = &LocalClient{}
 	}
@@ -162,6 +165,33 @@ func getUserPersistentSession(db gorp.SqlExecutor, store sessionstore.Store, hea
 	return true
 }
 
+//checkAccessToken authenticates the user with a personal access token. It returns false if there is no access
+//token in headers. The user never has the CDS administrator rights with an access token: the router restricts its
+//permissions to the scopes of the token
+func checkAccessToken(db gorp.SqlExecutor, headers http.Header, ctx *ctx.Ctx) (bool, error) {
+	h := headers.Get(sdk.AccessTokenHeader)
+	if h == "" {
+		return false, nil
+	}
+
+	t, err := token.LoadAccessToken(db, h)
+	if err != nil {
+		return true, err
+	}
+	u, err := user.LoadUserWithoutAuthByID(db, t.UserID)
+	if err != nil {
+		return true, fmt.Errorf("authorization failed for access token %d: %s", t.ID, err)
+	}
+	u.Admin = false
+	ctx.User = u
+	ctx.AccessToken = t
+
+	if err := token.UpdateAccessTokenLastUsed(db, t.ID); err != nil {
+		log.Warning("checkAccessToken> %s", err)
+	}
+	return true, nil
+}
+
 //GetWorker returns the worker instance from its id
 func GetWorker(db gorp.SqlExecutor, workerID string) (*sdk.Worker, error) {
 	// Load worker
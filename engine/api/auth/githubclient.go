@@ -30,5 +30,8 @@ func (c *GithubClient) AuthentifyUser(db gorp.SqlExecutor, u *sdk.User, password
 
 //CheckAuthHeader returns the func to heck http headers.
 func (c *GithubClient) CheckAuthHeader(db *gorp.DbMap, headers http.Header, ctx *businesscontext.Ctx) error {
+	if ok, err := checkAccessToken(db, headers, ctx); ok {
+		return err
+	}
 	return nil
 }
@@ -318,6 +318,10 @@ func (c *LDAPClient) AuthentifyUser(db gorp.SqlExecutor, u *sdk.User, password s
 
 //CheckAuthHeader returns the func to heck http headers.
 func (c *LDAPClient) CheckAuthHeader(db *gorp.DbMap, headers http.Header, ctx *businesscontext.Ctx) error {
+	if ok, err := checkAccessToken(db, headers, ctx); ok {
+		return err
+	}
+
 	//Check if its coming from CLI
 	if headers.Get(sdk.RequestedWithHeader) == sdk.RequestedWithValue {
 		if getUserPersistentSession(db, c.Store(), headers, ctx) {
@@ -49,6 +49,10 @@ func (c *LocalClient) AuthentifyUser(db gorp.SqlExecutor, u *sdk.User, password
 
 //CheckAuthHeader checks http headers.
 func (c *LocalClient) CheckAuthHeader(db *gorp.DbMap, headers http.Header, ctx *businesscontext.Ctx) error {
+	if ok, err := checkAccessToken(db, headers, ctx); ok {
+		return err
+	}
+
 	//Check if its coming from CLI
 	if headers.Get(sdk.RequestedWithHeader) == sdk.RequestedWithValue {
 		if getUserPersistentSession(db, c.Store(), headers, ctx) {
@@ -0,0 +1,540 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-gorp/gorp"
+
+	"github.com/ovh/cds/engine/api/businesscontext"
+	"github.com/ovh/cds/engine/api/cache"
+	"github.com/ovh/cds/engine/api/group"
+	"github.com/ovh/cds/engine/api/sessionstore"
+	"github.com/ovh/cds/engine/api/user"
+	"github.com/ovh/cds/sdk"
+	"github.com/ovh/cds/sdk/log"
+)
+
+// OIDCOrigin is the origin of the users provisioned by the OpenID Connect driver
+const OIDCOrigin = "oidc"
+
+//OIDCConfig handles all config to connect to the OpenID Connect provider
+type OIDCConfig struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is the UI page receiving the authorization code
+	RedirectURL string
+	Scopes      []string
+	// UsernameClaim is the claim of the ID token used as username, preferred_username by default
+	UsernameClaim string
+	// GroupsClaim is the claim of the ID token listing the groups of the user, groups by default
+	GroupsClaim string
+	// AdminGroup is the group of the ID token whose members are CDS administrators, if set
+	AdminGroup string
+}
+
+//OIDCClient is an OpenID Connect driver: users log in on the provider, and are provisioned with the claims of their ID token
+type OIDCClient struct {
+	store    sessionstore.Store
+	conf     OIDCConfig
+	local    *LocalClient
+	provider oidcProvider
+	client   *http.Client
+
+	keysMutex sync.Mutex
+	keys      map[string]*rsa.PublicKey
+}
+
+// oidcProvider is the discovery document of the provider
+type oidcProvider struct {
+	Issuer                      string `json:"issuer"`
+	AuthorizationEndpoint       string `json:"authorization_endpoint"`
+	TokenEndpoint               string `json:"token_endpoint"`
+	DeviceAuthorizationEndpoint string `json:"device_authorization_endpoint"`
+	JWKSURI                     string `json:"jwks_uri"`
+}
+
+// oidcTokenResponse is the response of the token endpoint
+type oidcTokenResponse struct {
+	IDToken          string `json:"id_token"`
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// OIDCClaims are the claims of an ID token
+type OIDCClaims map[string]interface{}
+
+// String returns a claim as a string, empty if it is not set
+func (c OIDCClaims) String(name string) string {
+	s, _ := c[name].(string)
+	return s
+}
+
+// Strings returns a claim as a list of strings
+func (c OIDCClaims) Strings(name string) []string {
+	switch v := c[name].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		res := make([]string, 0, len(v))
+		for _, i := range v {
+			if s, ok := i.(string); ok {
+				res = append(res, s)
+			}
+		}
+		return res
+	}
+	return nil
+}
+
+//Open discovers the endpoints of the OpenID Connect provider
+func (c *OIDCClient) Open(options interface{}, store sessionstore.Store) error {
+	log.Info("Auth> Connecting to session store")
+	c.store = store
+	//OIDC Client needs a local client to check local users
+	c.local = &LocalClient{}
+	c.local.Open(options, store)
+
+	conf, ok := options.(OIDCConfig)
+	if !ok {
+		return fmt.Errorf("invalid OpenID Connect configuration")
+	}
+	if conf.UsernameClaim == "" {
+		conf.UsernameClaim = "preferred_username"
+	}
+	if conf.GroupsClaim == "" {
+		conf.GroupsClaim = "groups"
+	}
+	if len(conf.Scopes) == 0 {
+		conf.Scopes = []string{"openid", "profile", "email", "groups"}
+	}
+	c.conf = conf
+	if c.client == nil {
+		c.client = &http.Client{Timeout: 10 * time.Second}
+	}
+
+	log.Info("Auth> Discovering OpenID Connect provider %s", conf.Issuer)
+	resp, err := c.client.Get(strings.TrimSuffix(conf.Issuer, "/") + "/.well-known/openid-configuration")
+	if err != nil {
+		return fmt.Errorf("unable to discover OpenID Connect provider %s: %s", conf.Issuer, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unable to discover OpenID Connect provider %s: %s", conf.Issuer, resp.Status)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&c.provider); err != nil {
+		return fmt.Errorf("invalid OpenID Connect discovery document: %s", err)
+	}
+	if c.provider.Issuer != conf.Issuer {
+		return fmt.Errorf("OpenID Connect provider issuer %s does not match %s", c.provider.Issuer, conf.Issuer)
+	}
+	return nil
+}
+
+//Store returns store
+func (c *OIDCClient) Store() sessionstore.Store {
+	return c.store
+}
+
+//Authentify check username and password of local users, OpenID Connect users log in on the provider
+func (c *OIDCClient) Authentify(db gorp.SqlExecutor, username, password string) (bool, error) {
+	return c.local.Authentify(db, username, password)
+}
+
+//AuthentifyUser check password in database
+func (c *OIDCClient) AuthentifyUser(db gorp.SqlExecutor, u *sdk.User, password string) (bool, error) {
+	return c.local.AuthentifyUser(db, u, password)
+}
+
+//CheckAuthHeader checks http headers: sessions are created by the login handlers
+func (c *OIDCClient) CheckAuthHeader(db *gorp.DbMap, headers http.Header, ctx *businesscontext.Ctx) error {
+	return c.local.CheckAuthHeader(db, headers, ctx)
+}
+
+// AuthCodeURL starts the authorization code flow. It returns the URL of the provider where the user logs in,
+// and the state to check on callback
+func (c *OIDCClient) AuthCodeURL() (string, string) {
+	state := oidcRandom()
+	nonce := oidcRandom()
+	cache.SetWithTTL(cache.Key("auth", "oidc", "state", state), nonce, 600)
+
+	v := url.Values{}
+	v.Set("response_type", "code")
+	v.Set("client_id", c.conf.ClientID)
+	v.Set("redirect_uri", c.conf.RedirectURL)
+	v.Set("scope", strings.Join(c.conf.Scopes, " "))
+	v.Set("state", state)
+	v.Set("nonce", nonce)
+
+	sep := "?"
+	if strings.Contains(c.provider.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return c.provider.AuthorizationEndpoint + sep + v.Encode(), state
+}
+
+func oidcRandom() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		log.Error("OIDC> Unable to generate random: %s", err)
+	}
+	return hex.EncodeToString(b)
+}
+
+// Exchange ends the authorization code flow: the code is exchanged against an ID token.
+// The UI must have checked that state is the one it received when the login started,
+// otherwise an attacker could log a victim in with the attacker's own code
+func (c *OIDCClient) Exchange(state, code string) (OIDCClaims, error) {
+	k := cache.Key("auth", "oidc", "state", state)
+	var nonce string
+	if !cache.Get(k, &nonce) || nonce == "" {
+		return nil, fmt.Errorf("unknown state")
+	}
+	cache.Delete(k)
+
+	v := url.Values{}
+	v.Set("grant_type", "authorization_code")
+	v.Set("code", code)
+	v.Set("redirect_uri", c.conf.RedirectURL)
+	tok, err := c.token(v)
+	if err != nil {
+		return nil, err
+	}
+	if tok.Error != "" {
+		return nil, fmt.Errorf("%s: %s", tok.Error, tok.ErrorDescription)
+	}
+	return c.VerifyIDToken(tok.IDToken, nonce)
+}
+
+// DeviceAuthorization starts the device flow: the user logs in on the verification URI with the user code
+func (c *OIDCClient) DeviceAuthorization() (*sdk.UserOIDCDeviceAuthorization, error) {
+	if c.provider.DeviceAuthorizationEndpoint == "" {
+		return nil, sdk.ErrNotImplemented
+	}
+
+	v := url.Values{}
+	v.Set("client_id", c.conf.ClientID)
+	v.Set("client_secret", c.conf.ClientSecret)
+	v.Set("scope", strings.Join(c.conf.Scopes, " "))
+	resp, err := c.client.PostForm(c.provider.DeviceAuthorizationEndpoint, v)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("device authorization failed: %s", resp.Status)
+	}
+
+	d := &sdk.UserOIDCDeviceAuthorization{}
+	if err := json.NewDecoder(resp.Body).Decode(d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// DeviceToken polls the token of a device flow. It returns sdk.ErrOIDCAuthorizationPending until the user logs in
+func (c *OIDCClient) DeviceToken(deviceCode string) (OIDCClaims, error) {
+	v := url.Values{}
+	v.Set("grant_type", "urn:ietf:params:oauth:grant-type:device_code")
+	v.Set("device_code", deviceCode)
+	tok, err := c.token(v)
+	if err != nil {
+		return nil, err
+	}
+	switch tok.Error {
+	case "":
+	case "authorization_pending", "slow_down":
+		return nil, sdk.ErrOIDCAuthorizationPending
+	default:
+		return nil, fmt.Errorf("%s: %s", tok.Error, tok.ErrorDescription)
+	}
+	return c.VerifyIDToken(tok.IDToken, "")
+}
+
+func (c *OIDCClient) token(v url.Values) (*oidcTokenResponse, error) {
+	v.Set("client_id", c.conf.ClientID)
+	v.Set("client_secret", c.conf.ClientSecret)
+	resp, err := c.client.PostForm(c.provider.TokenEndpoint, v)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	tok := &oidcTokenResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(tok); err != nil {
+		return nil, fmt.Errorf("invalid token response (%s): %s", resp.Status, err)
+	}
+	if tok.Error == "" && tok.IDToken == "" {
+		return nil, fmt.Errorf("no ID token in token response (%s)", resp.Status)
+	}
+	return tok, nil
+}
+
+// VerifyIDToken checks the signature (RS256), the issuer, the audience, the expiration and the nonce of an ID token
+func (c *OIDCClient) VerifyIDToken(raw, nonce string) (OIDCClaims, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("malformed ID token")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, err
+	}
+	if header.Alg != "RS256" {
+		return nil, fmt.Errorf("unsupported ID token algorithm %s", header.Alg)
+	}
+
+	key, err := c.key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("malformed ID token signature: %s", err)
+	}
+	h := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, h[:], sig); err != nil {
+		return nil, fmt.Errorf("invalid ID token signature")
+	}
+
+	claims := OIDCClaims{}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, err
+	}
+	if claims.String("iss") != c.provider.Issuer {
+		return nil, fmt.Errorf("invalid ID token issuer %s", claims.String("iss"))
+	}
+	var audOK bool
+	for _, aud := range claims.Strings("aud") {
+		if aud == c.conf.ClientID {
+			audOK = true
+		}
+	}
+	if !audOK {
+		return nil, fmt.Errorf("invalid ID token audience")
+	}
+	exp, _ := claims["exp"].(float64)
+	if time.Unix(int64(exp), 0).Before(time.Now()) {
+		return nil, fmt.Errorf("expired ID token")
+	}
+	if nonce != "" && claims.String("nonce") != nonce {
+		return nil, fmt.Errorf("invalid ID token nonce")
+	}
+	return claims, nil
+}
+
+func decodeJWTPart(s string, i interface{}) error {
+	btes, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return fmt.Errorf("malformed ID token: %s", err)
+	}
+	if err := json.Unmarshal(btes, i); err != nil {
+		return fmt.Errorf("malformed ID token: %s", err)
+	}
+	return nil
+}
+
+// key returns a signing key of the provider. The keys are fetched again for an unknown key ID, to follow key rotations
+func (c *OIDCClient) key(kid string) (*rsa.PublicKey, error) {
+	c.keysMutex.Lock()
+	defer c.keysMutex.Unlock()
+
+	if k, ok := c.keys[kid]; ok {
+		return k, nil
+	}
+
+	resp, err := c.client.Get(c.provider.JWKSURI)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get OpenID Connect keys: %s", err)
+	}
+	defer resp.Body.Close()
+
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return nil, fmt.Errorf("invalid OpenID Connect keys: %s", err)
+	}
+
+	c.keys = map[string]*rsa.PublicKey{}
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, errN := base64.RawURLEncoding.DecodeString(k.N)
+		e, errE := base64.RawURLEncoding.DecodeString(k.E)
+		if errN != nil || errE != nil {
+			log.Warning("OIDC> Invalid key %s", k.Kid)
+			continue
+		}
+		c.keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	k, ok := c.keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown ID token key %s", kid)
+	}
+	return k, nil
+}
+
+// ProvisionUser inserts or updates the user of an ID token, and sets its groups: the user is a member of
+// the CDS groups listed in the groups claim, and only of them. The user is identified by the issuer and the
+// subject of the ID token, the username claim is only used to name a new user
+func (c *OIDCClient) ProvisionUser(db gorp.SqlExecutor, claims OIDCClaims) (*sdk.User, error) {
+	issuer, subject := claims.String("iss"), claims.String("sub")
+	if issuer == "" || subject == "" {
+		return nil, fmt.Errorf("no iss or sub claim in ID token")
+	}
+
+	var u *sdk.User
+	var newUser, newSubject bool
+	id, err := user.LoadUserIDByOIDCSubject(db, issuer, subject)
+	switch {
+	case err == nil:
+		known, errLoad := user.LoadUserWithoutAuthByID(db, id)
+		if errLoad != nil {
+			return nil, sdk.WrapError(errLoad, "ProvisionUser> Unable to load user %d", id)
+		}
+		if u, err = user.LoadUserAndAuth(db, known.Username); err != nil {
+			return nil, sdk.WrapError(err, "ProvisionUser> Unable to load user %s", known.Username)
+		}
+	case err == sql.ErrNoRows:
+		if u, newUser, err = c.userToLink(db, claims); err != nil {
+			return nil, err
+		}
+		newSubject = true
+	default:
+		return nil, sdk.WrapError(err, "ProvisionUser> Unable to load user of subject %s", subject)
+	}
+
+	groups := claims.Strings(c.conf.GroupsClaim)
+	u.Fullname = claims.String("name")
+	u.Email = claims.String("email")
+	if c.conf.AdminGroup != "" {
+		u.Admin = sdk.IsInStringArray(c.conf.AdminGroup, groups)
+	}
+
+	if newUser {
+		a := &sdk.Auth{EmailVerified: claims["email_verified"] == true}
+		if err := user.InsertUser(db, u, a); err != nil {
+			return nil, sdk.WrapError(err, "ProvisionUser> Unable to insert user %s", u.Username)
+		}
+		u.Auth = *a
+	} else if err := user.UpdateUser(db, *u); err != nil {
+		return nil, sdk.WrapError(err, "ProvisionUser> Unable to update user %s", u.Username)
+	}
+
+	if newSubject {
+		if err := user.InsertOIDCSubject(db, u.ID, issuer, subject); err != nil {
+			return nil, sdk.WrapError(err, "ProvisionUser> Unable to link user %s to subject %s", u.Username, subject)
+		}
+	}
+
+	if err := c.syncGroups(db, u, groups); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+// userToLink returns the user to link to the subject of an ID token seen for the first time: a new user named
+// after the username claim, or an existing oidc user provisioned before the subjects were stored. The accounts
+// of the other origins and the accounts already linked to another subject are never taken over
+func (c *OIDCClient) userToLink(db gorp.SqlExecutor, claims OIDCClaims) (*sdk.User, bool, error) {
+	username := claims.String(c.conf.UsernameClaim)
+	if username == "" {
+		return nil, false, fmt.Errorf("no %s claim in ID token", c.conf.UsernameClaim)
+	}
+
+	u, err := user.LoadUserAndAuth(db, username)
+	if err == sql.ErrNoRows {
+		return &sdk.User{
+			Username: username,
+			Origin:   OIDCOrigin,
+		}, true, nil
+	} else if err != nil {
+		return nil, false, sdk.WrapError(err, "userToLink> Unable to load user %s", username)
+	}
+
+	if u.Origin != OIDCOrigin {
+		return nil, false, sdk.WrapError(sdk.ErrInvalidUser, "userToLink> User %s is a %s user", username, u.Origin)
+	}
+	linked, err := user.IsLinkedToOIDCSubject(db, u.ID)
+	if err != nil {
+		return nil, false, sdk.WrapError(err, "userToLink> Unable to load subject of user %s", username)
+	}
+	if linked {
+		return nil, false, sdk.WrapError(sdk.ErrInvalidUser, "userToLink> User %s belongs to another subject", username)
+	}
+	return u, false, nil
+}
+
+// syncGroups sets the groups of a user from the groups of its ID token. The groups missing in CDS are ignored,
+// the shared infrastructure group and the default group are managed by CDS
+func (c *OIDCClient) syncGroups(db gorp.SqlExecutor, u *sdk.User, names []string) error {
+	current, err := group.LoadGroupByUser(db, u.ID)
+	if err != nil {
+		return sdk.WrapError(err, "syncGroups> Unable to load groups of user %s", u.Username)
+	}
+
+	for _, g := range current {
+		if sdk.IsInStringArray(g.Name, names) || g.Name == group.SharedInfraGroupName || group.IsDefaultGroupID(g.ID) {
+			continue
+		}
+		if err := group.DeleteUserFromGroup(db, g.ID, u.ID); err == sdk.ErrNotEnoughAdmin {
+			log.Warning("syncGroups> User %s is the last administrator of group %s", u.Username, g.Name)
+		} else if err != nil {
+			return sdk.WrapError(err, "syncGroups> Unable to remove user %s from group %s", u.Username, g.Name)
+		}
+	}
+
+	for _, name := range names {
+		var member bool
+		for _, g := range current {
+			if g.Name == name {
+				member = true
+				break
+			}
+		}
+		if member {
+			continue
+		}
+
+		g, err := group.LoadGroup(db, name)
+		if err == sdk.ErrGroupNotFound {
+			continue
+		}
+		if err != nil {
+			return sdk.WrapError(err, "syncGroups> Unable to load group %s", name)
+		}
+		if err := group.InsertUserInGroup(db, g.ID, u.ID, false); err != nil {
+			return sdk.WrapError(err, "syncGroups> Unable to add user %s in group %s", u.Username, name)
+		}
+	}
+	return nil
+}
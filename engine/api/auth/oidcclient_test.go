@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ovh/cds/engine/api/cache"
+	"github.com/ovh/cds/engine/api/sessionstore"
+	"github.com/ovh/cds/sdk"
+)
+
+func newTestOIDCClient(t *testing.T) (*OIDCClient, *OIDCMockProvider) {
+	cache.Initialize("local", "", "", 60)
+
+	m, err := NewOIDCMockProvider("cds", "secret", OIDCClaims{
+		"preferred_username": "john.doe",
+		"name":               "John Doe",
+		"email":              "john.doe@example.com",
+		"groups":             []string{"dev", "ops"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	d, err := GetDriver(context.Background(), "oidc", OIDCConfig{
+		Issuer:       m.Issuer(),
+		ClientID:     "cds",
+		ClientSecret: "secret",
+		RedirectURL:  "http://localhost:4200/account/oidc",
+	}, sessionstore.Options{Mode: "local"})
+	if err != nil {
+		m.Close()
+		t.Fatal(err)
+	}
+	return d.(*OIDCClient), m
+}
+
+func TestOIDCAuthorizationCodeFlow(t *testing.T) {
+	c, m := newTestOIDCClient(t)
+	defer m.Close()
+
+	authURL, state := c.AuthCodeURL()
+	code, returnedState, err := m.Authorize(authURL)
+	assert.NoError(t, err)
+	assert.Equal(t, state, returnedState)
+
+	claims, err := c.Exchange(state, code)
+	assert.NoError(t, err)
+	assert.Equal(t, "john.doe", claims.String("preferred_username"))
+	assert.Equal(t, []string{"dev", "ops"}, claims.Strings("groups"))
+
+	// A state can only be used once
+	_, err = c.Exchange(state, code)
+	assert.Error(t, err)
+
+	_, err = c.Exchange("unknown", code)
+	assert.Error(t, err)
+}
+
+func TestOIDCDeviceFlow(t *testing.T) {
+	c, m := newTestOIDCClient(t)
+	defer m.Close()
+
+	device, err := c.DeviceAuthorization()
+	assert.NoError(t, err)
+	assert.NotEmpty(t, device.DeviceCode)
+	assert.NotEmpty(t, device.UserCode)
+
+	_, err = c.DeviceToken(device.DeviceCode)
+	assert.Equal(t, sdk.ErrOIDCAuthorizationPending, err)
+
+	assert.NoError(t, m.AuthorizeDevice(device.UserCode))
+	claims, err := c.DeviceToken(device.DeviceCode)
+	assert.NoError(t, err)
+	assert.Equal(t, "john.doe", claims.String("preferred_username"))
+
+	_, err = c.DeviceToken(device.DeviceCode)
+	assert.Error(t, err)
+}
+
+func TestOIDCVerifyIDToken(t *testing.T) {
+	c, m := newTestOIDCClient(t)
+	defer m.Close()
+
+	var claims = func(k string, v interface{}) OIDCClaims {
+		res := OIDCClaims{
+			"iss":   m.Issuer(),
+			"aud":   []string{"other", "cds"},
+			"exp":   time.Now().Add(time.Minute).Unix(),
+			"nonce": "nonce",
+		}
+		res[k] = v
+		return res
+	}
+
+	_, err := c.VerifyIDToken(m.IDToken(claims("sub", "john.doe")), "nonce")
+	assert.NoError(t, err)
+
+	_, err = c.VerifyIDToken(m.IDToken(claims("iss", "https://other")), "nonce")
+	assert.Error(t, err, "issuer")
+	_, err = c.VerifyIDToken(m.IDToken(claims("aud", "other")), "nonce")
+	assert.Error(t, err, "audience")
+	_, err = c.VerifyIDToken(m.IDToken(claims("exp", time.Now().Add(-time.Minute).Unix())), "nonce")
+	assert.Error(t, err, "expiration")
+	_, err = c.VerifyIDToken(m.IDToken(claims("nonce", "other")), "nonce")
+	assert.Error(t, err, "nonce")
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	assert.NoError(t, err)
+	_, err = c.VerifyIDToken(m.sign(key, claims("sub", "john.doe")), "nonce")
+	assert.Error(t, err, "signature")
+
+	_, err = c.VerifyIDToken("not.a.token", "")
+	assert.Error(t, err)
+}
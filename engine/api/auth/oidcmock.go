@@ -0,0 +1,235 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// OIDCMockProvider is a local OpenID Connect provider, for tests and local development.
+// Every user logging in gets an ID token with Claims
+type OIDCMockProvider struct {
+	Server       *httptest.Server
+	ClientID     string
+	ClientSecret string
+	Claims       OIDCClaims
+
+	key     *rsa.PrivateKey
+	mutex   sync.Mutex
+	codes   map[string]string
+	devices map[string]*oidcMockDevice
+}
+
+type oidcMockDevice struct {
+	deviceCode string
+	authorized bool
+}
+
+// NewOIDCMockProvider starts a local OpenID Connect provider
+func NewOIDCMockProvider(clientID, clientSecret string, claims OIDCClaims) (*OIDCMockProvider, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	m := &OIDCMockProvider{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Claims:       claims,
+		key:          key,
+		codes:        map[string]string{},
+		devices:      map[string]*oidcMockDevice{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", m.discoveryHandler)
+	mux.HandleFunc("/keys", m.keysHandler)
+	mux.HandleFunc("/authorize", m.authorizeHandler)
+	mux.HandleFunc("/device", m.deviceHandler)
+	mux.HandleFunc("/token", m.tokenHandler)
+	m.Server = httptest.NewServer(mux)
+	return m, nil
+}
+
+// Close stops the provider
+func (m *OIDCMockProvider) Close() {
+	m.Server.Close()
+}
+
+// Issuer returns the issuer URL of the provider
+func (m *OIDCMockProvider) Issuer() string {
+	return m.Server.URL
+}
+
+// Authorize logs the user in from an authorization URL, as a browser would do, and returns the code and the state
+// sent to the redirect URL
+func (m *OIDCMockProvider) Authorize(authURL string) (string, string, error) {
+	u, err := url.Parse(authURL)
+	if err != nil {
+		return "", "", err
+	}
+	q := u.Query()
+	if q.Get("client_id") != m.ClientID {
+		return "", "", fmt.Errorf("unknown client %s", q.Get("client_id"))
+	}
+
+	code := oidcRandom()
+	m.mutex.Lock()
+	m.codes[code] = q.Get("nonce")
+	m.mutex.Unlock()
+	return code, q.Get("state"), nil
+}
+
+// AuthorizeDevice logs the user in with the user code of a device flow
+func (m *OIDCMockProvider) AuthorizeDevice(userCode string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	d, ok := m.devices[userCode]
+	if !ok {
+		return fmt.Errorf("unknown user code %s", userCode)
+	}
+	d.authorized = true
+	return nil
+}
+
+// IDToken returns an ID token for claims, signed with the key of the provider
+func (m *OIDCMockProvider) IDToken(claims OIDCClaims) string {
+	return m.sign(m.key, claims)
+}
+
+func (m *OIDCMockProvider) sign(key *rsa.PrivateKey, claims OIDCClaims) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "mock", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	s := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	h := sha256.Sum256([]byte(s))
+	sig, _ := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, h[:])
+	return s + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func (m *OIDCMockProvider) idToken(nonce string) string {
+	claims := OIDCClaims{
+		"iss": m.Issuer(),
+		"aud": m.ClientID,
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+	if nonce != "" {
+		claims["nonce"] = nonce
+	}
+	for k, v := range m.Claims {
+		claims[k] = v
+	}
+	return m.IDToken(claims)
+}
+
+func (m *OIDCMockProvider) discoveryHandler(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(oidcProvider{
+		Issuer:                      m.Issuer(),
+		AuthorizationEndpoint:       m.Issuer() + "/authorize",
+		TokenEndpoint:               m.Issuer() + "/token",
+		DeviceAuthorizationEndpoint: m.Issuer() + "/device",
+		JWKSURI:                     m.Issuer() + "/keys",
+	})
+}
+
+func (m *OIDCMockProvider) keysHandler(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "mock",
+			"alg": "RS256",
+			"n":   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+		}},
+	})
+}
+
+// authorizeHandler logs the user in without asking anything, and redirects to the client
+func (m *OIDCMockProvider) authorizeHandler(w http.ResponseWriter, r *http.Request) {
+	code, state, err := m.Authorize(r.URL.String())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	redirect, err := url.Parse(r.URL.Query().Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	q := redirect.Query()
+	q.Set("code", code)
+	q.Set("state", state)
+	redirect.RawQuery = q.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (m *OIDCMockProvider) deviceHandler(w http.ResponseWriter, r *http.Request) {
+	if r.PostFormValue("client_id") != m.ClientID || r.PostFormValue("client_secret") != m.ClientSecret {
+		http.Error(w, "invalid client", http.StatusUnauthorized)
+		return
+	}
+	d := &oidcMockDevice{deviceCode: oidcRandom()}
+	userCode := oidcRandom()[:8]
+	m.mutex.Lock()
+	m.devices[userCode] = d
+	m.mutex.Unlock()
+
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"device_code":      d.deviceCode,
+		"user_code":        userCode,
+		"verification_uri": m.Issuer() + "/device/verify",
+		"expires_in":       600,
+		"interval":         1,
+	})
+}
+
+func (m *OIDCMockProvider) tokenHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if r.PostFormValue("client_id") != m.ClientID || r.PostFormValue("client_secret") != m.ClientSecret {
+		w.WriteHeader(http.StatusUnauthorized)
+		json.NewEncoder(w).Encode(oidcTokenResponse{Error: "invalid_client"})
+		return
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	switch r.PostFormValue("grant_type") {
+	case "authorization_code":
+		nonce, ok := m.codes[r.PostFormValue("code")]
+		if !ok {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(oidcTokenResponse{Error: "invalid_grant"})
+			return
+		}
+		delete(m.codes, r.PostFormValue("code"))
+		json.NewEncoder(w).Encode(oidcTokenResponse{IDToken: m.idToken(nonce)})
+	case "urn:ietf:params:oauth:grant-type:device_code":
+		for userCode, d := range m.devices {
+			if d.deviceCode != r.PostFormValue("device_code") {
+				continue
+			}
+			if !d.authorized {
+				w.WriteHeader(http.StatusBadRequest)
+				json.NewEncoder(w).Encode(oidcTokenResponse{Error: "authorization_pending"})
+				return
+			}
+			delete(m.devices, userCode)
+			json.NewEncoder(w).Encode(oidcTokenResponse{IDToken: m.idToken("")})
+			return
+		}
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(oidcTokenResponse{Error: "expired_token"})
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(oidcTokenResponse{Error: "unsupported_grant_type"})
+	}
+}
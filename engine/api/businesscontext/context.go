@@ -10,4 +10,6 @@ type Ctx struct {
 	User     *sdk.User
 	Worker   *sdk.Worker
 	Hatchery *sdk.Hatchery
+	// AccessToken is set when the user is authenticated with a personal access token
+	AccessToken *sdk.AccessToken
 }
@@ -330,7 +330,7 @@ func UpdateLastModified(db gorp.SqlExecutor, u *sdk.User, env *sdk.Environment)
 }
 
 func loadGroupByEnvironment(db gorp.SqlExecutor, environment *sdk.Environment) error {
-	query := `SELECT "group".id, "group".name, environment_group.role FROM "group"
+	query := `SELECT "group".id, "group".name, environment_group.role, environment_group.role_name FROM "group"
 	 		  JOIN environment_group ON environment_group.group_id = "group".id
 	 		  WHERE environment_group.environment_id = $1 ORDER BY "group".name ASC`
 
@@ -343,13 +343,15 @@ func loadGroupByEnvironment(db gorp.SqlExecutor, environment *sdk.Environment) e
 	for rows.Next() {
 		var group sdk.Group
 		var perm int
-		err = rows.Scan(&group.ID, &group.Name, &perm)
+		var role sql.NullString
+		err = rows.Scan(&group.ID, &group.Name, &perm, &role)
 		if err != nil {
 			return err
 		}
 		environment.EnvironmentGroups = append(environment.EnvironmentGroups, sdk.GroupPermission{
 			Group:      group,
 			Permission: perm,
+			Role:       role.String,
 		})
 	}
 	return nil
@@ -360,7 +362,8 @@ func LoadEnvironmentByGroup(db gorp.SqlExecutor, group *sdk.Group) error {
 	query := `SELECT project.projectKey,
 			 environment.id,
 	                 environment.name,
-	                 environment_group.role
+	                 environment_group.role,
+	                 environment_group.role_name
 	          FROM environment
 	          JOIN environment_group ON environment_group.environment_id = environment.id
 	 	  JOIN project ON environment.project_id = project.id
@@ -375,13 +378,15 @@ func LoadEnvironmentByGroup(db gorp.SqlExecutor, group *sdk.Group) error {
 	for rows.Next() {
 		var environment sdk.Environment
 		var perm int
-		err = rows.Scan(&environment.ProjectKey, &environment.ID, &environment.Name, &perm)
+		var role sql.NullString
+		err = rows.Scan(&environment.ProjectKey, &environment.ID, &environment.Name, &perm, &role)
 		if err != nil {
 			return err
 		}
 		group.EnvironmentGroups = append(group.EnvironmentGroups, sdk.EnvironmentGroup{
 			Environment: environment,
 			Permission:  perm,
+			Role:        role.String,
 		})
 	}
 	return nil
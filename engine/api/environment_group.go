@@ -26,6 +26,9 @@ func updateGroupRoleOnEnvironmentHandler(w http.ResponseWriter, r *http.Request,
 	if err := UnmarshalBody(r, &groupEnvironment); err != nil {
 		return sdk.WrapError(err, "updateGroupRoleOnEnvironmentHandler> Cannot read body")
 	}
+	if err := groupEnvironment.ApplyRole(); err != nil {
+		return sdk.WrapError(err, "updateGroupRoleOnEnvironmentHandler> invalid role %s", groupEnvironment.Role)
+	}
 
 	g, errG := group.LoadGroup(db, groupName)
 	if errG != nil {
@@ -63,6 +66,9 @@ func updateGroupRoleOnEnvironmentHandler(w http.ResponseWriter, r *http.Request,
 	if err := group.UpdateGroupRoleInEnvironment(tx, key, envName, groupName, groupEnvironment.Permission); err != nil {
 		return sdk.WrapError(err, "updateGroupRoleOnEnvironmentHandler: Cannot update permission for group %s in environment %s", groupName, envName)
 	}
+	if err := group.UpdateGroupRoleNameInEnvironment(tx, env.ID, g.ID, groupEnvironment.Role); err != nil {
+		return sdk.WrapError(err, "updateGroupRoleOnEnvironmentHandler: Cannot set role of group %s in environment %s", groupName, envName)
+	}
 
 	if err := environment.UpdateLastModified(tx, c.User, env); err != nil {
 		return sdk.WrapError(err, "updateGroupRoleOnEnvironmentHandler: Cannot update environment last modified date")
@@ -107,6 +113,9 @@ func addGroupsInEnvironmentHandler(w http.ResponseWriter, r *http.Request, db *g
 	defer tx.Rollback()
 
 	for _, gp := range groupPermission {
+		if err := gp.ApplyRole(); err != nil {
+			return sdk.WrapError(err, "addGroupsInEnvironmentHandler> invalid role %s", gp.Role)
+		}
 		g, errL := group.LoadGroup(tx, gp.Group.Name)
 		if errL != nil {
 			return sdk.WrapError(errL, "addGroupsInEnvironmentHandler: Cannot find group %s", gp.Group.Name)
@@ -124,6 +133,9 @@ func addGroupsInEnvironmentHandler(w http.ResponseWriter, r *http.Request, db *g
 		if err := group.InsertGroupInEnvironment(tx, env.ID, g.ID, gp.Permission); err != nil {
 			return sdk.WrapError(err, "addGroupsInEnvironmentHandler: Cannot add group %s in environment %s", g.Name, env.Name)
 		}
+		if err := group.UpdateGroupRoleNameInEnvironment(tx, env.ID, g.ID, gp.Role); err != nil {
+			return sdk.WrapError(err, "addGroupsInEnvironmentHandler: Cannot set role of group %s in environment %s", g.Name, env.Name)
+		}
 	}
 
 	// Update last modified on environment
@@ -166,6 +178,9 @@ func addGroupInEnvironmentHandler(w http.ResponseWriter, r *http.Request, db *go
 	if err := UnmarshalBody(r, &groupPermission); err != nil {
 		return err
 	}
+	if err := groupPermission.ApplyRole(); err != nil {
+		return sdk.WrapError(err, "addGroupInEnvironmentHandler> invalid role %s", groupPermission.Role)
+	}
 
 	env, err := environment.LoadEnvironmentByName(db, key, envName)
 	if err != nil {
@@ -194,6 +209,9 @@ func addGroupInEnvironmentHandler(w http.ResponseWriter, r *http.Request, db *go
 		log.Warning("addGroupInEnvironmentHandler: Cannot add group %s in environment %s:  %s\n", g.Name, env.Name, err)
 		return err
 	}
+	if err := group.UpdateGroupRoleNameInEnvironment(db, env.ID, g.ID, groupPermission.Role); err != nil {
+		return sdk.WrapError(err, "addGroupInEnvironmentHandler> Cannot set role of group %s in environment %s", g.Name, env.Name)
+	}
 
 	return nil
 }
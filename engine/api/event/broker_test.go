@@ -0,0 +1,421 @@
+package event
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ovh/cds/sdk"
+)
+
+func TestBrokerEntryAccept(t *testing.T) {
+	all := newBrokerEntry("all", nil, 0, nil)
+	assert.True(t, all.accept(&sdk.Event{EventType: "sdk.EventJob"}))
+
+	filtered := newBrokerEntry("filtered", nil, 0, []string{"sdk.EventPipelineBuild"})
+	assert.True(t, filtered.accept(&sdk.Event{EventType: "sdk.EventPipelineBuild"}))
+	assert.False(t, filtered.accept(&sdk.Event{EventType: "sdk.EventJob"}))
+}
+
+func TestInitializeSkipsFailingBrokers(t *testing.T) {
+	defer func() { brokers = nil }()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	unreachable := l.Addr().String()
+	l.Close()
+
+	Initialize(KafkaConfig{}, BrokerConfig{Name: "nats", Type: "nats", URL: "nats://" + unreachable, Topic: "cds.events"},
+		BrokerConfig{Name: "hook", Type: "webhook", URL: "http://localhost/events"})
+	assert.Len(t, brokers, 1)
+	assert.Equal(t, "hook", brokers[0].name)
+}
+
+// fakeBroker fails until nbFailures events have been sent
+type fakeBroker struct {
+	nbFailures int
+	err        error
+	sent       []sdk.Event
+}
+
+func (f *fakeBroker) initialize(options interface{}) (Broker, error) { return f, nil }
+func (f *fakeBroker) status() string                                 { return "Fake OK" }
+func (f *fakeBroker) close()                                         {}
+func (f *fakeBroker) sendEvent(e *sdk.Event) error {
+	if f.nbFailures > 0 {
+		f.nbFailures--
+		return f.err
+	}
+	f.sent = append(f.sent, *e)
+	return nil
+}
+
+func TestBrokerEntryDeliver(t *testing.T) {
+	retryBackoff = time.Millisecond
+
+	f := &fakeBroker{nbFailures: 2, err: fmt.Errorf("unreachable")}
+	b := newBrokerEntry("fake", f, 3, nil)
+	b.deliver(context.Background(), &sdk.Event{EventType: "sdk.EventEngine", Timestamp: time.Now().Add(-time.Second)})
+	assert.Len(t, f.sent, 1)
+	assert.Equal(t, 3, f.sent[0].Attempts)
+
+	m := b.metrics()
+	assert.Equal(t, int64(1), m.Sent)
+	assert.Equal(t, int64(2), m.Retried)
+	assert.Equal(t, int64(0), m.DeadLettered)
+	assert.True(t, m.LastLag >= 1)
+
+	// All retries fail
+	f = &fakeBroker{nbFailures: 10, err: fmt.Errorf("unreachable")}
+	b = newBrokerEntry("fake", f, 3, nil)
+	b.deliver(context.Background(), &sdk.Event{EventType: "sdk.EventEngine"})
+	assert.Empty(t, f.sent)
+	assert.Equal(t, 6, f.nbFailures)
+	assert.Equal(t, int64(1), b.metrics().DeadLettered)
+
+	// Permanent errors are not retried
+	f = &fakeBroker{nbFailures: 10, err: permanentError{fmt.Errorf("bad request")}}
+	b = newBrokerEntry("fake", f, 3, nil)
+	b.deliver(context.Background(), &sdk.Event{EventType: "sdk.EventEngine"})
+	assert.Equal(t, 9, f.nbFailures)
+	assert.Equal(t, int64(0), b.metrics().Retried)
+	assert.Equal(t, int64(1), b.metrics().DeadLettered)
+}
+
+func TestBrokerEntryRunDrain(t *testing.T) {
+	f := &fakeBroker{}
+	b := newBrokerEntry("fake", f, 3, nil)
+	b.enqueue(sdk.Event{EventType: "sdk.EventEngine"})
+	b.enqueue(sdk.Event{EventType: "sdk.EventJob"})
+
+	c, cancel := context.WithCancel(context.Background())
+	cancel()
+	b.run(c)
+
+	assert.Empty(t, f.sent)
+	assert.Equal(t, 0, b.metrics().QueueLength)
+	assert.Equal(t, int64(2), b.metrics().DeadLettered)
+}
+
+func TestWebhookSendEvent(t *testing.T) {
+	var received []byte
+	var signature, eventType string
+	status := http.StatusServiceUnavailable
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received, _ = ioutil.ReadAll(r.Body)
+		signature = r.Header.Get(webhookSignHeader)
+		eventType = r.Header.Get(webhookEventHeader)
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+
+	b, err := getBroker("webhook", WebhookConfig{URL: srv.URL, Secret: "s3cr3t"})
+	assert.NoError(t, err)
+
+	e := &sdk.Event{EventType: "sdk.EventEngine", Payload: map[string]interface{}{"Message": "hello"}}
+	err = b.sendEvent(e)
+	assert.Error(t, err)
+	_, permanent := err.(permanentError)
+	assert.False(t, permanent, "server errors should be retried")
+
+	status = http.StatusOK
+	assert.NoError(t, b.sendEvent(e))
+	assert.Equal(t, "sdk.EventEngine", eventType)
+	assert.Equal(t, "sha256="+webhookSignature("s3cr3t", received), signature)
+
+	var got sdk.Event
+	assert.NoError(t, json.Unmarshal(received, &got))
+	assert.Equal(t, "hello", got.Payload["Message"])
+
+	status = http.StatusBadRequest
+	err = b.sendEvent(e)
+	_, permanent = err.(permanentError)
+	assert.True(t, permanent, "client errors should not be retried")
+}
+
+// fakeNATS is a minimal NATS server storing the published messages
+type fakeNATS struct {
+	listener net.Listener
+	messages chan string
+	tls      *tls.Config
+}
+
+func newFakeNATS(t *testing.T, tlsConfig *tls.Config) *fakeNATS {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Cannot listen: %s", err)
+	}
+	f := &fakeNATS{listener: l, messages: make(chan string, 10), tls: tlsConfig}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeNATS) serve(conn net.Conn) {
+	defer conn.Close()
+	if f.tls == nil {
+		fmt.Fprint(conn, "INFO {\"server_id\":\"fake\",\"max_payload\":1048576}\r\n")
+	} else {
+		// The INFO is sent in plain text, then the client upgrades the connection
+		fmt.Fprint(conn, "INFO {\"server_id\":\"fake\",\"max_payload\":1048576,\"tls_required\":true}\r\n")
+		tlsConn := tls.Server(conn, f.tls)
+		if err := tlsConn.Handshake(); err != nil {
+			return
+		}
+		conn = tlsConn
+	}
+	r := bufio.NewReader(conn)
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(line, "CONNECT"):
+			if !strings.Contains(line, `"user":"cds"`) {
+				fmt.Fprint(conn, "-ERR 'Authorization Violation'\r\n")
+				return
+			}
+		case line == "PING":
+			fmt.Fprint(conn, "PONG\r\n")
+		case strings.HasPrefix(line, "PUB"):
+			args := strings.Fields(line)
+			n, _ := strconv.Atoi(args[2])
+			payload := make([]byte, n+2)
+			if _, err := io.ReadFull(r, payload); err != nil {
+				return
+			}
+			f.messages <- args[1] + " " + string(payload[:n])
+		}
+	}
+}
+
+func TestNATSSendEvent(t *testing.T) {
+	f := newFakeNATS(t, nil)
+	defer f.listener.Close()
+
+	b, err := getBroker("nats", NATSConfig{URL: "nats://" + f.listener.Addr().String(), Subject: "cds.events", User: "cds", Password: "cds"})
+	assert.NoError(t, err)
+	defer b.close()
+	assert.Equal(t, "NATS OK", b.status())
+
+	assert.NoError(t, b.sendEvent(&sdk.Event{EventType: "sdk.EventEngine"}))
+	msg := <-f.messages
+	assert.True(t, strings.HasPrefix(msg, "cds.events {"))
+	assert.Contains(t, msg, `"type_event":"sdk.EventEngine"`)
+
+	_, err = getBroker("nats", NATSConfig{URL: "nats://" + f.listener.Addr().String(), Subject: "cds.events", User: "unknown"})
+	assert.Error(t, err)
+}
+
+func TestNATSSendEventTLS(t *testing.T) {
+	// Reuse the certificate of a TLS test server
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	f := newFakeNATS(t, &tls.Config{Certificates: srv.TLS.Certificates})
+	defer f.listener.Close()
+
+	clientConfig := srv.Client().Transport.(*http.Transport).TLSClientConfig
+	b, err := getBroker("nats", NATSConfig{URL: "tls://" + f.listener.Addr().String(), Subject: "cds.events", User: "cds", Password: "cds", tlsConfig: clientConfig})
+	assert.NoError(t, err)
+	defer b.close()
+	assert.Equal(t, "NATS OK", b.status())
+
+	assert.NoError(t, b.sendEvent(&sdk.Event{EventType: "sdk.EventEngine"}))
+	msg := <-f.messages
+	assert.True(t, strings.HasPrefix(msg, "cds.events {"))
+}
+
+// fakeAMQP is a minimal AMQP 0-9-1 server handling the publisher handshake and acking published messages
+type fakeAMQP struct {
+	listener net.Listener
+	messages chan []byte
+}
+
+func newFakeAMQP(t *testing.T) *fakeAMQP {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Cannot listen: %s", err)
+	}
+	f := &fakeAMQP{listener: l, messages: make(chan []byte, 10)}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeAMQP) serve(conn net.Conn) {
+	defer conn.Close()
+	c := &fakeAMQPConn{conn: conn, reader: bufio.NewReader(conn)}
+
+	header := make([]byte, 8)
+	if _, err := io.ReadFull(c.reader, header); err != nil || string(header) != "AMQP\x00\x00\x09\x01" {
+		return
+	}
+
+	// connection.start: version, empty server properties, mechanisms and locales
+	start := new(bytes.Buffer)
+	start.Write([]byte{0, 9})
+	binary.Write(start, binary.BigEndian, uint32(0))
+	fakeAMQPWriteLongstr(start, "PLAIN")
+	fakeAMQPWriteLongstr(start, "en_US")
+	c.writeMethod(0, 10, 10, start.Bytes())
+	if _, err := c.expect(10, 11); err != nil {
+		return
+	}
+
+	// connection.tune: channel max, frame max and heartbeat
+	tune := new(bytes.Buffer)
+	binary.Write(tune, binary.BigEndian, uint16(2047))
+	binary.Write(tune, binary.BigEndian, uint32(4096))
+	binary.Write(tune, binary.BigEndian, uint16(60))
+	c.writeMethod(0, 10, 30, tune.Bytes())
+	if _, err := c.expect(10, 31); err != nil {
+		return
+	}
+	if _, err := c.expect(10, 40); err != nil {
+		return
+	}
+	c.writeMethod(0, 10, 41, []byte{0})
+
+	// channel.open and confirm.select
+	if _, err := c.expect(20, 10); err != nil {
+		return
+	}
+	c.writeMethod(1, 20, 11, []byte{0, 0, 0, 0})
+	if _, err := c.expect(85, 10); err != nil {
+		return
+	}
+	c.writeMethod(1, 85, 11, nil)
+
+	var tag uint64
+	for {
+		// basic.publish, then a content header and the body frames
+		if _, err := c.expect(60, 40); err != nil {
+			return
+		}
+		_, h, err := c.readFrame()
+		if err != nil {
+			return
+		}
+		size := binary.BigEndian.Uint64(h[4:12])
+		body := []byte{}
+		for uint64(len(body)) < size {
+			_, b, err := c.readFrame()
+			if err != nil {
+				return
+			}
+			body = append(body, b...)
+		}
+		f.messages <- body
+
+		// basic.ack
+		tag++
+		ack := new(bytes.Buffer)
+		binary.Write(ack, binary.BigEndian, tag)
+		ack.WriteByte(0)
+		c.writeMethod(1, 60, 80, ack.Bytes())
+	}
+}
+
+// fakeAMQPConn reads and writes the AMQP frames of the fake server
+type fakeAMQPConn struct {
+	conn   net.Conn
+	reader *bufio.Reader
+}
+
+// readFrame returns the type and the payload of the next frame, heartbeats are skipped
+func (c *fakeAMQPConn) readFrame() (byte, []byte, error) {
+	for {
+		head := make([]byte, 7)
+		if _, err := io.ReadFull(c.reader, head); err != nil {
+			return 0, nil, err
+		}
+		payload := make([]byte, binary.BigEndian.Uint32(head[3:7])+1)
+		if _, err := io.ReadFull(c.reader, payload); err != nil {
+			return 0, nil, err
+		}
+		if head[0] != 8 {
+			return head[0], payload[:len(payload)-1], nil
+		}
+	}
+}
+
+// expect reads the next frame and checks it is the expected method
+func (c *fakeAMQPConn) expect(class, method uint16) ([]byte, error) {
+	t, payload, err := c.readFrame()
+	if err != nil {
+		return nil, err
+	}
+	if t != 1 || len(payload) < 4 || binary.BigEndian.Uint16(payload[0:2]) != class || binary.BigEndian.Uint16(payload[2:4]) != method {
+		return nil, fmt.Errorf("unexpected frame, expecting method %d.%d", class, method)
+	}
+	return payload[4:], nil
+}
+
+func (c *fakeAMQPConn) writeMethod(channel, class, method uint16, args []byte) error {
+	frame := new(bytes.Buffer)
+	frame.WriteByte(1)
+	binary.Write(frame, binary.BigEndian, channel)
+	binary.Write(frame, binary.BigEndian, uint32(4+len(args)))
+	binary.Write(frame, binary.BigEndian, class)
+	binary.Write(frame, binary.BigEndian, method)
+	frame.Write(args)
+	frame.WriteByte(0xCE)
+	_, err := c.conn.Write(frame.Bytes())
+	return err
+}
+
+func fakeAMQPWriteLongstr(b *bytes.Buffer, s string) {
+	binary.Write(b, binary.BigEndian, uint32(len(s)))
+	b.WriteString(s)
+}
+
+func TestAMQPSendEvent(t *testing.T) {
+	f := newFakeAMQP(t)
+	defer f.listener.Close()
+
+	b, err := getBroker("amqp", AMQPConfig{URL: "amqp://guest:guest@" + f.listener.Addr().String() + "/", Exchange: "cds", RoutingKey: "events"})
+	assert.NoError(t, err)
+	defer b.close()
+	assert.Equal(t, "AMQP OK", b.status())
+
+	// The payload is bigger than the frame max, it is sent in several body frames
+	e := &sdk.Event{EventType: "sdk.EventEngine", Payload: map[string]interface{}{"Message": strings.Repeat("a", 10000)}}
+	assert.NoError(t, b.sendEvent(e))
+
+	var got sdk.Event
+	assert.NoError(t, json.Unmarshal(<-f.messages, &got))
+	assert.Equal(t, "sdk.EventEngine", got.EventType)
+	assert.Len(t, got.Payload["Message"], 10000)
+}
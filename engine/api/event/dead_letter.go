@@ -0,0 +1,95 @@
+package event
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/go-gorp/gorp"
+
+	"github.com/ovh/cds/sdk"
+)
+
+// insertDeadLetter stores an event which has not been delivered. Only the newest maxDeadLetters events are kept
+func insertDeadLetter(db gorp.SqlExecutor, brokerName string, e *sdk.Event, errSend error, maxDeadLetters int) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return sdk.WrapError(err, "insertDeadLetter> Unable to marshal event")
+	}
+
+	errMsg := ""
+	if errSend != nil {
+		errMsg = errSend.Error()
+	}
+
+	query := "INSERT INTO event_dead_letter (broker, event, error, attempts) VALUES ($1, $2, $3, $4)"
+	if _, err := db.Exec(query, brokerName, data, errMsg, e.Attempts); err != nil {
+		return sdk.WrapError(err, "insertDeadLetter> Unable to insert dead letter")
+	}
+
+	if maxDeadLetters > 0 {
+		query := "DELETE FROM event_dead_letter WHERE id NOT IN (SELECT id FROM event_dead_letter ORDER BY id DESC LIMIT $1)"
+		if _, err := db.Exec(query, maxDeadLetters); err != nil {
+			return sdk.WrapError(err, "insertDeadLetter> Unable to delete oldest dead letters")
+		}
+	}
+	return nil
+}
+
+// LoadDeadLetters loads the newest dead lettered events
+func LoadDeadLetters(db gorp.SqlExecutor, limit int) ([]sdk.EventDeadLetter, error) {
+	query := "SELECT id, broker, event, error, attempts, created FROM event_dead_letter ORDER BY id DESC LIMIT $1"
+	rows, err := db.Query(query, limit)
+	if err != nil {
+		return nil, sdk.WrapError(err, "LoadDeadLetters> Unable to load dead letters")
+	}
+	defer rows.Close()
+
+	ds := []sdk.EventDeadLetter{}
+	for rows.Next() {
+		d, err := scanDeadLetter(rows)
+		if err != nil {
+			return nil, err
+		}
+		ds = append(ds, *d)
+	}
+	return ds, nil
+}
+
+// LoadDeadLetter loads a dead lettered event
+func LoadDeadLetter(db gorp.SqlExecutor, id int64) (*sdk.EventDeadLetter, error) {
+	query := "SELECT id, broker, event, error, attempts, created FROM event_dead_letter WHERE id = $1"
+	d, err := scanDeadLetter(db.QueryRow(query, id))
+	if err == sql.ErrNoRows {
+		return nil, sdk.ErrNotFound
+	}
+	return d, err
+}
+
+// DeleteDeadLetter deletes a dead lettered event
+func DeleteDeadLetter(db gorp.SqlExecutor, id int64) error {
+	if _, err := db.Exec("DELETE FROM event_dead_letter WHERE id = $1", id); err != nil {
+		return sdk.WrapError(err, "DeleteDeadLetter> Unable to delete dead letter %d", id)
+	}
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanDeadLetter(s scanner) (*sdk.EventDeadLetter, error) {
+	var d sdk.EventDeadLetter
+	var data []byte
+	var errMsg sql.NullString
+	if err := s.Scan(&d.ID, &d.Broker, &data, &errMsg, &d.Attempts, &d.Created); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, err
+		}
+		return nil, sdk.WrapError(err, "scanDeadLetter> Unable to scan dead letter")
+	}
+	d.Error = errMsg.String
+	if err := json.Unmarshal(data, &d.Event); err != nil {
+		return nil, sdk.WrapError(err, "scanDeadLetter> Unable to unmarshal event")
+	}
+	return &d, nil
+}
@@ -0,0 +1,235 @@
+package event
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-gorp/gorp"
+
+	"github.com/ovh/cds/sdk"
+	"github.com/ovh/cds/sdk/log"
+)
+
+const (
+	defaultMaxRetry = 3
+	brokerQueueSize = 1000
+	maxRetryBackoff = 1 * time.Minute
+
+	defaultDeadLettersMax = 10000
+)
+
+// retryBackoff is the delay before the first retry, doubled on each retry
+var retryBackoff = 1 * time.Second
+
+var (
+	dbFunc         func() *gorp.DbMap
+	deadLettersMax int
+)
+
+// permanentError is returned by brokers when retrying to send the event would not help
+type permanentError struct {
+	error
+}
+
+// brokerEntry is an initialized broker with its event types filter and its delivery queue
+type brokerEntry struct {
+	name       string
+	broker     Broker
+	eventTypes map[string]bool
+	maxRetry   int
+	queue      chan sdk.Event
+
+	mutex        sync.Mutex
+	sent         int64
+	retried      int64
+	deadLettered int64
+	lastLag      time.Duration
+	totalLag     time.Duration
+	maxLag       time.Duration
+}
+
+func newBrokerEntry(name string, b Broker, maxRetry int, eventTypes []string) *brokerEntry {
+	if maxRetry == 0 {
+		maxRetry = defaultMaxRetry
+	}
+	if maxRetry < 0 {
+		maxRetry = 0
+	}
+
+	entry := &brokerEntry{
+		name:       name,
+		broker:     b,
+		eventTypes: map[string]bool{},
+		maxRetry:   maxRetry,
+		queue:      make(chan sdk.Event, brokerQueueSize),
+	}
+	for _, t := range eventTypes {
+		entry.eventTypes[t] = true
+	}
+	return entry
+}
+
+// accept returns true if the event has to be sent on the broker
+func (b *brokerEntry) accept(e *sdk.Event) bool {
+	if len(b.eventTypes) == 0 {
+		return true
+	}
+	return b.eventTypes[e.EventType]
+}
+
+// enqueue adds the event in the delivery queue of the broker. If the queue is full, the event is stored as dead letter
+func (b *brokerEntry) enqueue(e sdk.Event) {
+	select {
+	case b.queue <- e:
+	default:
+		b.storeDeadLetter(&e, fmt.Errorf("delivery queue is full"))
+	}
+}
+
+// run delivers the events of the queue. When the context is done, the events left in the queue are stored as dead letters
+func (b *brokerEntry) run(c context.Context) {
+	for {
+		select {
+		case <-c.Done():
+			b.drain(c.Err())
+			return
+		case e := <-b.queue:
+			if c.Err() != nil {
+				b.storeDeadLetter(&e, c.Err())
+				continue
+			}
+			b.deliver(c, &e)
+		}
+	}
+}
+
+// drain stores the events of the queue as dead letters, so that they can be replayed
+func (b *brokerEntry) drain(err error) {
+	for {
+		select {
+		case e := <-b.queue:
+			b.storeDeadLetter(&e, err)
+		default:
+			return
+		}
+	}
+}
+
+// deliver sends the event, with retries and an exponential backoff. If all retries fail, the event is stored as dead letter
+func (b *brokerEntry) deliver(c context.Context, e *sdk.Event) {
+	backoff := retryBackoff
+	var err error
+	for i := 0; i <= b.maxRetry; i++ {
+		if i > 0 {
+			b.mutex.Lock()
+			b.retried++
+			b.mutex.Unlock()
+
+			select {
+			case <-c.Done():
+				b.storeDeadLetter(e, c.Err())
+				return
+			case <-time.After(backoff):
+			}
+			backoff *= 2
+			if backoff > maxRetryBackoff {
+				backoff = maxRetryBackoff
+			}
+		}
+
+		e.Attempts++
+		err = b.broker.sendEvent(e)
+		if err == nil {
+			b.delivered(e)
+			return
+		}
+		log.Warning("Error while sending message on broker %s (attempt %d): %s", b.name, e.Attempts, err)
+		if _, ok := err.(permanentError); ok {
+			break
+		}
+	}
+	b.storeDeadLetter(e, err)
+}
+
+// delivered updates the delivery metrics
+func (b *brokerEntry) delivered(e *sdk.Event) {
+	lag := time.Since(e.Timestamp)
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.sent++
+	b.lastLag = lag
+	b.totalLag += lag
+	if lag > b.maxLag {
+		b.maxLag = lag
+	}
+}
+
+func (b *brokerEntry) storeDeadLetter(e *sdk.Event, err error) {
+	b.mutex.Lock()
+	b.deadLettered++
+	b.mutex.Unlock()
+
+	if dbFunc == nil {
+		log.Error("Event %s lost on broker %s: %s", e.EventType, b.name, err)
+		return
+	}
+	if errI := insertDeadLetter(dbFunc(), b.name, e, err, deadLettersMax); errI != nil {
+		log.Error("Event %s lost on broker %s: %s (%s)", e.EventType, b.name, err, errI)
+	}
+}
+
+func (b *brokerEntry) metrics() sdk.EventBrokerMetrics {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	m := sdk.EventBrokerMetrics{
+		Broker:       b.name,
+		QueueLength:  len(b.queue),
+		Sent:         b.sent,
+		Retried:      b.retried,
+		DeadLettered: b.deadLettered,
+		LastLag:      b.lastLag.Seconds(),
+		MaxLag:       b.maxLag.Seconds(),
+	}
+	if b.sent > 0 {
+		m.AverageLag = (b.totalLag / time.Duration(b.sent)).Seconds()
+	}
+	return m
+}
+
+// Metrics returns the delivery metrics of all the brokers
+func Metrics() []sdk.EventBrokerMetrics {
+	ms := make([]sdk.EventBrokerMetrics, 0, len(brokers))
+	for _, b := range brokers {
+		ms = append(ms, b.metrics())
+	}
+	return ms
+}
+
+// ReplayDeadLetter sends again a dead lettered event on its broker, and removes it from the dead letters
+func ReplayDeadLetter(db gorp.SqlExecutor, id int64) error {
+	d, err := LoadDeadLetter(db, id)
+	if err != nil {
+		return err
+	}
+
+	var entry *brokerEntry
+	for _, b := range brokers {
+		if b.name == d.Broker {
+			entry = b
+		}
+	}
+	if entry == nil {
+		return sdk.WrapError(sdk.ErrNotFound, "ReplayDeadLetter> Broker %s not found", d.Broker)
+	}
+
+	d.Event.Attempts = 0
+	select {
+	case entry.queue <- d.Event:
+	default:
+		return sdk.WrapError(sdk.ErrUnknownError, "ReplayDeadLetter> Delivery queue of broker %s is full", d.Broker)
+	}
+
+	return DeleteDeadLetter(db, id)
+}
@@ -6,6 +6,7 @@ import (
 	"os"
 
 	"github.com/docker/docker/pkg/namesgenerator"
+	"github.com/go-gorp/gorp"
 
 	"github.com/ovh/cds/engine/api/cache"
 	"github.com/ovh/cds/sdk"
@@ -14,7 +15,7 @@ import (
 
 var hostname, cdsname string
 var kafkaBroker Broker
-var brokers []Broker
+var brokers []*brokerEntry
 
 // Broker event typed
 type Broker interface {
@@ -24,17 +25,58 @@ type Broker interface {
 	close()
 }
 
+// BrokerConfig is the configuration of a broker declared in the events.brokers list.
+// Fields are used depending of the type of the broker
+type BrokerConfig struct {
+	Name            string
+	Type            string   // kafka, webhook, nats or amqp
+	EventTypes      []string // If set, only these event types (ie. sdk.EventPipelineBuild) are sent to the broker
+	URL             string   // Webhook, NATS or AMQP url
+	Secret          string   // Webhook: key used to sign the payload
+	MaxRetry        int      // Number of retries before storing the event as dead letter. Default is 3, -1 to disable retries
+	Topic           string   // Kafka topic, NATS subject or AMQP routing key
+	Exchange        string   // AMQP exchange
+	BrokerAddresses string   // Kafka addresses
+	User            string   // Kafka or NATS user
+	Password        string   // Kafka or NATS password
+}
+
 func getBroker(t string, option interface{}) (Broker, error) {
 	switch t {
 	case "kafka":
 		k := &KafkaClient{}
 		return k.initialize(option)
+	case "webhook":
+		w := &WebhookClient{}
+		return w.initialize(option)
+	case "nats":
+		n := &NATSClient{}
+		return n.initialize(option)
+	case "amqp":
+		a := &AMQPClient{}
+		return a.initialize(option)
 	}
 	return nil, fmt.Errorf("Invalid Broker Type %s", t)
 }
 
-// Initialize initializes event system
-func Initialize(k KafkaConfig) error {
+// options returns the configuration expected by the broker type
+func (c BrokerConfig) options() interface{} {
+	switch c.Type {
+	case "kafka":
+		return KafkaConfig{Enabled: true, BrokerAddresses: c.BrokerAddresses, User: c.User, Password: c.Password, Topic: c.Topic}
+	case "webhook":
+		return WebhookConfig{URL: c.URL, Secret: c.Secret}
+	case "nats":
+		return NATSConfig{URL: c.URL, Subject: c.Topic, User: c.User, Password: c.Password}
+	case "amqp":
+		return AMQPConfig{URL: c.URL, Exchange: c.Exchange, RoutingKey: c.Topic}
+	}
+	return nil
+}
+
+// Initialize initializes event system with the kafka broker, if enabled, and the brokers of the events.brokers list.
+// A broker which cannot be initialized is skipped, so that it doesn't disable the other ones
+func Initialize(k KafkaConfig, configs ...BrokerConfig) {
 	var err error
 	hostname, err = os.Hostname()
 	if err != nil {
@@ -42,20 +84,45 @@ func Initialize(k KafkaConfig) error {
 	}
 	cdsname = namesgenerator.GetRandomName(0)
 
-	brokers = []Broker{}
+	brokers = []*brokerEntry{}
 	if k.Enabled {
 		var errk error
 		kafkaBroker, errk = getBroker("kafka", k)
 		if errk != nil {
-			return errk
+			log.Error("Event> Broker kafka not initialized: %s", errk)
+		} else {
+			brokers = append(brokers, newBrokerEntry("kafka", kafkaBroker, 0, nil))
 		}
-		brokers = append(brokers, kafkaBroker)
 	}
-	return nil
+
+	for i, c := range configs {
+		name := c.Name
+		if name == "" {
+			name = fmt.Sprintf("%s-%d", c.Type, i)
+		}
+		b, errb := getBroker(c.Type, c.options())
+		if errb != nil {
+			log.Error("Event> Broker %s (%s) not initialized: %s", name, c.Type, errb)
+			continue
+		}
+		brokers = append(brokers, newBrokerEntry(name, b, c.MaxRetry, c.EventTypes))
+		log.Info("Event> Broker %s (%s) initialized", name, c.Type)
+	}
 }
 
-// DequeueEvent runs in a goroutine and dequeue event from cache
-func DequeueEvent(c context.Context) {
+// DequeueEvent runs in a goroutine and dequeue event from cache. Each event is dispatched
+// on the delivery queue of the brokers, events which cannot be delivered are stored as dead letters.
+// Only the newest maxDeadLetters are kept
+func DequeueEvent(c context.Context, DBFunc func() *gorp.DbMap, maxDeadLetters int) {
+	dbFunc = DBFunc
+	deadLettersMax = maxDeadLetters
+	if deadLettersMax <= 0 {
+		deadLettersMax = defaultDeadLettersMax
+	}
+	for _, b := range brokers {
+		go b.run(c)
+	}
+
 	for {
 		e := sdk.Event{}
 		cache.DequeueWithContext(c, "events", &e)
@@ -65,9 +132,10 @@ func DequeueEvent(c context.Context) {
 		}
 
 		for _, b := range brokers {
-			if err := b.sendEvent(&e); err != nil {
-				log.Warning("Error while sending message: %s", err)
+			if !b.accept(&e) {
+				continue
 			}
+			b.enqueue(e)
 		}
 	}
 }
@@ -75,7 +143,7 @@ func DequeueEvent(c context.Context) {
 // Close closes event system
 func Close() {
 	for _, b := range brokers {
-		b.close()
+		b.broker.close()
 	}
 }
 
@@ -83,7 +151,7 @@ func Close() {
 func Status() string {
 	o := ""
 	for _, b := range brokers {
-		o += b.status() + " "
+		o += b.broker.status() + " "
 	}
 
 	if o == "" {
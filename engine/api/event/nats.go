@@ -0,0 +1,122 @@
+package event
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+
+	"github.com/ovh/cds/sdk"
+	"github.com/ovh/cds/sdk/log"
+)
+
+const natsTimeout = 10 * time.Second
+
+// NATSClient publishes events on a NATS subject
+type NATSClient struct {
+	sync.Mutex
+	options NATSConfig
+	conn    *nats.Conn
+}
+
+// NATSConfig handles all config to connect to NATS
+type NATSConfig struct {
+	URL      string // nats://host:4222 or tls://host:4222
+	Subject  string
+	User     string
+	Password string
+
+	tlsConfig *tls.Config
+}
+
+// initialize returns broker, isInit and err if
+func (c *NATSClient) initialize(options interface{}) (Broker, error) {
+	conf, ok := options.(NATSConfig)
+	if !ok {
+		return nil, fmt.Errorf("Invalid NATS Initialization")
+	}
+
+	if conf.URL == "" || conf.Subject == "" {
+		return nil, fmt.Errorf("initNATS> Invalid NATS Configuration")
+	}
+	c.options = conf
+
+	if err := c.connect(); err != nil {
+		return nil, fmt.Errorf("initNATS> Cannot connect to %s: %s", conf.URL, err)
+	}
+
+	log.Debug("initNATS> NATS used at %s on subject:%s", c.options.URL, c.options.Subject)
+	return c, nil
+}
+
+// connect opens the connection. With a tls:// url, the client reads the server INFO then upgrades the connection
+func (c *NATSClient) connect() error {
+	opts := []nats.Option{
+		nats.Name("cds-api"),
+		nats.Timeout(natsTimeout),
+	}
+	if c.options.User != "" {
+		opts = append(opts, nats.UserInfo(c.options.User, c.options.Password))
+	}
+	if c.options.tlsConfig != nil {
+		opts = append(opts, nats.Secure(c.options.tlsConfig))
+	}
+
+	conn, err := nats.Connect(c.options.URL, opts...)
+	if err != nil {
+		return err
+	}
+	c.conn = conn
+	return nil
+}
+
+// close closes the connection
+func (c *NATSClient) close() {
+	c.Lock()
+	defer c.Unlock()
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+}
+
+// sendEvent publishes the event on the subject. The connection is reopened if it has been closed
+func (c *NATSClient) sendEvent(event *sdk.Event) error {
+	data, errm := json.Marshal(event)
+	if errm != nil {
+		return errm
+	}
+
+	c.Lock()
+	defer c.Unlock()
+
+	if c.conn == nil || c.conn.IsClosed() {
+		if err := c.connect(); err != nil {
+			return err
+		}
+	}
+
+	if err := c.conn.Publish(c.options.Subject, data); err != nil {
+		return err
+	}
+	// Flush waits for the server to process the message, so that errors are received
+	if err := c.conn.FlushTimeout(natsTimeout); err != nil {
+		return err
+	}
+
+	log.Debug("Event %s sent to NATS subject %s", event.EventType, c.options.Subject)
+	return nil
+}
+
+// status returns the NATS connection status
+func (c *NATSClient) status() string {
+	c.Lock()
+	defer c.Unlock()
+	if c.conn == nil || !c.conn.IsConnected() {
+		return "NATS KO"
+	}
+	return "NATS OK"
+}
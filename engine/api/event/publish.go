@@ -92,3 +92,39 @@ func PublishPipelineBuild(db gorp.SqlExecutor, pb *sdk.PipelineBuild, previous *
 
 	Publish(e)
 }
+
+// PublishWorkflowApproval sends a workflow approval event. While the approval is waiting, approvers are notified by mail
+func PublishWorkflowApproval(wr *sdk.WorkflowRun, a *sdk.WorkflowRunApproval, pipelineName, username string, approvers []sdk.User) {
+	e := sdk.EventWorkflowApproval{
+		ProjectKey:   wr.Workflow.ProjectKey,
+		WorkflowName: wr.Workflow.Name,
+		Number:       wr.Number,
+		ApprovalID:   a.ID,
+		PipelineName: pipelineName,
+		GroupName:    a.GroupName,
+		Status:       a.Status,
+		Required:     a.Required,
+		Approvals:    a.Approvals(),
+		Username:     username,
+	}
+	recipients := []string{}
+	for _, u := range approvers {
+		e.Approvers = append(e.Approvers, u.Username)
+		if u.Email != "" {
+			recipients = append(recipients, u.Email)
+		}
+	}
+	Publish(e)
+
+	if a.Status != sdk.ApprovalStatusWaiting || len(recipients) == 0 {
+		return
+	}
+	Publish(sdk.EventNotif{
+		Recipients: recipients,
+		Subject:    fmt.Sprintf("[CDS] %s/%s#%d: approval required to start %s", e.ProjectKey, e.WorkflowName, e.Number, pipelineName),
+		Body: fmt.Sprintf("Pipeline %s of workflow %s/%s#%d is waiting for %d approval(s) from group %s (%d/%d).\n"+
+			"Approve it with: cdsctl workflow approve %s %s %d %d",
+			pipelineName, e.ProjectKey, e.WorkflowName, e.Number, a.Required, a.GroupName, e.Approvals, a.Required,
+			e.ProjectKey, e.WorkflowName, e.Number, a.ID),
+	})
+}
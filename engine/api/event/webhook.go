@@ -0,0 +1,102 @@
+package event
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/ovh/cds/sdk"
+	"github.com/ovh/cds/sdk/log"
+)
+
+const (
+	webhookTimeout     = 10 * time.Second
+	webhookSignHeader  = "X-CDS-Signature"
+	webhookEventHeader = "X-CDS-Event-Type"
+)
+
+// WebhookClient sends events on an HTTP endpoint
+type WebhookClient struct {
+	options WebhookConfig
+	client  *http.Client
+}
+
+// WebhookConfig handles all config to send events on an HTTP endpoint
+type WebhookConfig struct {
+	URL    string
+	Secret string
+}
+
+// initialize returns broker, isInit and err if
+func (c *WebhookClient) initialize(options interface{}) (Broker, error) {
+	conf, ok := options.(WebhookConfig)
+	if !ok {
+		return nil, fmt.Errorf("Invalid Webhook Initialization")
+	}
+
+	if conf.URL == "" {
+		return nil, fmt.Errorf("initWebhook> Invalid Webhook Configuration")
+	}
+
+	c.options = conf
+	c.client = &http.Client{Timeout: webhookTimeout}
+	return c, nil
+}
+
+// close does nothing, there is no connection to close
+func (c *WebhookClient) close() {}
+
+// sendEvent posts the event. The payload is signed with HMAC-SHA256 if a secret is set.
+// Client errors are not retried
+func (c *WebhookClient) sendEvent(event *sdk.Event) error {
+	data, errm := json.Marshal(event)
+	if errm != nil {
+		return errm
+	}
+
+	req, err := http.NewRequest("POST", c.options.URL, bytes.NewReader(data))
+	if err != nil {
+		return permanentError{err}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(webhookEventHeader, event.EventType)
+	if c.options.Secret != "" {
+		req.Header.Set(webhookSignHeader, "sha256="+webhookSignature(c.options.Secret, data))
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+
+	if resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests {
+		return fmt.Errorf("webhook %s: %s", c.options.URL, resp.Status)
+	}
+	if resp.StatusCode >= 300 {
+		return permanentError{fmt.Errorf("webhook %s: %s", c.options.URL, resp.Status)}
+	}
+
+	log.Debug("Event %s sent to webhook %s", event.EventType, c.options.URL)
+	return nil
+}
+
+// webhookSignature returns the hex encoded HMAC-SHA256 of the payload
+func webhookSignature(secret string, data []byte) string {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// status: here, if c is initialized, webhook is ok
+func (c *WebhookClient) status() string {
+	return "Webhook OK"
+}
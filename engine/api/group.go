@@ -198,6 +198,11 @@ func getPublicGroups(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *
 	return WriteJSON(w, r, groups, http.StatusOK)
 }
 
+// getRolesHandler returns the roles a group can have on a project, an application, a pipeline or an environment
+func getRolesHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	return WriteJSON(w, r, sdk.Roles, http.StatusOK)
+}
+
 func addGroupHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
 	g := &sdk.Group{}
 	if err := UnmarshalBody(r, g); err != nil {
@@ -46,10 +46,10 @@ func InsertGroupInApplication(db gorp.SqlExecutor, applicationID, groupID int64,
 	return err
 }
 
-// UpdateGroupRoleInApplication update permission on application
+// UpdateGroupRoleInApplication update permission on application. It removes the named role of the group
 func UpdateGroupRoleInApplication(db gorp.SqlExecutor, key, appName, groupName string, role int) error {
 	query := `UPDATE application_group
-	          SET role=$1
+	          SET role=$1, role_name=NULL
 	          FROM application, project, "group"
 	          WHERE application.id = application_id AND application.project_id = project.id AND "group".id = group_id
 	          AND application.name = $2 AND  project.projectKey = $3 AND "group".name = $4 `
@@ -57,6 +57,13 @@ func UpdateGroupRoleInApplication(db gorp.SqlExecutor, key, appName, groupName s
 	return err
 }
 
+// UpdateGroupRoleNameInApplication sets the named role of a group on the given application. An empty role removes it
+func UpdateGroupRoleNameInApplication(db gorp.SqlExecutor, applicationID, groupID int64, role string) error {
+	query := `UPDATE application_group SET role_name=$1 WHERE application_id=$2 AND group_id=$3`
+	_, err := db.Exec(query, roleName(role), applicationID, groupID)
+	return err
+}
+
 // DeleteAllGroupFromApplication remove all group from the given application
 func DeleteAllGroupFromApplication(db gorp.SqlExecutor, applicationID int64) error {
 	query := `DELETE FROM application_group WHERE application_id=$1`
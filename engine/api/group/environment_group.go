@@ -57,14 +57,17 @@ func InsertGroupsInEnvironment(db gorp.SqlExecutor, groupPermission []sdk.GroupP
 			log.Warning("InsertGroupsInEnvironment> unable to insert group %d %s on env %d : %s", g.Group.ID, g.Group.Name, envID, err)
 			return err
 		}
+		if err := UpdateGroupRoleNameInEnvironment(db, envID, g.Group.ID, g.Role); err != nil {
+			return err
+		}
 	}
 	return nil
 }
 
-// UpdateGroupRoleInEnvironment update permission on environment
+// UpdateGroupRoleInEnvironment update permission on environment. It removes the named role of the group
 func UpdateGroupRoleInEnvironment(db gorp.SqlExecutor, key, envName, groupName string, role int) error {
 	query := `UPDATE environment_group
-	          SET role=$1
+	          SET role=$1, role_name=NULL
 	          FROM environment, project, "group"
 	          WHERE environment.id = environment_id AND environment.project_id = project.id AND "group".id = group_id
 	          AND environment.name = $2 AND  project.projectKey = $3 AND "group".name = $4 `
@@ -74,6 +77,13 @@ func UpdateGroupRoleInEnvironment(db gorp.SqlExecutor, key, envName, groupName s
 	return nil
 }
 
+// UpdateGroupRoleNameInEnvironment sets the named role of a group on the given environment. An empty role removes it
+func UpdateGroupRoleNameInEnvironment(db gorp.SqlExecutor, environmentID, groupID int64, role string) error {
+	query := `UPDATE environment_group SET role_name=$1 WHERE environment_id=$2 AND group_id=$3`
+	_, err := db.Exec(query, roleName(role), environmentID, groupID)
+	return err
+}
+
 // Deprecated
 // DeleteAllGroupFromEnvironment remove all group from the given environment
 func DeleteAllGroupFromEnvironment(db gorp.SqlExecutor, environmentID int64) error {
@@ -35,6 +35,9 @@ func InsertGroupsInPipeline(db gorp.SqlExecutor, groupPermission []sdk.GroupPerm
 		if err != nil {
 			return err
 		}
+		if err := UpdateGroupRoleNameInPipeline(db, pipelineID, g.Group.ID, g.Role); err != nil {
+			return err
+		}
 	}
 	return nil
 }
@@ -46,13 +49,20 @@ func InsertGroupInPipeline(db gorp.SqlExecutor, pipelineID, groupID int64, role
 	return err
 }
 
-// UpdateGroupRoleInPipeline update permission on pipeline
+// UpdateGroupRoleInPipeline update permission on pipeline. It removes the named role of the group
 func UpdateGroupRoleInPipeline(db gorp.SqlExecutor, pipelineID, groupID int64, role int) error {
-	query := `UPDATE pipeline_group SET role=$1 WHERE pipeline_id=$2 AND group_id=$3`
+	query := `UPDATE pipeline_group SET role=$1, role_name=NULL WHERE pipeline_id=$2 AND group_id=$3`
 	_, err := db.Exec(query, role, pipelineID, groupID)
 	return err
 }
 
+// UpdateGroupRoleNameInPipeline sets the named role of a group on the given pipeline. An empty role removes it
+func UpdateGroupRoleNameInPipeline(db gorp.SqlExecutor, pipelineID, groupID int64, role string) error {
+	query := `UPDATE pipeline_group SET role_name=$1 WHERE pipeline_id=$2 AND group_id=$3`
+	_, err := db.Exec(query, roleName(role), pipelineID, groupID)
+	return err
+}
+
 // DeleteGroupFromPipeline removes access to pipeline to group members
 func DeleteGroupFromPipeline(db gorp.SqlExecutor, pipelineID, groupID int64) error {
 	query := `DELETE FROM pipeline_group WHERE pipeline_id=$1 AND group_id=$2`
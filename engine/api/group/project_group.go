@@ -1,6 +1,8 @@
 package group
 
 import (
+	"database/sql"
+
 	"github.com/go-gorp/gorp"
 
 	"github.com/ovh/cds/sdk"
@@ -35,13 +37,25 @@ func DeleteGroupFromProject(db gorp.SqlExecutor, projectID, groupID int64) error
 	return err
 }
 
-// UpdateGroupRoleInProject Update group role for the given project
+// UpdateGroupRoleInProject Update group role for the given project. It removes the named role of the group
 func UpdateGroupRoleInProject(db gorp.SqlExecutor, projectID, groupID int64, role int) error {
-	query := `UPDATE project_group SET role=$1 WHERE project_id=$2 AND group_id=$3`
+	query := `UPDATE project_group SET role=$1, role_name=NULL WHERE project_id=$2 AND group_id=$3`
 	_, err := db.Exec(query, role, projectID, groupID)
 	return err
 }
 
+// UpdateGroupRoleNameInProject sets the named role of a group on the given project. An empty role removes it
+func UpdateGroupRoleNameInProject(db gorp.SqlExecutor, projectID, groupID int64, role string) error {
+	query := `UPDATE project_group SET role_name=$1 WHERE project_id=$2 AND group_id=$3`
+	_, err := db.Exec(query, roleName(role), projectID, groupID)
+	return err
+}
+
+// roleName returns the value of a role_name column
+func roleName(role string) sql.NullString {
+	return sql.NullString{String: role, Valid: role != ""}
+}
+
 // InsertGroupInProject Attach a group to a project
 func InsertGroupInProject(db gorp.SqlExecutor, projectID, groupID int64, role int) error {
 	query := `INSERT INTO project_group (project_id, group_id,role) VALUES($1,$2,$3)`
@@ -17,6 +17,11 @@ var SharedInfraGroup *sdk.Group
 
 var defaultGroupID int64
 
+// IsDefaultGroupID returns true if id is the ID of the default group, where every user is added
+func IsDefaultGroupID(id int64) bool {
+	return defaultGroupID != 0 && id == defaultGroupID
+}
+
 // CreateDefaultGroup creates a group 'public' where every user will be
 func CreateDefaultGroup(db *gorp.DbMap, groupName string) error {
 	query := `SELECT id FROM "group" where name = $1`
@@ -91,6 +91,7 @@ func (*handlers) SendResult(c context.Context, res *sdk.Result) (*empty.Empty, e
 		return new(empty.Empty), sdk.WrapError(errb, "postWorkflowJobResultHandler> Cannot begin tx")
 	}
 	defer tx.Rollback()
+	defer workflow.DiscardRunEvents(tx)
 
 	//Update worker status
 	if err := worker.UpdateWorkerStatus(tx, workerID, sdk.StatusWaiting); err != nil {
@@ -124,6 +125,7 @@ func (*handlers) SendResult(c context.Context, res *sdk.Result) (*empty.Empty, e
 	if err := tx.Commit(); err != nil {
 		return new(empty.Empty), sdk.WrapError(err, "postWorkflowJobResultHandler> Cannot commit tx")
 	}
+	workflow.PublishRunEvents(tx)
 
 	return new(empty.Empty), nil
 }
@@ -18,6 +18,7 @@ import (
 	"github.com/ovh/cds/engine/api/pipeline"
 	"github.com/ovh/cds/engine/api/project"
 	"github.com/ovh/cds/engine/api/workflow"
+	"github.com/ovh/cds/engine/api/workflowhook"
 	"github.com/ovh/cds/sdk"
 	"github.com/ovh/cds/sdk/log"
 )
@@ -241,6 +242,13 @@ func processHook(db *gorp.DbMap, h hook.ReceivedHook) error {
 		return err
 	}
 
+	// Workflows with a RepositoryWebHook on this repository
+	if h.Message != "DELETE" {
+		if err := workflowhook.ProcessRepositoryEvent(db, h.ProjectKey+"/"+h.Repository, h.Branch, h.Hash, h.Author, h.Message); err != nil {
+			log.Warning("processHook> cannot process workflow hooks for %s/%s: %s\n", h.ProjectKey, h.Repository, err)
+		}
+	}
+
 	// Actual search of hook binding
 	hooks, err := hook.LoadHooks(db, h.ProjectKey, h.Repository)
 	if err != nil {
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/go-gorp/gorp"
+
+	"github.com/ovh/cds/engine/api/auth"
+	"github.com/ovh/cds/engine/api/businesscontext"
+	"github.com/ovh/cds/engine/api/group"
+	"github.com/ovh/cds/engine/api/sessionstore"
+	"github.com/ovh/cds/sdk"
+	"github.com/ovh/cds/sdk/log"
+)
+
+// oidcDriver returns the auth driver if it is the OpenID Connect driver
+func oidcDriver() (*auth.OIDCClient, error) {
+	d, ok := router.authDriver.(*auth.OIDCClient)
+	if !ok {
+		return nil, sdk.WrapError(sdk.ErrNotImplemented, "oidcDriver> OpenID Connect login is not enabled")
+	}
+	return d, nil
+}
+
+// getLoginOIDCHandler starts an OpenID Connect login from the UI
+func getLoginOIDCHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	d, err := oidcDriver()
+	if err != nil {
+		return err
+	}
+
+	url, state := d.AuthCodeURL()
+	return WriteJSON(w, r, sdk.UserOIDCAuthorization{URL: url, State: state}, http.StatusOK)
+}
+
+// postLoginOIDCCallbackHandler ends an OpenID Connect login from the UI with the code returned by the provider
+func postLoginOIDCCallbackHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	d, err := oidcDriver()
+	if err != nil {
+		return err
+	}
+
+	var callback sdk.UserOIDCCallback
+	if err := UnmarshalBody(r, &callback); err != nil {
+		return err
+	}
+
+	claims, err := d.Exchange(callback.State, callback.Code)
+	if err != nil {
+		return sdk.WrapError(sdk.ErrInvalidUser, "postLoginOIDCCallbackHandler> Login failed: %s", err)
+	}
+	return oidcLogin(w, r, db, d, claims, false)
+}
+
+// postLoginOIDCDeviceHandler starts an OpenID Connect login from the CLI
+func postLoginOIDCDeviceHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	d, err := oidcDriver()
+	if err != nil {
+		return err
+	}
+
+	device, err := d.DeviceAuthorization()
+	if err != nil {
+		return sdk.WrapError(err, "postLoginOIDCDeviceHandler> Unable to start device authorization")
+	}
+	return WriteJSON(w, r, device, http.StatusOK)
+}
+
+// postLoginOIDCDeviceTokenHandler ends an OpenID Connect login from the CLI. It returns sdk.ErrOIDCAuthorizationPending
+// until the user logs in on the provider
+func postLoginOIDCDeviceTokenHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	d, err := oidcDriver()
+	if err != nil {
+		return err
+	}
+
+	var device sdk.UserOIDCDeviceAuthorization
+	if err := UnmarshalBody(r, &device); err != nil {
+		return err
+	}
+
+	claims, err := d.DeviceToken(device.DeviceCode)
+	if err == sdk.ErrOIDCAuthorizationPending {
+		return err
+	}
+	if err != nil {
+		return sdk.WrapError(sdk.ErrInvalidUser, "postLoginOIDCDeviceTokenHandler> Login failed: %s", err)
+	}
+	return oidcLogin(w, r, db, d, claims, true)
+}
+
+// oidcLogin provisions the user of an ID token and opens its session, persistent for the CLI
+func oidcLogin(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, d *auth.OIDCClient, claims auth.OIDCClaims, persistent bool) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return sdk.WrapError(err, "oidcLogin> Cannot start transaction")
+	}
+	defer tx.Rollback()
+
+	u, err := d.ProvisionUser(tx, claims)
+	if err != nil {
+		return sdk.WrapError(err, "oidcLogin> Unable to provision user")
+	}
+	if err := group.CheckUserInDefaultGroup(tx, u.ID); err != nil {
+		log.Warning("oidcLogin> Error while check user in default group:%s\n", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return sdk.WrapError(err, "oidcLogin> Cannot commit transaction")
+	}
+
+	var sessionKey sessionstore.SessionKey
+	if persistent {
+		sessionKey, err = auth.NewPersistentSession(db, d, u)
+	} else {
+		sessionKey, err = auth.NewSession(d, u)
+	}
+	if err != nil {
+		return sdk.WrapError(err, "oidcLogin> Error while creating new session")
+	}
+
+	w.Header().Set(sdk.SessionTokenHeader, string(sessionKey))
+	response := sdk.UserAPIResponse{
+		User:  *u,
+		Token: string(sessionKey),
+	}
+	response.User.Auth = sdk.Auth{}
+	return WriteJSON(w, r, response, http.StatusOK)
+}
@@ -37,6 +37,7 @@ import (
 	"github.com/ovh/cds/engine/api/user"
 	"github.com/ovh/cds/engine/api/worker"
 	"github.com/ovh/cds/engine/api/workflow"
+	"github.com/ovh/cds/engine/api/workflowhook"
 	"github.com/ovh/cds/sdk"
 	"github.com/ovh/cds/sdk/log"
 )
@@ -90,7 +91,22 @@ var mainCmd = &cobra.Command{
 		}()
 
 		//Initialize secret driver
-		secret.Init(viper.GetString(viperServerSecretKey))
+		if keyID := viper.GetString(viperServerSecretKeyID); keyID != "" {
+			if err := secret.InitKeys(keyID, viper.GetString(viperServerSecretKey), viper.GetStringMapString(viperServerSecretPreviousKeys)); err != nil {
+				log.Fatalf("Cannot initialize secret keys: %s", err)
+			}
+		} else {
+			secret.Init(viper.GetString(viperServerSecretKey))
+		}
+
+		//Initialize vault for vault variables
+		if addr := viper.GetString(viperVaultAddr); addr != "" {
+			v, err := secret.New(viper.GetString(viperVaultToken), addr)
+			if err != nil {
+				log.Fatalf("Cannot initialize vault: %s", err)
+			}
+			secret.InitVault(v)
+		}
 
 		//Initialize mail package
 		mail.Init(viper.GetString(viperSMTPUser),
@@ -108,10 +124,17 @@ var mainCmd = &cobra.Command{
 			objectstoreKind = objectstore.Openstack
 		case "filesystem", "local":
 			objectstoreKind = objectstore.Filesystem
+		case "s3", "minio":
+			objectstoreKind = objectstore.S3
 		default:
 			log.Fatalf("Unsupported objecstore mode : %s", viper.GetString(viperArtifactMode))
 		}
 
+		artifactSignKey := viper.GetString(viperArtifactLocalSignKey)
+		if artifactSignKey == "" && viper.GetString(viperServerSecretKey) != "" {
+			artifactSignKey = objectstore.DeriveSignKey(viper.GetString(viperServerSecretKey))
+		}
+
 		cfg := objectstore.Config{
 			Kind: objectstoreKind,
 			Options: objectstore.ConfigOptions{
@@ -122,9 +145,22 @@ var mainCmd = &cobra.Command{
 					Tenant:          viper.GetString(viperArtifactOSTenant),
 					Region:          viper.GetString(viperArtifactOSRegion),
 					ContainerPrefix: viper.GetString(viperArtifactOSContainerPrefix),
+					TempURLKey:      viper.GetString(viperArtifactOSTempURLKey),
 				},
 				Filesystem: objectstore.ConfigOptionsFilesystem{
 					Basedir: viper.GetString(viperArtifactLocalBasedir),
+					APIURL:  viper.GetString(viperURLAPI),
+					SignKey: artifactSignKey,
+				},
+				S3: objectstore.ConfigOptionsS3{
+					Endpoint:        viper.GetString(viperArtifactS3Endpoint),
+					Region:          viper.GetString(viperArtifactS3Region),
+					AccessKeyID:     viper.GetString(viperArtifactS3AccessKeyID),
+					SecretAccessKey: viper.GetString(viperArtifactS3SecretAccessKey),
+					SessionToken:    viper.GetString(viperArtifactS3SessionToken),
+					BucketName:      viper.GetString(viperArtifactS3Bucket),
+					Prefix:          viper.GetString(viperArtifactS3Prefix),
+					PartSize:        viper.GetInt64(viperArtifactS3PartSize),
 				},
 			},
 		}
@@ -156,8 +192,13 @@ var mainCmd = &cobra.Command{
 			log.Error("Cannot setup databases: %s", err)
 		}
 
+		if err := workflow.CreateBuiltinHookModels(database.GetDBMap()); err != nil {
+			log.Error("Cannot setup builtin workflow hook models: %s", err)
+		}
+
 		cache.Initialize(viper.GetString(viperCacheMode), viper.GetString(viperCacheRedisHost), viper.GetString(viperCacheRedisPassword), viper.GetInt(viperCacheTTL))
 		InitLastUpdateBroker(ctx, database.GetDBMap)
+		InitWorkflowRunEventsBroker(ctx, database.GetDBMap)
 
 		router = &Router{
 			mux: mux.NewRouter(),
@@ -192,8 +233,24 @@ var mainCmd = &cobra.Command{
 		// Initialize the auth driver
 		var authMode string
 		var authOptions interface{}
-		switch viper.GetBool(viperAuthLDAPEnable) {
-		case true:
+		switch {
+		case viper.GetBool(viperAuthOIDCEnable):
+			authMode = "oidc"
+			redirectURL := viper.GetString(viperAuthOIDCRedirectURL)
+			if redirectURL == "" {
+				redirectURL = viper.GetString(viperURLUI) + "/account/oidc"
+			}
+			authOptions = auth.OIDCConfig{
+				Issuer:        viper.GetString(viperAuthOIDCIssuer),
+				ClientID:      viper.GetString(viperAuthOIDCClientID),
+				ClientSecret:  viper.GetString(viperAuthOIDCClientSecret),
+				RedirectURL:   redirectURL,
+				Scopes:        viper.GetStringSlice(viperAuthOIDCScopes),
+				UsernameClaim: viper.GetString(viperAuthOIDCUsernameClaim),
+				GroupsClaim:   viper.GetString(viperAuthOIDCGroupsClaim),
+				AdminGroup:    viper.GetString(viperAuthOIDCAdminGroup),
+			}
+		case viper.GetBool(viperAuthLDAPEnable):
 			authMode = "ldap"
 			authOptions = auth.LDAPConfig{
 				Host:         viper.GetString(viperAuthLDAPHost),
@@ -227,11 +284,12 @@ var mainCmd = &cobra.Command{
 			Password:        viper.GetString(viperEventsKafkaPassword),
 			Topic:           viper.GetString(viperEventsKafkaTopic),
 		}
-		if err := event.Initialize(kafkaOptions); err != nil {
-			log.Warning("⚠ Error while initializing event system: %s", err)
-		} else {
-			go event.DequeueEvent(ctx)
+		brokersOptions := []event.BrokerConfig{}
+		if err := viper.UnmarshalKey(viperEventsBrokers, &brokersOptions); err != nil {
+			log.Warning("⚠ Invalid events brokers configuration: %s", err)
 		}
+		event.Initialize(kafkaOptions, brokersOptions...)
+		go event.DequeueEvent(ctx, database.GetDBMap, viper.GetInt(viperEventsDeadLettersMax))
 
 		if err := worker.Initialize(ctx, database.GetDBMap); err != nil {
 			log.Warning("⚠ Error while initializing workers routine: %s", err)
@@ -239,6 +297,10 @@ var mainCmd = &cobra.Command{
 
 		go queue.Pipelines(ctx, database.GetDBMap)
 		go workflow.Scheduler(ctx, database.GetDBMap)
+		go workflow.ApprovalsExpirer(ctx, database.GetDBMap)
+		go workflow.QueueQuotasApplier(ctx, database.GetDBMap)
+		go workflowhook.Scheduler(ctx, database.GetDBMap)
+		go workflowhook.KafkaListeners(ctx, database.GetDBMap)
 		go pipeline.AWOLPipelineKiller(ctx, database.GetDBMap)
 		go hatchery.Heartbeat(ctx, database.GetDBMap)
 		go auditCleanerRoutine(ctx, database.GetDBMap)
@@ -250,6 +312,7 @@ var mainCmd = &cobra.Command{
 		go hookRecoverer(ctx, database.GetDBMap)
 
 		go user.PersistentSessionTokenCleaner(ctx, database.GetDBMap)
+		go workflow.PurgeArtifacts(ctx, database.GetDBMap)
 
 		if !viper.GetBool(viperVCSPollingDisabled) {
 			go poller.Initialize(ctx, 10, database.GetDBMap)
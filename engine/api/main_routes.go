@@ -5,10 +5,16 @@ import (
 	"path"
 
 	"github.com/spf13/viper"
+
+	"github.com/ovh/cds/sdk"
 )
 
 func (router *Router) init() {
 	router.Handle("/login", Auth(false), POST(LoginUser))
+	router.Handle("/login/oidc", Auth(false), GET(getLoginOIDCHandler))
+	router.Handle("/login/oidc/callback", Auth(false), POST(postLoginOIDCCallbackHandler))
+	router.Handle("/login/oidc/device", Auth(false), POST(postLoginOIDCDeviceHandler))
+	router.Handle("/login/oidc/device/token", Auth(false), POST(postLoginOIDCDeviceTokenHandler))
 
 	// Action
 	router.Handle("/action", GET(getActionsHandler))
@@ -21,6 +27,15 @@ func (router *Router) init() {
 	// Admin
 	router.Handle("/admin/warning", NeedAdmin(true), DELETE(adminTruncateWarningsHandler))
 	router.Handle("/admin/maintenance", NeedAdmin(true), POST(postAdminMaintenanceHandler), GET(getAdminMaintenanceHandler), DELETE(deleteAdminMaintenanceHandler))
+	router.Handle("/admin/retention/report", NeedAdmin(true), GET(getAdminRetentionReportHandler))
+	router.Handle("/admin/events/metrics", NeedAdmin(true), GET(getAdminEventMetricsHandler))
+	router.Handle("/admin/events/deadletters", NeedAdmin(true), GET(getAdminEventDeadLettersHandler))
+	router.Handle("/admin/events/deadletters/replay", NeedAdmin(true), POST(postAdminReplayEventDeadLettersHandler))
+	router.Handle("/admin/events/deadletters/{id}", NeedAdmin(true), DELETE(deleteAdminEventDeadLetterHandler))
+	router.Handle("/admin/events/deadletters/{id}/replay", NeedAdmin(true), POST(postAdminReplayEventDeadLetterHandler))
+	router.Handle("/admin/queue/quotas", NeedAdmin(true), GET(getAdminQueueQuotasHandler), POST(postAdminQueueQuotaHandler))
+	router.Handle("/admin/queue/quotas/{id}", NeedAdmin(true), PUT(putAdminQueueQuotaHandler), DELETE(deleteAdminQueueQuotaHandler))
+	router.Handle("/admin/secrets/rotation", NeedAdmin(true), GET(getAdminSecretsRotationHandler), POST(postAdminSecretsRotationHandler))
 
 	// Action plugin
 	router.Handle("/plugin", NeedAdmin(true), POST(addPluginHandler), PUT(updatePluginHandler))
@@ -36,6 +51,7 @@ func (router *Router) init() {
 	// Group
 	router.Handle("/group", GET(getGroups), POST(addGroupHandler))
 	router.Handle("/group/public", GET(getPublicGroups))
+	router.Handle("/role", GET(getRolesHandler))
 	router.Handle("/group/{permGroupName}", GET(getGroupHandler), PUT(updateGroupHandler), DELETE(deleteGroupHandler))
 	router.Handle("/group/{permGroupName}/user", POST(addUserInGroup))
 	router.Handle("/group/{permGroupName}/user/{user}", DELETE(removeUserFromGroupHandler))
@@ -48,6 +64,7 @@ func (router *Router) init() {
 
 	// Hooks
 	router.Handle("/hook", Auth(false) /* Public handler called by third parties */, POST(receiveHook))
+	router.Handle("/hook/workflow/{uuid}", Auth(false) /* Public handler called by third parties */, POST(postWorkflowWebHookHandler))
 
 	// Overall health
 	router.Handle("/mon/status", Auth(false), GET(statusHandler))
@@ -65,23 +82,26 @@ func (router *Router) init() {
 	// Project
 	router.Handle("/project", GET(getProjectsHandler), POST(addProjectHandler))
 	router.Handle("/project/{permProjectKey}", GET(getProjectHandler), PUT(updateProjectHandler), DELETE(deleteProjectHandler))
-	router.Handle("/project/{permProjectKey}/group", POST(addGroupInProject), PUT(updateGroupsInProject, DEPRECATED))
-	router.Handle("/project/{permProjectKey}/group/{group}", PUT(updateGroupRoleOnProjectHandler), DELETE(deleteGroupFromProjectHandler))
-	router.Handle("/project/{permProjectKey}/variable", GET(getVariablesInProjectHandler), PUT(updateVariablesInProjectHandler, DEPRECATED))
+	router.Handle("/project/{permProjectKey}/group", NeedCapability(sdk.CapabilityManage), POST(addGroupInProject), PUT(updateGroupsInProject, DEPRECATED))
+	router.Handle("/project/{permProjectKey}/group/{group}", NeedCapability(sdk.CapabilityManage), PUT(updateGroupRoleOnProjectHandler), DELETE(deleteGroupFromProjectHandler))
+	router.Handle("/project/{permProjectKey}/variable", NeedCapability(sdk.CapabilityEditVariables), GET(getVariablesInProjectHandler), PUT(updateVariablesInProjectHandler, DEPRECATED))
 	router.Handle("/project/{key}/variable/audit", GET(getVariablesAuditInProjectnHandler))
 	router.Handle("/project/{key}/variable/audit/{auditID}", PUT(restoreProjectVariableAuditHandler, DEPRECATED))
-	router.Handle("/project/{permProjectKey}/variable/{name}", GET(getVariableInProjectHandler, DEPRECATED), POST(addVariableInProjectHandler), PUT(updateVariableInProjectHandler), DELETE(deleteVariableFromProjectHandler))
+	router.Handle("/project/{permProjectKey}/variable/{name}", NeedCapability(sdk.CapabilityEditVariables), GET(getVariableInProjectHandler, DEPRECATED), POST(addVariableInProjectHandler), PUT(updateVariableInProjectHandler), DELETE(deleteVariableFromProjectHandler))
 	router.Handle("/project/{permProjectKey}/variable/{name}/audit", GET(getVariableAuditInProjectHandler))
+	router.Handle("/project/{permProjectKey}/vault", NeedCapability(sdk.CapabilityEditVariables), GET(getProjectVaultHandler), PUT(putProjectVaultHandler), DELETE(deleteProjectVaultHandler))
 	router.Handle("/project/{permProjectKey}/applications", GET(getApplicationsHandler), POST(addApplicationHandler))
 	router.Handle("/project/{permProjectKey}/notifications", GET(getProjectNotificationsHandler))
+	router.Handle("/project/{permProjectKey}/retention", GET(getRetentionPolicyHandler), PUT(putRetentionPolicyHandler), DELETE(deleteRetentionPolicyHandler))
+	router.Handle("/project/{permProjectKey}/retention/report", GET(getRetentionReportHandler))
 
 	// Application
 	router.Handle("/project/{key}/application/{permApplicationName}", GET(getApplicationHandler), PUT(updateApplicationHandler), DELETE(deleteApplicationHandler))
 	router.Handle("/project/{key}/application/{permApplicationName}/branches", GET(getApplicationBranchHandler))
 	router.Handle("/project/{key}/application/{permApplicationName}/version", GET(getApplicationBranchVersionHandler))
 	router.Handle("/project/{key}/application/{permApplicationName}/clone", POST(cloneApplicationHandler))
-	router.Handle("/project/{key}/application/{permApplicationName}/group", POST(addGroupInApplicationHandler), PUT(updateGroupsInApplicationHandler, DEPRECATED))
-	router.Handle("/project/{key}/application/{permApplicationName}/group/{group}", PUT(updateGroupRoleOnApplicationHandler), DELETE(deleteGroupFromApplicationHandler))
+	router.Handle("/project/{key}/application/{permApplicationName}/group", NeedCapability(sdk.CapabilityManage), POST(addGroupInApplicationHandler), PUT(updateGroupsInApplicationHandler, DEPRECATED))
+	router.Handle("/project/{key}/application/{permApplicationName}/group/{group}", NeedCapability(sdk.CapabilityManage), PUT(updateGroupRoleOnApplicationHandler), DELETE(deleteGroupFromApplicationHandler))
 	router.Handle("/project/{key}/application/{permApplicationName}/history/branch", GET(getPipelineBuildBranchHistoryHandler))
 	router.Handle("/project/{key}/application/{permApplicationName}/history/env/deploy", GET(getApplicationDeployHistoryHandler))
 	router.Handle("/project/{key}/application/{permApplicationName}/notifications", POST(addNotificationsHandler))
@@ -93,10 +113,10 @@ func (router *Router) init() {
 	router.Handle("/project/{key}/application/{permApplicationName}/pipeline/{permPipelineKey}/scheduler/{id}", DELETE(deleteSchedulerApplicationPipelineHandler))
 	router.Handle("/project/{key}/application/{permApplicationName}/tree", GET(getApplicationTreeHandler))
 	router.Handle("/project/{key}/application/{permApplicationName}/tree/status", GET(getApplicationTreeStatusHandler))
-	router.Handle("/project/{key}/application/{permApplicationName}/variable", GET(getVariablesInApplicationHandler), PUT(updateVariablesInApplicationHandler))
+	router.Handle("/project/{key}/application/{permApplicationName}/variable", NeedCapability(sdk.CapabilityEditVariables), GET(getVariablesInApplicationHandler), PUT(updateVariablesInApplicationHandler))
 	router.Handle("/project/{key}/application/{permApplicationName}/variable/audit", GET(getVariablesAuditInApplicationHandler))
-	router.Handle("/project/{key}/application/{permApplicationName}/variable/audit/{auditID}", PUT(restoreAuditHandler, DEPRECATED))
-	router.Handle("/project/{key}/application/{permApplicationName}/variable/{name}", GET(getVariableInApplicationHandler), POST(addVariableInApplicationHandler), PUT(updateVariableInApplicationHandler), DELETE(deleteVariableFromApplicationHandler))
+	router.Handle("/project/{key}/application/{permApplicationName}/variable/audit/{auditID}", NeedCapability(sdk.CapabilityEditVariables), PUT(restoreAuditHandler, DEPRECATED))
+	router.Handle("/project/{key}/application/{permApplicationName}/variable/{name}", NeedCapability(sdk.CapabilityEditVariables), GET(getVariableInApplicationHandler), POST(addVariableInApplicationHandler), PUT(updateVariableInApplicationHandler), DELETE(deleteVariableFromApplicationHandler))
 	router.Handle("/project/{key}/application/{permApplicationName}/variable/{name}/audit", GET(getVariableAuditInApplicationHandler))
 
 	// Pipeline
@@ -119,8 +139,8 @@ func (router *Router) init() {
 	router.Handle("/project/{permProjectKey}/pipeline", GET(getPipelinesHandler), POST(addPipeline))
 	router.Handle("/project/{permProjectKey}/import/pipeline", POST(importPipelineHandler))
 	router.Handle("/project/{key}/pipeline/{permPipelineKey}/application", GET(getApplicationUsingPipelineHandler))
-	router.Handle("/project/{key}/pipeline/{permPipelineKey}/group", POST(addGroupInPipelineHandler), PUT(updateGroupsOnPipelineHandler, DEPRECATED))
-	router.Handle("/project/{key}/pipeline/{permPipelineKey}/group/{group}", PUT(updateGroupRoleOnPipelineHandler), DELETE(deleteGroupFromPipelineHandler))
+	router.Handle("/project/{key}/pipeline/{permPipelineKey}/group", NeedCapability(sdk.CapabilityManage), POST(addGroupInPipelineHandler), PUT(updateGroupsOnPipelineHandler, DEPRECATED))
+	router.Handle("/project/{key}/pipeline/{permPipelineKey}/group/{group}", NeedCapability(sdk.CapabilityManage), PUT(updateGroupRoleOnPipelineHandler), DELETE(deleteGroupFromPipelineHandler))
 	router.Handle("/project/{key}/pipeline/{permPipelineKey}/parameter", GET(getParametersInPipelineHandler), PUT(updateParametersInPipelineHandler, DEPRECATED))
 	router.Handle("/project/{key}/pipeline/{permPipelineKey}/parameter/{name}", POST(addParameterInPipelineHandler), PUT(updateParameterInPipelineHandler), DELETE(deleteParameterFromPipelineHandler))
 	router.Handle("/project/{key}/pipeline/{permPipelineKey}", GET(getPipelineHandler), PUT(updatePipelineHandler), DELETE(deletePipeline))
@@ -132,18 +152,39 @@ func (router *Router) init() {
 
 	// Workflows
 	router.Handle("/project/{permProjectKey}/workflows", POST(postWorkflowHandler), GET(getWorkflowsHandler))
+	router.Handle("/project/{permProjectKey}/workflows/import", POST(postWorkflowImportHandler))
+	router.Handle("/project/{permProjectKey}/runs", GET(searchWorkflowRunsHandler))
+	router.Handle("/project/{permProjectKey}/runs/events", GET(workflowRunEventsBroker.ServeHTTP))
+	router.Handle("/project/{permProjectKey}/workflows/{workflowName}/runs/events", GET(workflowRunEventsBroker.ServeHTTP))
+	router.Handle("/workflow/hook/models", GET(getWorkflowHookModelsHandler))
+	router.Handle("/workflow/hook/models/{model}", GET(getWorkflowHookModelHandler))
 	router.Handle("/project/{permProjectKey}/workflows/{workflowName}", GET(getWorkflowHandler), PUT(putWorkflowHandler), DELETE(deleteWorkflowHandler))
 	// Workflows run
-	router.Handle("/project/{permProjectKey}/workflows/{workflowName}/runs", GET(getWorkflowRunsHandler), POST(postWorkflowRunHandler))
+	router.Handle("/project/{permProjectKey}/workflows/{workflowName}/runs", GET(getWorkflowRunsHandler), POSTEXECUTE(postWorkflowRunHandler))
 	router.Handle("/project/{permProjectKey}/workflows/{workflowName}/runs/latest", GET(getLatestWorkflowRunHandler))
 	router.Handle("/project/{permProjectKey}/workflows/{workflowName}/runs/{number}", GET(getWorkflowRunHandler))
 	router.Handle("/project/{permProjectKey}/workflows/{workflowName}/runs/{number}/artifacts", GET(getWorkflowRunArtifactsHandler))
+	router.Handle("/project/{permProjectKey}/workflows/{workflowName}/runs/{number}/tags", POSTEXECUTE(postWorkflowRunTagsHandler))
+	router.Handle("/project/{permProjectKey}/workflows/{workflowName}/runs/{number}/stop", POSTEXECUTE(stopWorkflowRunHandler))
+	router.Handle("/project/{permProjectKey}/workflows/{workflowName}/runs/{number}/approvals", GET(getWorkflowRunApprovalsHandler))
+	router.Handle("/project/{permProjectKey}/workflows/{workflowName}/runs/{number}/approvals/{id}/approve", POSTEXECUTE(postWorkflowRunApprovalApproveHandler))
+	router.Handle("/project/{permProjectKey}/workflows/{workflowName}/runs/{number}/approvals/{id}/reject", POSTEXECUTE(postWorkflowRunApprovalRejectHandler))
 	router.Handle("/project/{permProjectKey}/workflows/{workflowName}/runs/{number}/nodes/{id}", GET(getWorkflowNodeRunHandler))
+	router.Handle("/project/{permProjectKey}/workflows/{workflowName}/runs/{number}/nodes/{id}/stop", POSTEXECUTE(stopWorkflowNodeRunHandler))
+	router.Handle("/project/{permProjectKey}/workflows/{workflowName}/runs/{number}/nodes/{id}/restart", POSTEXECUTE(postWorkflowNodeRunRestartHandler))
 	router.Handle("/project/{permProjectKey}/workflows/{workflowName}/runs/{number}/nodes/{id}/job/{runJobId}/step/{stepOrder}", GET(getWorkflowNodeRunJobStepHandler))
 	router.Handle("/project/{permProjectKey}/workflows/{workflowName}/runs/{number}/nodes/{id}/artifacts", GET(getWorkflowNodeRunArtifactsHandler))
 	router.Handle("/project/{permProjectKey}/workflows/{workflowName}/artifact/{artifactId}", GET(getDownloadArtifactHandler))
+	router.Handle("/project/{permProjectKey}/workflows/{workflowName}/artifact/{artifactId}/url", GET(getDownloadArtifactTemporaryURLHandler))
 	router.Handle("/project/{permProjectKey}/workflows/{workflowName}/node/{nodeID}/triggers/condition", GET(getWorkflowTriggerConditionHandler))
 	router.Handle("/project/{permProjectKey}/workflows/{workflowName}/join/{joinID}/triggers/condition", GET(getWorkflowTriggerJoinConditionHandler))
+	router.Handle("/project/{permProjectKey}/workflows/{workflowName}/export", GET(getWorkflowExportHandler))
+	router.Handle("/project/{permProjectKey}/workflows/{workflowName}/versions", GET(getWorkflowVersionsHandler))
+	router.Handle("/project/{permProjectKey}/workflows/{workflowName}/versions/{version}", GET(getWorkflowVersionHandler))
+	router.Handle("/project/{permProjectKey}/workflows/{workflowName}/versions/{version}/diff/{toVersion}", GET(getWorkflowVersionDiffHandler))
+	router.Handle("/project/{permProjectKey}/workflows/{workflowName}/versions/{version}/restore", POST(postWorkflowVersionRestoreHandler))
+	router.Handle("/project/{permProjectKey}/workflows/{workflowName}/schedules", GET(getWorkflowSchedulesHandler))
+	router.Handle("/project/{permProjectKey}/workflows/{workflowName}/retention", GET(getRetentionPolicyHandler), PUT(putRetentionPolicyHandler), DELETE(deleteRetentionPolicyHandler))
 
 	// DEPRECATED
 	router.Handle("/project/{key}/pipeline/{permPipelineKey}/action/{jobID}", PUT(updatePipelineActionHandler, DEPRECATED), DELETE(deleteJobHandler))
@@ -164,12 +205,12 @@ func (router *Router) init() {
 	router.Handle("/project/{key}/environment/{permEnvironmentName}", GET(getEnvironmentHandler), PUT(updateEnvironmentHandler), DELETE(deleteEnvironmentHandler))
 	router.Handle("/project/{key}/environment/{permEnvironmentName}/clone", POST(cloneEnvironmentHandler))
 	router.Handle("/project/{key}/environment/{permEnvironmentName}/audit", GET(getEnvironmentsAuditHandler, DEPRECATED))
-	router.Handle("/project/{key}/environment/{permEnvironmentName}/audit/{auditID}", PUT(restoreEnvironmentAuditHandler, DEPRECATED))
-	router.Handle("/project/{key}/environment/{permEnvironmentName}/group", POST(addGroupInEnvironmentHandler))
-	router.Handle("/project/{key}/environment/{permEnvironmentName}/groups", POST(addGroupsInEnvironmentHandler))
-	router.Handle("/project/{key}/environment/{permEnvironmentName}/group/{group}", PUT(updateGroupRoleOnEnvironmentHandler), DELETE(deleteGroupFromEnvironmentHandler))
+	router.Handle("/project/{key}/environment/{permEnvironmentName}/audit/{auditID}", NeedCapability(sdk.CapabilityEditVariables), PUT(restoreEnvironmentAuditHandler, DEPRECATED))
+	router.Handle("/project/{key}/environment/{permEnvironmentName}/group", NeedCapability(sdk.CapabilityManage), POST(addGroupInEnvironmentHandler))
+	router.Handle("/project/{key}/environment/{permEnvironmentName}/groups", NeedCapability(sdk.CapabilityManage), POST(addGroupsInEnvironmentHandler))
+	router.Handle("/project/{key}/environment/{permEnvironmentName}/group/{group}", NeedCapability(sdk.CapabilityManage), PUT(updateGroupRoleOnEnvironmentHandler), DELETE(deleteGroupFromEnvironmentHandler))
 	router.Handle("/project/{key}/environment/{permEnvironmentName}/variable", GET(getVariablesInEnvironmentHandler))
-	router.Handle("/project/{key}/environment/{permEnvironmentName}/variable/{name}", GET(getVariableInEnvironmentHandler), POST(addVariableInEnvironmentHandler), PUT(updateVariableInEnvironmentHandler), DELETE(deleteVariableFromEnvironmentHandler))
+	router.Handle("/project/{key}/environment/{permEnvironmentName}/variable/{name}", NeedCapability(sdk.CapabilityEditVariables), GET(getVariableInEnvironmentHandler), POST(addVariableInEnvironmentHandler), PUT(updateVariableInEnvironmentHandler), DELETE(deleteVariableFromEnvironmentHandler))
 	router.Handle("/project/{key}/environment/{permEnvironmentName}/variable/{name}/audit", GET(getVariableAuditInEnvironmentHandler))
 
 	// Artifacts
@@ -177,7 +218,9 @@ func (router *Router) init() {
 	router.Handle("/project/{key}/application/{permApplicationName}/pipeline/{permPipelineKey}/{buildNumber}/artifact", GET(listArtifactsBuildHandler))
 	router.Handle("/project/{key}/application/{permApplicationName}/pipeline/{permPipelineKey}/{buildNumber}/artifact/{tag}", POSTEXECUTE(uploadArtifactHandler))
 	router.Handle("/project/{key}/application/{permApplicationName}/pipeline/{permPipelineKey}/artifact/download/{id}", GET(downloadArtifactHandler))
+	router.Handle("/project/{key}/application/{permApplicationName}/pipeline/{permPipelineKey}/artifact/download/{id}/url", GET(getArtifactTemporaryURLHandler))
 	router.Handle("/artifact/{hash}", Auth(false), GET(downloadArtifactDirectHandler))
+	router.Handle("/objectstore/fs", Auth(false), GET(downloadObjectTemporaryURLHandler))
 
 	// Hooks
 	router.Handle("/project/{key}/application/{permApplicationName}/hook", GET(getApplicationHooksHandler))
@@ -211,6 +254,7 @@ func (router *Router) init() {
 	router.Handle("/queue/workflows/{permID}/variable", NeedWorker(), POSTEXECUTE(postWorkflowJobVariableHandler))
 	router.Handle("/queue/workflows/{permID}/step", NeedWorker(), POSTEXECUTE(postWorkflowJobStepStatusHandler))
 	router.Handle("/queue/workflows/{permID}/artifact/{tag}", NeedWorker(), POSTEXECUTE(postWorkflowJobArtifactHandler))
+	router.Handle("/queue/workflows/{permID}/artifact/{tag}/ref", NeedWorker(), POSTEXECUTE(postWorkflowJobArtifactRefHandler))
 
 	router.Handle("/variable/type", GET(getVariableTypeHandler))
 	router.Handle("/parameter/type", GET(getParameterTypeHandler))
@@ -259,6 +303,8 @@ func (router *Router) init() {
 	router.Handle("/user/import", NeedAdmin(true), POST(importUsersHandler))
 	router.Handle("/user/{username}", NeedUsernameOrAdmin(true), GET(GetUserHandler), PUT(UpdateUserHandler), DELETE(DeleteUserHandler))
 	router.Handle("/user/{username}/groups", NeedUsernameOrAdmin(true), GET(getUserGroupsHandler))
+	router.Handle("/user/{username}/token", NeedUsernameOrAdmin(true), GET(getUserAccessTokensHandler), POST(postUserAccessTokenHandler))
+	router.Handle("/user/{username}/token/{id}", NeedUsernameOrAdmin(true), DELETE(deleteUserAccessTokenHandler))
 	router.Handle("/user/{username}/confirm/{token}", Auth(false), GET(ConfirmUser))
 	router.Handle("/user/{username}/reset", Auth(false), POST(ResetUser))
 	router.Handle("/auth/mode", Auth(false), GET(AuthModeHandler))
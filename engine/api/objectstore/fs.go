@@ -1,27 +1,38 @@
 package objectstore
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
+	"net/url"
 	"os"
 	"path"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/ovh/cds/sdk"
 	"github.com/ovh/cds/sdk/log"
 )
 
 // FilesystemStore implements ObjectStore interface with filesystem driver
 type FilesystemStore struct {
 	basedir string
+	apiURL  string
+	signKey string
 }
 
-// NewFilesystemStore creates a new ObjectStore with filesystem driver
-func NewFilesystemStore(basedir string) (*FilesystemStore, error) {
+// NewFilesystemStore creates a new ObjectStore with filesystem driver.
+// apiURL and signKey are used to sign temporary urls, they are optional.
+func NewFilesystemStore(basedir, apiURL, signKey string) (*FilesystemStore, error) {
 	log.Info("Objectstore> Initialize Filesystem driver on directory: %s", basedir)
 	if basedir == "" {
 		return nil, fmt.Errorf("artifact storage is filesystem, but --artifact-basedir is not provided")
 	}
 
-	fss := &FilesystemStore{basedir: basedir}
+	fss := &FilesystemStore{basedir: basedir, apiURL: strings.TrimSuffix(apiURL, "/"), signKey: signKey}
 	return fss, nil
 }
 
@@ -61,3 +72,64 @@ func (fss *FilesystemStore) Delete(o Object) error {
 	dst := path.Join(fss.basedir, o.GetPath(), o.GetName())
 	return os.RemoveAll(dst)
 }
+
+// FetchURL returns an url served by the API to download the object as filename, with a signature valid until expires
+func (fss *FilesystemStore) FetchURL(o Object, filename string, expires time.Time) (string, error) {
+	if fss.apiURL == "" || fss.signKey == "" {
+		return "", sdk.ErrNotImplemented
+	}
+
+	q := url.Values{}
+	q.Set("path", o.GetPath())
+	q.Set("name", o.GetName())
+	q.Set("filename", filename)
+	q.Set("expires", strconv.FormatInt(expires.Unix(), 10))
+	q.Set("signature", fss.sign(o.GetPath(), o.GetName(), filename, expires.Unix()))
+
+	return fss.apiURL + "/objectstore/fs?" + q.Encode(), nil
+}
+
+// FetchSigned checks the signature of an url returned by FetchURL and returns the object and its download filename
+func (fss *FilesystemStore) FetchSigned(query url.Values) (io.ReadCloser, string, error) {
+	if fss.signKey == "" {
+		return nil, "", sdk.ErrNotImplemented
+	}
+
+	p, name, filename := query.Get("path"), query.Get("name"), query.Get("filename")
+	expires, err := strconv.ParseInt(query.Get("expires"), 10, 64)
+	if err != nil {
+		return nil, "", sdk.ErrWrongRequest
+	}
+
+	signature, err := hex.DecodeString(query.Get("signature"))
+	if err != nil {
+		return nil, "", sdk.ErrForbidden
+	}
+	expected, _ := hex.DecodeString(fss.sign(p, name, filename, expires))
+	if !hmac.Equal(signature, expected) {
+		return nil, "", sdk.ErrForbidden
+	}
+	if time.Now().Unix() > expires {
+		return nil, "", sdk.ErrForbidden
+	}
+
+	f, err := os.Open(path.Join(fss.basedir, p, name))
+	if err != nil {
+		return nil, "", err
+	}
+	return f, filename, nil
+}
+
+// DeriveSignKey returns a key dedicated to the temporary urls, derived from secretKey. The secret key itself,
+// which encrypts the secrets in database, is never used to sign urls
+func DeriveSignKey(secretKey string) string {
+	mac := hmac.New(sha256.New, []byte(secretKey))
+	fmt.Fprint(mac, "artifact-url")
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (fss *FilesystemStore) sign(p, name, filename string, expires int64) string {
+	mac := hmac.New(sha256.New, []byte(fss.signKey))
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%d", p, name, filename, expires)
+	return hex.EncodeToString(mac.Sum(nil))
+}
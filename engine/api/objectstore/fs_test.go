@@ -0,0 +1,77 @@
+package objectstore
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ovh/cds/sdk"
+)
+
+func TestFilesystemStoreTemporaryURL(t *testing.T) {
+	basedir, err := ioutil.TempDir("", "cds-objectstore")
+	assert.NoError(t, err)
+	defer os.RemoveAll(basedir)
+
+	fss, err := NewFilesystemStore(basedir, "http://cds.api/", "mysignkey")
+	assert.NoError(t, err)
+
+	art := &sdk.ActionPlugin{Name: "my-plugin"}
+	_, err = fss.Store(art, ioutil.NopCloser(bytes.NewBufferString("plugin content")))
+	assert.NoError(t, err)
+
+	tmpURL, err := fss.FetchURL(art, "plugin.bin", time.Now().Add(time.Minute))
+	assert.NoError(t, err)
+
+	u, err := url.Parse(tmpURL)
+	assert.NoError(t, err)
+	assert.Equal(t, "cds.api", u.Host)
+	assert.Equal(t, "/objectstore/fs", u.Path)
+
+	f, name, err := fss.FetchSigned(u.Query())
+	assert.NoError(t, err)
+	assert.Equal(t, "plugin.bin", name)
+	data, _ := ioutil.ReadAll(f)
+	f.Close()
+	assert.Equal(t, "plugin content", string(data))
+
+	// Tampered url
+	q := u.Query()
+	q.Set("name", "another-plugin")
+	_, _, err = fss.FetchSigned(q)
+	assert.Equal(t, sdk.ErrForbidden, err)
+
+	q = u.Query()
+	q.Set("filename", "another.bin")
+	_, _, err = fss.FetchSigned(q)
+	assert.Equal(t, sdk.ErrForbidden, err)
+
+	// Expired url
+	tmpURL, err = fss.FetchURL(art, "plugin.bin", time.Now().Add(-time.Minute))
+	assert.NoError(t, err)
+	u, _ = url.Parse(tmpURL)
+	_, _, err = fss.FetchSigned(u.Query())
+	assert.Equal(t, sdk.ErrForbidden, err)
+}
+
+func TestFilesystemStoreTemporaryURLDisabled(t *testing.T) {
+	fss, err := NewFilesystemStore("/tmp/cds", "", "")
+	assert.NoError(t, err)
+
+	_, err = fss.FetchURL(&sdk.ActionPlugin{Name: "my-plugin"}, "plugin.bin", time.Now().Add(time.Minute))
+	assert.Equal(t, sdk.ErrNotImplemented, err)
+}
+
+func TestDeriveSignKey(t *testing.T) {
+	secretKey := "0123456789abcdef0123456789abcdef"
+	key := DeriveSignKey(secretKey)
+	assert.Len(t, key, 64)
+	assert.NotContains(t, key, secretKey)
+	assert.Equal(t, key, DeriveSignKey(secretKey))
+	assert.NotEqual(t, key, DeriveSignKey("another secret key"))
+}
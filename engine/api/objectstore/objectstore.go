@@ -4,12 +4,17 @@ import (
 	"context"
 	"fmt"
 	"io"
+	"net/url"
+	"time"
 
 	"github.com/ovh/cds/sdk"
 )
 
 var storage Driver
 
+// TemporaryURLTTL is the validity duration of the temporary urls returned by FetchTemporaryURL
+var TemporaryURLTTL = 15 * time.Minute
+
 //Status is for status handler
 func Status() string {
 	if storage == nil {
@@ -43,6 +48,33 @@ func DeleteArtifact(o Object) error {
 	return fmt.Errorf("store not initialized")
 }
 
+//FetchTemporaryURL returns a signed temporary url to download an artifact as filename directly from
+//the storage backend. It returns sdk.ErrNotImplemented if the driver cannot sign urls.
+func FetchTemporaryURL(o Object, filename string) (string, time.Time, error) {
+	if storage == nil {
+		return "", time.Time{}, fmt.Errorf("store not initialized")
+	}
+	d, ok := storage.(DriverWithTemporaryURL)
+	if !ok {
+		return "", time.Time{}, sdk.ErrNotImplemented
+	}
+	expires := time.Now().Add(TemporaryURLTTL)
+	u, err := d.FetchURL(o, filename, expires)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return u, expires, nil
+}
+
+//FetchFromTemporaryURL fetches an object from the query of a temporary url signed by the filesystem driver
+func FetchFromTemporaryURL(query url.Values) (io.ReadCloser, string, error) {
+	fss, ok := storage.(*FilesystemStore)
+	if !ok {
+		return nil, "", sdk.ErrNotImplemented
+	}
+	return fss.FetchSigned(query)
+}
+
 //StorePlugin call Store on the common driver
 func StorePlugin(art sdk.ActionPlugin, data io.ReadCloser) (string, error) {
 	if storage != nil {
@@ -94,6 +126,7 @@ func DeleteTemplateExtension(tmpl sdk.TemplateExtension) error {
 // Driver allows artifact to be stored and retrieve the same way to any backend
 // - Openstack / Swift
 // - Filesystem
+// - S3 / Minio
 type Driver interface {
 	Status() string
 	Store(o Object, data io.ReadCloser) (string, error)
@@ -101,6 +134,15 @@ type Driver interface {
 	Delete(o Object) error
 }
 
+// DriverWithTemporaryURL has to be implemented by drivers able to sign temporary urls, so clients
+// can download objects directly without streaming them through the API
+// - Openstack / Swift with TempURL
+// - Filesystem with an url served by the API
+// - S3 / Minio with presigned urls
+type DriverWithTemporaryURL interface {
+	FetchURL(o Object, filename string, expires time.Time) (string, error)
+}
+
 // Initialize setup wanted ObjectStore driver
 func Initialize(c context.Context, cfg Config) error {
 	var err error
@@ -119,6 +161,7 @@ const (
 	Openstack Kind = iota
 	Filesystem
 	Swift
+	S3
 )
 
 //TODO Use github.com/graymeta/stow
@@ -133,6 +176,7 @@ type Config struct {
 type ConfigOptions struct {
 	Openstack  ConfigOptionsOpenstack
 	Filesystem ConfigOptionsFilesystem
+	S3         ConfigOptionsS3
 }
 
 // ConfigOptionsOpenstack is used by ConfigOptions
@@ -143,11 +187,30 @@ type ConfigOptionsOpenstack struct {
 	Tenant          string
 	Region          string
 	ContainerPrefix string
+	// TempURLKey is the X-Account-Meta-Temp-URL-Key of the account. If empty, temporary urls are disabled
+	TempURLKey string
 }
 
 // ConfigOptionsFilesystem is used by ConfigOptions
 type ConfigOptionsFilesystem struct {
 	Basedir string
+	// APIURL and SignKey are used to sign temporary urls served by the API. If empty, temporary urls are disabled
+	APIURL  string
+	SignKey string
+}
+
+// ConfigOptionsS3 is used by ConfigOptions
+type ConfigOptionsS3 struct {
+	// Endpoint is the S3 API URL. Leave it empty for AWS, set it for Minio or any S3 compatible storage
+	Endpoint        string
+	Region          string
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	BucketName      string
+	Prefix          string
+	// PartSize is the size in bytes of each part of a multipart upload (default 16MB, minimum 5MB)
+	PartSize int64
 }
 
 // New initialise a new ArtifactStorage
@@ -159,9 +222,12 @@ func New(c context.Context, cfg Config) (Driver, error) {
 			cfg.Options.Openstack.Password,
 			cfg.Options.Openstack.Tenant,
 			cfg.Options.Openstack.Region,
-			cfg.Options.Openstack.ContainerPrefix)
+			cfg.Options.Openstack.ContainerPrefix,
+			cfg.Options.Openstack.TempURLKey)
 	case Filesystem:
-		return NewFilesystemStore(cfg.Options.Filesystem.Basedir)
+		return NewFilesystemStore(cfg.Options.Filesystem.Basedir, cfg.Options.Filesystem.APIURL, cfg.Options.Filesystem.SignKey)
+	case S3:
+		return NewS3Store(c, cfg.Options.S3)
 	default:
 		return nil, fmt.Errorf("Invalid flag --artifact-mode")
 	}
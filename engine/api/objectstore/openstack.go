@@ -2,11 +2,16 @@ package objectstore
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net/url"
 	"strings"
+	"time"
 
+	"github.com/ovh/cds/sdk"
 	"github.com/ovh/cds/sdk/log"
 )
 
@@ -20,10 +25,11 @@ type OpenstackStore struct {
 	token           *Token
 	endpoint        string
 	containerprefix string
+	tempURLKey      string
 }
 
 // NewOpenstackStore create a new ObjectStore with openstack driver and check configuration
-func NewOpenstackStore(c context.Context, address, user, password, tenant, region, containerprefix string) (*OpenstackStore, error) {
+func NewOpenstackStore(c context.Context, address, user, password, tenant, region, containerprefix, tempURLKey string) (*OpenstackStore, error) {
 	log.Info("Objectstore> Initialize Swift(Openstack) driver on address: %s, tenant: %s, region: %s, prefix: %s", address, tenant, region, containerprefix)
 	if address == "" {
 		return nil, fmt.Errorf("artifact storage is openstack, but flag --artifact_address is not provided")
@@ -48,6 +54,7 @@ func NewOpenstackStore(c context.Context, address, user, password, tenant, regio
 		tenant:          tenant,
 		region:          region,
 		containerprefix: containerprefix,
+		tempURLKey:      tempURLKey,
 	}
 
 	var err error
@@ -123,3 +130,31 @@ func (ops *OpenstackStore) escape(container, object string) (string, string) {
 	object = strings.Replace(object, "/", "-", -1)
 	return container, object
 }
+
+// FetchURL returns a Swift TempURL to download the object as filename. The account must have tempURLKey as X-Account-Meta-Temp-URL-Key
+func (ops *OpenstackStore) FetchURL(o Object, filename string, expires time.Time) (string, error) {
+	if ops.tempURLKey == "" {
+		return "", sdk.ErrNotImplemented
+	}
+
+	// Same container and object names as Store, so that the signed path is the path of the stored object
+	container := ops.containerprefix + o.GetPath()
+	object := o.GetName()
+
+	u, err := url.Parse(fmt.Sprintf("%s/%s/%s", ops.endpoint, container, object))
+	if err != nil {
+		return "", err
+	}
+
+	// See https://docs.openstack.org/swift/latest/api/temporary_url_middleware.html
+	mac := hmac.New(sha1.New, []byte(ops.tempURLKey))
+	fmt.Fprintf(mac, "GET\n%d\n%s", expires.Unix(), u.Path)
+
+	q := url.Values{}
+	q.Set("temp_url_sig", hex.EncodeToString(mac.Sum(nil)))
+	q.Set("temp_url_expires", fmt.Sprintf("%d", expires.Unix()))
+	q.Set("filename", filename)
+	u.RawQuery = q.Encode()
+
+	return u.String(), nil
+}
@@ -0,0 +1,45 @@
+package objectstore
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ovh/cds/sdk"
+)
+
+func TestOpenstackStoreFetchURL(t *testing.T) {
+	ops := &OpenstackStore{
+		endpoint:        "https://swift.local/v1/AUTH_tenant",
+		containerprefix: "cds-",
+		tempURLKey:      "mytempurlkey",
+	}
+
+	art := &sdk.WorkflowNodeRunArtifact{Name: "my-artifact.tar.gz", SHA512sum: "abcdef"}
+	expires := time.Now().Add(time.Minute)
+	tmpURL, err := ops.FetchURL(art, art.Name, expires)
+	assert.NoError(t, err)
+
+	u, err := url.Parse(tmpURL)
+	assert.NoError(t, err)
+	assert.Equal(t, "/v1/AUTH_tenant/cds-"+sdk.WorkflowArtifactObjectPath+"/abcdef", u.Path)
+
+	q := u.Query()
+	assert.Equal(t, "my-artifact.tar.gz", q.Get("filename"))
+	assert.Equal(t, fmt.Sprintf("%d", expires.Unix()), q.Get("temp_url_expires"))
+
+	mac := hmac.New(sha1.New, []byte("mytempurlkey"))
+	fmt.Fprintf(mac, "GET\n%d\n%s", expires.Unix(), u.Path)
+	assert.Equal(t, hex.EncodeToString(mac.Sum(nil)), q.Get("temp_url_sig"))
+
+	// Temporary urls are disabled without key
+	ops.tempURLKey = ""
+	_, err = ops.FetchURL(art, art.Name, expires)
+	assert.Equal(t, sdk.ErrNotImplemented, err)
+}
@@ -0,0 +1,194 @@
+package objectstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/ovh/cds/sdk/log"
+)
+
+// defaultS3PartSize is the size of each part sent with multipart upload. Objects smaller
+// than this are sent with a single PUT request. S3 requires at least 5MB per part.
+const defaultS3PartSize = 16 * 1024 * 1024
+
+const minS3PartSize = 5 * 1024 * 1024
+
+// S3Store implements ObjectStore interface with an AWS S3 (or S3 compatible, ie. Minio, Ceph RGW) implementation
+type S3Store struct {
+	endpoint        string
+	region          string
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+	bucket          string
+	prefix          string
+	partSize        int64
+	client          *http.Client
+}
+
+// NewS3Store create a new ObjectStore with S3 driver and check configuration
+func NewS3Store(c context.Context, cfg ConfigOptionsS3) (*S3Store, error) {
+	log.Info("Objectstore> Initialize S3 driver on endpoint: %s, region: %s, bucket: %s, prefix: %s", cfg.Endpoint, cfg.Region, cfg.BucketName, cfg.Prefix)
+	if cfg.BucketName == "" {
+		return nil, fmt.Errorf("artifact storage is s3, but flag --artifact_s3_bucket is not provided")
+	}
+
+	if cfg.AccessKeyID == "" {
+		return nil, fmt.Errorf("artifact storage is s3, but flag --artifact_s3_access_key_id is not provided")
+	}
+
+	if cfg.SecretAccessKey == "" {
+		return nil, fmt.Errorf("artifact storage is s3, but flag --artifact_s3_secret_access_key is not provided")
+	}
+
+	region := cfg.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+
+	endpoint := strings.TrimSuffix(cfg.Endpoint, "/")
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+
+	partSize := cfg.PartSize
+	if partSize == 0 {
+		partSize = defaultS3PartSize
+	}
+	if partSize < minS3PartSize {
+		return nil, fmt.Errorf("artifact storage is s3, but part size must be at least %d bytes", minS3PartSize)
+	}
+
+	s3 := &S3Store{
+		endpoint:        endpoint,
+		region:          region,
+		accessKeyID:     cfg.AccessKeyID,
+		secretAccessKey: cfg.SecretAccessKey,
+		sessionToken:    cfg.SessionToken,
+		bucket:          cfg.BucketName,
+		prefix:          strings.Trim(cfg.Prefix, "/"),
+		partSize:        partSize,
+		client:          http.DefaultClient,
+	}
+
+	// Create bucket if it doesn't exist
+	exists, err := s3.headBucket()
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		log.Info("Objectstore> Creating S3 bucket %s", s3.bucket)
+		if err := s3.createBucket(); err != nil {
+			return nil, err
+		}
+	}
+
+	return s3, nil
+}
+
+//Status return S3 storage status
+func (s3 *S3Store) Status() string {
+	exists, err := s3.headBucket()
+	if err != nil {
+		return "S3 KO (" + err.Error() + ")"
+	}
+	if !exists {
+		return "S3 KO (bucket " + s3.bucket + " not found)"
+	}
+	return "S3 OK"
+}
+
+// Store stores in S3. Objects bigger than the part size are sent with multipart upload
+func (s3 *S3Store) Store(o Object, data io.ReadCloser) (string, error) {
+	defer data.Close()
+	key := s3.key(o)
+
+	log.Debug("S3Store> Storing /%s/%s\n", s3.bucket, key)
+
+	first, err := readPart(data, s3.partSize)
+	if err != nil {
+		return "", err
+	}
+
+	// The whole object fits in one part, no need for multipart
+	if int64(len(first)) < s3.partSize {
+		if err := s3.putObject(key, first); err != nil {
+			log.Warning("S3Store.Store> Cannot put object: %s\n", err)
+			return "", err
+		}
+		return s3.bucket + "/" + key, nil
+	}
+
+	if err := s3.multipartUpload(key, first, data); err != nil {
+		log.Warning("S3Store.Store> Cannot upload object: %s\n", err)
+		return "", err
+	}
+
+	return s3.bucket + "/" + key, nil
+}
+
+// Fetch lookup on S3 to fetch data
+func (s3 *S3Store) Fetch(o Object) (io.ReadCloser, error) {
+	key := s3.key(o)
+	log.Debug("S3Store> Fetching /%s/%s\n", s3.bucket, key)
+	return s3.getObject(key)
+}
+
+// Delete should delete on S3
+func (s3 *S3Store) Delete(o Object) error {
+	return s3.deleteObject(s3.key(o))
+}
+
+// FetchURL returns a presigned url to download the object as filename
+func (s3 *S3Store) FetchURL(o Object, filename string, expires time.Time) (string, error) {
+	return s3.presign("GET", s3.key(o), filename, time.Now().UTC(), expires)
+}
+
+func (s3 *S3Store) key(o Object) string {
+	return strings.TrimPrefix(path.Join(s3.prefix, o.GetPath(), o.GetName()), "/")
+}
+
+func (s3 *S3Store) multipartUpload(key string, first []byte, data io.Reader) error {
+	uploadID, err := s3.initiateMultipartUpload(key)
+	if err != nil {
+		return err
+	}
+
+	parts := []s3CompletedPart{}
+	buf := first
+	for partNumber := 1; len(buf) > 0; partNumber++ {
+		etag, err := s3.uploadPart(key, uploadID, partNumber, buf)
+		if err != nil {
+			s3.abortMultipartUpload(key, uploadID)
+			return err
+		}
+		parts = append(parts, s3CompletedPart{PartNumber: partNumber, ETag: etag})
+
+		buf, err = readPart(data, s3.partSize)
+		if err != nil {
+			s3.abortMultipartUpload(key, uploadID)
+			return err
+		}
+	}
+
+	if err := s3.completeMultipartUpload(key, uploadID, parts); err != nil {
+		s3.abortMultipartUpload(key, uploadID)
+		return err
+	}
+	return nil
+}
+
+// readPart reads up to size bytes from r. It returns a shorter slice only when r is exhausted
+func readPart(r io.Reader, size int64) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	if _, err := io.CopyN(buf, r, size); err != nil && err != io.EOF {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
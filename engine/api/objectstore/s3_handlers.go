@@ -0,0 +1,381 @@
+package objectstore
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//////////// S3 HANDLERS //////////
+
+const (
+	s3Algorithm     = "AWS4-HMAC-SHA256"
+	s3Service       = "s3"
+	s3DateFormat    = "20060102"
+	s3TimeFormat    = "20060102T150405Z"
+	s3EmptyPayload  = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	s3DefaultRegion = "us-east-1"
+)
+
+type s3InitiateMultipartUploadResult struct {
+	XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+	Bucket   string   `xml:"Bucket"`
+	Key      string   `xml:"Key"`
+	UploadID string   `xml:"UploadId"`
+}
+
+type s3CompletedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type s3CompleteMultipartUpload struct {
+	XMLName xml.Name          `xml:"CompleteMultipartUpload"`
+	Parts   []s3CompletedPart `xml:"Part"`
+}
+
+type s3CreateBucketConfiguration struct {
+	XMLName            xml.Name `xml:"CreateBucketConfiguration"`
+	LocationConstraint string   `xml:"LocationConstraint"`
+}
+
+/*<Error><Code>NoSuchKey</Code><Message>The resource you requested does not exist</Message></Error>*/
+type s3Error struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+func (s3 *S3Store) headBucket() (bool, error) {
+	resp, err := s3.do("HEAD", "", nil, nil)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return false, nil
+	}
+	if resp.StatusCode >= 400 {
+		return false, fmt.Errorf("%s", resp.Status)
+	}
+	return true, nil
+}
+
+func (s3 *S3Store) createBucket() error {
+	var body []byte
+	if s3.region != s3DefaultRegion {
+		var err error
+		body, err = xml.Marshal(s3CreateBucketConfiguration{LocationConstraint: s3.region})
+		if err != nil {
+			return err
+		}
+	}
+
+	resp, err := s3.do("PUT", "", nil, body)
+	if err != nil {
+		return err
+	}
+	return checkS3Response(resp)
+}
+
+func (s3 *S3Store) putObject(key string, data []byte) error {
+	resp, err := s3.do("PUT", key, nil, data)
+	if err != nil {
+		return err
+	}
+	return checkS3Response(resp)
+}
+
+func (s3 *S3Store) getObject(key string) (io.ReadCloser, error) {
+	resp, err := s3.do("GET", key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 400 {
+		return nil, checkS3Response(resp)
+	}
+	return resp.Body, nil
+}
+
+func (s3 *S3Store) deleteObject(key string) error {
+	resp, err := s3.do("DELETE", key, nil, nil)
+	if err != nil {
+		return err
+	}
+	return checkS3Response(resp)
+}
+
+func (s3 *S3Store) initiateMultipartUpload(key string) (string, error) {
+	resp, err := s3.do("POST", key, url.Values{"uploads": {""}}, nil)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	rbody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("cannot read body")
+	}
+	if resp.StatusCode >= 400 {
+		return "", unmarshalS3Error(rbody, resp.Status)
+	}
+
+	var res s3InitiateMultipartUploadResult
+	if err := xml.Unmarshal(rbody, &res); err != nil {
+		return "", err
+	}
+	if res.UploadID == "" {
+		return "", fmt.Errorf("s3 did not return an upload id")
+	}
+	return res.UploadID, nil
+}
+
+func (s3 *S3Store) uploadPart(key, uploadID string, partNumber int, data []byte) (string, error) {
+	query := url.Values{
+		"partNumber": {strconv.Itoa(partNumber)},
+		"uploadId":   {uploadID},
+	}
+	resp, err := s3.do("PUT", key, query, data)
+	if err != nil {
+		return "", err
+	}
+	etag := resp.Header.Get("ETag")
+	if err := checkS3Response(resp); err != nil {
+		return "", err
+	}
+	return etag, nil
+}
+
+func (s3 *S3Store) completeMultipartUpload(key, uploadID string, parts []s3CompletedPart) error {
+	body, err := xml.Marshal(s3CompleteMultipartUpload{Parts: parts})
+	if err != nil {
+		return err
+	}
+
+	resp, err := s3.do("POST", key, url.Values{"uploadId": {uploadID}}, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	// S3 may return 200 OK with an error in the body
+	rbody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("cannot read body")
+	}
+	if resp.StatusCode >= 400 || bytes.Contains(rbody, []byte("<Error>")) {
+		return unmarshalS3Error(rbody, resp.Status)
+	}
+	return nil
+}
+
+func (s3 *S3Store) abortMultipartUpload(key, uploadID string) {
+	resp, err := s3.do("DELETE", key, url.Values{"uploadId": {uploadID}}, nil)
+	if err != nil {
+		return
+	}
+	checkS3Response(resp)
+}
+
+// do sends a signed request on the path style url {endpoint}/{bucket}/{key}
+func (s3 *S3Store) do(method, key string, query url.Values, body []byte) (*http.Response, error) {
+	uri := s3.endpoint + "/" + s3URIEncode(s3.bucket, false)
+	if key != "" {
+		uri += "/" + s3URIEncode(key, false)
+	}
+	if len(query) > 0 {
+		uri += "?" + s3CanonicalQuery(query)
+	}
+
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, uri, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.ContentLength = int64(len(body))
+	}
+
+	s3.sign(req, body, time.Now().UTC())
+
+	return s3.client.Do(req)
+}
+
+// sign adds AWS Signature Version 4 headers on the request
+func (s3 *S3Store) sign(req *http.Request, body []byte, t time.Time) {
+	payloadHash := s3EmptyPayload
+	if len(body) > 0 {
+		h := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(h[:])
+	}
+
+	amzDate := t.Format(s3TimeFormat)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s3.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s3.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k := range req.Header {
+		lk := strings.ToLower(k)
+		if lk == "x-amz-date" || lk == "x-amz-content-sha256" || lk == "x-amz-security-token" {
+			headers[lk] = strings.TrimSpace(req.Header.Get(k))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders bytes.Buffer
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	signature := s3.signature(canonicalRequest, t)
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s", s3Algorithm, s3.accessKeyID, s3.scope(t), signedHeaders, signature))
+}
+
+// presign returns the url with query string authentication, valid until expires. If filename is set, the object is downloaded as filename
+func (s3 *S3Store) presign(method, key, filename string, t, expires time.Time) (string, error) {
+	u, err := url.Parse(s3.endpoint + "/" + s3URIEncode(s3.bucket, false) + "/" + s3URIEncode(key, false))
+	if err != nil {
+		return "", err
+	}
+
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", s3Algorithm)
+	query.Set("X-Amz-Credential", s3.accessKeyID+"/"+s3.scope(t))
+	query.Set("X-Amz-Date", t.Format(s3TimeFormat))
+	query.Set("X-Amz-Expires", strconv.FormatInt(int64(expires.Sub(t).Seconds()), 10))
+	query.Set("X-Amz-SignedHeaders", "host")
+	if s3.sessionToken != "" {
+		query.Set("X-Amz-Security-Token", s3.sessionToken)
+	}
+	if filename != "" {
+		query.Set("response-content-disposition", fmt.Sprintf("attachment; filename=\"%s\"", filename))
+	}
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		u.EscapedPath(),
+		s3CanonicalQuery(query),
+		"host:" + u.Host + "\n",
+		"host",
+		"UNSIGNED-PAYLOAD",
+	}, "\n")
+
+	u.RawQuery = s3CanonicalQuery(query) + "&X-Amz-Signature=" + s3.signature(canonicalRequest, t)
+	return u.String(), nil
+}
+
+func (s3 *S3Store) scope(t time.Time) string {
+	return strings.Join([]string{t.Format(s3DateFormat), s3.region, s3Service, "aws4_request"}, "/")
+}
+
+func (s3 *S3Store) signature(canonicalRequest string, t time.Time) string {
+	hashedRequest := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{s3Algorithm, t.Format(s3TimeFormat), s3.scope(t), hex.EncodeToString(hashedRequest[:])}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s3.secretAccessKey), t.Format(s3DateFormat))
+	key = hmacSHA256(key, s3.region)
+	key = hmacSHA256(key, s3Service)
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3CanonicalQuery returns the query string sorted by key, with each key and value URI encoded
+func s3CanonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	params := []string{}
+	for _, k := range keys {
+		values := query[k]
+		sort.Strings(values)
+		for _, v := range values {
+			params = append(params, s3URIEncode(k, true)+"="+s3URIEncode(v, true))
+		}
+	}
+	return strings.Join(params, "&")
+}
+
+// s3URIEncode encodes every byte except the unreserved characters as required by AWS Signature Version 4
+func s3URIEncode(s string, encodeSlash bool) string {
+	var buf bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9',
+			c == '-', c == '_', c == '.', c == '~':
+			buf.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			buf.WriteByte(c)
+		default:
+			fmt.Fprintf(&buf, "%%%02X", c)
+		}
+	}
+	return buf.String()
+}
+
+func checkS3Response(resp *http.Response) error {
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		rbody, err := ioutil.ReadAll(resp.Body)
+		if err != nil {
+			return fmt.Errorf("cannot read body")
+		}
+		return unmarshalS3Error(rbody, resp.Status)
+	}
+	return nil
+}
+
+func unmarshalS3Error(data []byte, status string) error {
+	s3err := s3Error{}
+	if err := xml.Unmarshal(data, &s3err); err != nil {
+		return fmt.Errorf("%s", status)
+	}
+
+	if s3err.Code == "" {
+		return fmt.Errorf("%s", status)
+	}
+
+	return fmt.Errorf("%s: %s", s3err.Code, s3err.Message)
+}
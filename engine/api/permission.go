@@ -1,6 +1,7 @@
 package main
 
 import (
+	"net/http"
 	"strconv"
 
 	"github.com/go-gorp/gorp"
@@ -77,6 +78,30 @@ func checkWorkerPermission(db gorp.SqlExecutor, rc *routerConfig, routeVar map[s
 	return true
 }
 
+// checkAccessTokenPermission restricts the permissions of a user authenticated with a personal access token to
+// the scopes of the token. The permissions of the user are checked anyway
+func checkAccessTokenPermission(rc *routerConfig, method string, routeVar map[string]string, t *sdk.AccessToken) bool {
+	if rc.needAdmin {
+		return false
+	}
+
+	key := routeVar["permProjectKey"]
+	if key == "" {
+		key = routeVar["key"]
+	}
+	if key != "" && t.HasScope(sdk.AccessTokenScopeAdminProject(key)) {
+		return true
+	}
+
+	switch {
+	case method == http.MethodGet:
+		return t.HasScope(sdk.AccessTokenScopeRead) || t.HasScope(sdk.AccessTokenScopeRun)
+	case method == http.MethodPost && rc.isExecution:
+		return t.HasScope(sdk.AccessTokenScopeRun)
+	}
+	return false
+}
+
 func checkPermission(routeVar map[string]string, c *businesscontext.Ctx, permission int) bool {
 	for _, g := range c.User.Groups {
 		if group.SharedInfraGroup != nil && g.Name == group.SharedInfraGroup.Name {
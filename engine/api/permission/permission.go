@@ -144,9 +144,14 @@ func AccessToEnvironment(envID int64, user *sdk.User, access int) bool {
 			return true
 		}
 		for _, eg := range g.EnvironmentGroups {
-			if eg.Environment.ID == envID && eg.Permission >= access {
-				return true
+			if eg.Environment.ID != envID || eg.Permission < access {
+				continue
+			}
+			// Running on an environment needs to deploy on it
+			if access == PermissionReadExecute && !eg.HasCapability(sdk.CapabilityDeploy) {
+				continue
 			}
+			return true
 		}
 	}
 	return false
@@ -4,6 +4,10 @@ import (
 	"reflect"
 	"testing"
 
+	"github.com/gorilla/mux"
+
+	"github.com/ovh/cds/engine/api/businesscontext"
+	"github.com/ovh/cds/engine/api/permission"
 	"github.com/ovh/cds/sdk"
 )
 
@@ -117,3 +121,123 @@ func Test_checkWorkerModelPermissionsByUser(t *testing.T) {
 		}
 	}
 }
+
+func Test_checkAccessTokenPermission(t *testing.T) {
+	read := &sdk.AccessToken{Scopes: []string{sdk.AccessTokenScopeRead}}
+	run := &sdk.AccessToken{Scopes: []string{sdk.AccessTokenScopeRun}}
+	admin := &sdk.AccessToken{Scopes: []string{sdk.AccessTokenScopeAdminProject("FOO")}}
+
+	tests := []struct {
+		name     string
+		rc       *routerConfig
+		method   string
+		routeVar map[string]string
+		token    *sdk.AccessToken
+		want     bool
+	}{
+		{name: "read token can GET", rc: &routerConfig{}, method: "GET", token: read, want: true},
+		{name: "read token can't POST", rc: &routerConfig{}, method: "POST", token: read, want: false},
+		{name: "read token can't execute", rc: &routerConfig{isExecution: true}, method: "POST", token: read, want: false},
+		{name: "run token can execute", rc: &routerConfig{isExecution: true}, method: "POST", token: run, want: true},
+		{name: "run token can't DELETE", rc: &routerConfig{}, method: "DELETE", token: run, want: false},
+		{name: "project admin token can update its project", rc: &routerConfig{}, method: "PUT", routeVar: map[string]string{"permProjectKey": "FOO"}, token: admin, want: true},
+		{name: "project admin token can't update another project", rc: &routerConfig{}, method: "PUT", routeVar: map[string]string{"key": "BAR"}, token: admin, want: false},
+		{name: "no token can use admin routes", rc: &routerConfig{needAdmin: true}, method: "GET", routeVar: map[string]string{"key": "FOO"}, token: admin, want: false},
+	}
+	for _, tt := range tests {
+		got := checkAccessTokenPermission(tt.rc, tt.method, tt.routeVar, tt.token)
+		if got != tt.want {
+			t.Errorf("%q. checkAccessTokenPermission() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// testRouteConfig returns the configuration of a route declared in main_routes.go
+func testRouteConfig(t *testing.T, prefix, uri string) *routerConfig {
+	router = newRouter(nil, mux.NewRouter(), prefix)
+	router.init()
+	rc, ok := mapRouterConfigs[prefix+uri]
+	if !ok {
+		t.Fatalf("route %s is not declared", uri)
+	}
+	return rc
+}
+
+func Test_checkAccessTokenPermissionOnWorkflowRuns(t *testing.T) {
+	rc := testRouteConfig(t, "/Test_checkAccessTokenPermissionOnWorkflowRuns", "/project/{permProjectKey}/workflows/{workflowName}/runs")
+	routeVar := map[string]string{"permProjectKey": "FOO", "workflowName": "bar"}
+
+	if !checkAccessTokenPermission(rc, "POST", routeVar, &sdk.AccessToken{Scopes: []string{sdk.AccessTokenScopeRun}}) {
+		t.Errorf("run token must be able to run a workflow")
+	}
+	if checkAccessTokenPermission(rc, "POST", routeVar, &sdk.AccessToken{Scopes: []string{sdk.AccessTokenScopeRead}}) {
+		t.Errorf("read token must not be able to run a workflow")
+	}
+}
+
+func Test_checkCapability(t *testing.T) {
+	u := &sdk.User{
+		Username: "foo",
+		Groups: []sdk.Group{{
+			Name:          "dev",
+			ProjectGroups: []sdk.ProjectGroup{{Project: sdk.Project{Key: "FOO"}, Permission: 7, Role: sdk.RoleMaintainer}},
+			ApplicationGroups: []sdk.ApplicationGroup{
+				{Application: sdk.Application{Name: "app", ProjectKey: "FOO"}, Permission: 7},
+			},
+			EnvironmentGroups: []sdk.EnvironmentGroup{
+				{Environment: sdk.Environment{Name: "staging", ProjectKey: "FOO"}, Permission: 5, Role: sdk.RoleDeployer},
+				{Environment: sdk.Environment{Name: "prod", ProjectKey: "FOO"}, Permission: 5, Role: sdk.RoleRunner},
+			},
+		}},
+	}
+	c := &businesscontext.Ctx{User: u}
+
+	tests := []struct {
+		name       string
+		routeVar   map[string]string
+		capability string
+		want       bool
+	}{
+		{name: "maintainer can edit the project", routeVar: map[string]string{"permProjectKey": "FOO"}, capability: sdk.CapabilityEdit, want: true},
+		{name: "maintainer can't edit the project variables", routeVar: map[string]string{"permProjectKey": "FOO"}, capability: sdk.CapabilityEditVariables, want: false},
+		{name: "maintainer can't manage the project groups", routeVar: map[string]string{"permProjectKey": "FOO"}, capability: sdk.CapabilityManage, want: false},
+		{name: "group without role is owner of the application", routeVar: map[string]string{"key": "FOO", "permApplicationName": "app"}, capability: sdk.CapabilityManage, want: true},
+		{name: "deployer can run on staging", routeVar: map[string]string{"key": "FOO", "permEnvironmentName": "staging"}, capability: sdk.CapabilityRun, want: true},
+		{name: "runner can't run on prod", routeVar: map[string]string{"key": "FOO", "permEnvironmentName": "prod"}, capability: sdk.CapabilityRun, want: false},
+		{name: "no capability on another project", routeVar: map[string]string{"permProjectKey": "BAR"}, capability: sdk.CapabilityRead, want: false},
+	}
+	for _, tt := range tests {
+		if got := checkCapability(tt.routeVar, c, tt.capability); got != tt.want {
+			t.Errorf("%q. checkCapability() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_checkCapabilityOnWorkflowRuns(t *testing.T) {
+	rc := testRouteConfig(t, "/Test_checkCapabilityOnWorkflowRuns", "/project/{permProjectKey}/workflows/{workflowName}/runs")
+	routeVar := map[string]string{"permProjectKey": "FOO", "workflowName": "bar"}
+
+	tests := []struct {
+		name       string
+		permission int
+		role       string
+		want       bool
+	}{
+		{name: "runner can run a workflow", permission: permission.PermissionReadExecute, role: sdk.RoleRunner, want: true},
+		{name: "viewer can't run a workflow", permission: permission.PermissionRead, role: sdk.RoleViewer, want: false},
+	}
+	for _, tt := range tests {
+		c := &businesscontext.Ctx{User: &sdk.User{
+			Username: "foo",
+			Groups: []sdk.Group{{
+				Name:          "dev",
+				ProjectGroups: []sdk.ProjectGroup{{Project: sdk.Project{Key: "FOO"}, Permission: tt.permission, Role: tt.role}},
+			}},
+		}}
+		got := checkPermission(routeVar, c, getPermissionByMethod("POST", rc.isExecution)) &&
+			checkCapability(routeVar, c, getCapabilityByMethod("POST", rc))
+		if got != tt.want {
+			t.Errorf("%q. checkPermission() && checkCapability() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
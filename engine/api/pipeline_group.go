@@ -24,6 +24,9 @@ func updateGroupRoleOnPipelineHandler(w http.ResponseWriter, r *http.Request, db
 	if err := UnmarshalBody(r, &groupPipeline); err != nil {
 		return sdk.WrapError(err, "updateGroupRoleOnPipelineHandler> cannot unmarshal request")
 	}
+	if err := groupPipeline.ApplyRole(); err != nil {
+		return sdk.WrapError(err, "updateGroupRoleOnPipelineHandler> invalid role %s", groupPipeline.Role)
+	}
 
 	if groupName != groupPipeline.Group.Name {
 		return sdk.ErrGroupNotFound
@@ -71,6 +74,9 @@ func updateGroupRoleOnPipelineHandler(w http.ResponseWriter, r *http.Request, db
 	if err := group.UpdateGroupRoleInPipeline(tx, p.ID, g.ID, groupPipeline.Permission); err != nil {
 		return sdk.WrapError(err, "updateGroupRoleOnPipelineHandler: Cannot add group %s in pipeline %s", g.Name, p.Name)
 	}
+	if err := group.UpdateGroupRoleNameInPipeline(tx, p.ID, g.ID, groupPipeline.Role); err != nil {
+		return sdk.WrapError(err, "updateGroupRoleOnPipelineHandler: Cannot set role of group %s in pipeline %s", g.Name, p.Name)
+	}
 
 	if err := pipeline.UpdatePipelineLastModified(tx, proj, p, c.User); err != nil {
 		return sdk.WrapError(err, "updateGroupRoleOnPipelineHandler: Cannot update pipeline last_modified date")
@@ -168,6 +174,9 @@ func addGroupInPipelineHandler(w http.ResponseWriter, r *http.Request, db *gorp.
 	if err := UnmarshalBody(r, &groupPermission); err != nil {
 		return err
 	}
+	if err := groupPermission.ApplyRole(); err != nil {
+		return sdk.WrapError(err, "addGroupInPipelineHandler> invalid role %s", groupPermission.Role)
+	}
 
 	p, err := pipeline.LoadPipeline(db, key, pipelineName, false)
 	if err != nil {
@@ -197,6 +206,9 @@ func addGroupInPipelineHandler(w http.ResponseWriter, r *http.Request, db *gorp.
 	if err := group.InsertGroupInPipeline(tx, p.ID, g.ID, groupPermission.Permission); err != nil {
 		return sdk.WrapError(err, "addGroupInPipeline: Cannot add group %s in pipeline %s", g.Name, p.Name)
 	}
+	if err := group.UpdateGroupRoleNameInPipeline(tx, p.ID, g.ID, groupPermission.Role); err != nil {
+		return sdk.WrapError(err, "addGroupInPipeline: Cannot set role of group %s in pipeline %s", g.Name, p.Name)
+	}
 
 	if err := pipeline.UpdatePipelineLastModified(tx, proj, p, c.User); err != nil {
 		return sdk.WrapError(err, "addGroupInPipeline: Cannot update pipeline last_modified date")
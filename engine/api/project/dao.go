@@ -51,7 +51,7 @@ func LoadAll(db gorp.SqlExecutor, u *sdk.User, opts ...LoadOptionFunc) ([]sdk.Pr
 // LoadPermissions loads all projects where group has access
 func LoadPermissions(db gorp.SqlExecutor, group *sdk.Group) error {
 	query := `
-		SELECT project.projectKey, project.name, project.last_modified, project_group.role
+		SELECT project.projectKey, project.name, project.last_modified, project_group.role, project_group.role_name
 		FROM project
 	 	JOIN project_group ON project_group.project_id = project.id
 	 	WHERE project_group.group_id = $1
@@ -66,8 +66,9 @@ func LoadPermissions(db gorp.SqlExecutor, group *sdk.Group) error {
 	for rows.Next() {
 		var projectKey, projectName string
 		var perm int
+		var role sql.NullString
 		var lastModified time.Time
-		if err := rows.Scan(&projectKey, &projectName, &lastModified, &perm); err != nil {
+		if err := rows.Scan(&projectKey, &projectName, &lastModified, &perm, &role); err != nil {
 			return err
 		}
 		group.ProjectGroups = append(group.ProjectGroups, sdk.ProjectGroup{
@@ -77,6 +78,7 @@ func LoadPermissions(db gorp.SqlExecutor, group *sdk.Group) error {
 				LastModified: lastModified,
 			},
 			Permission: perm,
+			Role:       role.String,
 		})
 	}
 	return nil
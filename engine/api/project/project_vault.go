@@ -0,0 +1,48 @@
+package project
+
+import (
+	"database/sql"
+	"strings"
+
+	"github.com/go-gorp/gorp"
+
+	"github.com/ovh/cds/sdk"
+)
+
+// LoadVault loads the vault configuration of a project. It returns sdk.ErrNotFound if the project has none
+func LoadVault(db gorp.SqlExecutor, projectID int64) (*sdk.ProjectVault, error) {
+	v := &sdk.ProjectVault{ProjectID: projectID}
+	if err := db.QueryRow("SELECT mount, policy FROM project_vault WHERE project_id = $1", projectID).Scan(&v.Mount, &v.Policy); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, sdk.ErrNotFound
+		}
+		return nil, sdk.WrapError(err, "LoadVault> Unable to load vault of project %d", projectID)
+	}
+	return v, nil
+}
+
+// UpsertVault inserts or updates the vault configuration of a project
+func UpsertVault(db gorp.SqlExecutor, v *sdk.ProjectVault) error {
+	v.Mount = strings.Trim(v.Mount, "/")
+	if v.Mount == "" {
+		return sdk.WrapError(sdk.ErrWrongRequest, "UpsertVault> Vault mount is mandatory")
+	}
+	if v.Policy == "" {
+		return sdk.WrapError(sdk.ErrWrongRequest, "UpsertVault> Vault policy is mandatory")
+	}
+
+	query := `INSERT INTO project_vault (project_id, mount, policy) VALUES ($1, $2, $3)
+	ON CONFLICT (project_id) DO UPDATE SET mount = $2, policy = $3`
+	if _, err := db.Exec(query, v.ProjectID, v.Mount, v.Policy); err != nil {
+		return sdk.WrapError(err, "UpsertVault> Unable to save vault of project %d", v.ProjectID)
+	}
+	return nil
+}
+
+// DeleteVault deletes the vault configuration of a project
+func DeleteVault(db gorp.SqlExecutor, projectID int64) error {
+	if _, err := db.Exec("DELETE FROM project_vault WHERE project_id = $1", projectID); err != nil {
+		return sdk.WrapError(err, "DeleteVault> Unable to delete vault of project %d", projectID)
+	}
+	return nil
+}
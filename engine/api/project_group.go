@@ -63,6 +63,9 @@ func updateGroupRoleOnProjectHandler(w http.ResponseWriter, r *http.Request, db
 	if err := UnmarshalBody(r, &groupProject); err != nil {
 		return sdk.WrapError(err, "updateGroupRoleOnProjectHandler> unable to unmarshal")
 	}
+	if err := groupProject.ApplyRole(); err != nil {
+		return sdk.WrapError(err, "updateGroupRoleOnProjectHandler> invalid role %s", groupProject.Role)
+	}
 
 	if groupName != groupProject.Group.Name {
 		return sdk.ErrGroupNotFound
@@ -107,6 +110,9 @@ func updateGroupRoleOnProjectHandler(w http.ResponseWriter, r *http.Request, db
 	if err := group.UpdateGroupRoleInProject(db, p.ID, g.ID, groupProject.Permission); err != nil {
 		return sdk.WrapError(err, "updateGroupRoleHandler: Cannot add group %s in project %s", g.Name, p.Name)
 	}
+	if err := group.UpdateGroupRoleNameInProject(tx, p.ID, g.ID, groupProject.Role); err != nil {
+		return sdk.WrapError(err, "updateGroupRoleHandler: Cannot set role of group %s in project %s", g.Name, p.Name)
+	}
 
 	if err := project.UpdateLastModified(tx, c.User, p); err != nil {
 		return sdk.WrapError(err, "updateGroupRoleHandler: Cannot update last modified date")
@@ -186,6 +192,9 @@ func addGroupInProject(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c
 	if err := UnmarshalBody(r, &groupProject); err != nil {
 		return sdk.WrapError(err, "addGroupInProject> unable to unmarshal")
 	}
+	if err := groupProject.ApplyRole(); err != nil {
+		return sdk.WrapError(err, "addGroupInProject> invalid role %s", groupProject.Role)
+	}
 
 	p, errl := project.Load(db, key, c.User)
 	if errl != nil {
@@ -214,6 +223,9 @@ func addGroupInProject(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c
 	if err := group.InsertGroupInProject(tx, p.ID, g.ID, groupProject.Permission); err != nil {
 		return sdk.WrapError(err, "AddGroupInProject: Cannot add group %s in project %s", g.Name, p.Name)
 	}
+	if err := group.UpdateGroupRoleNameInProject(tx, p.ID, g.ID, groupProject.Role); err != nil {
+		return sdk.WrapError(err, "AddGroupInProject: Cannot set role of group %s in project %s", g.Name, p.Name)
+	}
 
 	// apply on application
 	applications, errla := application.LoadAll(tx, p.Key, c.User)
@@ -231,6 +243,9 @@ func addGroupInProject(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c
 				if err := group.UpdateGroupRoleInApplication(tx, p.Key, app.Name, g.Name, groupProject.Permission); err != nil {
 					return sdk.WrapError(err, "AddGroupInProject: Cannot update group %s on application %s", g.Name, app.Name)
 				}
+				if err := group.UpdateGroupRoleNameInApplication(tx, app.ID, g.ID, groupProject.Role); err != nil {
+					return sdk.WrapError(err, "AddGroupInProject: Cannot set role of group %s on application %s", g.Name, app.Name)
+				}
 			} else if err := application.AddGroup(tx, p, &app, c.User, groupProject); err != nil {
 				return sdk.WrapError(err, "AddGroupInProject: Cannot insert group %s on application %s", g.Name, app.Name)
 			}
@@ -256,6 +271,9 @@ func addGroupInProject(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c
 			} else if err := group.InsertGroupInPipeline(tx, pip.ID, g.ID, groupProject.Permission); err != nil {
 				return sdk.WrapError(err, "AddGroupInProject: Cannot insert group %s on pipeline %s", g.Name, pip.Name)
 			}
+			if err := group.UpdateGroupRoleNameInPipeline(tx, pip.ID, g.ID, groupProject.Role); err != nil {
+				return sdk.WrapError(err, "AddGroupInProject: Cannot set role of group %s on pipeline %s", g.Name, pip.Name)
+			}
 		}
 	}
 
@@ -278,6 +296,9 @@ func addGroupInProject(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c
 			} else if err := group.InsertGroupInEnvironment(tx, env.ID, g.ID, groupProject.Permission); err != nil {
 				return sdk.WrapError(err, "AddGroupInProject: Cannot insert group %s on environment %s", g.Name, env.Name)
 			}
+			if err := group.UpdateGroupRoleNameInEnvironment(tx, env.ID, g.ID, groupProject.Role); err != nil {
+				return sdk.WrapError(err, "AddGroupInProject: Cannot set role of group %s on environment %s", g.Name, env.Name)
+			}
 		}
 	}
 
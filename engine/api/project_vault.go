@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/go-gorp/gorp"
+	"github.com/gorilla/mux"
+
+	"github.com/ovh/cds/engine/api/businesscontext"
+	"github.com/ovh/cds/engine/api/project"
+	"github.com/ovh/cds/sdk"
+)
+
+// getProjectVaultHandler returns the vault configuration of a project
+func getProjectVaultHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	key := mux.Vars(r)["permProjectKey"]
+
+	p, err := project.Load(db, key, c.User)
+	if err != nil {
+		return sdk.WrapError(err, "getProjectVaultHandler> Cannot load project %s", key)
+	}
+
+	v, err := project.LoadVault(db, p.ID)
+	if err != nil {
+		return sdk.WrapError(err, "getProjectVaultHandler> Cannot load vault of project %s", key)
+	}
+	return WriteJSON(w, r, v, http.StatusOK)
+}
+
+// putProjectVaultHandler sets the vault mount and policy used to resolve the vault variables of a project.
+// They give access to secrets outside of the project, so only CDS admins can set them
+func putProjectVaultHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	key := mux.Vars(r)["permProjectKey"]
+	if !c.User.Admin {
+		return sdk.WrapError(sdk.ErrForbidden, "putProjectVaultHandler> Only CDS admins can set the vault of project %s", key)
+	}
+
+	var v sdk.ProjectVault
+	if err := UnmarshalBody(r, &v); err != nil {
+		return err
+	}
+
+	p, err := project.Load(db, key, c.User)
+	if err != nil {
+		return sdk.WrapError(err, "putProjectVaultHandler> Cannot load project %s", key)
+	}
+
+	v.ProjectID = p.ID
+	if err := project.UpsertVault(db, &v); err != nil {
+		return sdk.WrapError(err, "putProjectVaultHandler> Cannot save vault of project %s", key)
+	}
+	return WriteJSON(w, r, v, http.StatusOK)
+}
+
+// deleteProjectVaultHandler deletes the vault configuration of a project
+func deleteProjectVaultHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	key := mux.Vars(r)["permProjectKey"]
+	if !c.User.Admin {
+		return sdk.WrapError(sdk.ErrForbidden, "deleteProjectVaultHandler> Only CDS admins can delete the vault of project %s", key)
+	}
+
+	p, err := project.Load(db, key, c.User)
+	if err != nil {
+		return sdk.WrapError(err, "deleteProjectVaultHandler> Cannot load project %s", key)
+	}
+
+	if err := project.DeleteVault(db, p.ID); err != nil {
+		return sdk.WrapError(err, "deleteProjectVaultHandler> Cannot delete vault of project %s", key)
+	}
+	return nil
+}
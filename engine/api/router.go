@@ -53,6 +53,7 @@ type routerConfig struct {
 	needUsernameOrAdmin bool
 	needHatchery        bool
 	needWorker          bool
+	capability          string
 }
 
 // ServeAbsoluteFile Serve file to download
@@ -162,7 +163,7 @@ func (r *Router) Handle(uri string, handlers ...RouterConfigParam) {
 		// Authorization
 		w.Header().Add("Access-Control-Allow-Origin", "*")
 		w.Header().Add("Access-Control-Allow-Methods", "GET,OPTIONS,PUT,POST,DELETE")
-		w.Header().Add("Access-Control-Allow-Headers", "Accept, Origin, Referer, User-Agent, Content-Type, Authorization, Session-Token, Last-Event-Id, If-Modified-Since, Content-Disposition")
+		w.Header().Add("Access-Control-Allow-Headers", "Accept, Origin, Referer, User-Agent, Content-Type, Authorization, Session-Token, Access-Token, Last-Event-Id, If-Modified-Since, Content-Disposition")
 		w.Header().Add("Access-Control-Expose-Headers", "Accept, Origin, Referer, User-Agent, Content-Type, Authorization, Session-Token, Last-Event-Id, ETag, Content-Disposition")
 		w.Header().Add("X-Api-Time", time.Now().Format(time.RFC3339))
 		w.Header().Add("ETag", fmt.Sprintf("%d", time.Now().Unix()))
@@ -273,6 +274,9 @@ func (r *Router) Handle(uri string, handlers ...RouterConfigParam) {
 			}
 			if !rc.needAdmin && !c.User.Admin {
 				permissionOk = checkPermission(mux.Vars(req), c, getPermissionByMethod(req.Method, rc.isExecution))
+				if permissionOk {
+					permissionOk = checkCapability(mux.Vars(req), c, getCapabilityByMethod(req.Method, rc))
+				}
 			}
 
 			// else case, just need auth
@@ -281,6 +285,10 @@ func (r *Router) Handle(uri string, handlers ...RouterConfigParam) {
 			}
 		}
 
+		if permissionOk && c.AccessToken != nil {
+			permissionOk = checkAccessTokenPermission(rc, req.Method, mux.Vars(req), c.AccessToken)
+		}
+
 		if !permissionOk {
 			WriteError(w, req, sdk.ErrForbidden)
 			return
@@ -426,6 +434,14 @@ func NeedUsernameOrAdmin(need bool) RouterConfigParam {
 	return f
 }
 
+// NeedCapability set the capability needed to POST, PUT or DELETE on the route, instead of edit
+func NeedCapability(capability string) RouterConfigParam {
+	f := func(rc *routerConfig) {
+		rc.capability = capability
+	}
+	return f
+}
+
 // NeedHatchery set the route for hatchery only
 func NeedHatchery() RouterConfigParam {
 	f := func(rc *routerConfig) {
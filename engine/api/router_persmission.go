@@ -2,16 +2,20 @@ package main
 
 import (
 	"fmt"
+	"net/http"
 	"strconv"
 
 	"github.com/go-gorp/gorp"
 
 	"github.com/ovh/cds/engine/api/application"
+	"github.com/ovh/cds/engine/api/businesscontext"
 	"github.com/ovh/cds/engine/api/cache"
 	"github.com/ovh/cds/engine/api/environment"
+	"github.com/ovh/cds/engine/api/group"
 	"github.com/ovh/cds/engine/api/pipeline"
 	"github.com/ovh/cds/engine/api/project"
 	"github.com/ovh/cds/sdk"
+	"github.com/ovh/cds/sdk/log"
 )
 
 // loadUserPermissions retrieves all group memberships
@@ -86,3 +90,95 @@ func loadGroupPermissions(db gorp.SqlExecutor, groupID int64) (*sdk.Group, error
 	}
 	return group, nil
 }
+
+// getCapabilityByMethod returns the capability needed on the resources of a route: reading for GET, running for
+// executions, and the capability of the route (editing by default) for the other methods
+func getCapabilityByMethod(method string, rc *routerConfig) string {
+	switch {
+	case method == http.MethodGet:
+		return sdk.CapabilityRead
+	case method == http.MethodPost && rc.isExecution:
+		return sdk.CapabilityRun
+	case rc.capability != "":
+		return rc.capability
+	}
+	return sdk.CapabilityEdit
+}
+
+// checkCapability checks that a group of the user has the capability on every resource of the route. Running
+// something on an environment needs to deploy on it. It refines checkPermission, which must be checked first
+func checkCapability(routeVar map[string]string, c *businesscontext.Ctx, capability string) bool {
+	for _, g := range c.User.Groups {
+		if group.SharedInfraGroup != nil && g.Name == group.SharedInfraGroup.Name {
+			return true
+		}
+	}
+
+	projectKey := routeVar["key"]
+	if k, ok := routeVar["permProjectKey"]; ok && !hasCapabilityOnProject(c.User, k, capability) {
+		log.Warning("Access denied. user %s has not capability %s on project %s", c.User.Username, capability, k)
+		return false
+	}
+	if a, ok := routeVar["permApplicationName"]; ok && !hasCapabilityOnApplication(c.User, projectKey, a, capability) {
+		log.Warning("Access denied. user %s has not capability %s on application %s", c.User.Username, capability, a)
+		return false
+	}
+	if p, ok := routeVar["permPipelineKey"]; ok && !hasCapabilityOnPipeline(c.User, projectKey, p, capability) {
+		log.Warning("Access denied. user %s has not capability %s on pipeline %s", c.User.Username, capability, p)
+		return false
+	}
+	if e, ok := routeVar["permEnvironmentName"]; ok {
+		if capability == sdk.CapabilityRun {
+			capability = sdk.CapabilityDeploy
+		}
+		if !hasCapabilityOnEnvironment(c.User, projectKey, e, capability) {
+			log.Warning("Access denied. user %s has not capability %s on environment %s", c.User.Username, capability, e)
+			return false
+		}
+	}
+	return true
+}
+
+func hasCapabilityOnProject(u *sdk.User, key, capability string) bool {
+	for _, g := range u.Groups {
+		for _, pg := range g.ProjectGroups {
+			if pg.Project.Key == key && pg.HasCapability(capability) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasCapabilityOnApplication(u *sdk.User, key, name, capability string) bool {
+	for _, g := range u.Groups {
+		for _, ag := range g.ApplicationGroups {
+			if ag.Application.ProjectKey == key && ag.Application.Name == name && ag.HasCapability(capability) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasCapabilityOnPipeline(u *sdk.User, key, name, capability string) bool {
+	for _, g := range u.Groups {
+		for _, pg := range g.PipelineGroups {
+			if pg.Pipeline.ProjectKey == key && pg.Pipeline.Name == name && pg.HasCapability(capability) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func hasCapabilityOnEnvironment(u *sdk.User, key, name, capability string) bool {
+	for _, g := range u.Groups {
+		for _, eg := range g.EnvironmentGroups {
+			if eg.Environment.ProjectKey == key && eg.Environment.Name == name && eg.HasCapability(capability) {
+				return true
+			}
+		}
+	}
+	return false
+}
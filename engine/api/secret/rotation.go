@@ -0,0 +1,260 @@
+package secret
+
+import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-gorp/gorp"
+
+	"github.com/ovh/cds/engine/api/cache"
+	"github.com/ovh/cds/sdk"
+	"github.com/ovh/cds/sdk/log"
+)
+
+// cipheredTables are the tables storing ciphered values in a cipher_value column: password and key variables,
+// and password pipeline parameters
+var cipheredTables = []string{
+	"project_variable",
+	"application_variable",
+	"environment_variable",
+	"pipeline_parameter",
+}
+
+// cipheredAuditTables are the variable audit tables: the values of the password and key variables are ciphered
+// and base64 encoded in the variable_before and variable_after JSON columns
+var cipheredAuditTables = []string{
+	"project_variable_audit",
+	"application_variable_audit",
+	"environment_variable_audit",
+}
+
+var (
+	rotationMutex sync.Mutex
+	rotationKey   = cache.Key("secret", "rotation")
+)
+
+// rotationRunningTTL expires the progress of a rotation whose API has been stopped
+const rotationRunningTTL = 300
+
+// RotationStatus returns the progress of the last re-encryption
+func RotationStatus() *sdk.SecretKeyRotation {
+	var r sdk.SecretKeyRotation
+	if !cache.Get(rotationKey, &r) {
+		return nil
+	}
+	return &r
+}
+
+// StartRotation re-encrypts in background all the ciphered values which are not ciphered with the current key
+func StartRotation(DBFunc func() *gorp.DbMap) (*sdk.SecretKeyRotation, error) {
+	rotationMutex.Lock()
+	defer rotationMutex.Unlock()
+
+	if keyID == "" {
+		return nil, sdk.WrapError(sdk.ErrSecretKeyFetchFailed, "StartRotation> Cipher keys are not versioned")
+	}
+	if r := RotationStatus(); r != nil && r.Running {
+		return nil, sdk.WrapError(sdk.ErrConflict, "StartRotation> A rotation to key %s is running", r.KeyID)
+	}
+
+	r := &sdk.SecretKeyRotation{
+		KeyID:   keyID,
+		Running: true,
+		Started: time.Now(),
+	}
+	cache.SetWithTTL(rotationKey, r, rotationRunningTTL)
+
+	status := *r
+	go rotate(DBFunc(), r)
+	return &status, nil
+}
+
+func rotate(db gorp.SqlExecutor, r *sdk.SecretKeyRotation) {
+	defer func() {
+		r.Running = false
+		r.Ended = time.Now()
+		cache.SetWithTTL(rotationKey, r, -1)
+		log.Info("secret.rotate> Rotation to key %s done: %d/%d values re-encrypted, %d errors", r.KeyID, r.Rotated, r.Total, r.Errors)
+	}()
+
+	for _, t := range cipheredTables {
+		n, err := db.SelectInt(fmt.Sprintf("select count(id) from %s where cipher_value is not null", t))
+		if err != nil {
+			r.Errors++
+			r.LastError = fmt.Sprintf("Unable to count values of %s: %s", t, err)
+			log.Error("secret.rotate> %s", r.LastError)
+			return
+		}
+		r.Total += int(n)
+	}
+	for _, t := range cipheredAuditTables {
+		n, err := db.SelectInt(fmt.Sprintf("select count(id) from %s where variable_before is not null or variable_after is not null", t))
+		if err != nil {
+			r.Errors++
+			r.LastError = fmt.Sprintf("Unable to count values of %s: %s", t, err)
+			log.Error("secret.rotate> %s", r.LastError)
+			return
+		}
+		r.Total += int(n)
+	}
+	cache.SetWithTTL(rotationKey, r, rotationRunningTTL)
+
+	for _, t := range cipheredTables {
+		if err := rotateTable(db, t, r); err != nil {
+			r.Errors++
+			r.LastError = err.Error()
+			log.Error("secret.rotate> %s", r.LastError)
+		}
+	}
+	for _, t := range cipheredAuditTables {
+		if err := rotateAuditTable(db, t, r); err != nil {
+			r.Errors++
+			r.LastError = err.Error()
+			log.Error("secret.rotate> %s", r.LastError)
+		}
+	}
+}
+
+// rotateTable re-encrypts the values of a table. A value updated since it has been loaded is skipped:
+// it has been ciphered with the current key by the update
+func rotateTable(db gorp.SqlExecutor, table string, r *sdk.SecretKeyRotation) error {
+	var ids []int64
+	if _, err := db.Select(&ids, fmt.Sprintf("select id from %s where cipher_value is not null order by id", table)); err != nil {
+		return fmt.Errorf("Unable to load values of %s: %s", table, err)
+	}
+
+	for i, id := range ids {
+		if i%100 == 0 {
+			cache.SetWithTTL(rotationKey, r, rotationRunningTTL)
+		}
+		r.Done++
+
+		var data []byte
+		if err := db.QueryRow(fmt.Sprintf("select cipher_value from %s where id = $1", table), id).Scan(&data); err != nil {
+			r.Errors++
+			r.LastError = fmt.Sprintf("Unable to load value %d of %s: %s", id, table, err)
+			log.Warning("secret.rotate> %s", r.LastError)
+			continue
+		}
+		if IsCipheredWithCurrentKey(data) {
+			continue
+		}
+
+		clear, err := Decrypt(data)
+		if err != nil {
+			r.Errors++
+			r.LastError = fmt.Sprintf("Unable to decrypt value %d of %s with key %s: %s", id, table, KeyID(data), err)
+			log.Warning("secret.rotate> %s", r.LastError)
+			continue
+		}
+		ciphered, err := Encrypt(clear)
+		if err != nil {
+			r.Errors++
+			r.LastError = fmt.Sprintf("Unable to encrypt value %d of %s: %s", id, table, err)
+			log.Warning("secret.rotate> %s", r.LastError)
+			continue
+		}
+		res, err := db.Exec(fmt.Sprintf("update %s set cipher_value = $1 where id = $2 and cipher_value = $3", table), ciphered, id, data)
+		if err != nil {
+			r.Errors++
+			r.LastError = fmt.Sprintf("Unable to update value %d of %s: %s", id, table, err)
+			log.Warning("secret.rotate> %s", r.LastError)
+			continue
+		}
+		if n, _ := res.RowsAffected(); n == 1 {
+			r.Rotated++
+		}
+	}
+	return nil
+}
+
+// rotateAuditTable re-encrypts the variables of an audit table. Like in rotateTable, an audit updated since it
+// has been loaded is skipped
+func rotateAuditTable(db gorp.SqlExecutor, table string, r *sdk.SecretKeyRotation) error {
+	var ids []int64
+	if _, err := db.Select(&ids, fmt.Sprintf("select id from %s where variable_before is not null or variable_after is not null order by id", table)); err != nil {
+		return fmt.Errorf("Unable to load values of %s: %s", table, err)
+	}
+
+	for i, id := range ids {
+		if i%100 == 0 {
+			cache.SetWithTTL(rotationKey, r, rotationRunningTTL)
+		}
+		r.Done++
+
+		var before, after sql.NullString
+		if err := db.QueryRow(fmt.Sprintf("select variable_before, variable_after from %s where id = $1", table), id).Scan(&before, &after); err != nil {
+			r.Errors++
+			r.LastError = fmt.Sprintf("Unable to load value %d of %s: %s", id, table, err)
+			log.Warning("secret.rotate> %s", r.LastError)
+			continue
+		}
+
+		newBefore, rotatedBefore, errB := rotateAuditVariable(before)
+		newAfter, rotatedAfter, errA := rotateAuditVariable(after)
+		if errB != nil || errA != nil {
+			r.Errors++
+			r.LastError = fmt.Sprintf("Unable to re-encrypt value %d of %s: %v %v", id, table, errB, errA)
+			log.Warning("secret.rotate> %s", r.LastError)
+			continue
+		}
+		if !rotatedBefore && !rotatedAfter {
+			continue
+		}
+
+		query := fmt.Sprintf(`update %s set variable_before = $1, variable_after = $2
+			where id = $3 and variable_before is not distinct from $4::jsonb and variable_after is not distinct from $5::jsonb`, table)
+		res, err := db.Exec(query, newBefore, newAfter, id, before, after)
+		if err != nil {
+			r.Errors++
+			r.LastError = fmt.Sprintf("Unable to update value %d of %s: %s", id, table, err)
+			log.Warning("secret.rotate> %s", r.LastError)
+			continue
+		}
+		if n, _ := res.RowsAffected(); n == 1 {
+			r.Rotated++
+		}
+	}
+	return nil
+}
+
+// rotateAuditVariable re-encrypts the value of an audited variable, and returns true if it has been re-encrypted
+func rotateAuditVariable(s sql.NullString) (sql.NullString, bool, error) {
+	if !s.Valid {
+		return s, false, nil
+	}
+	var v sdk.Variable
+	if err := json.Unmarshal([]byte(s.String), &v); err != nil {
+		return s, false, err
+	}
+	if !sdk.NeedPlaceholder(v.Type) {
+		return s, false, nil
+	}
+
+	data, err := base64.StdEncoding.DecodeString(v.Value)
+	if err != nil {
+		return s, false, err
+	}
+	if IsCipheredWithCurrentKey(data) {
+		return s, false, nil
+	}
+	clear, err := Decrypt(data)
+	if err != nil {
+		return s, false, fmt.Errorf("Unable to decrypt with key %s: %s", KeyID(data), err)
+	}
+	ciphered, err := Encrypt(clear)
+	if err != nil {
+		return s, false, err
+	}
+	v.Value = base64.StdEncoding.EncodeToString(ciphered)
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return s, false, err
+	}
+	return sql.NullString{String: string(b), Valid: true}, true, nil
+}
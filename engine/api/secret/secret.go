@@ -9,6 +9,7 @@ import (
 	"database/sql"
 	"fmt"
 	"io"
+	"regexp"
 	"strings"
 
 	"github.com/ovh/cds/sdk"
@@ -24,9 +25,20 @@ const (
 	ckeySize  = 32
 )
 
+// LegacyKeyID is the ID of the previous key used to decrypt the data ciphered before the keys were versioned
+const LegacyKeyID = "legacy"
+
 var (
 	key    []byte
 	prefix = "3DICC3It"
+	// keyID is the ID of the current key. Data is ciphered with the legacy prefix while it is empty
+	keyID string
+	// keys are the previous keys, by ID
+	keys = map[string][]byte{}
+	// versionedPrefix is followed by the ID of the key and by versionedSeparator
+	versionedPrefix    = "3DICC3Ik"
+	versionedSeparator = "$"
+	keyIDRegexp        = regexp.MustCompile(`^[a-zA-Z0-9_\-]+$`)
 )
 
 type Secret struct {
@@ -38,6 +50,80 @@ type Secret struct {
 // cipherKey is set from viper configuration
 func Init(cipherKey string) {
 	key = []byte(cipherKey)
+	keyID = ""
+	keys = map[string][]byte{}
+}
+
+// InitKeys secrets: versioned cipherKey and the previous keys which are still able to decrypt data.
+// The previous key registered as LegacyKeyID decrypts the data ciphered without key ID. The IDs of the
+// previous keys are case insensitive: the configuration lowercases them
+func InitKeys(currentKeyID, cipherKey string, previousKeys map[string]string) error {
+	if strings.EqualFold(currentKeyID, LegacyKeyID) || !keyIDRegexp.MatchString(currentKeyID) {
+		return fmt.Errorf("invalid key ID %q", currentKeyID)
+	}
+	if len(cipherKey) < ckeySize {
+		return fmt.Errorf("key %s must be at least %d bytes long", currentKeyID, ckeySize)
+	}
+	ks := make(map[string][]byte, len(previousKeys))
+	for id, k := range previousKeys {
+		if strings.EqualFold(id, currentKeyID) || !keyIDRegexp.MatchString(id) {
+			return fmt.Errorf("invalid previous key ID %q", id)
+		}
+		if _, ok := ks[strings.ToLower(id)]; ok {
+			return fmt.Errorf("duplicate previous key ID %q", id)
+		}
+		if len(k) < ckeySize {
+			return fmt.Errorf("previous key %s must be at least %d bytes long", id, ckeySize)
+		}
+		ks[strings.ToLower(id)] = []byte(k)
+	}
+	key = []byte(cipherKey)
+	keyID = currentKeyID
+	keys = ks
+	return nil
+}
+
+// CurrentKeyID returns the ID of the key used to cipher data, empty if the keys are not versioned
+func CurrentKeyID() string {
+	return keyID
+}
+
+// KeyID returns the ID of the key which ciphered data, LegacyKeyID if data has been ciphered without key ID
+// and an empty string if data is not ciphered
+func KeyID(data []byte) string {
+	s := string(data)
+	if strings.HasPrefix(s, prefix) {
+		return LegacyKeyID
+	}
+	if !strings.HasPrefix(s, versionedPrefix) {
+		return ""
+	}
+	s = strings.TrimPrefix(s, versionedPrefix)
+	i := strings.Index(s, versionedSeparator)
+	if i <= 0 {
+		return ""
+	}
+	return s[:i]
+}
+
+// IsCipheredWithCurrentKey returns true if data is ciphered with the current key, or if data is not ciphered
+func IsCipheredWithCurrentKey(data []byte) bool {
+	id := KeyID(data)
+	if id == "" {
+		return true
+	}
+	if keyID == "" {
+		return id == LegacyKeyID
+	}
+	return id == keyID
+}
+
+// keyByID returns the key registered with an ID
+func keyByID(id string) []byte {
+	if id == keyID || (id == LegacyKeyID && keyID == "") {
+		return key
+	}
+	return keys[strings.ToLower(id)]
 }
 
 // Create new secret client
@@ -74,7 +160,7 @@ func (secret *Secret) GetFromVault(s string) (string, error) {
 	return fmt.Sprintf("%v", value), nil
 }
 
-// Encrypt data using aes+hmac algorithm
+// Encrypt data using aes+hmac algorithm with the current key
 // Init() must be called before any encryption
 func Encrypt(data []byte) ([]byte, error) {
 	// Check key is ready
@@ -82,13 +168,24 @@ func Encrypt(data []byte) ([]byte, error) {
 		log.Error("Missing key, init failed?")
 		return nil, sdk.ErrSecretKeyFetchFailed
 	}
+	ct, err := encrypt(key, data)
+	if err != nil {
+		return nil, err
+	}
+	if keyID == "" {
+		return append([]byte(prefix), ct...), nil
+	}
+	return append([]byte(versionedPrefix+keyID+versionedSeparator), ct...), nil
+}
+
+func encrypt(k, data []byte) ([]byte, error) {
 	// generate nonce
 	nonce := make([]byte, nonceSize)
 	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
 		return nil, err
 	}
 	// init aes cipher
-	c, err := aes.NewCipher(key)
+	c, err := aes.NewCipher(k)
 	if err != nil {
 		return nil, err
 	}
@@ -97,28 +194,34 @@ func Encrypt(data []byte) ([]byte, error) {
 	ct := make([]byte, len(data))
 	ctr.XORKeyStream(ct, data)
 	// add hmac
-	h := hmac.New(sha256.New, key[ckeySize:])
+	h := hmac.New(sha256.New, k[ckeySize:])
 	ct = append(nonce, ct...)
 	h.Write(ct)
-	ct = h.Sum(ct)
-
-	return append([]byte(prefix), ct...), nil
+	return h.Sum(ct), nil
 }
 
-// Decrypt data using aes+hmac algorithm
+// Decrypt data using aes+hmac algorithm, with the key which ciphered it
 // Init() must be called before any decryption
 func Decrypt(data []byte) ([]byte, error) {
-
-	if !strings.HasPrefix(string(data), prefix) {
+	id := KeyID(data)
+	switch id {
+	case "":
 		return data, nil
+	case LegacyKeyID:
+		data = []byte(strings.TrimPrefix(string(data), prefix))
+	default:
+		data = []byte(strings.TrimPrefix(string(data), versionedPrefix+id+versionedSeparator))
 	}
-	data = []byte(strings.TrimPrefix(string(data), prefix))
 
-	if key == nil {
-		log.Error("Missing key, init failed?")
+	k := keyByID(id)
+	if k == nil {
+		log.Error("Missing key %s, init failed?", id)
 		return nil, sdk.ErrSecretKeyFetchFailed
 	}
+	return decrypt(k, data)
+}
 
+func decrypt(k, data []byte) ([]byte, error) {
 	if len(data) < (nonceSize + macSize) {
 		log.Error("cannot decrypt secret, got invalid data")
 		return nil, sdk.ErrInvalidSecretFormat
@@ -130,14 +233,14 @@ func Decrypt(data []byte) ([]byte, error) {
 	out := make([]byte, macStart-nonceSize)
 	data = data[:macStart]
 	// check hmac
-	h := hmac.New(sha256.New, key[ckeySize:])
+	h := hmac.New(sha256.New, k[ckeySize:])
 	h.Write(data)
 	mac := h.Sum(nil)
 	if !hmac.Equal(mac, tag) {
 		return nil, fmt.Errorf("invalid hmac")
 	}
 	// uncipher data
-	c, err := aes.NewCipher(key[:ckeySize])
+	c, err := aes.NewCipher(k[:ckeySize])
 	if err != nil {
 		return nil, err
 	}
@@ -197,11 +300,19 @@ func DecryptS(ptype string, val sql.NullString, data []byte, clear bool) (string
 }
 
 // EncryptS wrap Encrypt and:
+// - check the path of a vault variable
 // - return valid string if type is not a password
 // - cipher and returned ciphered value in a []byte if password
 func EncryptS(ptype string, value string) (sql.NullString, []byte, error) {
 	var n sql.NullString
 
+	// Vault variables only store the path of their secret
+	if ptype == sdk.VaultVariable {
+		if _, _, err := sdk.ParseVaultVariable(value); err != nil {
+			return n, nil, err
+		}
+	}
+
 	if !sdk.NeedPlaceholder(ptype) {
 		n.String = value
 		n.Valid = true
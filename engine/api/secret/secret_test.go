@@ -3,6 +3,9 @@ package secret
 import (
 	"bytes"
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
 	"testing"
 
 	"github.com/ovh/cds/sdk"
@@ -99,3 +102,138 @@ func TestDecryptS(t *testing.T) {
 	}
 
 }
+
+func TestEncryptVersionedKeys(t *testing.T) {
+	Init("78eKVxCGLm6gwoH9LAQ15ZD5AOABo1Xf")
+	data := []byte("Hello world !")
+
+	legacy, err := Encrypt(data)
+	if err != nil {
+		t.Fatalf("Encrypt failed: %s", err)
+	}
+	if KeyID(legacy) != LegacyKeyID {
+		t.Fatalf("Fail: Expected key %s, got '%s'", LegacyKeyID, KeyID(legacy))
+	}
+
+	if err := InitKeys("v1", "s8dUb3VHyT0ZydTxU8GdCvkLYC7bYhGs", map[string]string{LegacyKeyID: "78eKVxCGLm6gwoH9LAQ15ZD5AOABo1Xf"}); err != nil {
+		t.Fatalf("InitKeys failed: %s", err)
+	}
+	if IsCipheredWithCurrentKey(legacy) {
+		t.Fatalf("Legacy data should not be ciphered with the current key")
+	}
+
+	v1, err := Encrypt(data)
+	if err != nil {
+		t.Fatalf("Encrypt failed: %s", err)
+	}
+	if KeyID(v1) != "v1" || !IsCipheredWithCurrentKey(v1) {
+		t.Fatalf("Fail: Expected key v1, got '%s'", KeyID(v1))
+	}
+
+	if err := InitKeys("v2", "Tb5Q8dBWG3LgxnPc1ZrKeyA0fHsj7mVu", map[string]string{
+		LegacyKeyID: "78eKVxCGLm6gwoH9LAQ15ZD5AOABo1Xf",
+		"v1":        "s8dUb3VHyT0ZydTxU8GdCvkLYC7bYhGs",
+	}); err != nil {
+		t.Fatalf("InitKeys failed: %s", err)
+	}
+
+	for _, ct := range [][]byte{legacy, v1} {
+		clear, err := Decrypt(ct)
+		if err != nil {
+			t.Fatalf("Decrypt failed with key %s: %s", KeyID(ct), err)
+		}
+		if bytes.Compare(clear, data) != 0 {
+			t.Fatalf("Fail: Expected '%s', got '%s'", data, clear)
+		}
+	}
+}
+
+func TestDecryptUnknownKey(t *testing.T) {
+	if err := InitKeys("v1", "s8dUb3VHyT0ZydTxU8GdCvkLYC7bYhGs", nil); err != nil {
+		t.Fatalf("InitKeys failed: %s", err)
+	}
+	ct, err := Encrypt([]byte("Hello world !"))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %s", err)
+	}
+
+	if err := InitKeys("v2", "Tb5Q8dBWG3LgxnPc1ZrKeyA0fHsj7mVu", nil); err != nil {
+		t.Fatalf("InitKeys failed: %s", err)
+	}
+	if _, err := Decrypt(ct); err != sdk.ErrSecretKeyFetchFailed {
+		t.Fatalf("Decrypt should have failed with an unknown key: %v", err)
+	}
+}
+
+func TestInitKeysInvalidID(t *testing.T) {
+	for _, id := range []string{"", LegacyKeyID, "v$1"} {
+		if err := InitKeys(id, "s8dUb3VHyT0ZydTxU8GdCvkLYC7bYhGs", nil); err == nil {
+			t.Fatalf("InitKeys should have failed with key ID %q", id)
+		}
+	}
+	if err := InitKeys("v2", "s8dUb3VHyT0ZydTxU8GdCvkLYC7bYhGs", map[string]string{"v2": "Tb5Q8dBWG3LgxnPc1ZrKeyA0fHsj7mVu"}); err == nil {
+		t.Fatalf("InitKeys should have failed with a previous key using the current key ID")
+	}
+}
+
+func TestInitKeysShortKey(t *testing.T) {
+	if err := InitKeys("v1", "tooshort", nil); err == nil {
+		t.Fatalf("InitKeys should have failed with a short key")
+	}
+	if err := InitKeys("v2", "Tb5Q8dBWG3LgxnPc1ZrKeyA0fHsj7mVu", map[string]string{"v1": "tooshort"}); err == nil {
+		t.Fatalf("InitKeys should have failed with a short previous key")
+	}
+}
+
+func TestDecryptWithUppercasePreviousKeyID(t *testing.T) {
+	if err := InitKeys("V1", "s8dUb3VHyT0ZydTxU8GdCvkLYC7bYhGs", nil); err != nil {
+		t.Fatalf("InitKeys failed: %s", err)
+	}
+	ct, err := Encrypt([]byte("Hello world !"))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %s", err)
+	}
+
+	// The configuration lowercases the IDs of the previous keys
+	if err := InitKeys("v2", "Tb5Q8dBWG3LgxnPc1ZrKeyA0fHsj7mVu", map[string]string{"v1": "s8dUb3VHyT0ZydTxU8GdCvkLYC7bYhGs"}); err != nil {
+		t.Fatalf("InitKeys failed: %s", err)
+	}
+	clear, err := Decrypt(ct)
+	if err != nil {
+		t.Fatalf("Decrypt failed: %s", err)
+	}
+	if string(clear) != "Hello world !" {
+		t.Fatalf("Decrypt returned %q", clear)
+	}
+}
+
+func TestRotateAuditVariable(t *testing.T) {
+	if err := InitKeys("v1", "s8dUb3VHyT0ZydTxU8GdCvkLYC7bYhGs", nil); err != nil {
+		t.Fatalf("InitKeys failed: %s", err)
+	}
+	ct, err := Encrypt([]byte("Hello world !"))
+	if err != nil {
+		t.Fatalf("Encrypt failed: %s", err)
+	}
+	audit := fmt.Sprintf(`{"name":"foo","type":"password","value":"%s"}`, base64.StdEncoding.EncodeToString(ct))
+
+	if err := InitKeys("v2", "Tb5Q8dBWG3LgxnPc1ZrKeyA0fHsj7mVu", map[string]string{"v1": "s8dUb3VHyT0ZydTxU8GdCvkLYC7bYhGs"}); err != nil {
+		t.Fatalf("InitKeys failed: %s", err)
+	}
+	rotated, ok, err := rotateAuditVariable(sql.NullString{String: audit, Valid: true})
+	if err != nil || !ok {
+		t.Fatalf("rotateAuditVariable failed: %v", err)
+	}
+
+	var v sdk.Variable
+	if err := json.Unmarshal([]byte(rotated.String), &v); err != nil {
+		t.Fatalf("Invalid rotated audit: %s", err)
+	}
+	data, _ := base64.StdEncoding.DecodeString(v.Value)
+	if KeyID(data) != "v2" {
+		t.Fatalf("Audit must be ciphered with key v2, got %q", KeyID(data))
+	}
+	if _, ok, _ := rotateAuditVariable(rotated); ok {
+		t.Fatalf("Audit ciphered with the current key must not be re-encrypted")
+	}
+}
@@ -0,0 +1,118 @@
+package secret
+
+import (
+	"fmt"
+	"strings"
+
+	vault "github.com/hashicorp/vault/api"
+
+	"github.com/ovh/cds/sdk"
+)
+
+// Vault reads the secrets of the vault variables
+type Vault interface {
+	// Read returns a field of the secret stored at path, read with a token restricted to policy if not empty
+	Read(path, field, policy string) (string, error)
+}
+
+var vaultClient Vault
+
+// InitVault sets the vault which resolves the vault variables
+func InitVault(v Vault) {
+	vaultClient = v
+}
+
+// Read reads a field of a secret in vault. If policy is set, the secret is read with a single use child token
+// restricted to this policy
+func (secret *Secret) Read(path, field, policy string) (string, error) {
+	client := secret.Client
+	if policy != "" {
+		t, err := secret.Client.Auth().Token().Create(&vault.TokenCreateRequest{
+			Policies:    []string{policy},
+			TTL:         "60s",
+			NumUses:     1,
+			DisplayName: "cds",
+		})
+		if err != nil {
+			return "", fmt.Errorf("unable to create a token with policy %s: %s", policy, err)
+		}
+		if t == nil || t.Auth == nil {
+			return "", fmt.Errorf("unable to create a token with policy %s", policy)
+		}
+
+		client, err = vault.NewClient(vault.DefaultConfig())
+		if err != nil {
+			return "", err
+		}
+		client.SetAddress(secret.Client.Address())
+		client.SetToken(t.Auth.ClientToken)
+	}
+
+	s, err := client.Logical().Read(path)
+	if err != nil {
+		return "", err
+	}
+	if s == nil {
+		return "", sdk.ErrVaultSecretNotFound
+	}
+	value, ok := s.Data[field]
+	if !ok {
+		return "", sdk.ErrVaultSecretNotFound
+	}
+	return fmt.Sprintf("%v", value), nil
+}
+
+// VaultMock is an in memory vault, for tests and local development
+type VaultMock struct {
+	// Secrets are the fields of the secrets, by path
+	Secrets map[string]map[string]string
+	// Policies are the path prefixes readable with a policy. Without policy, all the secrets are readable
+	Policies map[string][]string
+}
+
+// Read returns a field of a secret of the mock
+func (m *VaultMock) Read(path, field, policy string) (string, error) {
+	if policy != "" {
+		var allowed bool
+		for _, prefix := range m.Policies[policy] {
+			if strings.HasPrefix(path, prefix) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return "", fmt.Errorf("permission denied on %s with policy %s", path, policy)
+		}
+	}
+
+	value, ok := m.Secrets[path][field]
+	if !ok {
+		return "", sdk.ErrVaultSecretNotFound
+	}
+	return value, nil
+}
+
+// DecryptVaultVariable resolves a vault variable: its value, a path relative to the vault mount of the project,
+// is replaced by the secret read in vault with the policy of the project, and it becomes a password variable.
+// A project without policy can't read anything, the token of the API is never used
+func DecryptVaultVariable(v *sdk.Variable, pv *sdk.ProjectVault) error {
+	if v.Type != sdk.VaultVariable {
+		return nil
+	}
+	if vaultClient == nil || pv == nil || pv.Policy == "" {
+		return sdk.ErrVaultNotConfigured
+	}
+
+	path, field, err := sdk.ParseVaultVariable(v.Value)
+	if err != nil {
+		return err
+	}
+
+	value, err := vaultClient.Read(pv.Mount+"/"+path, field, pv.Policy)
+	if err != nil {
+		return sdk.WrapError(err, "DecryptVaultVariable> Unable to read %s#%s", path, field)
+	}
+	v.Value = value
+	v.Type = sdk.SecretVariable
+	return nil
+}
@@ -0,0 +1,62 @@
+package secret
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ovh/cds/sdk"
+)
+
+func TestDecryptVaultVariable(t *testing.T) {
+	InitVault(&VaultMock{
+		Secrets: map[string]map[string]string{
+			"secret/cds/PROJ/db":    {"data": "s3cr3t", "user": "admin"},
+			"secret/cds/OTHER/db":   {"data": "other"},
+			"secret/cds/PROJ/empty": {},
+		},
+		Policies: map[string][]string{
+			"proj": {"secret/cds/PROJ/"},
+		},
+	})
+	defer InitVault(nil)
+
+	pv := &sdk.ProjectVault{Mount: "secret/cds/PROJ", Policy: "proj"}
+
+	v := sdk.Variable{Name: "db", Type: sdk.VaultVariable, Value: "db"}
+	assert.NoError(t, DecryptVaultVariable(&v, pv))
+	assert.Equal(t, "s3cr3t", v.Value)
+	assert.Equal(t, sdk.SecretVariable, v.Type)
+
+	v = sdk.Variable{Name: "user", Type: sdk.VaultVariable, Value: "db#user"}
+	assert.NoError(t, DecryptVaultVariable(&v, pv))
+	assert.Equal(t, "admin", v.Value)
+
+	v = sdk.Variable{Name: "empty", Type: sdk.VaultVariable, Value: "empty"}
+	assert.Error(t, DecryptVaultVariable(&v, pv))
+
+	// The path cannot escape the mount of the project
+	v = sdk.Variable{Name: "other", Type: sdk.VaultVariable, Value: "../OTHER/db"}
+	assert.Equal(t, sdk.ErrInvalidVaultVariable, DecryptVaultVariable(&v, pv))
+
+	// The policy of the project restricts the readable secrets
+	v = sdk.Variable{Name: "other", Type: sdk.VaultVariable, Value: "db"}
+	assert.Error(t, DecryptVaultVariable(&v, &sdk.ProjectVault{Mount: "secret/cds/OTHER", Policy: "proj"}))
+
+	v = sdk.Variable{Name: "db", Type: sdk.VaultVariable, Value: "db"}
+	assert.Equal(t, sdk.ErrVaultNotConfigured, DecryptVaultVariable(&v, nil))
+
+	// Without policy, the secrets would be read with the token of the API
+	v = sdk.Variable{Name: "db", Type: sdk.VaultVariable, Value: "db"}
+	assert.Equal(t, sdk.ErrVaultNotConfigured, DecryptVaultVariable(&v, &sdk.ProjectVault{Mount: "secret/cds/PROJ"}))
+}
+
+func TestEncryptSVaultVariable(t *testing.T) {
+	n, data, err := EncryptS(sdk.VaultVariable, "prod/db#password")
+	assert.NoError(t, err)
+	assert.Nil(t, data)
+	assert.Equal(t, "prod/db#password", n.String)
+
+	_, _, err = EncryptS(sdk.VaultVariable, "/prod/db")
+	assert.Equal(t, sdk.ErrInvalidVaultVariable, err)
+}
@@ -0,0 +1,127 @@
+package token
+
+import (
+	"crypto/sha512"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/go-gorp/gorp"
+	"github.com/lib/pq"
+
+	"github.com/ovh/cds/sdk"
+)
+
+// hashAccessToken returns the hash of a personal access token, which is the only thing stored in database
+func hashAccessToken(token string) string {
+	h := sha512.Sum512([]byte(token))
+	return hex.EncodeToString(h[:])
+}
+
+// InsertAccessToken generates a personal access token for a user and inserts it. The generated token is
+// set in t.Token, it can't be retrieved later
+func InsertAccessToken(db gorp.SqlExecutor, t *sdk.AccessToken) error {
+	if err := t.IsValid(); err != nil {
+		return err
+	}
+
+	tok, err := GenerateToken()
+	if err != nil {
+		return sdk.WrapError(err, "InsertAccessToken> Unable to generate token")
+	}
+	scopes, err := json.Marshal(t.Scopes)
+	if err != nil {
+		return sdk.WrapError(err, "InsertAccessToken> Unable to marshal scopes")
+	}
+
+	t.Created = time.Now()
+	t.LastUsed = nil
+	query := `INSERT INTO user_access_token (user_id, token_hash, description, scopes, created, expire_at)
+	VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+	if err := db.QueryRow(query, t.UserID, hashAccessToken(tok), t.Description, scopes, t.Created, t.ExpireAt).Scan(&t.ID); err != nil {
+		return sdk.WrapError(err, "InsertAccessToken> Unable to insert access token for user %d", t.UserID)
+	}
+	t.Token = tok
+	return nil
+}
+
+const accessTokenColumns = `id, user_id, description, scopes, created, expire_at, last_used`
+
+func scanAccessToken(s interface {
+	Scan(dest ...interface{}) error
+}) (*sdk.AccessToken, error) {
+	var t sdk.AccessToken
+	var scopes []byte
+	var expireAt, lastUsed pq.NullTime
+	if err := s.Scan(&t.ID, &t.UserID, &t.Description, &scopes, &t.Created, &expireAt, &lastUsed); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(scopes, &t.Scopes); err != nil {
+		return nil, err
+	}
+	if expireAt.Valid {
+		t.ExpireAt = &expireAt.Time
+	}
+	if lastUsed.Valid {
+		t.LastUsed = &lastUsed.Time
+	}
+	return &t, nil
+}
+
+// LoadAccessToken loads a personal access token from its value. It returns sdk.ErrInvalidToken if the token
+// does not exist or is expired
+func LoadAccessToken(db gorp.SqlExecutor, token string) (*sdk.AccessToken, error) {
+	query := `SELECT ` + accessTokenColumns + ` FROM user_access_token WHERE token_hash = $1`
+	t, err := scanAccessToken(db.QueryRow(query, hashAccessToken(token)))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, sdk.ErrInvalidToken
+		}
+		return nil, sdk.WrapError(err, "LoadAccessToken> Unable to load access token")
+	}
+	if t.IsExpired() {
+		return nil, sdk.ErrInvalidToken
+	}
+	return t, nil
+}
+
+// LoadAccessTokensByUser loads the personal access tokens of a user, without their value
+func LoadAccessTokensByUser(db gorp.SqlExecutor, userID int64) ([]sdk.AccessToken, error) {
+	query := `SELECT ` + accessTokenColumns + ` FROM user_access_token WHERE user_id = $1 ORDER BY created`
+	rows, err := db.Query(query, userID)
+	if err != nil {
+		return nil, sdk.WrapError(err, "LoadAccessTokensByUser> Unable to load access tokens of user %d", userID)
+	}
+	defer rows.Close()
+
+	tokens := []sdk.AccessToken{}
+	for rows.Next() {
+		t, err := scanAccessToken(rows)
+		if err != nil {
+			return nil, sdk.WrapError(err, "LoadAccessTokensByUser> Unable to scan access token")
+		}
+		tokens = append(tokens, *t)
+	}
+	return tokens, nil
+}
+
+// UpdateAccessTokenLastUsed sets the last time a personal access token was used
+func UpdateAccessTokenLastUsed(db gorp.SqlExecutor, id int64) error {
+	if _, err := db.Exec("UPDATE user_access_token SET last_used = $2 WHERE id = $1", id, time.Now()); err != nil {
+		return sdk.WrapError(err, "UpdateAccessTokenLastUsed> Unable to update access token %d", id)
+	}
+	return nil
+}
+
+// DeleteAccessToken revokes a personal access token of a user
+func DeleteAccessToken(db gorp.SqlExecutor, userID, id int64) error {
+	res, err := db.Exec("DELETE FROM user_access_token WHERE id = $1 AND user_id = $2", id, userID)
+	if err != nil {
+		return sdk.WrapError(err, "DeleteAccessToken> Unable to delete access token %d", id)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sdk.ErrNotFound
+	}
+	return nil
+}
@@ -379,3 +379,32 @@ func PersistentSessionTokenCleaner(c context.Context, DBFunc func() *gorp.DbMap)
 		}
 	}
 }
+
+// LoadUserIDByOIDCSubject returns the ID of the user linked to a subject of an OpenID Connect issuer
+func LoadUserIDByOIDCSubject(db gorp.SqlExecutor, issuer, subject string) (int64, error) {
+	query := `SELECT user_id FROM user_oidc WHERE issuer = $1 AND subject = $2`
+
+	var id int64
+	if err := db.QueryRow(query, issuer, subject).Scan(&id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+// IsLinkedToOIDCSubject checks if a user has already been linked to an OpenID Connect subject
+func IsLinkedToOIDCSubject(db gorp.SqlExecutor, userID int64) (bool, error) {
+	query := `SELECT count(user_id) FROM user_oidc WHERE user_id = $1`
+
+	var n int64
+	if err := db.QueryRow(query, userID).Scan(&n); err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
+
+// InsertOIDCSubject links a user to a subject of an OpenID Connect issuer
+func InsertOIDCSubject(db gorp.SqlExecutor, userID int64, issuer, subject string) error {
+	query := `INSERT INTO user_oidc (user_id, issuer, subject) VALUES ($1, $2, $3)`
+	_, err := db.Exec(query, userID, issuer, subject)
+	return err
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/go-gorp/gorp"
+	"github.com/gorilla/mux"
+
+	"github.com/ovh/cds/engine/api/businesscontext"
+	"github.com/ovh/cds/engine/api/token"
+	"github.com/ovh/cds/engine/api/user"
+	"github.com/ovh/cds/sdk"
+)
+
+// getUserAccessTokensHandler returns the personal access tokens of a user, without their value
+func getUserAccessTokensHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	username := mux.Vars(r)["username"]
+	u, err := user.LoadUserWithoutAuth(db, username)
+	if err != nil {
+		return sdk.WrapError(sdk.ErrNotFound, "getUserAccessTokensHandler> Cannot load user %s: %s", username, err)
+	}
+
+	tokens, err := token.LoadAccessTokensByUser(db, u.ID)
+	if err != nil {
+		return sdk.WrapError(err, "getUserAccessTokensHandler> Cannot load access tokens")
+	}
+	return WriteJSON(w, r, tokens, http.StatusOK)
+}
+
+// postUserAccessTokenHandler creates a personal access token for a user. Its value is only returned here
+func postUserAccessTokenHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	username := mux.Vars(r)["username"]
+	u, err := user.LoadUserWithoutAuth(db, username)
+	if err != nil {
+		return sdk.WrapError(sdk.ErrNotFound, "postUserAccessTokenHandler> Cannot load user %s: %s", username, err)
+	}
+
+	var t sdk.AccessToken
+	if err := UnmarshalBody(r, &t); err != nil {
+		return err
+	}
+	t.UserID = u.ID
+	if t.IsExpired() {
+		return sdk.WrapError(sdk.ErrWrongRequest, "postUserAccessTokenHandler> Expiration date is in the past")
+	}
+
+	if err := token.InsertAccessToken(db, &t); err != nil {
+		return sdk.WrapError(err, "postUserAccessTokenHandler> Cannot insert access token")
+	}
+	return WriteJSON(w, r, t, http.StatusCreated)
+}
+
+// deleteUserAccessTokenHandler revokes a personal access token of a user
+func deleteUserAccessTokenHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	username := mux.Vars(r)["username"]
+	id, err := requestVarInt(r, "id")
+	if err != nil {
+		return err
+	}
+
+	u, err := user.LoadUserWithoutAuth(db, username)
+	if err != nil {
+		return sdk.WrapError(sdk.ErrNotFound, "deleteUserAccessTokenHandler> Cannot load user %s: %s", username, err)
+	}
+
+	if err := token.DeleteAccessToken(db, u.ID, id); err != nil {
+		return sdk.WrapError(err, "deleteUserAccessTokenHandler> Cannot delete access token %d", id)
+	}
+	return WriteJSON(w, r, nil, http.StatusOK)
+}
@@ -68,6 +68,20 @@ func LoadByID(db gorp.SqlExecutor, id int64, u *sdk.User) (*sdk.Workflow, error)
 	return res, nil
 }
 
+// LoadByNodeID loads the workflow of a node for a given user (ie. checking permissions)
+func LoadByNodeID(db gorp.SqlExecutor, nodeID int64, u *sdk.User) (*sdk.Workflow, error) {
+	query := `
+		select workflow.* 
+		from workflow
+		join workflow_node on workflow_node.workflow_id = workflow.id
+		where workflow_node.id = $1`
+	res, err := load(db, u, query, nodeID)
+	if err != nil {
+		return nil, sdk.WrapError(err, "Load> Unable to load workflow of node %d", nodeID)
+	}
+	return res, nil
+}
+
 func load(db gorp.SqlExecutor, u *sdk.User, query string, args ...interface{}) (*sdk.Workflow, error) {
 	t0 := time.Now()
 	dbRes := Workflow{}
@@ -142,6 +156,10 @@ func Insert(db gorp.SqlExecutor, w *sdk.Workflow, u *sdk.User) error {
 		}
 	}
 
+	if err := insertVersion(db, w, u); err != nil {
+		return sdk.WrapError(err, "Insert> Unable to record version of workflow %d", w.ID)
+	}
+
 	return updateLastModified(db, w, u)
 }
 
@@ -158,6 +176,11 @@ func Update(db gorp.SqlExecutor, w *sdk.Workflow, oldWorkflow *sdk.Workflow, u *
 		}
 	}
 
+	// Keep the secrets of the hooks which have not been changed
+	if err := restoreHookSecrets(db, w, oldWorkflow.ID); err != nil {
+		return sdk.WrapError(err, "Update> unable to load hooks secrets of workflow(%d)", w.ID)
+	}
+
 	// Delete old Root Node
 	if oldWorkflow.Root != nil {
 		if _, err := db.Exec("update workflow set root_node_id = null where id = $1", w.ID); err != nil {
@@ -184,11 +207,16 @@ func Update(db gorp.SqlExecutor, w *sdk.Workflow, oldWorkflow *sdk.Workflow, u *
 	}
 
 	w.LastModified = time.Now()
+	w.Version = oldWorkflow.Version
 	dbw := Workflow(*w)
 	if _, err := db.Update(&dbw); err != nil {
 		return sdk.WrapError(err, "Update> Unable to update workflow")
 	}
 
+	if err := insertVersion(db, w, u); err != nil {
+		return sdk.WrapError(err, "Update> Unable to record version of workflow %d", w.ID)
+	}
+
 	return updateLastModified(db, w, u)
 }
 
@@ -238,6 +266,25 @@ func HasAccessTo(db gorp.SqlExecutor, w *sdk.Workflow, u *sdk.User) (bool, error
 	return true, nil
 }
 
+// checkNodeConditions checks the conditions of the hooks of a node, and of its triggers recursively
+func checkNodeConditions(n *sdk.WorkflowNode) error {
+	for _, h := range n.Hooks {
+		if err := sdk.WorkflowValidateConditions(h.Conditions); err != nil {
+			return fmt.Errorf("Invalid conditions on hook of node %s: %v", n.Name, err)
+		}
+	}
+	for i := range n.Triggers {
+		t := &n.Triggers[i]
+		if err := sdk.WorkflowValidateConditions(t.Conditions); err != nil {
+			return fmt.Errorf("Invalid conditions on trigger of node %s: %v", n.Name, err)
+		}
+		if err := checkNodeConditions(&t.WorkflowDestNode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // IsValid cheks workflow validity
 func IsValid(db gorp.SqlExecutor, w *sdk.Workflow, u *sdk.User) error {
 	//Check project is not empty
@@ -262,6 +309,24 @@ func IsValid(db gorp.SqlExecutor, w *sdk.Workflow, u *sdk.User) error {
 		}
 	}
 
+	//Check conditions of triggers and hooks
+	if w.Root != nil {
+		if err := checkNodeConditions(w.Root); err != nil {
+			return sdk.NewError(sdk.ErrWorkflowInvalid, err)
+		}
+	}
+	for i := range w.Joins {
+		for j := range w.Joins[i].Triggers {
+			t := &w.Joins[i].Triggers[j]
+			if err := sdk.WorkflowValidateConditions(t.Conditions); err != nil {
+				return sdk.NewError(sdk.ErrWorkflowInvalid, err)
+			}
+			if err := checkNodeConditions(&t.WorkflowDestNode); err != nil {
+				return sdk.NewError(sdk.ErrWorkflowInvalid, err)
+			}
+		}
+	}
+
 	//Load the project
 	proj, err := project.Load(db, w.ProjectKey, u, project.LoadOptions.WithApplications, project.LoadOptions.WithPipelines, project.LoadOptions.WithEnvironments)
 	if err != nil {
@@ -0,0 +1,118 @@
+package workflow
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/go-gorp/gorp"
+	"github.com/lib/pq"
+
+	"github.com/ovh/cds/sdk"
+)
+
+const approvalColumns = `id, workflow_run_id, workflow_node_trigger_id, workflow_node_run_id, workflow_dest_node_id, sub_num,
+	status, group_id, group_name, required, votes, created, expire`
+
+// insertRunApproval inserts the approval awaited by a trigger in a workflow run
+func insertRunApproval(db gorp.SqlExecutor, a *sdk.WorkflowRunApproval) error {
+	votes, err := json.Marshal(a.Votes)
+	if err != nil {
+		return sdk.WrapError(err, "insertRunApproval> Unable to marshal votes")
+	}
+	query := `INSERT INTO workflow_run_approval (workflow_run_id, workflow_node_trigger_id, workflow_node_run_id, workflow_dest_node_id, sub_num,
+		status, group_id, group_name, required, votes, created, expire)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id`
+	if err := db.QueryRow(query, a.WorkflowRunID, a.WorkflowNodeTriggerID, a.WorkflowNodeRunID, a.WorkflowDestNodeID, a.SubNumber,
+		a.Status, a.GroupID, a.GroupName, a.Required, votes, a.Created, pq.NullTime{Time: timeOrZero(a.Expire), Valid: a.Expire != nil}).Scan(&a.ID); err != nil {
+		return sdk.WrapError(err, "insertRunApproval> Unable to insert approval on run %d", a.WorkflowRunID)
+	}
+	return nil
+}
+
+// updateRunApproval updates the status and the votes of an approval
+func updateRunApproval(db gorp.SqlExecutor, a *sdk.WorkflowRunApproval) error {
+	votes, err := json.Marshal(a.Votes)
+	if err != nil {
+		return sdk.WrapError(err, "updateRunApproval> Unable to marshal votes")
+	}
+	if _, err := db.Exec("UPDATE workflow_run_approval SET status = $2, votes = $3 WHERE id = $1", a.ID, a.Status, votes); err != nil {
+		return sdk.WrapError(err, "updateRunApproval> Unable to update approval %d", a.ID)
+	}
+	return nil
+}
+
+// LoadRunApprovals loads all the approvals of a workflow run
+func LoadRunApprovals(db gorp.SqlExecutor, workflowRunID int64) ([]sdk.WorkflowRunApproval, error) {
+	query := "SELECT " + approvalColumns + " FROM workflow_run_approval WHERE workflow_run_id = $1 ORDER BY id"
+	return loadRunApprovals(db, query, workflowRunID)
+}
+
+// loadRunApproval loads the approval of a trigger for a source node run, it returns nil if there is none
+func loadRunApproval(db gorp.SqlExecutor, workflowRunID, triggerID, nodeRunID int64) (*sdk.WorkflowRunApproval, error) {
+	query := "SELECT " + approvalColumns + " FROM workflow_run_approval WHERE workflow_run_id = $1 AND workflow_node_trigger_id = $2 AND workflow_node_run_id = $3"
+	as, err := loadRunApprovals(db, query, workflowRunID, triggerID, nodeRunID)
+	if err != nil {
+		return nil, err
+	}
+	if len(as) == 0 {
+		return nil, nil
+	}
+	return &as[0], nil
+}
+
+// loadAndLockRunApproval loads and locks an approval of a workflow run
+func loadAndLockRunApproval(db gorp.SqlExecutor, workflowRunID, id int64) (*sdk.WorkflowRunApproval, error) {
+	query := "SELECT " + approvalColumns + " FROM workflow_run_approval WHERE workflow_run_id = $1 AND id = $2 FOR UPDATE NOWAIT"
+	as, err := loadRunApprovals(db, query, workflowRunID, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(as) == 0 {
+		return nil, sdk.WrapError(sdk.ErrWorkflowApprovalNotFound, "loadAndLockRunApproval> Approval %d not found on run %d", id, workflowRunID)
+	}
+	return &as[0], nil
+}
+
+// loadExpiredRunApprovals loads the waiting approvals which have expired
+func loadExpiredRunApprovals(db gorp.SqlExecutor, now time.Time) ([]sdk.WorkflowRunApproval, error) {
+	query := "SELECT " + approvalColumns + " FROM workflow_run_approval WHERE status = $1 AND expire < $2"
+	return loadRunApprovals(db, query, sdk.ApprovalStatusWaiting, now)
+}
+
+func loadRunApprovals(db gorp.SqlExecutor, query string, args ...interface{}) ([]sdk.WorkflowRunApproval, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, sdk.WrapError(err, "loadRunApprovals> Unable to load approvals")
+	}
+	defer rows.Close()
+
+	as := []sdk.WorkflowRunApproval{}
+	for rows.Next() {
+		var a sdk.WorkflowRunApproval
+		var votes sql.NullString
+		var expire pq.NullTime
+		if err := rows.Scan(&a.ID, &a.WorkflowRunID, &a.WorkflowNodeTriggerID, &a.WorkflowNodeRunID, &a.WorkflowDestNodeID, &a.SubNumber,
+			&a.Status, &a.GroupID, &a.GroupName, &a.Required, &votes, &a.Created, &expire); err != nil {
+			return nil, sdk.WrapError(err, "loadRunApprovals> Unable to scan approval")
+		}
+		a.Votes = []sdk.WorkflowRunApprovalVote{}
+		if votes.Valid {
+			if err := json.Unmarshal([]byte(votes.String), &a.Votes); err != nil {
+				return nil, sdk.WrapError(err, "loadRunApprovals> Unable to unmarshal votes of approval %d", a.ID)
+			}
+		}
+		if expire.Valid {
+			a.Expire = &expire.Time
+		}
+		as = append(as, a)
+	}
+	return as, nil
+}
+
+func timeOrZero(t *time.Time) time.Time {
+	if t == nil {
+		return time.Time{}
+	}
+	return *t
+}
@@ -1,9 +1,13 @@
 package workflow
 
 import (
+	"database/sql"
+
 	"github.com/go-gorp/gorp"
 
+	"github.com/ovh/cds/engine/api/objectstore"
 	"github.com/ovh/cds/sdk"
+	"github.com/ovh/cds/sdk/log"
 )
 
 // LoadArtifactByIDs Load artifact by workflow ID and artifact ID
@@ -45,3 +49,164 @@ func InsertArtifact(db gorp.SqlExecutor, a *sdk.WorkflowNodeRunArtifact) error {
 	a.ID = wArtifactDB.ID
 	return nil
 }
+
+// AcquireArtifactObject adds a reference on the stored object with the same sha512 digest than the artifact.
+// It returns false if there is no such object, then the artifact content has to be stored.
+func AcquireArtifactObject(db gorp.SqlExecutor, a *sdk.WorkflowNodeRunArtifact) (bool, error) {
+	if a.SHA512sum == "" {
+		return false, nil
+	}
+
+	var objectPath sql.NullString
+	query := "UPDATE workflow_artifact_object SET ref_count = ref_count + 1 WHERE sha512sum = $1 RETURNING object_path"
+	if err := db.QueryRow(query, a.SHA512sum).Scan(&objectPath); err != nil {
+		if err == sql.ErrNoRows {
+			return false, nil
+		}
+		return false, sdk.WrapError(err, "AcquireArtifactObject> Unable to reference object %s", a.SHA512sum)
+	}
+	a.ObjectPath = objectPath.String
+	return true, nil
+}
+
+// InsertArtifactObject references a newly stored object. If the object has been stored concurrently
+// by another artifact, the reference is added on the existing object
+func InsertArtifactObject(db gorp.SqlExecutor, a *sdk.WorkflowNodeRunArtifact) error {
+	if a.SHA512sum == "" {
+		return nil
+	}
+
+	query := `INSERT INTO workflow_artifact_object (sha512sum, object_path, size, ref_count) VALUES ($1, $2, $3, 1)
+	ON CONFLICT (sha512sum) DO UPDATE SET ref_count = workflow_artifact_object.ref_count + 1`
+	if _, err := db.Exec(query, a.SHA512sum, a.ObjectPath, a.Size); err != nil {
+		return sdk.WrapError(err, "InsertArtifactObject> Unable to insert object %s", a.SHA512sum)
+	}
+	return nil
+}
+
+// ReleaseArtifactObject deletes the stored object of a deleted artifact when no artifact references it anymore.
+// The reference is removed by a trigger on the deletion of the artifact, so that the cascade deletions of the
+// runs, workflows and projects release it too. It returns true when the object has to be deleted from the
+// objectstore, once the transaction is committed, so that a rollback does not lose it.
+func ReleaseArtifactObject(db gorp.SqlExecutor, a *sdk.WorkflowNodeRunArtifact) (bool, error) {
+	if a.SHA512sum == "" {
+		return true, nil
+	}
+
+	res, err := db.Exec("DELETE FROM workflow_artifact_object WHERE sha512sum = $1 AND ref_count <= 0", a.SHA512sum)
+	if err != nil {
+		return false, sdk.WrapError(err, "ReleaseArtifactObject> Unable to delete object %s", a.SHA512sum)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, sdk.WrapError(err, "ReleaseArtifactObject> Unable to delete object %s", a.SHA512sum)
+	}
+	return n > 0, nil
+}
+
+// deleteUnreferencedArtifactObjects locks and deletes the stored objects which are not referenced by any artifact,
+// ie. whose artifacts have been deleted with their run, workflow or project. It returns the objects to delete
+// from the objectstore once the transaction is committed
+func deleteUnreferencedArtifactObjects(db gorp.SqlExecutor) ([]sdk.WorkflowNodeRunArtifact, error) {
+	query := "SELECT sha512sum FROM workflow_artifact_object WHERE ref_count <= 0 FOR UPDATE SKIP LOCKED"
+	rows, err := db.Query(query)
+	if err != nil {
+		return nil, sdk.WrapError(err, "deleteUnreferencedArtifactObjects> Unable to load objects")
+	}
+	defer rows.Close()
+
+	objects := []sdk.WorkflowNodeRunArtifact{}
+	for rows.Next() {
+		var a sdk.WorkflowNodeRunArtifact
+		if err := rows.Scan(&a.SHA512sum); err != nil {
+			return nil, sdk.WrapError(err, "deleteUnreferencedArtifactObjects> Unable to scan object")
+		}
+		objects = append(objects, a)
+	}
+	rows.Close()
+
+	for i := range objects {
+		if _, err := db.Exec("DELETE FROM workflow_artifact_object WHERE sha512sum = $1", objects[i].SHA512sum); err != nil {
+			return nil, sdk.WrapError(err, "deleteUnreferencedArtifactObjects> Unable to delete object %s", objects[i].SHA512sum)
+		}
+	}
+	return objects, nil
+}
+
+// DeleteOrphanArtifactObject deletes from the objectstore the object of an artifact whose upload failed, unless
+// another artifact with the same sha512 digest references it
+func DeleteOrphanArtifactObject(db gorp.SqlExecutor, a *sdk.WorkflowNodeRunArtifact) error {
+	if a.SHA512sum != "" {
+		n, err := db.SelectInt("SELECT COUNT(1) FROM workflow_artifact_object WHERE sha512sum = $1", a.SHA512sum)
+		if err != nil {
+			return sdk.WrapError(err, "DeleteOrphanArtifactObject> Unable to check object %s", a.SHA512sum)
+		}
+		if n > 0 {
+			return nil
+		}
+	}
+	return objectstore.DeleteArtifact(a)
+}
+
+// copyArtifacts references on a restarted node run the artifacts of the jobs it keeps from the previous node run.
+// The artifacts of the jobs run again are not copied, they are uploaded again. The artifacts stored by digest
+// share their objects, which are kept until the last artifact is purged. The legacy artifacts are stored by node
+// run, so their content is copied
+func copyArtifacts(db gorp.SqlExecutor, fromNodeRunID int64, to *sdk.WorkflowNodeRun) error {
+	arts, err := loadArtifactByNodeRunID(db, fromNodeRunID)
+	if err != nil {
+		return sdk.WrapError(err, "copyArtifacts> Unable to load artifacts of node run %d", fromNodeRunID)
+	}
+
+	keptJobs := map[int64]bool{}
+	for _, s := range to.Stages {
+		for _, j := range s.RunJobs {
+			keptJobs[j.ID] = true
+		}
+	}
+
+	for i := range arts {
+		a := arts[i]
+		//The job of the artifacts uploaded before the jobs were recorded is unknown, they are kept
+		if a.WorkflowNodeRunJobID != 0 && !keptJobs[a.WorkflowNodeRunJobID] {
+			continue
+		}
+
+		from := a
+		a.ID = 0
+		a.WorkflowNodeRunID = to.ID
+		if a.SHA512sum == "" {
+			if err := copyArtifactObject(&from, &a); err != nil {
+				return sdk.WrapError(err, "copyArtifacts> Unable to copy artifact %s", a.Name)
+			}
+		} else {
+			exists, err := AcquireArtifactObject(db, &a)
+			if err != nil {
+				return sdk.WrapError(err, "copyArtifacts> Unable to reference artifact %s", a.Name)
+			}
+			if !exists {
+				log.Warning("copyArtifacts> Object %s of artifact %s is not stored", a.SHA512sum, a.Name)
+				continue
+			}
+		}
+		if err := InsertArtifact(db, &a); err != nil {
+			return sdk.WrapError(err, "copyArtifacts> Unable to insert artifact %s", a.Name)
+		}
+	}
+	return nil
+}
+
+// copyArtifactObject stores the content of an artifact for another artifact
+func copyArtifactObject(from, to *sdk.WorkflowNodeRunArtifact) error {
+	content, err := objectstore.FetchArtifact(from)
+	if err != nil {
+		return err
+	}
+	defer content.Close()
+	objectPath, err := objectstore.StoreArtifact(to, content)
+	if err != nil {
+		return err
+	}
+	to.ObjectPath = objectPath
+	return nil
+}
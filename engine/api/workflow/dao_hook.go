@@ -1,17 +1,277 @@
 package workflow
 
 import (
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"time"
+
 	"github.com/go-gorp/gorp"
+	"github.com/lib/pq"
+	"github.com/satori/go.uuid"
 
+	"github.com/ovh/cds/engine/api/secret"
 	"github.com/ovh/cds/sdk"
 )
 
-// InsertOrUpdateHook inserts or updates a hook
+const hookQuery = `SELECT workflow_node_hook.id, workflow_node_hook.uuid, workflow_node_hook.workflow_node_id, workflow_node_hook.workflow_hook_model_id, workflow_node_hook.conditions, workflow_node_hook.config, workflow_node_hook.last_execution
+	FROM workflow_node_hook`
+
+// insertHook inserts a hook. The hook model can be set by name, and the missing configuration keys are set from the model
 func insertHook(db gorp.SqlExecutor, node *sdk.WorkflowNode, hook *sdk.WorkflowNodeHook) error {
+	hook.WorkflowNodeID = node.ID
+	if hook.UUID == "" {
+		hook.UUID = uuid.NewV4().String()
+	}
+
+	if hook.WorkflowHookModelID == 0 {
+		hook.WorkflowHookModelID = hook.WorkflowHookModel.ID
+	}
+	var model *sdk.WorkflowHookModel
+	var errm error
+	if hook.WorkflowHookModelID != 0 {
+		model, errm = LoadHookModelByID(db, hook.WorkflowHookModelID)
+	} else {
+		model, errm = LoadHookModelByName(db, hook.WorkflowHookModel.Name)
+	}
+	if errm != nil {
+		return sdk.WrapError(sdk.ErrWorkflowInvalid, "insertHook> Unable to load hook model %d (%s): %s", hook.WorkflowHookModelID, hook.WorkflowHookModel.Name, errm)
+	}
+	hook.WorkflowHookModel = *model
+	hook.WorkflowHookModelID = model.ID
+
+	if hook.Config == nil {
+		hook.Config = sdk.WorkflowNodeHookConfig{}
+	}
+	for k, v := range model.DefaultConfig {
+		if _, ok := hook.Config[k]; !ok {
+			hook.Config[k] = v
+		}
+	}
+	if err := hook.IsValid(); err != nil {
+		return sdk.WrapError(err, "insertHook> Invalid %s hook", model.Name)
+	}
+
+	conditions, err := json.Marshal(hook.Conditions)
+	if err != nil {
+		return sdk.WrapError(err, "insertHook> Unable to marshal hook conditions")
+	}
+	storedConfig, err := encryptHookSecrets(hook)
+	if err != nil {
+		return sdk.WrapError(err, "insertHook> Unable to encrypt secrets of hook %s", hook.UUID)
+	}
+	config, err := json.Marshal(storedConfig)
+	if err != nil {
+		return sdk.WrapError(err, "insertHook> Unable to marshal hook config")
+	}
+
+	query := "INSERT INTO workflow_node_hook (uuid, workflow_node_id, workflow_hook_model_id, conditions, config) VALUES ($1, $2, $3, $4, $5) RETURNING id"
+	if err := db.QueryRow(query, hook.UUID, hook.WorkflowNodeID, hook.WorkflowHookModelID, conditions, config).Scan(&hook.ID); err != nil {
+		return sdk.WrapError(err, "insertHook> Unable to insert hook on node %d", node.ID)
+	}
+
+	// The workflow is recorded as a version after its insertion: the secrets must not appear in clear
+	maskHookSecrets(hook)
+	return nil
+}
+
+// encryptHookSecrets returns the configuration of the hook to store, with the secrets encrypted
+func encryptHookSecrets(hook *sdk.WorkflowNodeHook) (sdk.WorkflowNodeHookConfig, error) {
+	config := sdk.WorkflowNodeHookConfig{}
+	for k, v := range hook.Config {
+		config[k] = v
+	}
+	for _, k := range sdk.HookSecretConfigKeys[hook.WorkflowHookModel.Name] {
+		v := config[k]
+		if v == "" {
+			continue
+		}
+		if v == sdk.PasswordPlaceholder {
+			return nil, sdk.WrapError(sdk.ErrInvalidSecretValue, "encryptHookSecrets> %s is not specified", k)
+		}
+		d, err := secret.Encrypt([]byte(v))
+		if err != nil {
+			return nil, err
+		}
+		config[k] = base64.StdEncoding.EncodeToString(d)
+	}
+	return config, nil
+}
+
+// decryptHookSecrets decrypts the secrets of a loaded hook
+func decryptHookSecrets(hook *sdk.WorkflowNodeHook) error {
+	for _, k := range sdk.HookSecretConfigKeys[hook.WorkflowHookModel.Name] {
+		v := hook.Config[k]
+		if v == "" {
+			continue
+		}
+		b, err := base64.StdEncoding.DecodeString(v)
+		if err != nil {
+			return err
+		}
+		d, err := secret.Decrypt(b)
+		if err != nil {
+			return err
+		}
+		hook.Config[k] = string(d)
+	}
+	return nil
+}
+
+// maskHookSecrets replaces the secrets of a hook by the password placeholder
+func maskHookSecrets(hook *sdk.WorkflowNodeHook) {
+	for _, k := range sdk.HookSecretConfigKeys[hook.WorkflowHookModel.Name] {
+		if hook.Config[k] != "" {
+			hook.Config[k] = sdk.PasswordPlaceholder
+		}
+	}
+}
+
+// restoreHookSecrets sets the secrets sent with the password placeholder from the stored hooks with the same UUID.
+// It must be called before the old nodes of the workflow are deleted
+func restoreHookSecrets(db gorp.SqlExecutor, w *sdk.Workflow, oldWorkflowID int64) error {
+	query := hookQuery + `
+	JOIN workflow_node ON workflow_node.id = workflow_node_hook.workflow_node_id
+	WHERE workflow_node.workflow_id = $1`
+	stored, err := loadHooksWithModel(db, true, query, oldWorkflowID)
+	if err != nil {
+		return err
+	}
+	storedByUUID := make(map[string]sdk.WorkflowNodeHook, len(stored))
+	for _, h := range stored {
+		storedByUUID[h.UUID] = h
+	}
+
+	var restore func(n *sdk.WorkflowNode)
+	restore = func(n *sdk.WorkflowNode) {
+		for i := range n.Hooks {
+			h := &n.Hooks[i]
+			old, ok := storedByUUID[h.UUID]
+			if !ok {
+				continue
+			}
+			for _, k := range sdk.HookSecretConfigKeys[old.WorkflowHookModel.Name] {
+				if h.Config[k] == sdk.PasswordPlaceholder {
+					h.Config[k] = old.Config[k]
+				}
+			}
+		}
+		for i := range n.Triggers {
+			restore(&n.Triggers[i].WorkflowDestNode)
+		}
+	}
+
+	if w.Root != nil {
+		restore(w.Root)
+	}
+	for i := range w.Joins {
+		for j := range w.Joins[i].Triggers {
+			restore(&w.Joins[i].Triggers[j].WorkflowDestNode)
+		}
+	}
 	return nil
 }
 
-// DeleteHook deletes a hook
+// deleteHook deletes a hook
 func deleteHook(db gorp.SqlExecutor, hook *sdk.WorkflowNodeHook) error {
+	if _, err := db.Exec("DELETE FROM workflow_node_hook WHERE id = $1", hook.ID); err != nil {
+		return sdk.WrapError(err, "deleteHook> Unable to delete hook %d", hook.ID)
+	}
 	return nil
 }
+
+// loadHooks loads the hooks of a node, with their secrets masked
+func loadHooks(db gorp.SqlExecutor, node *sdk.WorkflowNode) ([]sdk.WorkflowNodeHook, error) {
+	return loadHooksWithModel(db, false, hookQuery+" WHERE workflow_node_hook.workflow_node_id = $1 ORDER BY workflow_node_hook.id", node.ID)
+}
+
+// LoadHookByUUID loads a hook by its UUID, with its secrets masked
+func LoadHookByUUID(db gorp.SqlExecutor, uuid string) (*sdk.WorkflowNodeHook, error) {
+	hs, err := loadHooksWithModel(db, false, hookQuery+" WHERE workflow_node_hook.uuid = $1", uuid)
+	if err != nil {
+		return nil, err
+	}
+	if len(hs) == 0 {
+		return nil, sdk.ErrNotFound
+	}
+	return &hs[0], nil
+}
+
+// LoadHooksByModelName loads all the hooks using a model. Their secrets are decrypted if clearSecrets is true
+func LoadHooksByModelName(db gorp.SqlExecutor, name string, clearSecrets bool) ([]sdk.WorkflowNodeHook, error) {
+	query := hookQuery + `
+	JOIN workflow_hook_model ON workflow_hook_model.id = workflow_node_hook.workflow_hook_model_id
+	WHERE workflow_hook_model.name = $1 ORDER BY workflow_node_hook.id`
+	return loadHooksWithModel(db, clearSecrets, query, name)
+}
+
+func loadHooksWithModel(db gorp.SqlExecutor, clearSecrets bool, query string, args ...interface{}) ([]sdk.WorkflowNodeHook, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, sdk.WrapError(err, "loadHooks> Unable to load hooks")
+	}
+	defer rows.Close()
+
+	hs := []sdk.WorkflowNodeHook{}
+	for rows.Next() {
+		var h sdk.WorkflowNodeHook
+		var conditions, config sql.NullString
+		var lastExecution pq.NullTime
+		if err := rows.Scan(&h.ID, &h.UUID, &h.WorkflowNodeID, &h.WorkflowHookModelID, &conditions, &config, &lastExecution); err != nil {
+			return nil, sdk.WrapError(err, "loadHooks> Unable to scan hook")
+		}
+		if conditions.Valid {
+			if err := json.Unmarshal([]byte(conditions.String), &h.Conditions); err != nil {
+				return nil, sdk.WrapError(err, "loadHooks> Unable to unmarshal conditions of hook %d", h.ID)
+			}
+		}
+		h.Config = sdk.WorkflowNodeHookConfig{}
+		if config.Valid {
+			if err := json.Unmarshal([]byte(config.String), &h.Config); err != nil {
+				return nil, sdk.WrapError(err, "loadHooks> Unable to unmarshal config of hook %d", h.ID)
+			}
+		}
+		if lastExecution.Valid {
+			h.LastExecution = &lastExecution.Time
+		}
+		hs = append(hs, h)
+	}
+	rows.Close()
+
+	models := map[int64]*sdk.WorkflowHookModel{}
+	for i := range hs {
+		h := &hs[i]
+		m, ok := models[h.WorkflowHookModelID]
+		if !ok {
+			m, err = LoadHookModelByID(db, h.WorkflowHookModelID)
+			if err != nil {
+				return nil, sdk.WrapError(err, "loadHooks> Unable to load model of hook %d", h.ID)
+			}
+			models[h.WorkflowHookModelID] = m
+		}
+		h.WorkflowHookModel = *m
+
+		if !clearSecrets {
+			maskHookSecrets(h)
+			continue
+		}
+		if err := decryptHookSecrets(h); err != nil {
+			return nil, sdk.WrapError(err, "loadHooks> Unable to decrypt secrets of hook %d", h.ID)
+		}
+	}
+	return hs, nil
+}
+
+// ClaimHookExecution sets the last execution of a hook if it is before t. It returns false if the execution was already claimed
+func ClaimHookExecution(db gorp.SqlExecutor, hookID int64, t time.Time) (bool, error) {
+	query := "UPDATE workflow_node_hook SET last_execution = $2 WHERE id = $1 AND (last_execution IS NULL OR last_execution < $2)"
+	res, err := db.Exec(query, hookID, t)
+	if err != nil {
+		return false, sdk.WrapError(err, "ClaimHookExecution> Unable to update hook %d", hookID)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, sdk.WrapError(err, "ClaimHookExecution> Unable to update hook %d", hookID)
+	}
+	return n == 1, nil
+}
@@ -0,0 +1,115 @@
+package workflow
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/go-gorp/gorp"
+
+	"github.com/ovh/cds/sdk"
+	"github.com/ovh/cds/sdk/log"
+)
+
+const hookModelQuery = "SELECT id, name, type, image, command, default_config FROM workflow_hook_model"
+
+// CreateBuiltinHookModels inserts the builtin hook models, or updates their default configuration
+func CreateBuiltinHookModels(db gorp.SqlExecutor) error {
+	for _, m := range sdk.BuiltinHookModels {
+		existing, err := LoadHookModelByName(db, m.Name)
+		if err != nil && err != sdk.ErrNotFound {
+			return sdk.WrapError(err, "CreateBuiltinHookModels> Unable to load hook model %s", m.Name)
+		}
+		if existing == nil {
+			log.Debug("CreateBuiltinHookModels> Insert hook model %s", m.Name)
+			if err := InsertHookModel(db, m); err != nil {
+				return err
+			}
+			continue
+		}
+		m.ID = existing.ID
+		if err := UpdateHookModel(db, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LoadHookModels loads all the hook models
+func LoadHookModels(db gorp.SqlExecutor) ([]sdk.WorkflowHookModel, error) {
+	return loadHookModels(db, hookModelQuery+" ORDER BY name")
+}
+
+// LoadHookModelByName loads a hook model by its name
+func LoadHookModelByName(db gorp.SqlExecutor, name string) (*sdk.WorkflowHookModel, error) {
+	ms, err := loadHookModels(db, hookModelQuery+" WHERE name = $1", name)
+	if err != nil {
+		return nil, err
+	}
+	if len(ms) == 0 {
+		return nil, sdk.ErrNotFound
+	}
+	return &ms[0], nil
+}
+
+// LoadHookModelByID loads a hook model by its id
+func LoadHookModelByID(db gorp.SqlExecutor, id int64) (*sdk.WorkflowHookModel, error) {
+	ms, err := loadHookModels(db, hookModelQuery+" WHERE id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(ms) == 0 {
+		return nil, sdk.ErrNotFound
+	}
+	return &ms[0], nil
+}
+
+func loadHookModels(db gorp.SqlExecutor, query string, args ...interface{}) ([]sdk.WorkflowHookModel, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, sdk.WrapError(err, "loadHookModels> Unable to load hook models")
+	}
+	defer rows.Close()
+
+	ms := []sdk.WorkflowHookModel{}
+	for rows.Next() {
+		var m sdk.WorkflowHookModel
+		var config sql.NullString
+		if err := rows.Scan(&m.ID, &m.Name, &m.Type, &m.Image, &m.Command, &config); err != nil {
+			return nil, sdk.WrapError(err, "loadHookModels> Unable to scan hook model")
+		}
+		m.DefaultConfig = sdk.WorkflowNodeHookConfig{}
+		if config.Valid {
+			if err := json.Unmarshal([]byte(config.String), &m.DefaultConfig); err != nil {
+				return nil, sdk.WrapError(err, "loadHookModels> Unable to unmarshal default config of %s", m.Name)
+			}
+		}
+		ms = append(ms, m)
+	}
+	return ms, nil
+}
+
+// InsertHookModel inserts a hook model
+func InsertHookModel(db gorp.SqlExecutor, m *sdk.WorkflowHookModel) error {
+	config, err := json.Marshal(m.DefaultConfig)
+	if err != nil {
+		return sdk.WrapError(err, "InsertHookModel> Unable to marshal default config of %s", m.Name)
+	}
+	query := "INSERT INTO workflow_hook_model (name, type, image, command, default_config) VALUES ($1, $2, $3, $4, $5) RETURNING id"
+	if err := db.QueryRow(query, m.Name, m.Type, m.Image, m.Command, config).Scan(&m.ID); err != nil {
+		return sdk.WrapError(err, "InsertHookModel> Unable to insert hook model %s", m.Name)
+	}
+	return nil
+}
+
+// UpdateHookModel updates a hook model
+func UpdateHookModel(db gorp.SqlExecutor, m *sdk.WorkflowHookModel) error {
+	config, err := json.Marshal(m.DefaultConfig)
+	if err != nil {
+		return sdk.WrapError(err, "UpdateHookModel> Unable to marshal default config of %s", m.Name)
+	}
+	query := "UPDATE workflow_hook_model SET name = $2, type = $3, image = $4, command = $5, default_config = $6 WHERE id = $1"
+	if _, err := db.Exec(query, m.ID, m.Name, m.Type, m.Image, m.Command, config); err != nil {
+		return sdk.WrapError(err, "UpdateHookModel> Unable to update hook model %s", m.Name)
+	}
+	return nil
+}
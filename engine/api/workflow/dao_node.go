@@ -38,11 +38,20 @@ func insertNode(db gorp.SqlExecutor, w *sdk.Workflow, n *sdk.WorkflowNode, u *sd
 	n.WorkflowID = w.ID
 	n.ID = 0
 
+	if n.SubWorkflow != nil {
+		if err := checkSubWorkflow(db, w, n, u); err != nil {
+			return err
+		}
+		if n.Name == "" {
+			n.Name = n.SubWorkflow.WorkflowName
+		}
+	}
+
 	if n.Name == "" {
 		n.Name = n.Pipeline.Name
 	}
 
-	if n.PipelineID == 0 {
+	if n.PipelineID == 0 && n.SubWorkflow == nil {
 		n.PipelineID = n.Pipeline.ID
 	}
 
@@ -57,17 +66,38 @@ func insertNode(db gorp.SqlExecutor, w *sdk.Workflow, n *sdk.WorkflowNode, u *sd
 		n.Context.EnvironmentID = n.Context.Environment.ID
 	}
 
-	//Insert new node
-	dbwn := Node(*n)
-	if err := db.Insert(&dbwn); err != nil {
-		return sdk.WrapError(err, "InsertOrUpdateNode> Unable to insert workflow node")
+	//Insert new node. A sub workflow node has no pipeline
+	if n.SubWorkflow != nil {
+		query := "INSERT INTO workflow_node (workflow_id, name, sub_workflow_id) VALUES ($1, $2, $3) RETURNING id"
+		if err := db.QueryRow(query, n.WorkflowID, n.Name, n.SubWorkflow.WorkflowID).Scan(&n.ID); err != nil {
+			return sdk.WrapError(err, "InsertOrUpdateNode> Unable to insert workflow node")
+		}
+	} else {
+		dbwn := Node(*n)
+		if err := db.Insert(&dbwn); err != nil {
+			return sdk.WrapError(err, "InsertOrUpdateNode> Unable to insert workflow node")
+		}
+		n.ID = dbwn.ID
 	}
-	n.ID = dbwn.ID
 
 	if skipDependencies {
 		return nil
 	}
 
+	//Insert matrix
+	if len(n.Matrix) > 0 {
+		if err := n.Matrix.IsValid(); err != nil {
+			return sdk.WrapError(sdk.ErrWorkflowInvalid, "InsertOrUpdateNode> Invalid matrix on node %s: %s", n.Name, err)
+		}
+		b, err := json.Marshal(n.Matrix)
+		if err != nil {
+			return sdk.WrapError(err, "InsertOrUpdateNode> Unable to marshal matrix of node %d", n.ID)
+		}
+		if _, err := db.Exec("UPDATE workflow_node SET matrix = $1 where id = $2", b, n.ID); err != nil {
+			return sdk.WrapError(err, "InsertOrUpdateNode> Unable to set matrix of node %d", n.ID)
+		}
+	}
+
 	//Insert context
 	n.Context.WorkflowNodeID = n.ID
 	if err := insertNodeContext(db, n.Context); err != nil {
@@ -149,7 +179,8 @@ func insertNodeContext(db gorp.SqlExecutor, c *sdk.WorkflowNodeContext) error {
 // loadNode loads a node in a workflow
 func loadNode(db gorp.SqlExecutor, w *sdk.Workflow, id int64, u *sdk.User) (*sdk.WorkflowNode, error) {
 	dbwn := Node{}
-	if err := db.SelectOne(&dbwn, "select * from workflow_node where workflow_id = $1 and id = $2", w.ID, id); err != nil {
+	query := "select id, name, workflow_id, coalesce(pipeline_id, 0) as pipeline_id from workflow_node where workflow_id = $1 and id = $2"
+	if err := db.SelectOne(&dbwn, query, w.ID, id); err != nil {
 		if err == sql.ErrNoRows {
 			return nil, sdk.ErrWorkflowNodeNotFound
 		}
@@ -160,6 +191,26 @@ func loadNode(db gorp.SqlExecutor, w *sdk.Workflow, id int64, u *sdk.User) (*sdk
 	wn.WorkflowID = w.ID
 	wn.Ref = fmt.Sprintf("%d", dbwn.ID)
 
+	//Load matrix
+	sqlMatrix, errM := db.SelectNullStr("select matrix from workflow_node where id = $1", wn.ID)
+	if errM != nil {
+		return nil, sdk.WrapError(errM, "LoadNode> Unable to load matrix of %d", id)
+	}
+	if sqlMatrix.Valid {
+		if err := json.Unmarshal([]byte(sqlMatrix.String), &wn.Matrix); err != nil {
+			return nil, sdk.WrapError(err, "LoadNode> Unable to unmarshal matrix of %d", id)
+		}
+	}
+
+	//Load sub workflow
+	subWorkflowID, errS := db.SelectNullInt("select sub_workflow_id from workflow_node where id = $1", wn.ID)
+	if errS != nil {
+		return nil, sdk.WrapError(errS, "LoadNode> Unable to load sub workflow of %d", id)
+	}
+	if subWorkflowID.Valid {
+		wn.SubWorkflow = loadSubWorkflow(db, subWorkflowID.Int64)
+	}
+
 	//Load triggers
 	triggers, errTrig := loadTriggers(db, w, &wn, u)
 	if errTrig != nil {
@@ -176,15 +227,24 @@ func loadNode(db gorp.SqlExecutor, w *sdk.Workflow, id int64, u *sdk.User) (*sdk
 	}
 	wn.Context = ctx
 
-	//Load pipeline
-	pip, err := pipeline.LoadPipelineByID(db, wn.PipelineID, true)
-	if err != nil {
-		return nil, sdk.WrapError(err, "LoadNode> Unable to load pipeline of %d", id)
+	//Load hooks
+	hooks, errHooks := loadHooks(db, &wn)
+	if errHooks != nil {
+		return nil, sdk.WrapError(errHooks, "LoadNode> Unable to load hooks of %d", id)
 	}
-	wn.Pipeline = *pip
+	wn.Hooks = hooks
+
+	//Load pipeline
+	if wn.SubWorkflow == nil {
+		pip, err := pipeline.LoadPipelineByID(db, wn.PipelineID, true)
+		if err != nil {
+			return nil, sdk.WrapError(err, "LoadNode> Unable to load pipeline of %d", id)
+		}
+		wn.Pipeline = *pip
 
-	if wn.Name == "" {
-		wn.Name = pip.Name
+		if wn.Name == "" {
+			wn.Name = pip.Name
+		}
 	}
 
 	return &wn, nil
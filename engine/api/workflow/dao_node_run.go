@@ -69,6 +69,7 @@ func insertWorkflowNodeRun(db gorp.SqlExecutor, n *sdk.WorkflowNodeRun) error {
 	}
 	n.ID = nodeRunDB.ID
 	log.Debug("insertWorkflowNodeRun> new node run: %d (%d)", n.ID, n.WorkflowNodeID)
+	publishRunEvent(db, sdk.WorkflowRunEvent{Type: sdk.WorkflowRunEventNodeRun, WorkflowRunID: n.WorkflowRunID, NodeRunID: n.ID, Status: n.Status})
 	return nil
 }
 
@@ -79,6 +80,7 @@ func UpdateNodeRun(db gorp.SqlExecutor, n *sdk.WorkflowNodeRun) error {
 	if _, err := db.Update(&nodeRunDB); err != nil {
 		return err
 	}
+	publishRunEvent(db, sdk.WorkflowRunEvent{Type: sdk.WorkflowRunEventNodeRun, WorkflowRunID: n.WorkflowRunID, NodeRunID: n.ID, Status: n.Status})
 	return nil
 }
 
@@ -93,10 +95,11 @@ type sqlNodeRun struct {
 	Tests              sql.NullString `db:"tests"`
 	Commits            sql.NullString `db:"commits"`
 	Stages             sql.NullString `db:"stages"`
+	Matrix             sql.NullString `db:"matrix"`
 }
 
 //PostInsert is a db hook on WorkflowNodeRun in table workflow_node_run
-//it stores columns hook_event, manual, trigger_id, payload, pipeline_parameters, tests, commits
+//it stores columns hook_event, manual, trigger_id, payload, pipeline_parameters, tests, commits, matrix
 func (r *NodeRun) PostInsert(db gorp.SqlExecutor) error {
 	var rr = sqlNodeRun{ID: r.ID}
 	if r.Stages != nil {
@@ -162,6 +165,13 @@ func (r *NodeRun) PostInsert(db gorp.SqlExecutor) error {
 		}
 		rr.Commits = s
 	}
+	if r.Matrix != nil {
+		s, err := gorpmapping.JSONToNullString(r.Matrix)
+		if err != nil {
+			return sdk.WrapError(err, "NodeRun.PostInsert> unable to get json from matrix")
+		}
+		rr.Matrix = s
+	}
 	if n, err := db.Update(&rr); err != nil {
 		return sdk.WrapError(err, "NodeRun.PostInsert> unable to update workflow_node_run id=%d", rr.ID)
 	} else if n == 0 {
@@ -222,6 +232,9 @@ func (r *NodeRun) PostGet(db gorp.SqlExecutor) error {
 	if err := gorpmapping.JSONNullString(rr.BuildParameters, &r.BuildParameters); err != nil {
 		return sdk.WrapError(err, "NodeRun.PostGet> Error loading node run %d", r.ID)
 	}
+	if err := gorpmapping.JSONNullString(rr.Matrix, &r.Matrix); err != nil {
+		return sdk.WrapError(err, "NodeRun.PostGet> Error loading node run %d", r.ID)
+	}
 	if rr.Tests.Valid {
 		r.Tests = new(venom.Tests)
 	}
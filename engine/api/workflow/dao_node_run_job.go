@@ -66,9 +66,66 @@ func LoadNodeJobRunQueue(db gorp.SqlExecutor, groupsID []int64, since *time.Time
 		jobs[i] = sdk.WorkflowNodeJobRun(sqlJobs[i])
 	}
 
+	if err := loadNodeJobRunQueueTriggers(db, jobs); err != nil {
+		return nil, sdk.WrapError(err, "workflow.LoadNodeJobRun> Unable to load job runs triggers")
+	}
+
 	return jobs, nil
 }
 
+// loadNodeJobRunQueueTriggers sets the project key and the trigger of the workflow run of each job, used by hatcheries to prioritize the queue
+func loadNodeJobRunQueueTriggers(db gorp.SqlExecutor, jobs []sdk.WorkflowNodeJobRun) error {
+	if len(jobs) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(jobs))
+	for i := range jobs {
+		ids[i] = strconv.FormatInt(jobs[i].ID, 10)
+	}
+
+	query := `select workflow_node_run_job.id, project.projectkey,
+		exists (select 1 from workflow_node_run n where n.workflow_run_id = workflow_node_run.workflow_run_id and n.manual is not null),
+		exists (select 1 from workflow_node_run n where n.workflow_run_id = workflow_node_run.workflow_run_id and n.hook_event is not null)
+	from workflow_node_run_job
+	join workflow_node_run on workflow_node_run.id = workflow_node_run_job.workflow_node_run_id
+	join workflow_run on workflow_run.id = workflow_node_run.workflow_run_id
+	join workflow on workflow.id = workflow_run.workflow_id
+	join project on project.id = workflow.project_id
+	where workflow_node_run_job.id = ANY(string_to_array($1, ',')::bigint[])`
+	rows, err := db.Query(query, strings.Join(ids, ","))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	index := make(map[int64]*sdk.WorkflowNodeJobRun, len(jobs))
+	for i := range jobs {
+		index[jobs[i].ID] = &jobs[i]
+	}
+
+	for rows.Next() {
+		var id int64
+		var key string
+		var manual, hook bool
+		if err := rows.Scan(&id, &key, &manual, &hook); err != nil {
+			return err
+		}
+		j, ok := index[id]
+		if !ok {
+			continue
+		}
+		j.ProjectKey = key
+		switch {
+		case manual:
+			j.Trigger = sdk.WorkflowJobTriggerManual
+		case hook:
+			j.Trigger = sdk.WorkflowJobTriggerHook
+		}
+	}
+	return nil
+}
+
 //LoadNodeJobRun load a NodeJobRun given its ID
 func LoadNodeJobRun(db gorp.SqlExecutor, id int64) (*sdk.WorkflowNodeJobRun, error) {
 	j := JobRun{}
@@ -97,6 +154,19 @@ func insertWorkflowNodeJobRun(db gorp.SqlExecutor, j *sdk.WorkflowNodeJobRun) er
 		return err
 	}
 	j.ID = dbj.ID
+	publishRunEvent(db, sdk.WorkflowRunEvent{Type: sdk.WorkflowRunEventJobRun, NodeRunID: j.WorkflowNodeRunID, JobRunID: j.ID, Status: j.Status})
+	return nil
+}
+
+// updateNodeJobRunSpawnInfos only updates the spawn infos of a workflow_node_run_job
+func updateNodeJobRunSpawnInfos(db gorp.SqlExecutor, j *sdk.WorkflowNodeJobRun) error {
+	spawnJSON, err := json.Marshal(j.SpawnInfos)
+	if err != nil {
+		return err
+	}
+	if _, err := db.Exec("update workflow_node_run_job set spawninfos = $2 where id = $1", j.ID, spawnJSON); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -107,12 +177,18 @@ func DeleteNodeJobRuns(db gorp.SqlExecutor, nodeID int64) error {
 	return err
 }
 
+func deleteNodeJobRun(db gorp.SqlExecutor, id int64) error {
+	_, err := db.Exec("delete from workflow_node_run_job where id = $1", id)
+	return err
+}
+
 //UpdateNodeJobRun updates a workflow_node_run_job
 func UpdateNodeJobRun(db gorp.SqlExecutor, j *sdk.WorkflowNodeJobRun) error {
 	dbj := JobRun(*j)
 	if _, err := db.Update(&dbj); err != nil {
 		return err
 	}
+	publishRunEvent(db, sdk.WorkflowRunEvent{Type: sdk.WorkflowRunEventJobRun, NodeRunID: j.WorkflowNodeRunID, JobRunID: j.ID, Status: j.Status})
 
 	nRun, errR := LoadNodeRunByID(db, j.WorkflowNodeRunID)
 	if errR != nil {
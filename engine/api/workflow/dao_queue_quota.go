@@ -0,0 +1,368 @@
+package workflow
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/go-gorp/gorp"
+
+	"github.com/ovh/cds/engine/api/cache"
+	"github.com/ovh/cds/engine/api/group"
+	"github.com/ovh/cds/sdk"
+	"github.com/ovh/cds/sdk/log"
+)
+
+const (
+	queueQuotaQuery = `SELECT queue_quota.id, queue_quota.project_id, project.projectkey, queue_quota.group_id, "group".name, queue_quota.max_running, queue_quota.max_queued
+	FROM queue_quota
+	LEFT JOIN project ON project.id = queue_quota.project_id
+	LEFT JOIN "group" ON "group".id = queue_quota.group_id`
+
+	// queueQuotaJobs joins the job runs with their project
+	queueQuotaJobs = `FROM workflow_node_run_job
+	JOIN workflow_node_run ON workflow_node_run.id = workflow_node_run_job.workflow_node_run_id
+	JOIN workflow_run ON workflow_run.id = workflow_node_run.workflow_run_id
+	JOIN workflow ON workflow.id = workflow_run.workflow_id`
+
+	// queueQuotaLockClass is the first key of the advisory locks taken on quotas
+	queueQuotaLockClass = 1010
+
+	// queueQuotasDelay is the delay between two computations of the jobs held by quotas
+	queueQuotasDelay = 10 * time.Second
+)
+
+// queueQuotasKey is the cache key of the quota statuses of the held jobs, by job ID
+var queueQuotasKey = cache.Key("workflow", "queue", "quotas")
+
+// LoadQueueQuotas loads all the quotas with their current state
+func LoadQueueQuotas(db gorp.SqlExecutor) ([]sdk.QueueQuota, error) {
+	qs, err := loadQueueQuotas(db, queueQuotaQuery+" ORDER BY queue_quota.id")
+	if err != nil {
+		return nil, err
+	}
+	for i := range qs {
+		if err := loadQueueQuotaState(db, &qs[i]); err != nil {
+			return nil, err
+		}
+	}
+	return qs, nil
+}
+
+// LoadQueueQuota loads a quota with its current state
+func LoadQueueQuota(db gorp.SqlExecutor, id int64) (*sdk.QueueQuota, error) {
+	qs, err := loadQueueQuotas(db, queueQuotaQuery+" WHERE queue_quota.id = $1", id)
+	if err != nil {
+		return nil, err
+	}
+	if len(qs) == 0 {
+		return nil, sdk.ErrNotFound
+	}
+	if err := loadQueueQuotaState(db, &qs[0]); err != nil {
+		return nil, err
+	}
+	return &qs[0], nil
+}
+
+// loadProjectQueueQuotas loads the quotas of a project and of the groups of the project, whatever their permission
+func loadProjectQueueQuotas(db gorp.SqlExecutor, projectID int64) ([]sdk.QueueQuota, error) {
+	query := queueQuotaQuery + ` WHERE queue_quota.project_id = $1
+	OR queue_quota.group_id IN (SELECT group_id FROM project_group WHERE project_id = $1)
+	ORDER BY queue_quota.id`
+	return loadQueueQuotas(db, query, projectID)
+}
+
+func loadQueueQuotas(db gorp.SqlExecutor, query string, args ...interface{}) ([]sdk.QueueQuota, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, sdk.WrapError(err, "loadQueueQuotas> Unable to load quotas")
+	}
+	defer rows.Close()
+
+	qs := []sdk.QueueQuota{}
+	for rows.Next() {
+		var q sdk.QueueQuota
+		var projectID, groupID sql.NullInt64
+		var projectKey, groupName sql.NullString
+		if err := rows.Scan(&q.ID, &projectID, &projectKey, &groupID, &groupName, &q.MaxRunning, &q.MaxQueued); err != nil {
+			return nil, sdk.WrapError(err, "loadQueueQuotas> Unable to scan quota")
+		}
+		q.ProjectID = projectID.Int64
+		q.ProjectKey = projectKey.String
+		q.GroupID = groupID.Int64
+		q.GroupName = groupName.String
+		qs = append(qs, q)
+	}
+	return qs, nil
+}
+
+// queueQuotaScope returns the condition selecting the jobs of the quota, and its arguments
+func queueQuotaScope(q *sdk.QueueQuota) (string, []interface{}) {
+	if q.Scope() == sdk.QueueQuotaScopeProject {
+		return "workflow.project_id = $1", []interface{}{q.ProjectID}
+	}
+	return "workflow.project_id IN (SELECT project_id FROM project_group WHERE group_id = $1)", []interface{}{q.GroupID}
+}
+
+// loadQueueQuotaState counts the running and queued jobs of the quota
+func loadQueueQuotaState(db gorp.SqlExecutor, q *sdk.QueueQuota) error {
+	cond, args := queueQuotaScope(q)
+	args = append(args, sdk.StatusBuilding.String(), sdk.StatusWaiting.String())
+	query := fmt.Sprintf(`SELECT
+		COUNT(*) FILTER (WHERE workflow_node_run_job.status = $%d),
+		COUNT(*) FILTER (WHERE workflow_node_run_job.status = $%d)
+	%s WHERE %s`, len(args)-1, len(args), queueQuotaJobs, cond)
+	if err := db.QueryRow(query, args...).Scan(&q.Running, &q.Queued); err != nil {
+		return sdk.WrapError(err, "loadQueueQuotaState> Unable to count jobs of quota %d", q.ID)
+	}
+	return nil
+}
+
+// queueQuotaHold returns the reason why the quota holds the job, nil if the job can be taken
+func queueQuotaHold(q *sdk.QueueQuota) *sdk.QueueQuotaStatus {
+	if q.MaxRunning == 0 || q.Running < q.MaxRunning {
+		return nil
+	}
+	return &sdk.QueueQuotaStatus{QuotaID: q.ID, Scope: q.Scope(), Name: q.Name(), Reason: "max running jobs reached"}
+}
+
+// queueQuotaReject returns the reason why the quota rejects a new job, nil if the job can be queued
+func queueQuotaReject(q *sdk.QueueQuota) *sdk.QueueQuotaStatus {
+	if q.MaxQueued == 0 || q.Queued < q.MaxQueued {
+		return nil
+	}
+	return &sdk.QueueQuotaStatus{QuotaID: q.ID, Scope: q.Scope(), Name: q.Name(), Reason: "max queued jobs reached"}
+}
+
+// InsertQueueQuota inserts a quota. The project and the group can be set by key and by name
+func InsertQueueQuota(db gorp.SqlExecutor, q *sdk.QueueQuota) error {
+	if err := q.IsValid(); err != nil {
+		return sdk.WrapError(err, "InsertQueueQuota> Invalid quota")
+	}
+
+	if q.Scope() == sdk.QueueQuotaScopeProject && q.ProjectID == 0 {
+		if err := db.QueryRow("SELECT id FROM project WHERE projectkey = $1", q.ProjectKey).Scan(&q.ProjectID); err != nil {
+			if err == sql.ErrNoRows {
+				return sdk.WrapError(sdk.ErrNoProject, "InsertQueueQuota> Project %s not found", q.ProjectKey)
+			}
+			return sdk.WrapError(err, "InsertQueueQuota> Unable to load project %s", q.ProjectKey)
+		}
+	}
+	if q.Scope() == sdk.QueueQuotaScopeGroup && q.GroupID == 0 {
+		if err := db.QueryRow(`SELECT id FROM "group" WHERE name = $1`, q.GroupName).Scan(&q.GroupID); err != nil {
+			if err == sql.ErrNoRows {
+				return sdk.WrapError(sdk.ErrGroupNotFound, "InsertQueueQuota> Group %s not found", q.GroupName)
+			}
+			return sdk.WrapError(err, "InsertQueueQuota> Unable to load group %s", q.GroupName)
+		}
+	}
+
+	projectID := sql.NullInt64{Int64: q.ProjectID, Valid: q.ProjectID != 0}
+	groupID := sql.NullInt64{Int64: q.GroupID, Valid: q.GroupID != 0}
+	query := "INSERT INTO queue_quota (project_id, group_id, max_running, max_queued) VALUES ($1, $2, $3, $4) RETURNING id"
+	if err := db.QueryRow(query, projectID, groupID, q.MaxRunning, q.MaxQueued).Scan(&q.ID); err != nil {
+		return sdk.WrapError(err, "InsertQueueQuota> Unable to insert quota")
+	}
+	return nil
+}
+
+// UpdateQueueQuota updates the limits of a quota
+func UpdateQueueQuota(db gorp.SqlExecutor, q *sdk.QueueQuota) error {
+	if err := q.IsValid(); err != nil {
+		return sdk.WrapError(err, "UpdateQueueQuota> Invalid quota")
+	}
+	res, err := db.Exec("UPDATE queue_quota SET max_running = $2, max_queued = $3 WHERE id = $1", q.ID, q.MaxRunning, q.MaxQueued)
+	if err != nil {
+		return sdk.WrapError(err, "UpdateQueueQuota> Unable to update quota %d", q.ID)
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return sdk.ErrNotFound
+	}
+	return nil
+}
+
+// DeleteQueueQuota deletes a quota
+func DeleteQueueQuota(db gorp.SqlExecutor, id int64) error {
+	if _, err := db.Exec("DELETE FROM queue_quota WHERE id = $1", id); err != nil {
+		return sdk.WrapError(err, "DeleteQueueQuota> Unable to delete quota %d", id)
+	}
+	return nil
+}
+
+// checkQueueQuotas returns ErrQueueQuotaExceeded if a quota holds the job.
+// Quotas are locked until the end of the transaction, so that concurrent takes see the running jobs of each other
+func checkQueueQuotas(db gorp.SqlExecutor, job *sdk.WorkflowNodeJobRun) error {
+	var projectID int64
+	query := "SELECT workflow.project_id " + queueQuotaJobs + " WHERE workflow_node_run_job.id = $1"
+	if err := db.QueryRow(query, job.ID).Scan(&projectID); err != nil {
+		return sdk.WrapError(err, "checkQueueQuotas> Unable to load project of job %d", job.ID)
+	}
+
+	qs, err := lockProjectQueueQuotas(db, projectID)
+	if err != nil {
+		return err
+	}
+	for i := range qs {
+		if s := queueQuotaHold(&qs[i]); s != nil {
+			return sdk.WrapError(sdk.ErrQueueQuotaExceeded, "checkQueueQuotas> job %d held by %s", job.ID, s)
+		}
+	}
+	return nil
+}
+
+// checkQueueQuotasOnQueue returns the status of the quota rejecting a new job of the node run, nil if it can be queued.
+// Quotas are locked until the end of the transaction, so that concurrent runs see the queued jobs of each other
+func checkQueueQuotasOnQueue(db gorp.SqlExecutor, run *sdk.WorkflowNodeRun) (*sdk.QueueQuotaStatus, error) {
+	var projectID int64
+	query := "SELECT workflow.project_id FROM workflow_run JOIN workflow ON workflow.id = workflow_run.workflow_id WHERE workflow_run.id = $1"
+	if err := db.QueryRow(query, run.WorkflowRunID).Scan(&projectID); err != nil {
+		return nil, sdk.WrapError(err, "checkQueueQuotasOnQueue> Unable to load project of node run %d", run.ID)
+	}
+
+	qs, err := lockProjectQueueQuotas(db, projectID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range qs {
+		if s := queueQuotaReject(&qs[i]); s != nil {
+			return s, nil
+		}
+	}
+	return nil, nil
+}
+
+// lockProjectQueueQuotas locks the quotas of a project, and loads their current state
+func lockProjectQueueQuotas(db gorp.SqlExecutor, projectID int64) ([]sdk.QueueQuota, error) {
+	qs, err := loadProjectQueueQuotas(db, projectID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range qs {
+		q := &qs[i]
+		if _, err := db.Exec("SELECT pg_advisory_xact_lock($1, $2)", queueQuotaLockClass, q.ID); err != nil {
+			return nil, sdk.WrapError(err, "lockProjectQueueQuotas> Unable to lock quota %d", q.ID)
+		}
+		if err := loadQueueQuotaState(db, q); err != nil {
+			return nil, err
+		}
+	}
+	return qs, nil
+}
+
+// QueueQuotasApplier periodically adds a spawn info on the waiting jobs held by a quota, and caches their quota status for the queue
+func QueueQuotasApplier(c context.Context, DBFunc func() *gorp.DbMap) {
+	tick := time.NewTicker(queueQuotasDelay).C
+	for {
+		select {
+		case <-c.Done():
+			if c.Err() != nil {
+				log.Error("Exiting workflow.QueueQuotasApplier: %v", c.Err())
+			}
+			return
+		case <-tick:
+			db := DBFunc()
+			if db == nil {
+				continue
+			}
+			if err := applyQueueQuotas(db); err != nil {
+				log.Warning("QueueQuotasApplier> %s", err)
+			}
+		}
+	}
+}
+
+// applyQueueQuotas looks for the waiting jobs held by a quota
+func applyQueueQuotas(db gorp.SqlExecutor) error {
+	holds := map[int64]sdk.QueueQuotaStatus{}
+	// The statuses expire if no API computes them anymore
+	defer cache.SetWithTTL(queueQuotasKey, holds, int(3*queueQuotasDelay/time.Second))
+
+	qs, err := LoadQueueQuotas(db)
+	if err != nil {
+		return err
+	}
+	if len(qs) == 0 {
+		return nil
+	}
+
+	jobs, err := LoadNodeJobRunQueue(db, []int64{group.SharedInfraGroup.ID}, nil)
+	if err != nil {
+		return err
+	}
+	if len(jobs) == 0 {
+		return nil
+	}
+
+	// Keys of the projects of each quota
+	projects := make([]map[string]bool, len(qs))
+	for i := range qs {
+		q := &qs[i]
+		if q.Scope() == sdk.QueueQuotaScopeProject {
+			projects[i] = map[string]bool{q.ProjectKey: true}
+		} else {
+			keys := []string{}
+			query := "SELECT DISTINCT project.projectkey FROM project JOIN project_group ON project_group.project_id = project.id WHERE project_group.group_id = $1"
+			if _, err := db.Select(&keys, query, q.GroupID); err != nil {
+				return sdk.WrapError(err, "applyQueueQuotas> Unable to load projects of group %s", q.GroupName)
+			}
+			projects[i] = map[string]bool{}
+			for _, k := range keys {
+				projects[i][k] = true
+			}
+		}
+	}
+
+	for j := range jobs {
+		job := &jobs[j]
+		for i := range qs {
+			if !projects[i][job.ProjectKey] {
+				continue
+			}
+			if s := queueQuotaHold(&qs[i]); s != nil {
+				job.Quota = s
+				holds[job.ID] = *s
+				addQueueQuotaSpawnInfo(db, job)
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// SetQueueQuotas sets the quota status of the jobs held by a quota, as last computed by QueueQuotasApplier
+func SetQueueQuotas(jobs []sdk.WorkflowNodeJobRun) {
+	holds := map[int64]sdk.QueueQuotaStatus{}
+	if !cache.Get(queueQuotasKey, &holds) {
+		return
+	}
+	for i := range jobs {
+		if s, ok := holds[jobs[i].ID]; ok {
+			jobs[i].Quota = &s
+		}
+	}
+}
+
+// addQueueQuotaSpawnInfo adds a spawn info explaining why the job is waiting, if it is not already the last one
+func addQueueQuotaSpawnInfo(db gorp.SqlExecutor, job *sdk.WorkflowNodeJobRun) {
+	reason := job.Quota.String()
+	if n := len(job.SpawnInfos); n > 0 {
+		last := job.SpawnInfos[n-1].Message
+		if last.ID == sdk.MsgSpawnInfoJobInQueueQuota.ID && len(last.Args) == 1 && last.Args[0] == reason {
+			return
+		}
+	}
+
+	info := sdk.SpawnInfo{
+		APITime:    time.Now(),
+		RemoteTime: time.Now(),
+		Message:    sdk.SpawnMsg{ID: sdk.MsgSpawnInfoJobInQueueQuota.ID, Args: []interface{}{reason}},
+	}
+	job.SpawnInfos = append(job.SpawnInfos, info)
+
+	if err := updateNodeJobRunSpawnInfos(db, job); err != nil {
+		log.Warning("addQueueQuotaSpawnInfo> Unable to add spawn info on job %d: %s", job.ID, err)
+		return
+	}
+	publishRunEvent(db, sdk.WorkflowRunEvent{Type: sdk.WorkflowRunEventSpawnInfo, NodeRunID: job.WorkflowNodeRunID, JobRunID: job.ID, SpawnInfos: []sdk.SpawnInfo{info}})
+}
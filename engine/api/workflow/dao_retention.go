@@ -0,0 +1,105 @@
+package workflow
+
+import (
+	"database/sql"
+	"encoding/json"
+
+	"github.com/go-gorp/gorp"
+
+	"github.com/ovh/cds/sdk"
+)
+
+const retentionPolicyColumns = "id, project_id, workflow_id, keep_last_runs, keep_days, keep_tags"
+
+// LoadRetentionPolicy loads the retention policy of a workflow, or the project one if workflowID is 0
+func LoadRetentionPolicy(db gorp.SqlExecutor, projectID, workflowID int64) (*sdk.WorkflowRetentionPolicy, error) {
+	query := "SELECT " + retentionPolicyColumns + " FROM workflow_retention_policy WHERE project_id = $1 AND COALESCE(workflow_id, 0) = $2"
+	ps, err := loadRetentionPolicies(db, query, projectID, workflowID)
+	if err != nil {
+		return nil, err
+	}
+	if len(ps) == 0 {
+		return nil, sdk.ErrNotFound
+	}
+	return &ps[0], nil
+}
+
+// LoadRetentionPolicies loads the retention policies of a project and its workflows, or all the policies if projectID is 0
+func LoadRetentionPolicies(db gorp.SqlExecutor, projectID int64) ([]sdk.WorkflowRetentionPolicy, error) {
+	if projectID == 0 {
+		return loadRetentionPolicies(db, "SELECT "+retentionPolicyColumns+" FROM workflow_retention_policy")
+	}
+	return loadRetentionPolicies(db, "SELECT "+retentionPolicyColumns+" FROM workflow_retention_policy WHERE project_id = $1", projectID)
+}
+
+func loadRetentionPolicies(db gorp.SqlExecutor, query string, args ...interface{}) ([]sdk.WorkflowRetentionPolicy, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, sdk.WrapError(err, "loadRetentionPolicies> Unable to load retention policies")
+	}
+	defer rows.Close()
+
+	ps := []sdk.WorkflowRetentionPolicy{}
+	for rows.Next() {
+		var p sdk.WorkflowRetentionPolicy
+		var workflowID sql.NullInt64
+		var tags sql.NullString
+		if err := rows.Scan(&p.ID, &p.ProjectID, &workflowID, &p.KeepLastRuns, &p.KeepDays, &tags); err != nil {
+			return nil, sdk.WrapError(err, "loadRetentionPolicies> Unable to scan retention policy")
+		}
+		if workflowID.Valid {
+			p.WorkflowID = workflowID.Int64
+		}
+		if tags.Valid {
+			if err := json.Unmarshal([]byte(tags.String), &p.KeepTags); err != nil {
+				return nil, sdk.WrapError(err, "loadRetentionPolicies> Unable to unmarshal tags")
+			}
+		}
+		ps = append(ps, p)
+	}
+	return ps, nil
+}
+
+// UpsertRetentionPolicy inserts or updates the retention policy of a workflow, or of the project if WorkflowID is 0
+func UpsertRetentionPolicy(db gorp.SqlExecutor, p *sdk.WorkflowRetentionPolicy) error {
+	if err := p.IsValid(); err != nil {
+		return sdk.WrapError(err, "UpsertRetentionPolicy> Invalid retention policy")
+	}
+
+	tags, err := json.Marshal(p.KeepTags)
+	if err != nil {
+		return sdk.WrapError(err, "UpsertRetentionPolicy> Unable to marshal tags")
+	}
+
+	workflowID := sql.NullInt64{Int64: p.WorkflowID, Valid: p.WorkflowID != 0}
+
+	old, err := LoadRetentionPolicy(db, p.ProjectID, p.WorkflowID)
+	if err != nil && err != sdk.ErrNotFound {
+		return err
+	}
+
+	if old == nil {
+		query := `INSERT INTO workflow_retention_policy (project_id, workflow_id, keep_last_runs, keep_days, keep_tags)
+		VALUES ($1, $2, $3, $4, $5) RETURNING id`
+		if err := db.QueryRow(query, p.ProjectID, workflowID, p.KeepLastRuns, p.KeepDays, tags).Scan(&p.ID); err != nil {
+			return sdk.WrapError(err, "UpsertRetentionPolicy> Unable to insert retention policy")
+		}
+		return nil
+	}
+
+	p.ID = old.ID
+	query := `UPDATE workflow_retention_policy SET keep_last_runs = $2, keep_days = $3, keep_tags = $4 WHERE id = $1`
+	if _, err := db.Exec(query, p.ID, p.KeepLastRuns, p.KeepDays, tags); err != nil {
+		return sdk.WrapError(err, "UpsertRetentionPolicy> Unable to update retention policy")
+	}
+	return nil
+}
+
+// DeleteRetentionPolicy deletes the retention policy of a workflow, or of the project if workflowID is 0
+func DeleteRetentionPolicy(db gorp.SqlExecutor, projectID, workflowID int64) error {
+	query := "DELETE FROM workflow_retention_policy WHERE project_id = $1 AND COALESCE(workflow_id, 0) = $2"
+	if _, err := db.Exec(query, projectID, workflowID); err != nil {
+		return sdk.WrapError(err, "DeleteRetentionPolicy> Unable to delete retention policy")
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package workflow
 import (
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"time"
 
 	"github.com/go-gorp/gorp"
@@ -12,25 +13,88 @@ import (
 
 // insertWorkflowRun inserts in table "workflow_run""
 func insertWorkflowRun(db gorp.SqlExecutor, w *sdk.WorkflowRun) error {
+	if w.Status == "" {
+		w.Status = sdk.StatusWaiting.String()
+	}
 	runDB := Run(*w)
 	if err := db.Insert(&runDB); err != nil {
 		return sdk.WrapError(err, "insertWorkflowRun> Unable to insert run")
 	}
 	w.ID = runDB.ID
+	publishRunEvent(db, sdk.WorkflowRunEvent{Type: sdk.WorkflowRunEventRun, WorkflowRunID: w.ID})
 	return nil
 }
 
 // updateWorkflowRun updates in table "workflow_run""
 func updateWorkflowRun(db gorp.SqlExecutor, w *sdk.WorkflowRun) error {
 	w.LastModified = time.Now()
+	status, err := loadRunStatus(db, w.ID)
+	if err != nil {
+		return sdk.WrapError(err, "updateWorkflowRun> Unable to compute run status")
+	}
+	w.Status = status
 	runDB := Run(*w)
 	if _, err := db.Update(&runDB); err != nil {
 		return sdk.WrapError(err, "updateWorkflowRun> Unable to update run")
 	}
 	w.ID = runDB.ID
+	publishRunEvent(db, sdk.WorkflowRunEvent{Type: sdk.WorkflowRunEventRun, WorkflowRunID: w.ID})
+
+	//A sub workflow run reports its end to the workflow run which started it
+	if w.ParentNodeRunID != 0 {
+		if err := endSubWorkflowRun(db, w); err != nil {
+			return sdk.WrapError(err, "updateWorkflowRun> Unable to end sub workflow run %d", w.ID)
+		}
+	}
 	return nil
 }
 
+// loadRunStatus computes the status of a workflow run from the last sub run of each of its nodes,
+// with all the instances of a matrix. A run waiting for approvals, or without node run, is Waiting
+func loadRunStatus(db gorp.SqlExecutor, id int64) (string, error) {
+	var statuses []string
+	query := `select status
+	from workflow_node_run
+	where workflow_run_id = $1
+	and sub_num = (
+		select max(last.sub_num) from workflow_node_run last
+		where last.workflow_run_id = workflow_node_run.workflow_run_id
+		and last.workflow_node_id = workflow_node_run.workflow_node_id
+	)`
+	if _, err := db.Select(&statuses, query, id); err != nil {
+		return "", err
+	}
+
+	approvals, err := db.SelectInt("select count(id) from workflow_run_approval where workflow_run_id = $1 and status = $2", id, sdk.ApprovalStatusWaiting)
+	if err != nil {
+		return "", err
+	}
+
+	var building, fail, stopped bool
+	for _, s := range statuses {
+		switch s {
+		case sdk.StatusWaiting.String(), sdk.StatusBuilding.String():
+			building = true
+		case sdk.StatusFail.String():
+			fail = true
+		case sdk.StatusStopped.String():
+			stopped = true
+		}
+	}
+
+	switch {
+	case building:
+		return sdk.StatusBuilding.String(), nil
+	case approvals > 0 || len(statuses) == 0:
+		return sdk.StatusWaiting.String(), nil
+	case fail:
+		return sdk.StatusFail.String(), nil
+	case stopped:
+		return sdk.StatusStopped.String(), nil
+	}
+	return sdk.StatusSuccess.String(), nil
+}
+
 //PostInsert is a db hook on WorkflowRun
 func (r *Run) PostInsert(db gorp.SqlExecutor) error {
 	w, errw := json.Marshal(r.Workflow)
@@ -47,6 +111,10 @@ func (r *Run) PostInsert(db gorp.SqlExecutor) error {
 		return sdk.WrapError(err, "Run.PostInsert> Unable to store marshalled infos")
 	}
 
+	if err := InsertRunTags(db, r.ID, r.Tags...); err != nil {
+		return sdk.WrapError(err, "Run.PostInsert> Unable to store tags")
+	}
+
 	return nil
 }
 
@@ -82,9 +150,38 @@ func (r *Run) PostGet(db gorp.SqlExecutor) error {
 		r.Infos = i
 	}
 
+	tags, err := loadRunTags(db, r.ID)
+	if err != nil {
+		return sdk.WrapError(err, "Run.PostGet> Unable to load tags")
+	}
+	r.Tags = tags
+
+	return nil
+}
+
+// InsertRunTags adds tags on a workflow run. If the run already has a tag, its value is updated
+func InsertRunTags(db gorp.SqlExecutor, runID int64, tags ...sdk.WorkflowRunTag) error {
+	query := `INSERT INTO workflow_run_tag (workflow_run_id, tag, value) VALUES ($1, $2, $3)
+	ON CONFLICT (workflow_run_id, tag) DO UPDATE SET value = $3`
+	for _, t := range tags {
+		if t.Tag == "" {
+			return sdk.ErrWrongRequest
+		}
+		if _, err := db.Exec(query, runID, t.Tag, t.Value); err != nil {
+			return sdk.WrapError(err, "InsertRunTags> Unable to insert tag %s on run %d", t.Tag, runID)
+		}
+	}
 	return nil
 }
 
+func loadRunTags(db gorp.SqlExecutor, runID int64) ([]sdk.WorkflowRunTag, error) {
+	tags := []sdk.WorkflowRunTag{}
+	if _, err := db.Select(&tags, "select tag, coalesce(value, '') as value from workflow_run_tag where workflow_run_id = $1 order by tag", runID); err != nil {
+		return nil, err
+	}
+	return tags, nil
+}
+
 // LoadLastRun returns the last run for a workflow
 func LoadLastRun(db gorp.SqlExecutor, projectkey, workflowname string) (*sdk.WorkflowRun, error) {
 	query := `select workflow_run.*
@@ -172,6 +269,78 @@ func LoadRuns(db gorp.SqlExecutor, projectkey, workflowname string, offset, limi
 	return wruns, offset, limit, int(count), nil
 }
 
+//SearchRuns loads the runs of all the workflows of a project matching a filter, the most recent first.
+//It returns the runs and the count of all the matching runs
+func SearchRuns(db gorp.SqlExecutor, projectkey string, filter sdk.WorkflowRunFilter) ([]sdk.WorkflowRun, int, error) {
+	args := []interface{}{projectkey}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	from := `from workflow_run
+	join project on workflow_run.project_id = project.id
+	join workflow on workflow_run.workflow_id = workflow.id
+	where project.projectkey = $1`
+	if filter.Workflow != "" {
+		from += " and workflow.name = " + arg(filter.Workflow)
+	}
+	if filter.Status != "" {
+		from += " and workflow_run.status = " + arg(filter.Status)
+	}
+	if filter.Branch != "" {
+		from += " and workflow_run.vcs_branch = " + arg(filter.Branch)
+	}
+	if filter.Hash != "" {
+		from += " and workflow_run.vcs_hash = " + arg(filter.Hash)
+	}
+	if filter.Username != "" {
+		from += " and workflow_run.username = " + arg(filter.Username)
+	}
+	if filter.Since != nil {
+		from += " and workflow_run.start >= " + arg(*filter.Since)
+	}
+	if filter.Until != nil {
+		from += " and workflow_run.start <= " + arg(*filter.Until)
+	}
+	if filter.Node != "" {
+		from += ` and exists (select 1 from workflow_node_run
+		join workflow_node on workflow_node_run.workflow_node_id = workflow_node.id
+		join pipeline on workflow_node.pipeline_id = pipeline.id
+		where workflow_node_run.workflow_run_id = workflow_run.id
+		and (workflow_node.name = ` + arg(filter.Node) + ` or pipeline.name = ` + arg(filter.Node) + `))`
+	}
+	for _, t := range filter.Tags {
+		from += ` and exists (select 1 from workflow_run_tag
+		where workflow_run_tag.workflow_run_id = workflow_run.id
+		and workflow_run_tag.tag = ` + arg(t.Tag) + ` and workflow_run_tag.value = ` + arg(t.Value) + `)`
+	}
+
+	count, err := db.SelectInt("select count(workflow_run.id) "+from, args...)
+	if err != nil {
+		return nil, 0, sdk.WrapError(err, "SearchRuns> Unable to count runs")
+	}
+	if count == 0 {
+		return []sdk.WorkflowRun{}, 0, nil
+	}
+
+	// A null limit returns all the runs
+	var limit interface{}
+	if filter.Limit > 0 {
+		limit = filter.Limit
+	}
+	query := "select workflow_run.* " + from + " order by workflow_run.start desc limit " + arg(limit) + " offset " + arg(filter.Offset)
+	runs := []Run{}
+	if _, err := db.Select(&runs, query, args...); err != nil {
+		return nil, 0, sdk.WrapError(err, "SearchRuns> Unable to load runs")
+	}
+	wruns := make([]sdk.WorkflowRun, len(runs))
+	for i := range runs {
+		wruns[i] = sdk.WorkflowRun(runs[i])
+	}
+	return wruns, int(count), nil
+}
+
 func loadRun(db gorp.SqlExecutor, query string, args ...interface{}) (*sdk.WorkflowRun, error) {
 	runDB := &Run{}
 	if err := db.SelectOne(runDB, query, args...); err != nil {
@@ -182,7 +351,7 @@ func loadRun(db gorp.SqlExecutor, query string, args ...interface{}) (*sdk.Workf
 	}
 	wr := sdk.WorkflowRun(*runDB)
 
-	q := "select workflow_node_run.* from workflow_node_run where workflow_run_id = $1 ORDER BY workflow_node_run.sub_num DESC"
+	q := "select workflow_node_run.* from workflow_node_run where workflow_run_id = $1 ORDER BY workflow_node_run.sub_num DESC, workflow_node_run.id"
 	dbNodeRuns := []NodeRun{}
 	if _, err := db.Select(&dbNodeRuns, q, wr.ID); err != nil {
 		if err != sql.ErrNoRows {
@@ -879,3 +879,162 @@ func TestUpdateWorkflowWithJoins(t *testing.T) {
 
 	test.NoError(t, Delete(db, w2, u))
 }
+
+func TestWorkflowVersionsAndRestore(t *testing.T) {
+	db := test.SetupPG(t)
+	u, _ := assets.InsertAdminUser(db)
+	key := sdk.RandomString(10)
+	proj := assets.InsertTestProject(t, db, key, key, u)
+
+	pip := sdk.Pipeline{
+		ProjectID:  proj.ID,
+		ProjectKey: proj.Key,
+		Name:       "pip1",
+		Type:       sdk.BuildPipeline,
+	}
+	test.NoError(t, pipeline.InsertPipeline(db, proj, &pip, u))
+
+	w := sdk.Workflow{
+		Name:           "test_versions",
+		ProjectID:      proj.ID,
+		ProjectKey:     proj.Key,
+		VersionComment: "First version",
+		Root: &sdk.WorkflowNode{
+			Pipeline: pip,
+		},
+	}
+	test.NoError(t, Insert(db, &w, u))
+	assert.Equal(t, int64(1), w.Version)
+
+	w1, err := Load(db, key, w.Name, u)
+	test.NoError(t, err)
+	assert.Equal(t, int64(1), w1.Version)
+
+	//Add a trigger
+	w2 := *w1
+	w2.VersionComment = "Add a trigger"
+	w2.Root = &sdk.WorkflowNode{
+		Pipeline: pip,
+		Triggers: []sdk.WorkflowNodeTrigger{
+			{
+				Conditions: []sdk.WorkflowTriggerCondition{{Variable: "git.branch", Operator: "eq", Value: "master"}},
+				WorkflowDestNode: sdk.WorkflowNode{
+					Name:     "deploy",
+					Pipeline: pip,
+				},
+			},
+		},
+	}
+	test.NoError(t, Update(db, &w2, w1, u))
+	assert.Equal(t, int64(2), w2.Version)
+
+	vs, err := LoadVersions(db, w.ID)
+	test.NoError(t, err)
+	assert.Len(t, vs, 2)
+	assert.Equal(t, int64(2), vs[0].Version)
+	assert.Equal(t, "Add a trigger", vs[0].Comment)
+	assert.Equal(t, u.Username, vs[0].Author)
+	assert.Nil(t, vs[0].Workflow)
+
+	v1, err := LoadVersion(db, w.ID, 1)
+	test.NoError(t, err)
+	assert.Equal(t, "First version", v1.Comment)
+	v2, err := LoadVersion(db, w.ID, 2)
+	test.NoError(t, err)
+
+	diff := sdk.DiffWorkflows(v1.Workflow, v2.Workflow)
+	assert.Len(t, diff.Nodes, 1)
+	assert.Equal(t, "deploy", diff.Nodes[0].Name)
+	assert.Equal(t, sdk.WorkflowDiffAdded, diff.Nodes[0].Status)
+
+	_, err = LoadVersion(db, w.ID, 3)
+	assert.Error(t, err)
+
+	//Restore the first version
+	current, err := Load(db, key, w.Name, u)
+	test.NoError(t, err)
+	restored, err := Restore(db, current, 1, u)
+	test.NoError(t, err)
+	assert.Equal(t, int64(3), restored.Version)
+
+	w3, err := Load(db, key, w.Name, u)
+	test.NoError(t, err)
+	assert.Equal(t, int64(3), w3.Version)
+	assert.Len(t, w3.Root.Triggers, 0)
+
+	v3, err := LoadVersion(db, w.ID, 3)
+	test.NoError(t, err)
+	assert.Equal(t, "Restore version 1", v3.Comment)
+	diff = sdk.DiffWorkflows(v1.Workflow, v3.Workflow)
+	assert.Empty(t, diff.Nodes)
+}
+
+func TestInsertAndUpdateWorkflowWithKafkaHookSecret(t *testing.T) {
+	db := test.SetupPG(t)
+	u, _ := assets.InsertAdminUser(db)
+	key := sdk.RandomString(10)
+	proj := assets.InsertTestProject(t, db, key, key, u)
+	test.NoError(t, CreateBuiltinHookModels(db))
+
+	pip := sdk.Pipeline{
+		ProjectID:  proj.ID,
+		ProjectKey: proj.Key,
+		Name:       "pip1",
+		Type:       sdk.BuildPipeline,
+	}
+	test.NoError(t, pipeline.InsertPipeline(db, proj, &pip, u))
+
+	w := sdk.Workflow{
+		Name:       "test_kafka_hook",
+		ProjectID:  proj.ID,
+		ProjectKey: proj.Key,
+		Root: &sdk.WorkflowNode{
+			Pipeline: pip,
+			Hooks: []sdk.WorkflowNodeHook{
+				{
+					WorkflowHookModel: sdk.WorkflowHookModel{Name: sdk.KafkaHookModelName},
+					Config: sdk.WorkflowNodeHookConfig{
+						sdk.HookConfigKafkaBrokers:  "localhost:9092",
+						sdk.HookConfigKafkaTopic:    "cds",
+						sdk.HookConfigKafkaUser:     "cds",
+						sdk.HookConfigKafkaPassword: "mypassword",
+					},
+				},
+			},
+		},
+	}
+	test.NoError(t, Insert(db, &w, u))
+	uuid := w.Root.Hooks[0].UUID
+	assert.Equal(t, sdk.PasswordPlaceholder, w.Root.Hooks[0].Config[sdk.HookConfigKafkaPassword])
+
+	// The password is stored encrypted
+	var config string
+	test.NoError(t, db.QueryRow("SELECT config FROM workflow_node_hook WHERE uuid = $1", uuid).Scan(&config))
+	assert.NotContains(t, config, "mypassword")
+
+	// The password is masked when the workflow is loaded and in its versions
+	w1, err := Load(db, key, w.Name, u)
+	test.NoError(t, err)
+	assert.Equal(t, sdk.PasswordPlaceholder, w1.Root.Hooks[0].Config[sdk.HookConfigKafkaPassword])
+	v1, err := LoadVersion(db, w.ID, 1)
+	test.NoError(t, err)
+	assert.Equal(t, sdk.PasswordPlaceholder, v1.Workflow.Root.Hooks[0].Config[sdk.HookConfigKafkaPassword])
+
+	// Updating the workflow with the placeholder keeps the password
+	w2 := *w1
+	w2.VersionComment = "Update the topic"
+	w2.Root.Hooks[0].Config[sdk.HookConfigKafkaTopic] = "cds-events"
+	test.NoError(t, Update(db, &w2, w1, u))
+
+	hooks, err := LoadHooksByModelName(db, sdk.KafkaHookModelName, true)
+	test.NoError(t, err)
+	var found bool
+	for _, h := range hooks {
+		if h.UUID == uuid {
+			found = true
+			assert.Equal(t, "cds-events", h.Config[sdk.HookConfigKafkaTopic])
+			assert.Equal(t, "mypassword", h.Config[sdk.HookConfigKafkaPassword])
+		}
+	}
+	assert.True(t, found)
+}
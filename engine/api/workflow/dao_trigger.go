@@ -6,6 +6,7 @@ import (
 
 	"github.com/go-gorp/gorp"
 
+	"github.com/ovh/cds/engine/api/group"
 	"github.com/ovh/cds/sdk"
 	"github.com/ovh/cds/sdk/log"
 )
@@ -47,6 +48,27 @@ func insertTrigger(db gorp.SqlExecutor, w *sdk.Workflow, node *sdk.WorkflowNode,
 		return sdk.WrapError(err, "InsertOrUpdateTrigger> Unable to set trigger conditions in database")
 	}
 
+	//Manage approval
+	if trigger.Approval != nil {
+		if err := trigger.Approval.IsValid(); err != nil {
+			return sdk.WrapError(sdk.ErrWorkflowInvalid, "insertTrigger> Invalid approval on trigger %d: %s", trigger.ID, err)
+		}
+		if trigger.Approval.GroupID == 0 {
+			g, err := group.LoadGroup(db, trigger.Approval.GroupName)
+			if err != nil {
+				return sdk.WrapError(err, "insertTrigger> Unable to load approval group %s", trigger.Approval.GroupName)
+			}
+			trigger.Approval.GroupID = g.ID
+		}
+		b, err := json.Marshal(trigger.Approval)
+		if err != nil {
+			return sdk.WrapError(err, "insertTrigger> Unable to marshal trigger approval")
+		}
+		if _, err := db.Exec("UPDATE workflow_node_trigger SET approval = $1 where id = $2", b, trigger.ID); err != nil {
+			return sdk.WrapError(err, "insertTrigger> Unable to set trigger approval in database")
+		}
+	}
+
 	return nil
 }
 
@@ -87,6 +109,18 @@ func loadTriggers(db gorp.SqlExecutor, w *sdk.Workflow, node *sdk.WorkflowNode,
 			}
 		}
 
+		//Load approval
+		sqlApproval, err := db.SelectNullStr("select approval from workflow_node_trigger where id = $1", t.ID)
+		if err != nil {
+			return nil, sdk.WrapError(err, "LoadTriggers> Unable to load approval for trigger %d", t.ID)
+		}
+		if sqlApproval.Valid {
+			t.Approval = new(sdk.WorkflowTriggerApproval)
+			if err := json.Unmarshal([]byte(sqlApproval.String), t.Approval); err != nil {
+				return nil, sdk.WrapError(err, "LoadTriggers> Unable to unmarshall approval for trigger %d", t.ID)
+			}
+		}
+
 		triggers = append(triggers, t)
 	}
 	return triggers, nil
@@ -0,0 +1,93 @@
+package workflow
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/go-gorp/gorp"
+
+	"github.com/ovh/cds/sdk"
+)
+
+// insertVersion records an immutable snapshot of a saved workflow, and bumps the workflow version
+func insertVersion(db gorp.SqlExecutor, w *sdk.Workflow, u *sdk.User) error {
+	if err := db.QueryRow("UPDATE workflow SET version = version + 1 WHERE id = $1 RETURNING version", w.ID).Scan(&w.Version); err != nil {
+		return sdk.WrapError(err, "insertVersion> Unable to bump version of workflow %d", w.ID)
+	}
+
+	// Snapshot the workflow as it is loaded, so that it can be restored as is
+	snapshot, err := LoadByID(db, w.ID, u)
+	if err != nil {
+		return sdk.WrapError(err, "insertVersion> Unable to load workflow %d", w.ID)
+	}
+	snapshot.VersionComment = ""
+	btes, err := json.Marshal(snapshot)
+	if err != nil {
+		return sdk.WrapError(err, "insertVersion> Unable to marshal workflow %d", w.ID)
+	}
+
+	var author string
+	if u != nil {
+		author = u.Username
+	}
+	query := `INSERT INTO workflow_version (workflow_id, version, workflow, author, comment, created) VALUES ($1, $2, $3, $4, $5, $6)`
+	if _, err := db.Exec(query, w.ID, w.Version, btes, author, w.VersionComment, time.Now()); err != nil {
+		return sdk.WrapError(err, "insertVersion> Unable to insert version %d of workflow %d", w.Version, w.ID)
+	}
+	return nil
+}
+
+// LoadVersions loads the versions of a workflow, the most recent first. Snapshots are not loaded
+func LoadVersions(db gorp.SqlExecutor, workflowID int64) ([]sdk.WorkflowVersion, error) {
+	vs := []sdk.WorkflowVersion{}
+	query := `SELECT id, workflow_id, version, author, comment, created FROM workflow_version WHERE workflow_id = $1 ORDER BY version DESC`
+	if _, err := db.Select(&vs, query, workflowID); err != nil {
+		return nil, sdk.WrapError(err, "LoadVersions> Unable to load versions of workflow %d", workflowID)
+	}
+	return vs, nil
+}
+
+// LoadVersion loads a version of a workflow with its snapshot
+func LoadVersion(db gorp.SqlExecutor, workflowID, version int64) (*sdk.WorkflowVersion, error) {
+	v := sdk.WorkflowVersion{}
+	var snapshot []byte
+	query := `SELECT id, workflow_id, version, workflow, author, comment, created FROM workflow_version WHERE workflow_id = $1 AND version = $2`
+	if err := db.QueryRow(query, workflowID, version).Scan(&v.ID, &v.WorkflowID, &v.Version, &snapshot, &v.Author, &v.Comment, &v.Created); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, sdk.WrapError(sdk.ErrWorkflowVersionNotFound, "LoadVersion> Version %d of workflow %d not found", version, workflowID)
+		}
+		return nil, sdk.WrapError(err, "LoadVersion> Unable to load version %d of workflow %d", version, workflowID)
+	}
+	v.Workflow = &sdk.Workflow{}
+	if err := json.Unmarshal(snapshot, v.Workflow); err != nil {
+		return nil, sdk.WrapError(err, "LoadVersion> Unable to unmarshal version %d of workflow %d", version, workflowID)
+	}
+	return &v, nil
+}
+
+// Restore saves the snapshot of a version as a new version of the workflow.
+// The workflow keeps its current name
+func Restore(db gorp.SqlExecutor, w *sdk.Workflow, version int64, u *sdk.User) (*sdk.Workflow, error) {
+	v, err := LoadVersion(db, w.ID, version)
+	if err != nil {
+		return nil, err
+	}
+	if v.Workflow.Root == nil {
+		return nil, sdk.WrapError(sdk.ErrWorkflowInvalidRoot, "Restore> Version %d of workflow %d has no root", version, w.ID)
+	}
+
+	restored := *v.Workflow
+	restored.ID = w.ID
+	restored.Name = w.Name
+	restored.ProjectID = w.ProjectID
+	restored.ProjectKey = w.ProjectKey
+	restored.RootID = w.RootID
+	restored.Root.ID = w.RootID
+	restored.VersionComment = fmt.Sprintf("Restore version %d", version)
+	if err := Update(db, &restored, w, u); err != nil {
+		return nil, sdk.WrapError(err, "Restore> Unable to restore version %d of workflow %d", version, w.ID)
+	}
+	return &restored, nil
+}
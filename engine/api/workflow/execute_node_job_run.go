@@ -7,7 +7,9 @@ import (
 
 	"github.com/go-gorp/gorp"
 
+	"github.com/ovh/cds/engine/api/application"
 	"github.com/ovh/cds/engine/api/cache"
+	"github.com/ovh/cds/engine/api/environment"
 	"github.com/ovh/cds/engine/api/event"
 	"github.com/ovh/cds/engine/api/project"
 	"github.com/ovh/cds/engine/api/secret"
@@ -38,6 +40,10 @@ func UpdateNodeJobRunStatus(db gorp.SqlExecutor, job *sdk.WorkflowNodeJobRun, st
 	case sdk.StatusFail, sdk.StatusSuccess, sdk.StatusDisabled, sdk.StatusSkipped:
 		if currentStatus != string(sdk.StatusWaiting) && currentStatus != string(sdk.StatusBuilding) && status != sdk.StatusDisabled && status != sdk.StatusSkipped {
 			log.Debug("workflow.UpdateNodeJobRunStatus> Status is %s, cannot update %d to %s", currentStatus, job.ID, status)
+			// The worker of a stopped job has sent its result, the job can leave the queue
+			if currentStatus == sdk.StatusStopped.String() {
+				return deleteNodeJobRun(db, job.ID)
+			}
 			// too late, Nate
 			return nil
 		}
@@ -106,6 +112,7 @@ func AddSpawnInfosNodeJobRun(db gorp.SqlExecutor, id int64, infos []sdk.SpawnInf
 	if err := UpdateNodeJobRun(db, j); err != nil {
 		return nil, sdk.WrapError(err, "AddSpawnInfosNodeJobRun> Cannot update node job run")
 	}
+	publishRunEvent(db, sdk.WorkflowRunEvent{Type: sdk.WorkflowRunEventSpawnInfo, NodeRunID: j.WorkflowNodeRunID, JobRunID: j.ID, SpawnInfos: infos})
 	return j, nil
 }
 
@@ -136,6 +143,10 @@ func TakeNodeJobRun(db gorp.SqlExecutor, id int64, workerModel string, workerNam
 		return nil, sdk.WrapError(sdk.ErrAlreadyTaken, "TakeNodeJobRun> job %d is not waiting status. Current status:%s", id, job.Status)
 	}
 
+	if err := checkQueueQuotas(db, job); err != nil {
+		return nil, err
+	}
+
 	job.Model = workerModel
 	job.Job.WorkerName = workerName
 	job.Job.WorkerID = workerID
@@ -148,6 +159,7 @@ func TakeNodeJobRun(db gorp.SqlExecutor, id int64, workerModel string, workerNam
 	if err := UpdateNodeJobRunStatus(db, job, sdk.StatusBuilding); err != nil {
 		return nil, sdk.WrapError(err, "TakeNodeJobRun>Cannot update node job run")
 	}
+	publishRunEvent(db, sdk.WorkflowRunEvent{Type: sdk.WorkflowRunEventSpawnInfo, NodeRunID: job.WorkflowNodeRunID, JobRunID: job.ID, SpawnInfos: infos})
 
 	return job, nil
 }
@@ -166,48 +178,109 @@ func LoadNodeJobRunSecrets(db gorp.SqlExecutor, job *sdk.WorkflowNodeJobRun) ([]
 		return nil, sdk.WrapError(err, "LoadNodeJobRunSecrets> Unable to load workflow run")
 	}
 
-	var secrets []sdk.Variable
+	//Load node definition
+	n := w.Workflow.GetNode(node.WorkflowNodeID)
+	if n == nil {
+		return nil, sdk.WrapError(fmt.Errorf("Unable to find node %d in workflow", node.WorkflowNodeID), "LoadNodeJobRunSecrets>")
+	}
 
+	return loadNodeSecrets(db, w.Workflow.ProjectID, n)
+}
+
+// loadNodeSecrets loads and decrypts the secrets of the project, the application and the environment of a node.
+// The variables are loaded from the database: the node context doesn't hold clear secrets
+func loadNodeSecrets(db gorp.SqlExecutor, projectID int64, n *sdk.WorkflowNode) ([]sdk.Variable, error) {
 	// Load project secrets
-	pv, err := project.GetAllVariableInProject(db, w.Workflow.ProjectID, project.WithClearPassword())
+	pv, err := project.GetAllVariableInProject(db, projectID, project.WithClearPassword())
 	if err != nil {
 		return nil, err
 	}
-	pv = sdk.VariablesFilter(pv, sdk.SecretVariable, sdk.KeyVariable)
-	pv = sdk.VariablesPrefix(pv, "cds.proj")
-	secrets = append(secrets, pv...)
 
-	//Load node definition
-	n := w.Workflow.GetNode(node.WorkflowNodeID)
-	if n == nil {
-		return nil, sdk.WrapError(fmt.Errorf("Unable to find node %d in workflow", node.WorkflowNodeID), "LoadNodeJobRunSecrets>")
+	var appID, envID int64
+	if n.Context != nil {
+		appID, envID = n.Context.ApplicationID, n.Context.EnvironmentID
+		if appID == 0 && n.Context.Application != nil {
+			appID = n.Context.Application.ID
+		}
+		if envID == 0 && n.Context.Environment != nil {
+			envID = n.Context.Environment.ID
+		}
 	}
 
 	//Application variables
 	av := []sdk.Variable{}
-	if n.Context != nil && n.Context.Application != nil {
-		av = sdk.VariablesFilter(n.Context.Application.Variable, sdk.SecretVariable, sdk.KeyVariable)
-		av = sdk.VariablesPrefix(pv, "cds.app")
+	if appID != 0 {
+		av, err = application.GetAllVariableByID(db, appID, application.WithClearPassword())
+		if err != nil {
+			return nil, sdk.WrapError(err, "LoadNodeJobRunSecrets> Unable to load variables of application %d", appID)
+		}
 	}
-	secrets = append(secrets, av...)
 
 	//Environment variables
 	ev := []sdk.Variable{}
-	if n.Context != nil && n.Context.Environment != nil {
-		ev = sdk.VariablesFilter(n.Context.Environment.Variable, sdk.SecretVariable, sdk.KeyVariable)
-		ev = sdk.VariablesPrefix(pv, "cds.env")
+	if envID != 0 {
+		ev, err = environment.GetAllVariableByID(db, envID, environment.WithClearPassword())
+		if err != nil {
+			return nil, sdk.WrapError(err, "LoadNodeJobRunSecrets> Unable to load variables of environment %d", envID)
+		}
+	}
+
+	secrets := nodeJobRunSecrets(pv, av, ev)
+
+	//Decrypt secrets, and read the secrets of vault variables in the vault of the project
+	loadVault := func() (*sdk.ProjectVault, error) {
+		pvault, err := project.LoadVault(db, projectID)
+		if err != nil && err != sdk.ErrNotFound {
+			return nil, sdk.WrapError(err, "LoadNodeJobRunSecrets> Unable to load vault of project %d", projectID)
+		}
+		return pvault, nil
 	}
-	secrets = append(secrets, ev...)
+	if err := decryptNodeJobRunSecrets(secrets, loadVault); err != nil {
+		return nil, err
+	}
+
+	return secrets, nil
+}
+
+// nodeJobRunSecrets returns the secret, key and vault variables of the project, the application and the
+// environment of a node
+func nodeJobRunSecrets(pv, av, ev []sdk.Variable) []sdk.Variable {
+	var secrets []sdk.Variable
+
+	pv = sdk.VariablesFilter(pv, sdk.SecretVariable, sdk.KeyVariable, sdk.VaultVariable)
+	secrets = append(secrets, sdk.VariablesPrefix(pv, "cds.proj")...)
 
-	//Decrypt secrets
+	av = sdk.VariablesFilter(av, sdk.SecretVariable, sdk.KeyVariable, sdk.VaultVariable)
+	secrets = append(secrets, sdk.VariablesPrefix(av, "cds.app")...)
+
+	ev = sdk.VariablesFilter(ev, sdk.SecretVariable, sdk.KeyVariable, sdk.VaultVariable)
+	return append(secrets, sdk.VariablesPrefix(ev, "cds.env")...)
+}
+
+// decryptNodeJobRunSecrets decrypts secrets, and reads the secrets of the vault variables in the vault of the
+// project, loaded with loadVault the first time it is needed
+func decryptNodeJobRunSecrets(secrets []sdk.Variable, loadVault func() (*sdk.ProjectVault, error)) error {
+	var pvault *sdk.ProjectVault
 	for i := range secrets {
 		s := &secrets[i]
+		if s.Type == sdk.VaultVariable {
+			if pvault == nil {
+				var err error
+				pvault, err = loadVault()
+				if err != nil {
+					return err
+				}
+			}
+			if err := secret.DecryptVaultVariable(s, pvault); err != nil {
+				return sdk.WrapError(err, "LoadNodeJobRunSecrets> Unable to resolve vault variable %s", s.Name)
+			}
+			continue
+		}
 		if err := secret.DecryptVariable(s); err != nil {
-			return nil, sdk.WrapError(err, "LoadNodeJobRunSecrets> Unable to decrypt variables")
+			return sdk.WrapError(err, "LoadNodeJobRunSecrets> Unable to decrypt variables")
 		}
 	}
-
-	return secrets, nil
+	return nil
 }
 
 //BookNodeJobRun  Book a job for a hatchery
@@ -246,5 +319,6 @@ func AddLog(db gorp.SqlExecutor, job *sdk.WorkflowNodeJobRun, logs *sdk.Log) err
 			return sdk.WrapError(err, "AddLog> Cannot update log")
 		}
 	}
+	publishRunEvent(db, sdk.WorkflowRunEvent{Type: sdk.WorkflowRunEventLog, NodeRunID: logs.PipelineBuildID, JobRunID: logs.PipelineBuildJobID, Log: logs})
 	return nil
 }
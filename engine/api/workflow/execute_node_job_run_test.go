@@ -0,0 +1,101 @@
+package workflow
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ovh/cds/engine/api/application"
+	"github.com/ovh/cds/engine/api/environment"
+	"github.com/ovh/cds/engine/api/pipeline"
+	"github.com/ovh/cds/engine/api/project"
+	"github.com/ovh/cds/engine/api/secret"
+	"github.com/ovh/cds/engine/api/test"
+	"github.com/ovh/cds/engine/api/test/assets"
+	"github.com/ovh/cds/sdk"
+)
+
+func TestNodeJobRunSecretsResolveApplicationVaultVariable(t *testing.T) {
+	secret.InitVault(&secret.VaultMock{
+		Secrets: map[string]map[string]string{
+			"secret/cds/PROJ/app/db": {"data": "app-s3cr3t"},
+			"secret/cds/PROJ/env/db": {"data": "env-s3cr3t"},
+		},
+		Policies: map[string][]string{
+			"proj": {"secret/cds/PROJ/"},
+		},
+	})
+	defer secret.InitVault(nil)
+
+	pv := []sdk.Variable{{Name: ".name", Type: sdk.StringVariable, Value: "proj"}}
+	av := []sdk.Variable{
+		{Name: ".db", Type: sdk.VaultVariable, Value: "app/db"},
+		{Name: ".name", Type: sdk.StringVariable, Value: "app"},
+	}
+	ev := []sdk.Variable{{Name: ".db", Type: sdk.VaultVariable, Value: "env/db"}}
+
+	secrets := nodeJobRunSecrets(pv, av, ev)
+	loadVault := func() (*sdk.ProjectVault, error) {
+		return &sdk.ProjectVault{Mount: "secret/cds/PROJ", Policy: "proj"}, nil
+	}
+	assert.NoError(t, decryptNodeJobRunSecrets(secrets, loadVault))
+
+	assert.Equal(t, []sdk.Variable{
+		{Name: "cds.app.db", Type: sdk.SecretVariable, Value: "app-s3cr3t"},
+		{Name: "cds.env.db", Type: sdk.SecretVariable, Value: "env-s3cr3t"},
+	}, secrets)
+}
+
+func TestLoadNodeSecrets(t *testing.T) {
+	db := test.SetupPG(t)
+	u, _ := assets.InsertAdminUser(db)
+	key := sdk.RandomString(10)
+	proj := assets.InsertTestProject(t, db, key, key, u)
+
+	secret.InitVault(&secret.VaultMock{
+		Secrets:  map[string]map[string]string{"secret/cds/" + key + "/app/db": {"data": "app-s3cr3t"}},
+		Policies: map[string][]string{"proj": {"secret/cds/" + key + "/"}},
+	})
+	defer secret.InitVault(nil)
+	test.NoError(t, project.UpsertVault(db, &sdk.ProjectVault{ProjectID: proj.ID, Mount: "secret/cds/" + key, Policy: "proj"}))
+
+	pip := sdk.Pipeline{ProjectID: proj.ID, ProjectKey: proj.Key, Name: "pip1", Type: sdk.BuildPipeline}
+	test.NoError(t, pipeline.InsertPipeline(db, proj, &pip, u))
+
+	app := sdk.Application{ProjectID: proj.ID, ProjectKey: proj.Key, Name: "app1"}
+	test.NoError(t, application.Insert(db, proj, &app, u))
+	test.NoError(t, application.InsertVariable(db, &app, sdk.Variable{Name: "password", Type: sdk.SecretVariable, Value: "app-password"}, u))
+	test.NoError(t, application.InsertVariable(db, &app, sdk.Variable{Name: "db", Type: sdk.VaultVariable, Value: "app/db"}, u))
+	test.NoError(t, application.InsertVariable(db, &app, sdk.Variable{Name: "name", Type: sdk.StringVariable, Value: "app1"}, u))
+
+	env := sdk.Environment{ProjectID: proj.ID, ProjectKey: proj.Key, Name: "env1"}
+	test.NoError(t, environment.InsertEnvironment(db, &env))
+	test.NoError(t, environment.InsertVariable(db, env.ID, &sdk.Variable{Name: "password", Type: sdk.SecretVariable, Value: "env-password"}, u))
+
+	w := sdk.Workflow{
+		Name:       "test_1",
+		ProjectID:  proj.ID,
+		ProjectKey: proj.Key,
+		Root: &sdk.WorkflowNode{
+			Pipeline: pip,
+			Context:  &sdk.WorkflowNodeContext{Application: &app, Environment: &env},
+		},
+	}
+	test.NoError(t, Insert(db, &w, u))
+
+	// The node context of a loaded workflow doesn't hold the clear secrets
+	w1, err := Load(db, key, "test_1", u)
+	test.NoError(t, err)
+
+	secrets, err := loadNodeSecrets(db, proj.ID, w1.Root)
+	test.NoError(t, err)
+
+	values := map[string]string{}
+	for _, s := range secrets {
+		values[s.Name] = s.Value
+	}
+	assert.Equal(t, "app-password", values["cds.app.password"])
+	assert.Equal(t, "app-s3cr3t", values["cds.app.db"])
+	assert.Equal(t, "env-password", values["cds.env.password"])
+	assert.NotContains(t, values, "cds.app.name")
+}
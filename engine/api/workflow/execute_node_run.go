@@ -1,6 +1,7 @@
 package workflow
 
 import (
+	"database/sql"
 	"fmt"
 	"time"
 
@@ -64,6 +65,12 @@ func execute(db gorp.SqlExecutor, n *sdk.WorkflowNodeRun) (err error) {
 		return nil
 	}
 
+	//The status of a sub workflow node run is the status of the sub workflow run
+	if n.SubWorkflowRunID != 0 {
+		return nil
+	}
+
+	var oldStatus = n.Status
 	var newStatus = n.Status
 
 	//If no stages ==> success
@@ -108,9 +115,9 @@ func execute(db gorp.SqlExecutor, n *sdk.WorkflowNodeRun) (err error) {
 			}
 			if end {
 				//The stage is over
-				if stage.Status == sdk.StatusFail {
+				if stage.Status == sdk.StatusFail || stage.Status == sdk.StatusStopped {
 					n.Done = time.Now()
-					newStatus = sdk.StatusFail.String()
+					newStatus = stage.Status.String()
 					break
 				}
 				if stageIndex == len(n.Stages)-1 {
@@ -142,6 +149,11 @@ func execute(db gorp.SqlExecutor, n *sdk.WorkflowNodeRun) (err error) {
 		if err := processWorkflowRun(db, updatedWorkflowRun, nil, nil, nil); err != nil {
 			return sdk.WrapError(err, "workflow.execute> Unable to reprocess workflow !")
 		}
+	} else if n.Status != oldStatus {
+		//Update the status of the workflow run
+		if err := updateWorkflowRun(db, updatedWorkflowRun); err != nil {
+			return sdk.WrapError(err, "workflow.execute> Unable to update workflow run id=%d", n.WorkflowRunID)
+		}
 	}
 
 	//Delete jobs only when node is over
@@ -173,61 +185,195 @@ func addJobsToQueue(db gorp.SqlExecutor, stage *sdk.Stage, run *sdk.WorkflowNode
 
 	//Browse the jobs
 	for _, job := range stage.Jobs {
-		//Process variables for the jobs
-		jobParams, errParam := getNodeJobRunParameters(db, job, run, stage)
-
-		//Create the job run
-		job := sdk.WorkflowNodeJobRun{
-			WorkflowNodeRunID: run.ID,
-			Start:             time.Time{},
-			Queued:            time.Now(),
-			Status:            sdk.StatusWaiting.String(),
-			Parameters:        jobParams,
-			Job: sdk.ExecutedJob{
-				Job: job,
-			},
+		if err := addJobToQueue(db, stage, run, job, conditionsOK); err != nil {
+			return err
 		}
+	}
 
-		if !stage.Enabled || !job.Job.Enabled {
-			job.Status = sdk.StatusDisabled.String()
-		} else if !conditionsOK {
-			job.Status = sdk.StatusSkipped.String()
-		}
+	return nil
+}
 
-		if errParam != nil {
-			job.Status = sdk.StatusFail.String()
+func addJobToQueue(db gorp.SqlExecutor, stage *sdk.Stage, run *sdk.WorkflowNodeRun, j sdk.Job, conditionsOK bool) error {
+	//Process variables for the jobs
+	jobParams, errParam := getNodeJobRunParameters(db, j, run, stage)
+
+	//Create the job run
+	job := sdk.WorkflowNodeJobRun{
+		WorkflowNodeRunID: run.ID,
+		Start:             time.Time{},
+		Queued:            time.Now(),
+		Status:            sdk.StatusWaiting.String(),
+		Parameters:        jobParams,
+		Job: sdk.ExecutedJob{
+			Job: j,
+		},
+	}
 
-			errm, ok := errParam.(*sdk.MultiError)
-			spawnInfos := sdk.SpawnMsg{
-				ID: sdk.MsgSpawnInfoJobError.ID,
-			}
+	if !stage.Enabled || !job.Job.Enabled {
+		job.Status = sdk.StatusDisabled.String()
+	} else if !conditionsOK {
+		job.Status = sdk.StatusSkipped.String()
+	}
 
-			if ok {
-				for _, e := range *errm {
-					spawnInfos.Args = append(spawnInfos.Args, e.Error())
-				}
-			} else {
-				spawnInfos.Args = []interface{}{errParam.Error()}
+	if errParam != nil {
+		job.Status = sdk.StatusFail.String()
+
+		errm, ok := errParam.(*sdk.MultiError)
+		spawnInfos := sdk.SpawnMsg{
+			ID: sdk.MsgSpawnInfoJobError.ID,
+		}
+
+		if ok {
+			for _, e := range *errm {
+				spawnInfos.Args = append(spawnInfos.Args, e.Error())
 			}
+		} else {
+			spawnInfos.Args = []interface{}{errParam.Error()}
+		}
+
+		job.SpawnInfos = []sdk.SpawnInfo{sdk.SpawnInfo{
+			APITime:    time.Now(),
+			Message:    spawnInfos,
+			RemoteTime: time.Now(),
+		}}
 
+	}
+
+	//Check the max queued jobs of the quotas
+	if job.Status == sdk.StatusWaiting.String() {
+		s, errq := checkQueueQuotasOnQueue(db, run)
+		if errq != nil {
+			return sdk.WrapError(errq, "addJobsToQueue> Unable to check queue quotas")
+		}
+		if s != nil {
+			job.Status = sdk.StatusFail.String()
 			job.SpawnInfos = []sdk.SpawnInfo{sdk.SpawnInfo{
 				APITime:    time.Now(),
-				Message:    spawnInfos,
+				Message:    sdk.SpawnMsg{ID: sdk.MsgSpawnInfoJobError.ID, Args: []interface{}{s.String()}},
 				RemoteTime: time.Now(),
 			}}
+		}
+	}
+
+	//Insert in database
+	if err := insertWorkflowNodeJobRun(db, &job); err != nil {
+		return sdk.WrapError(err, "addJobsToQueue> Unable to insert in table workflow_node_run_job")
+	}
+
+	//Put the job run in database
+	event.PublishJobRun(run, &job)
+	stage.RunJobs = append(stage.RunJobs, job)
+
+	return nil
+}
+
+// stopNodeRun stops a waiting or building node run. The waiting jobs are removed from the queue, the building jobs
+// are kept with the Stopped status until their worker, which checks the status of its job, aborts and sends its result
+func stopNodeRun(db gorp.SqlExecutor, n *sdk.WorkflowNodeRun) error {
+	if n.Status != sdk.StatusWaiting.String() && n.Status != sdk.StatusBuilding.String() {
+		return nil
+	}
+
+	// Stop the node run first: updating its jobs executes it again
+	now := time.Now()
+	n.Status = sdk.StatusStopped.String()
+	n.Done = now
+	stopped := []*sdk.WorkflowNodeJobRun{}
+	for i := range n.Stages {
+		stage := &n.Stages[i]
+		if stage.Status == sdk.StatusWaiting || stage.Status == sdk.StatusBuilding {
+			stage.Status = sdk.StatusStopped
+		}
+		for j := range stage.RunJobs {
+			runJob := &stage.RunJobs[j]
+			if runJob.Status != sdk.StatusWaiting.String() && runJob.Status != sdk.StatusBuilding.String() {
+				continue
+			}
+			runJob.Status = sdk.StatusStopped.String()
+			runJob.Done = now
+			stopSteps(runJob)
+			stopped = append(stopped, runJob)
+		}
+	}
+	if err := UpdateNodeRun(db, n); err != nil {
+		return sdk.WrapError(err, "stopNodeRun> Unable to update node run %d", n.ID)
+	}
+
+	for _, runJob := range stopped {
+		if err := stopNodeJobRun(db, runJob.ID, now); err != nil {
+			return err
+		}
+		event.PublishJobRun(n, runJob)
+	}
+	return nil
+}
+
+func stopNodeJobRun(db gorp.SqlExecutor, id int64, now time.Time) error {
+	job, err := LoadAndLockNodeJobRun(db, id)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil
+		}
+		return sdk.WrapError(err, "stopNodeJobRun> Unable to lock job %d", id)
+	}
 
+	switch job.Status {
+	case sdk.StatusWaiting.String():
+		if err := deleteNodeJobRun(db, job.ID); err != nil {
+			return sdk.WrapError(err, "stopNodeJobRun> Unable to remove job %d from queue", job.ID)
+		}
+	case sdk.StatusBuilding.String():
+		job.Status = sdk.StatusStopped.String()
+		job.Done = now
+		stopSteps(job)
+		if err := UpdateNodeJobRun(db, job); err != nil {
+			return sdk.WrapError(err, "stopNodeJobRun> Unable to stop job %d", job.ID)
 		}
+	}
+	return nil
+}
 
-		//Insert in database
-		if err := insertWorkflowNodeJobRun(db, &job); err != nil {
-			return sdk.WrapError(err, "addJobsToQueue> Unable to insert in table workflow_node_run_job")
+func stopSteps(job *sdk.WorkflowNodeJobRun) {
+	for i := range job.Job.StepStatus {
+		if job.Job.StepStatus[i].Status == sdk.StatusBuilding.String() {
+			job.Job.StepStatus[i].Status = sdk.StatusStopped.String()
 		}
+	}
+}
 
-		//Put the job run in database
-		event.PublishJobRun(run, &job)
-		stage.RunJobs = append(stage.RunJobs, job)
+// restartFailedJobs prepares the stages of a node run restarted from a failed or stopped one: the stages over before
+// the first failed one are kept, the failed and stopped jobs of this stage are queued again, the next stages are reset
+func restartFailedJobs(db gorp.SqlExecutor, n *sdk.WorkflowNodeRun) error {
+	restarted := false
+	for i := range n.Stages {
+		stage := &n.Stages[i]
+		if restarted {
+			stage.Status = ""
+			stage.RunJobs = nil
+			continue
+		}
+		if stage.Status != sdk.StatusFail && stage.Status != sdk.StatusStopped {
+			continue
+		}
+
+		runJobs := stage.RunJobs
+		stage.RunJobs = nil
+		for _, runJob := range runJobs {
+			if runJob.Status != sdk.StatusFail.String() && runJob.Status != sdk.StatusStopped.String() {
+				stage.RunJobs = append(stage.RunJobs, runJob)
+				continue
+			}
+			if err := addJobToQueue(db, stage, n, runJob.Job.Job, true); err != nil {
+				return err
+			}
+		}
+		stage.Status = sdk.StatusWaiting
+		restarted = true
 	}
 
+	if !restarted {
+		return sdk.WrapError(sdk.ErrWrongRequest, "restartFailedJobs> Node run %d has no failed stage", n.ID)
+	}
 	return nil
 }
 
@@ -283,6 +429,9 @@ func syncStage(db gorp.SqlExecutor, stage *sdk.Stage) (bool, error) {
 			case sdk.StatusFail.String():
 				finalStatus = sdk.StatusFail
 				break finalStageLoop
+			case sdk.StatusStopped.String():
+				finalStatus = sdk.StatusStopped
+				break finalStageLoop
 			case sdk.StatusSuccess.String():
 				if finalStatus != sdk.StatusFail {
 					finalStatus = sdk.StatusSuccess
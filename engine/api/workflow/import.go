@@ -0,0 +1,134 @@
+package workflow
+
+import (
+	"github.com/go-gorp/gorp"
+	"github.com/pkg/errors"
+
+	"github.com/ovh/cds/engine/api/pipeline"
+	"github.com/ovh/cds/sdk"
+)
+
+// Import inserts a workflow described by names (ie. from exportentities.Workflow), or updates it if it exists and force is true.
+// The project must be loaded with its pipelines, applications and environments
+func Import(db gorp.SqlExecutor, proj *sdk.Project, w *sdk.Workflow, force bool, u *sdk.User) error {
+	w.ProjectID = proj.ID
+	w.ProjectKey = proj.Key
+
+	if w.Root == nil {
+		return sdk.ErrWorkflowInvalidRoot
+	}
+	if err := resolveNodeNames(db, proj, w.Root); err != nil {
+		return err
+	}
+	for i := range w.Joins {
+		j := &w.Joins[i]
+		for k := range j.Triggers {
+			if err := resolveNodeNames(db, proj, &j.Triggers[k].WorkflowDestNode); err != nil {
+				return err
+			}
+		}
+	}
+
+	if w.VersionComment == "" {
+		w.VersionComment = "Import"
+	}
+
+	oldW, errL := Load(db, proj.Key, w.Name, u)
+	if errL != nil && errors.Cause(errL) != sdk.ErrWorkflowNotFound {
+		return sdk.WrapError(errL, "Import> Unable to load workflow %s", w.Name)
+	}
+
+	if oldW == nil {
+		if err := Insert(db, w, u); err != nil {
+			return sdk.WrapError(err, "Import> Unable to insert workflow %s", w.Name)
+		}
+		return nil
+	}
+
+	if !force {
+		return sdk.WrapError(sdk.ErrAlreadyExist, "Import> Workflow %s already exists", w.Name)
+	}
+
+	w.ID = oldW.ID
+	if err := Update(db, w, oldW, u); err != nil {
+		return sdk.WrapError(err, "Import> Unable to update workflow %s", w.Name)
+	}
+	return nil
+}
+
+// resolveNodeNames sets the IDs of the pipelines, applications and environments of the node and of the nodes it triggers
+func resolveNodeNames(db gorp.SqlExecutor, proj *sdk.Project, n *sdk.WorkflowNode) error {
+	//A sub workflow is resolved by name when the node is inserted
+	if n.SubWorkflow == nil {
+		var pip *sdk.Pipeline
+		for i := range proj.Pipelines {
+			if proj.Pipelines[i].Name == n.Pipeline.Name {
+				pip = &proj.Pipelines[i]
+				break
+			}
+		}
+		if pip == nil {
+			return sdk.WrapError(sdk.ErrWorkflowInvalid, "resolveNodeNames> Unknown pipeline %s", n.Pipeline.Name)
+		}
+		n.Pipeline = *pip
+		n.PipelineID = pip.ID
+	}
+
+	if n.Context == nil {
+		n.Context = &sdk.WorkflowNodeContext{}
+	}
+
+	if n.Context.Application != nil {
+		var found bool
+		for i := range proj.Applications {
+			if proj.Applications[i].Name == n.Context.Application.Name {
+				n.Context.Application = &proj.Applications[i]
+				n.Context.ApplicationID = proj.Applications[i].ID
+				found = true
+				break
+			}
+		}
+		if !found {
+			return sdk.WrapError(sdk.ErrWorkflowInvalid, "resolveNodeNames> Unknown application %s", n.Context.Application.Name)
+		}
+	}
+
+	if n.Context.Environment != nil {
+		var found bool
+		for i := range proj.Environments {
+			if proj.Environments[i].Name == n.Context.Environment.Name {
+				n.Context.Environment = &proj.Environments[i]
+				n.Context.EnvironmentID = proj.Environments[i].ID
+				found = true
+				break
+			}
+		}
+		if !found {
+			return sdk.WrapError(sdk.ErrWorkflowInvalid, "resolveNodeNames> Unknown environment %s", n.Context.Environment.Name)
+		}
+	}
+
+	// Exported parameters only have a value, the type comes from the pipeline. The parameters of a sub workflow are strings
+	if len(n.Context.DefaultPipelineParameters) > 0 && n.SubWorkflow == nil {
+		params, err := pipeline.GetAllParametersInPipeline(db, n.PipelineID)
+		if err != nil {
+			return sdk.WrapError(err, "resolveNodeNames> Unable to load parameters of pipeline %s", n.Pipeline.Name)
+		}
+		for i := range n.Context.DefaultPipelineParameters {
+			p := &n.Context.DefaultPipelineParameters[i]
+			for _, pp := range params {
+				if pp.Name == p.Name {
+					p.Type = pp.Type
+					break
+				}
+			}
+		}
+	}
+
+	for i := range n.Triggers {
+		if err := resolveNodeNames(db, proj, &n.Triggers[i].WorkflowDestNode); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,11 +2,16 @@ package workflow
 
 import (
 	"fmt"
+	"sort"
 	"time"
 
 	"github.com/fsamin/go-dump"
 	"github.com/go-gorp/gorp"
 
+	"github.com/ovh/cds/engine/api/environment"
+	"github.com/ovh/cds/engine/api/group"
+	"github.com/ovh/cds/engine/api/permission"
+	"github.com/ovh/cds/engine/api/user"
 	"github.com/ovh/cds/sdk"
 	"github.com/ovh/cds/sdk/log"
 )
@@ -59,6 +64,20 @@ func processWorkflowRun(db gorp.SqlExecutor, w *sdk.WorkflowRun, hookEvent *sdk.
 
 			//Trigger only if the node is over (successfull or not)
 			if nodeRun.Status == string(sdk.StatusSuccess) || nodeRun.Status == string(sdk.StatusFail) {
+				//A node run of a matrix triggers only once all the instances of the matrix are over.
+				//The triggers are then processed for the first instance, with all the instances as sources
+				sourceNodeRuns := []int64{nodeRun.ID}
+				if len(nodeRun.Matrix) > 0 {
+					instances := nodeRunInstances(w, nodeRun.WorkflowNodeID, nodeRun.SubNumber)
+					if !nodeRunsOver(instances) || instances[0].ID != nodeRun.ID {
+						continue
+					}
+					sourceNodeRuns = make([]int64, len(instances))
+					for x := range instances {
+						sourceNodeRuns[x] = instances[x].ID
+					}
+				}
+
 				//Find the node in the workflow
 				node := w.Workflow.GetNode(nodeRun.WorkflowNodeID)
 				if node == nil {
@@ -103,10 +122,23 @@ func processWorkflowRun(db gorp.SqlExecutor, w *sdk.WorkflowRun, hookEvent *sdk.
 						}
 					}
 
+					//Wait for the approval before starting the destination node
+					if !abortTrigger && t.Approval != nil {
+						approved, erra := checkTriggerApproval(db, w, nodeRun, t)
+						if erra != nil {
+							log.Error("processWorkflowRun> Unable to check approval of trigger %d: %s", t.ID, erra)
+							AddWorkflowRunInfo(w, sdk.SpawnMsg{
+								ID:   sdk.MsgWorkflowError.ID,
+								Args: []interface{}{erra},
+							})
+						}
+						abortTrigger = !approved
+					}
+
 					if !abortTrigger {
 						//Keep the subnumber of the previous node in the graph
 						log.Debug("processWorkflowRun> starting from trigger %#v", t)
-						if err := processWorkflowNodeRun(db, w, &t.WorkflowDestNode, int(nodeRun.SubNumber), []int64{nodeRun.ID}, nil, nil); err != nil {
+						if err := processWorkflowNodeRun(db, w, &t.WorkflowDestNode, int(nodeRun.SubNumber), sourceNodeRuns, nil, nil); err != nil {
 							log.Error("processWorkflowRun> Unable to process node ID=%d: %s", t.WorkflowDestNode.ID, err)
 							AddWorkflowRunInfo(w, sdk.SpawnMsg{
 								ID:   sdk.MsgWorkflowError.ID,
@@ -122,51 +154,43 @@ func processWorkflowRun(db gorp.SqlExecutor, w *sdk.WorkflowRun, hookEvent *sdk.
 	//Checks the joins
 	for i := range w.Workflow.Joins {
 		j := &w.Workflow.Joins[i]
-		sources := map[int64]*sdk.WorkflowNodeRun{}
+		sources := map[int64][]*sdk.WorkflowNodeRun{}
 
-		//we have to check noderun for every sources
+		//we have to check the last noderuns for every sources, all the instances of a matrix are needed
 		for _, id := range j.SourceNodeIDs {
-			sources[id] = nil
-			for k, v := range w.WorkflowNodeRuns {
-				for x := range v {
-					nodeRun := &w.WorkflowNodeRuns[k][x]
-					if nodeRun.WorkflowNodeID == id {
-						//We found the source in the list of the noderuns
-						sources[id] = nodeRun
-					}
-				}
-
-			}
+			sources[id] = lastNodeRuns(w, id)
 		}
 
 		//now checks if all sources have been completed
 		var ok = true
 		nodeRunIDs := []int64{}
 		sourcesParams := map[string]string{}
-		for _, nodeRun := range sources {
-			if nodeRun == nil {
+		for _, nodeRuns := range sources {
+			if len(nodeRuns) == 0 {
 				//One of the sources have not been started
 				ok = false
 				break
 			}
-			if nodeRun.Status != string(sdk.StatusSuccess) && nodeRun.Status != string(sdk.StatusFail) {
+			if !nodeRunsOver(nodeRuns) {
 				//One of the sources have not been completed
 				ok = false
 				break
 			}
 
-			nodeRunIDs = append(nodeRunIDs, nodeRun.ID)
-			//Merge build parameters from all sources
-			sourcesParams = sdk.ParametersMapMerge(sourcesParams, sdk.ParametersToMap(nodeRun.BuildParameters))
+			for _, nodeRun := range nodeRuns {
+				nodeRunIDs = append(nodeRunIDs, nodeRun.ID)
+				//Merge build parameters from all sources
+				sourcesParams = sdk.ParametersMapMerge(sourcesParams, sdk.ParametersToMap(nodeRun.BuildParameters))
+			}
 		}
 
 		//All the sources are completed
 		if ok {
 			//Keep a ref to the sources
-			nodeRun := sources[j.SourceNodeIDs[0]]
-			if nodeRun == nil {
+			if len(sources[j.SourceNodeIDs[0]]) == 0 {
 				return fmt.Errorf("processWorkflowRun> this should not append %#v", w)
 			}
+			nodeRun := sources[j.SourceNodeIDs[0]][0]
 
 			//All the sources are completed
 			//Checks the triggers
@@ -230,7 +254,46 @@ func processWorkflowRun(db gorp.SqlExecutor, w *sdk.WorkflowRun, hookEvent *sdk.
 	return nil
 }
 
-//processWorkflowNodeRun triggers execution of a node run
+//nodeRunInstances returns the node runs of a node for a subnumber, ordered by id.
+//There are several node runs for a node with a matrix
+func nodeRunInstances(w *sdk.WorkflowRun, nodeID int64, subnumber int64) []*sdk.WorkflowNodeRun {
+	res := []*sdk.WorkflowNodeRun{}
+	for i := range w.WorkflowNodeRuns[nodeID] {
+		nodeRun := &w.WorkflowNodeRuns[nodeID][i]
+		if nodeRun.SubNumber == subnumber {
+			res = append(res, nodeRun)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].ID < res[j].ID })
+	return res
+}
+
+//lastNodeRuns returns the node runs of a node with the highest subnumber
+func lastNodeRuns(w *sdk.WorkflowRun, nodeID int64) []*sdk.WorkflowNodeRun {
+	if len(w.WorkflowNodeRuns[nodeID]) == 0 {
+		return nil
+	}
+	var subnumber int64 = -1
+	for _, nodeRun := range w.WorkflowNodeRuns[nodeID] {
+		if nodeRun.SubNumber > subnumber {
+			subnumber = nodeRun.SubNumber
+		}
+	}
+	return nodeRunInstances(w, nodeID, subnumber)
+}
+
+//nodeRunsOver returns true if all the node runs are over (successfull or not)
+func nodeRunsOver(nodeRuns []*sdk.WorkflowNodeRun) bool {
+	for _, nodeRun := range nodeRuns {
+		if nodeRun.Status != string(sdk.StatusSuccess) && nodeRun.Status != string(sdk.StatusFail) {
+			return false
+		}
+	}
+	return true
+}
+
+//processWorkflowNodeRun triggers execution of a node run.
+//A node with a matrix is run once per combination of the matrix
 func processWorkflowNodeRun(db gorp.SqlExecutor, w *sdk.WorkflowRun, n *sdk.WorkflowNode, subnumber int, sourceNodeRuns []int64, h *sdk.WorkflowNodeRunHookEvent, m *sdk.WorkflowNodeRunManual) error {
 	t0 := time.Now()
 	log.Debug("processWorkflowNodeRun> Begin [#%d.%d]%s.%d", w.Number, subnumber, w.Workflow.Name, n.ID)
@@ -238,6 +301,129 @@ func processWorkflowNodeRun(db gorp.SqlExecutor, w *sdk.WorkflowRun, n *sdk.Work
 		log.Debug("processWorkflowNodeRun> End [#%d.%d]%s.%d  - %.3fs", w.Number, subnumber, w.Workflow.Name, n.ID, time.Since(t0).Seconds())
 	}()
 
+	//The user starting the node must be allowed to deploy on its environment
+	if err := checkNodeRunDeployment(db, w, n, m); err != nil {
+		if m != nil {
+			return sdk.WrapError(err, "processWorkflowNodeRun> Unable to run node %d", n.ID)
+		}
+		//A triggered node is not run, the workflow run keeps going
+		AddWorkflowRunInfo(w, sdk.SpawnMsg{
+			ID:   sdk.MsgWorkflowError.ID,
+			Args: []interface{}{err},
+		})
+		return updateWorkflowRun(db, w)
+	}
+
+	combinations := n.Matrix.Combinations()
+	if combinations == nil {
+		combinations = []map[string]string{nil}
+	}
+
+	runs := make([]*sdk.WorkflowNodeRun, 0, len(combinations))
+	for _, combination := range combinations {
+		run, err := insertNodeRunInstance(db, w, n, subnumber, sourceNodeRuns, h, m, combination)
+		if err != nil {
+			return err
+		}
+		runs = append(runs, run)
+	}
+
+	//The first node run gives the git context and the user of the workflow run
+	if w.VCSBranch == "" {
+		w.VCSBranch = sdk.ParameterValue(runs[0].BuildParameters, "git.branch")
+	}
+	if w.VCSHash == "" {
+		w.VCSHash = sdk.ParameterValue(runs[0].BuildParameters, "git.hash")
+	}
+	if w.Username == "" && m != nil {
+		w.Username = m.User.Username
+	}
+
+	//Update the workflow run
+	if err := updateWorkflowRun(db, w); err != nil {
+		return sdk.WrapError(err, "processWorkflowNodeRun> unable to update workflow run")
+	}
+
+	//Execute the node runs ! They are all inserted first, so that the triggers wait for all the instances of a matrix
+	for _, run := range runs {
+		if n.SubWorkflow != nil {
+			if err := startSubWorkflowRun(db, w, n, run); err != nil {
+				return sdk.WrapError(err, "processWorkflowNodeRun> unable to start sub workflow run")
+			}
+			continue
+		}
+		if err := execute(db, run); err != nil {
+			return sdk.WrapError(err, "processWorkflowNodeRun> unable to execute workflow run")
+		}
+	}
+
+	return nil
+}
+
+//checkNodeRunDeployment checks the user starting the node has the deploy capability on its environment.
+//A triggered node is started by the user of the workflow run. A workflow run started by a hook has no user
+func checkNodeRunDeployment(db gorp.SqlExecutor, w *sdk.WorkflowRun, n *sdk.WorkflowNode, m *sdk.WorkflowNodeRunManual) error {
+	if nodeEnvironmentID(n) == 0 {
+		return nil
+	}
+	switch {
+	case m != nil:
+		return CheckDeployCapability(n, &m.User)
+	case w.Username != "":
+		u, err := loadUserEnvironmentPermissions(db, w.Username)
+		if err != nil {
+			return sdk.WrapError(err, "checkNodeRunDeployment> Unable to load user %s", w.Username)
+		}
+		return CheckDeployCapability(n, u)
+	}
+	return nil
+}
+
+//CheckDeployCapability returns sdk.ErrNoEnvExecution if the user can't deploy on the environment of the node
+func CheckDeployCapability(n *sdk.WorkflowNode, u *sdk.User) error {
+	envID := nodeEnvironmentID(n)
+	if envID == 0 {
+		return nil
+	}
+	if !permission.AccessToEnvironment(envID, u, permission.PermissionReadExecute) {
+		return sdk.WrapError(sdk.ErrNoEnvExecution, "CheckDeployCapability> User %s can't deploy on the environment %d of node %s", u.Username, envID, n.Name)
+	}
+	return nil
+}
+
+//nodeEnvironmentID returns the environment of the node, 0 if the node has no environment or the default one
+func nodeEnvironmentID(n *sdk.WorkflowNode) int64 {
+	envID := n.Context.EnvironmentID
+	if envID == 0 && n.Context.Environment != nil {
+		envID = n.Context.Environment.ID
+	}
+	if envID == sdk.DefaultEnv.ID {
+		return 0
+	}
+	return envID
+}
+
+//loadUserEnvironmentPermissions loads a user with the environment permissions of its groups
+func loadUserEnvironmentPermissions(db gorp.SqlExecutor, username string) (*sdk.User, error) {
+	u, err := user.LoadUserWithoutAuth(db, username)
+	if err != nil {
+		return nil, err
+	}
+	groups, err := group.LoadGroupByUser(db, u.ID)
+	if err != nil {
+		return nil, err
+	}
+	for i := range groups {
+		if err := environment.LoadEnvironmentByGroup(db, &groups[i]); err != nil {
+			return nil, err
+		}
+	}
+	u.Groups = groups
+	return u, nil
+}
+
+//insertNodeRunInstance inserts a node run of a node, for a combination of its matrix if any
+func insertNodeRunInstance(db gorp.SqlExecutor, w *sdk.WorkflowRun, n *sdk.WorkflowNode, subnumber int, sourceNodeRuns []int64, h *sdk.WorkflowNodeRunHookEvent, m *sdk.WorkflowNodeRunManual, combination map[string]string) (*sdk.WorkflowNodeRun, error) {
 	//Recopy stages
 	stages := make([]sdk.Stage, len(n.Pipeline.Stages))
 	copy(stages, n.Pipeline.Stages)
@@ -251,6 +437,7 @@ func processWorkflowNodeRun(db gorp.SqlExecutor, w *sdk.WorkflowRun, n *sdk.Work
 		WorkflowNodeID: n.ID,
 		Status:         string(sdk.StatusWaiting),
 		Stages:         stages,
+		Matrix:         combination,
 	}
 
 	run.SourceNodeRuns = sourceNodeRuns
@@ -306,12 +493,12 @@ func processWorkflowNodeRun(db gorp.SqlExecutor, w *sdk.WorkflowRun, n *sdk.Work
 			ID:   sdk.MsgWorkflowError.ID,
 			Args: []interface{}{errParam},
 		})
-		return sdk.WrapError(errParam, "processWorkflowNodeRun> getNodeRunBuildParameters failed")
+		return nil, sdk.WrapError(errParam, "processWorkflowNodeRun> getNodeRunBuildParameters failed")
 	}
 	run.BuildParameters = jobParams
 
 	if err := insertWorkflowNodeRun(db, run); err != nil {
-		return sdk.WrapError(err, "processWorkflowNodeRun> unable to insert run")
+		return nil, sdk.WrapError(err, "processWorkflowNodeRun> unable to insert run")
 	}
 
 	//Update workflow run
@@ -320,17 +507,7 @@ func processWorkflowNodeRun(db gorp.SqlExecutor, w *sdk.WorkflowRun, n *sdk.Work
 	}
 	w.WorkflowNodeRuns[run.WorkflowNodeID] = append(w.WorkflowNodeRuns[run.WorkflowNodeID], *run)
 
-	//Update the workflow run
-	if err := updateWorkflowRun(db, w); err != nil {
-		return sdk.WrapError(err, "processWorkflowNodeRun> unable to update workflow run")
-	}
-
-	//Execute the node run !
-	if err := execute(db, run); err != nil {
-		return sdk.WrapError(err, "processWorkflowNodeRun> unable to execute workflow run")
-	}
-
-	return nil
+	return run, nil
 }
 
 // AddWorkflowRunInfo add WorkflowRunInfo on a WorkflowRun
@@ -145,6 +145,9 @@ func getNodeRunBuildParameters(db gorp.SqlExecutor, run *sdk.WorkflowNodeRun) ([
 	tmp["cds.run"] = fmt.Sprintf("%d.%d", run.Number, run.SubNumber)
 	tmp["cds.run.number"] = fmt.Sprintf("%d", run.Number)
 	tmp["cds.run.subnumber"] = fmt.Sprintf("%d", run.SubNumber)
+	for k, v := range run.Matrix {
+		tmp["cds.matrix."+k] = v
+	}
 
 	params = []sdk.Parameter{}
 	for k, v := range tmp {
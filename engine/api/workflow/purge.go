@@ -0,0 +1,219 @@
+package workflow
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-gorp/gorp"
+
+	"github.com/ovh/cds/engine/api/objectstore"
+	"github.com/ovh/cds/sdk"
+	"github.com/ovh/cds/sdk/log"
+)
+
+//PurgeArtifacts is the purge main goroutine. It deletes the artifacts of the workflow runs which don't match the retention policies
+func PurgeArtifacts(c context.Context, DBFunc func() *gorp.DbMap) {
+	tick := time.NewTicker(1 * time.Hour).C
+	for {
+		select {
+		case <-c.Done():
+			if c.Err() != nil {
+				log.Error("Exiting workflow.PurgeArtifacts: %v", c.Err())
+				return
+			}
+		case <-tick:
+			report, err := PurgeArtifactsRun(DBFunc(), 0, false)
+			if err != nil {
+				log.Warning("PurgeArtifacts> Unable to purge artifacts: %s", err)
+				continue
+			}
+			if report.NbArtifacts > 0 {
+				log.Info("PurgeArtifacts> %d artifacts deleted (%d bytes) on %d workflow runs", report.NbArtifacts, report.Size, len(report.Runs))
+			}
+			if err := PurgeArtifactObjects(DBFunc()); err != nil {
+				log.Warning("PurgeArtifacts> Unable to purge unreferenced objects: %s", err)
+			}
+		}
+	}
+}
+
+type purgeWorkflow struct {
+	ID         int64  `db:"id"`
+	Name       string `db:"name"`
+	ProjectID  int64  `db:"project_id"`
+	ProjectKey string `db:"projectkey"`
+}
+
+//PurgeArtifactsRun is the core function of the purge goroutine. If projectID is 0, all the projects are purged.
+//In dry run mode, nothing is deleted and the report lists the artifacts which would have been deleted.
+func PurgeArtifactsRun(db *gorp.DbMap, projectID int64, dryRun bool) (*sdk.WorkflowPurgeReport, error) {
+	report := &sdk.WorkflowPurgeReport{DryRun: dryRun, Runs: []sdk.WorkflowPurgeReportRun{}}
+
+	policies, err := LoadRetentionPolicies(db, projectID)
+	if err != nil {
+		return nil, sdk.WrapError(err, "PurgeArtifactsRun> Unable to load retention policies")
+	}
+	if len(policies) == 0 {
+		return report, nil
+	}
+
+	projectPolicies := map[int64]sdk.WorkflowRetentionPolicy{}
+	workflowPolicies := map[int64]sdk.WorkflowRetentionPolicy{}
+	for _, p := range policies {
+		if p.WorkflowID == 0 {
+			projectPolicies[p.ProjectID] = p
+		} else {
+			workflowPolicies[p.WorkflowID] = p
+		}
+	}
+
+	query := `SELECT workflow.id, workflow.name, project.id as project_id, project.projectkey
+	FROM workflow
+	JOIN project ON project.id = workflow.project_id
+	WHERE project.id IN (SELECT project_id FROM workflow_retention_policy)`
+	args := []interface{}{}
+	if projectID != 0 {
+		query += " AND project.id = $1"
+		args = append(args, projectID)
+	}
+	workflows := []purgeWorkflow{}
+	if _, err := db.Select(&workflows, query, args...); err != nil {
+		return nil, sdk.WrapError(err, "PurgeArtifactsRun> Unable to load workflows")
+	}
+
+	now := time.Now()
+	for _, w := range workflows {
+		p, ok := workflowPolicies[w.ID]
+		if !ok {
+			p, ok = projectPolicies[w.ProjectID]
+		}
+		if !ok {
+			continue
+		}
+
+		if err := purgeWorkflowArtifacts(db, w, p, now, report); err != nil {
+			log.Warning("PurgeArtifactsRun> Unable to purge artifacts of workflow %s/%s: %s", w.ProjectKey, w.Name, err)
+		}
+	}
+
+	return report, nil
+}
+
+func purgeWorkflowArtifacts(db *gorp.DbMap, w purgeWorkflow, p sdk.WorkflowRetentionPolicy, now time.Time, report *sdk.WorkflowPurgeReport) error {
+	runs := []sdk.WorkflowRun{}
+	if _, err := db.Select(&runs, "SELECT id, num, start FROM workflow_run WHERE workflow_id = $1 ORDER BY num DESC", w.ID); err != nil {
+		return sdk.WrapError(err, "purgeWorkflowArtifacts> Unable to load runs")
+	}
+
+	for i := range runs {
+		run := &runs[i]
+		tags, err := loadRunTags(db, run.ID)
+		if err != nil {
+			return sdk.WrapError(err, "purgeWorkflowArtifacts> Unable to load tags of run %d", run.ID)
+		}
+		run.Tags = tags
+
+		if p.Keep(*run, i, now) {
+			continue
+		}
+
+		arts, err := purgeRunArtifacts(db, run.ID, report.DryRun)
+		if err != nil {
+			return err
+		}
+		if len(arts) == 0 {
+			continue
+		}
+
+		reportRun := sdk.WorkflowPurgeReportRun{
+			ProjectKey:   w.ProjectKey,
+			WorkflowName: w.Name,
+			RunID:        run.ID,
+			Number:       run.Number,
+			Start:        run.Start,
+			Tags:         run.Tags,
+			Artifacts:    arts,
+		}
+		for _, a := range arts {
+			report.Size += a.Size
+		}
+		report.NbArtifacts += len(arts)
+		report.Runs = append(report.Runs, reportRun)
+	}
+	return nil
+}
+
+//PurgeArtifactObjects deletes from the objectstore the objects which are not referenced by any artifact
+func PurgeArtifactObjects(db *gorp.DbMap) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return sdk.WrapError(err, "PurgeArtifactObjects> Unable to start transaction")
+	}
+	defer tx.Rollback()
+
+	objects, err := deleteUnreferencedArtifactObjects(tx)
+	if err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return sdk.WrapError(err, "PurgeArtifactObjects> Unable to commit transaction")
+	}
+
+	for i := range objects {
+		if err := objectstore.DeleteArtifact(&objects[i]); err != nil {
+			log.Warning("PurgeArtifactObjects> Unable to delete stored object %s: %s", objects[i].GetName(), err)
+		}
+	}
+	if len(objects) > 0 {
+		log.Info("PurgeArtifactObjects> %d unreferenced objects deleted", len(objects))
+	}
+	return nil
+}
+
+// purgeRunArtifacts deletes the stored objects and the rows of the artifacts of a run, and returns the deleted artifacts.
+// Artifacts locked by another purge are skipped.
+func purgeRunArtifacts(db *gorp.DbMap, runID int64, dryRun bool) ([]sdk.WorkflowNodeRunArtifact, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, sdk.WrapError(err, "purgeRunArtifacts> Unable to start transaction")
+	}
+	defer tx.Rollback()
+
+	var artifactsGorp []NodeRunArtifact
+	query := "SELECT * FROM workflow_node_run_artifacts WHERE workflow_run_id = $1 FOR UPDATE SKIP LOCKED"
+	if _, err := tx.Select(&artifactsGorp, query, runID); err != nil {
+		return nil, sdk.WrapError(err, "purgeRunArtifacts> Unable to load artifacts of run %d", runID)
+	}
+
+	arts := make([]sdk.WorkflowNodeRunArtifact, 0, len(artifactsGorp))
+	// The stored objects are deleted once the transaction is committed
+	objects := []sdk.WorkflowNodeRunArtifact{}
+	for i := range artifactsGorp {
+		a := sdk.WorkflowNodeRunArtifact(artifactsGorp[i])
+		if !dryRun {
+			if _, err := tx.Exec("DELETE FROM workflow_node_run_artifacts WHERE id = $1", a.ID); err != nil {
+				return nil, sdk.WrapError(err, "purgeRunArtifacts> Unable to delete artifact %d", a.ID)
+			}
+			unreferenced, err := ReleaseArtifactObject(tx, &a)
+			if err != nil {
+				return nil, sdk.WrapError(err, "purgeRunArtifacts> Unable to release stored object %s", a.GetName())
+			}
+			if unreferenced {
+				objects = append(objects, a)
+			}
+		}
+		arts = append(arts, a)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, sdk.WrapError(err, "purgeRunArtifacts> Unable to commit transaction")
+	}
+
+	for i := range objects {
+		if err := objectstore.DeleteArtifact(&objects[i]); err != nil {
+			log.Warning("purgeRunArtifacts> Unable to delete stored object %s: %s", objects[i].GetName(), err)
+		}
+	}
+	return arts, nil
+}
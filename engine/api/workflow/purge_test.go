@@ -0,0 +1,78 @@
+package workflow
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ovh/cds/engine/api/bootstrap"
+	"github.com/ovh/cds/engine/api/pipeline"
+	"github.com/ovh/cds/engine/api/test"
+	"github.com/ovh/cds/engine/api/test/assets"
+	"github.com/ovh/cds/sdk"
+)
+
+func TestPurgeArtifactObjectsOfDeletedRuns(t *testing.T) {
+	db := test.SetupPG(t, bootstrap.InitiliazeDB)
+	u, _ := assets.InsertAdminUser(db)
+	key := sdk.RandomString(10)
+	proj := assets.InsertTestProject(t, db, key, key, u)
+
+	pip := sdk.Pipeline{
+		ProjectID:  proj.ID,
+		ProjectKey: proj.Key,
+		Name:       "pip1",
+		Type:       sdk.BuildPipeline,
+	}
+	test.NoError(t, pipeline.InsertPipeline(db, proj, &pip, u))
+
+	w := sdk.Workflow{
+		Name:       "test_purge_objects",
+		ProjectID:  proj.ID,
+		ProjectKey: proj.Key,
+		Root:       &sdk.WorkflowNode{Pipeline: pip},
+	}
+	test.NoError(t, Insert(db, &w, u))
+	w1, err := Load(db, key, w.Name, u)
+	test.NoError(t, err)
+
+	wr, err := ManualRun(db, w1, &sdk.WorkflowNodeRunManual{User: *u})
+	test.NoError(t, err)
+	wr, err = LoadRunByID(db, key, wr.ID)
+	test.NoError(t, err)
+	nodeRun := wr.WorkflowNodeRuns[w1.Root.ID][0]
+
+	// Two artifacts share the same stored object
+	sha512sum := sdk.RandomString(128)
+	for _, name := range []string{"a.txt", "b.txt"} {
+		a := sdk.WorkflowNodeRunArtifact{
+			WorkflowID:        wr.ID,
+			WorkflowNodeRunID: nodeRun.ID,
+			Name:              name,
+			SHA512sum:         sha512sum,
+			ObjectPath:        sdk.WorkflowArtifactObjectPath + "/" + sha512sum,
+		}
+		exists, err := AcquireArtifactObject(db, &a)
+		test.NoError(t, err)
+		if !exists {
+			test.NoError(t, InsertArtifactObject(db, &a))
+		}
+		test.NoError(t, InsertArtifact(db, &a))
+	}
+
+	refCount, err := db.SelectInt("SELECT ref_count FROM workflow_artifact_object WHERE sha512sum = $1", sha512sum)
+	test.NoError(t, err)
+	assert.Equal(t, int64(2), refCount)
+
+	// The artifacts are deleted with their run: the object is released and purged
+	_, err = db.Exec("DELETE FROM workflow_run WHERE id = $1", wr.ID)
+	test.NoError(t, err)
+	refCount, err = db.SelectInt("SELECT ref_count FROM workflow_artifact_object WHERE sha512sum = $1", sha512sum)
+	test.NoError(t, err)
+	assert.Equal(t, int64(0), refCount)
+
+	test.NoError(t, PurgeArtifactObjects(db))
+	n, err := db.SelectInt("SELECT COUNT(1) FROM workflow_artifact_object WHERE sha512sum = $1", sha512sum)
+	test.NoError(t, err)
+	assert.Equal(t, int64(0), n)
+}
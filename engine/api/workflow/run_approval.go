@@ -0,0 +1,220 @@
+package workflow
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-gorp/gorp"
+
+	"github.com/ovh/cds/engine/api/event"
+	"github.com/ovh/cds/engine/api/group"
+	"github.com/ovh/cds/sdk"
+	"github.com/ovh/cds/sdk/log"
+)
+
+// checkTriggerApproval returns true if the approval of a trigger allows to start its destination node.
+// The first time the trigger is reached, the approval is created and the approvers are notified
+func checkTriggerApproval(db gorp.SqlExecutor, w *sdk.WorkflowRun, nodeRun *sdk.WorkflowNodeRun, t *sdk.WorkflowNodeTrigger) (bool, error) {
+	a, err := loadRunApproval(db, w.ID, t.ID, nodeRun.ID)
+	if err != nil {
+		return false, sdk.WrapError(err, "checkTriggerApproval> Unable to load approval of trigger %d", t.ID)
+	}
+	if a != nil {
+		return a.Status == sdk.ApprovalStatusApproved, nil
+	}
+
+	now := time.Now()
+	a = &sdk.WorkflowRunApproval{
+		WorkflowRunID:         w.ID,
+		WorkflowNodeTriggerID: t.ID,
+		WorkflowNodeRunID:     nodeRun.ID,
+		WorkflowDestNodeID:    t.WorkflowDestNode.ID,
+		SubNumber:             nodeRun.SubNumber,
+		Status:                sdk.ApprovalStatusWaiting,
+		GroupID:               t.Approval.GroupID,
+		GroupName:             t.Approval.GroupName,
+		Required:              t.Approval.Required,
+		Votes:                 []sdk.WorkflowRunApprovalVote{},
+		Created:               now,
+	}
+	if t.Approval.Expiry > 0 {
+		expire := now.Add(time.Duration(t.Approval.Expiry) * time.Second)
+		a.Expire = &expire
+	}
+
+	approvers, err := loadApprovers(db, a)
+	if err != nil {
+		return false, err
+	}
+	if err := insertRunApproval(db, a); err != nil {
+		return false, err
+	}
+
+	AddWorkflowRunInfo(w, sdk.SpawnMsg{
+		ID:   sdk.MsgWorkflowApprovalWaiting.ID,
+		Args: []interface{}{t.WorkflowDestNode.Pipeline.Name, a.Required, a.GroupName},
+	})
+	event.PublishWorkflowApproval(w, a, t.WorkflowDestNode.Pipeline.Name, "", approvers)
+	return false, nil
+}
+
+// VoteWorkflowRunApproval records the approval or the rejection of a user on a waiting approval.
+// Once enough approvals are recorded, the destination node of the trigger is started
+func VoteWorkflowRunApproval(db gorp.SqlExecutor, wr *sdk.WorkflowRun, approvalID int64, u *sdk.User, approved bool, comment string) (*sdk.WorkflowRun, error) {
+	a, err := loadAndLockRunApproval(db, wr.ID, approvalID)
+	if err != nil {
+		return nil, sdk.WrapError(err, "VoteWorkflowRunApproval> Unable to lock approval %d", approvalID)
+	}
+	if a.Status != sdk.ApprovalStatusWaiting || (a.Expire != nil && a.Expire.Before(time.Now())) {
+		return nil, sdk.WrapError(sdk.ErrWorkflowApprovalClosed, "VoteWorkflowRunApproval> Approval %d is %s", a.ID, a.Status)
+	}
+
+	approvers, err := loadApprovers(db, a)
+	if err != nil {
+		return nil, err
+	}
+	if !u.Admin && !isApprover(approvers, u.Username) {
+		return nil, sdk.WrapError(sdk.ErrForbidden, "VoteWorkflowRunApproval> %s is not a member of group %s", u.Username, a.GroupName)
+	}
+	if a.HasVoted(u.Username) {
+		return nil, sdk.WrapError(sdk.ErrWorkflowApprovalAlreadyVoted, "VoteWorkflowRunApproval> %s has already voted on approval %d", u.Username, a.ID)
+	}
+
+	voter := *u
+	voter.Auth = sdk.Auth{}
+	voter.Groups = nil
+	a.Votes = append(a.Votes, sdk.WorkflowRunApprovalVote{
+		User:     voter,
+		Approved: approved,
+		Comment:  comment,
+		Date:     time.Now(),
+	})
+
+	var pipelineName string
+	if node := wr.Workflow.GetNode(a.WorkflowDestNodeID); node != nil {
+		pipelineName = node.Pipeline.Name
+	}
+
+	if approved {
+		AddWorkflowRunInfo(wr, sdk.SpawnMsg{
+			ID:   sdk.MsgWorkflowApprovalVote.ID,
+			Args: []interface{}{u.Username, pipelineName, a.Approvals(), a.Required},
+		})
+		if a.Approvals() >= a.Required {
+			a.Status = sdk.ApprovalStatusApproved
+		}
+	} else {
+		a.Status = sdk.ApprovalStatusRejected
+		AddWorkflowRunInfo(wr, sdk.SpawnMsg{
+			ID:   sdk.MsgWorkflowApprovalRejected.ID,
+			Args: []interface{}{u.Username, pipelineName},
+		})
+	}
+
+	if err := updateRunApproval(db, a); err != nil {
+		return nil, err
+	}
+	event.PublishWorkflowApproval(wr, a, pipelineName, u.Username, approvers)
+
+	// processWorkflowRun starts the destination node now that the approval is approved, and saves the workflow run
+	if a.Status == sdk.ApprovalStatusApproved {
+		if err := processWorkflowRun(db, wr, nil, nil, nil); err != nil {
+			return nil, sdk.WrapError(err, "VoteWorkflowRunApproval> Unable to process workflow run %d", wr.ID)
+		}
+	} else if err := updateWorkflowRun(db, wr); err != nil {
+		return nil, sdk.WrapError(err, "VoteWorkflowRunApproval> Unable to update workflow run %d", wr.ID)
+	}
+	return loadRunByID(db, wr.ID)
+}
+
+// ApprovalsExpirer expires the approvals which have not been approved in time
+func ApprovalsExpirer(c context.Context, DBFunc func() *gorp.DbMap) {
+	tick := time.NewTicker(time.Minute).C
+	for {
+		select {
+		case <-c.Done():
+			if c.Err() != nil {
+				log.Error("Exiting workflow.ApprovalsExpirer: %v", c.Err())
+			}
+			return
+		case <-tick:
+			db := DBFunc()
+			if db == nil {
+				continue
+			}
+			as, err := loadExpiredRunApprovals(db, time.Now())
+			if err != nil {
+				log.Warning("ApprovalsExpirer> %s", err)
+				continue
+			}
+			for i := range as {
+				if err := expireApproval(db, &as[i]); err != nil {
+					log.Warning("ApprovalsExpirer> Unable to expire approval %d: %s", as[i].ID, err)
+				}
+			}
+		}
+	}
+}
+
+func expireApproval(db *gorp.DbMap, expired *sdk.WorkflowRunApproval) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return sdk.WrapError(err, "expireApproval> Unable to start transaction")
+	}
+	defer tx.Rollback()
+	defer DiscardRunEvents(tx)
+
+	// Another API instance may have expired it, or a vote may have closed it
+	a, err := loadAndLockRunApproval(tx, expired.WorkflowRunID, expired.ID)
+	if err != nil {
+		return err
+	}
+	if a.Status != sdk.ApprovalStatusWaiting {
+		return nil
+	}
+	a.Status = sdk.ApprovalStatusExpired
+	if err := updateRunApproval(tx, a); err != nil {
+		return err
+	}
+
+	wr, err := loadAndLockRunByID(tx, a.WorkflowRunID)
+	if err != nil {
+		return sdk.WrapError(err, "expireApproval> Unable to lock workflow run %d", a.WorkflowRunID)
+	}
+	var pipelineName string
+	if node := wr.Workflow.GetNode(a.WorkflowDestNodeID); node != nil {
+		pipelineName = node.Pipeline.Name
+	}
+	AddWorkflowRunInfo(wr, sdk.SpawnMsg{
+		ID:   sdk.MsgWorkflowApprovalExpired.ID,
+		Args: []interface{}{pipelineName},
+	})
+	if err := updateWorkflowRun(tx, wr); err != nil {
+		return sdk.WrapError(err, "expireApproval> Unable to update workflow run %d", wr.ID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return sdk.WrapError(err, "expireApproval> Unable to commit transaction")
+	}
+	PublishRunEvents(tx)
+	event.PublishWorkflowApproval(wr, a, pipelineName, "", nil)
+	return nil
+}
+
+// loadApprovers loads the members and the admins of the group of an approval
+func loadApprovers(db gorp.SqlExecutor, a *sdk.WorkflowRunApproval) ([]sdk.User, error) {
+	g := &sdk.Group{ID: a.GroupID, Name: a.GroupName}
+	if err := group.LoadUserGroup(db, g); err != nil {
+		return nil, sdk.WrapError(err, "loadApprovers> Unable to load users of group %s", a.GroupName)
+	}
+	return append(g.Admins, g.Users...), nil
+}
+
+func isApprover(approvers []sdk.User, username string) bool {
+	for _, u := range approvers {
+		if u.Username == username {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,86 @@
+package workflow
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/go-gorp/gorp"
+
+	"github.com/ovh/cds/engine/api/cache"
+	"github.com/ovh/cds/sdk"
+	"github.com/ovh/cds/sdk/log"
+)
+
+// RunEventsChannel is the cache channel on which workflow run events are published
+const RunEventsChannel = "workflowRunEvents"
+
+var (
+	pendingRunEventsMutex sync.Mutex
+	// pendingRunEvents are the events of the transactions which are not committed yet
+	pendingRunEvents = map[*gorp.Transaction][]sdk.WorkflowRunEvent{}
+)
+
+// publishRunEvent completes the event with the project key, the workflow name and the run number, then publishes it on the cache channel.
+// In a transaction, the event is only published by PublishRunEvents, once the transaction is committed.
+// Errors are only logged, the run must not fail because its progress cannot be streamed
+func publishRunEvent(db gorp.SqlExecutor, e sdk.WorkflowRunEvent) {
+	query := `SELECT project.projectkey, workflow.name, workflow_run.id, workflow_run.num
+	FROM workflow_run
+	JOIN workflow ON workflow.id = workflow_run.workflow_id
+	JOIN project ON project.id = workflow.project_id
+	WHERE workflow_run.id = $1`
+	id := e.WorkflowRunID
+	if id == 0 {
+		query = `SELECT project.projectkey, workflow.name, workflow_run.id, workflow_run.num
+		FROM workflow_node_run
+		JOIN workflow_run ON workflow_run.id = workflow_node_run.workflow_run_id
+		JOIN workflow ON workflow.id = workflow_run.workflow_id
+		JOIN project ON project.id = workflow.project_id
+		WHERE workflow_node_run.id = $1`
+		id = e.NodeRunID
+	}
+
+	if err := db.QueryRow(query, id).Scan(&e.ProjectKey, &e.WorkflowName, &e.WorkflowRunID, &e.Number); err != nil {
+		log.Warning("publishRunEvent> Unable to load run %d/%d: %s", e.WorkflowRunID, e.NodeRunID, err)
+		return
+	}
+	e.Timestamp = time.Now()
+
+	if tx, ok := db.(*gorp.Transaction); ok {
+		pendingRunEventsMutex.Lock()
+		pendingRunEvents[tx] = append(pendingRunEvents[tx], e)
+		pendingRunEventsMutex.Unlock()
+		return
+	}
+	sendRunEvent(e)
+}
+
+// PublishRunEvents publishes the run events of a transaction. It must be called once the transaction is committed
+func PublishRunEvents(tx *gorp.Transaction) {
+	pendingRunEventsMutex.Lock()
+	events := pendingRunEvents[tx]
+	delete(pendingRunEvents, tx)
+	pendingRunEventsMutex.Unlock()
+
+	for _, e := range events {
+		sendRunEvent(e)
+	}
+}
+
+// DiscardRunEvents forgets the run events of a transaction which has not been committed. It is deferred with the
+// rollback of the transaction, and does nothing once the events have been published by PublishRunEvents
+func DiscardRunEvents(tx *gorp.Transaction) {
+	pendingRunEventsMutex.Lock()
+	delete(pendingRunEvents, tx)
+	pendingRunEventsMutex.Unlock()
+}
+
+func sendRunEvent(e sdk.WorkflowRunEvent) {
+	b, err := json.Marshal(e)
+	if err != nil {
+		log.Warning("publishRunEvent> Unable to marshal event: %s", err)
+		return
+	}
+	cache.Publish(RunEventsChannel, string(b))
+}
@@ -8,9 +8,60 @@ import (
 	"github.com/ovh/cds/sdk"
 )
 
-//RunFromHook is the entry point to trigger a workflow from a hook
+//RunFromHook is the entry point to trigger a workflow from a hook. The run starts from the node of the hook
 func RunFromHook(db gorp.SqlExecutor, w *sdk.Workflow, e *sdk.WorkflowNodeRunHookEvent) (*sdk.WorkflowRun, error) {
-	return nil, nil
+	node := HookNode(w, e.WorkflowNodeHookID)
+	if node == nil {
+		return nil, sdk.WrapError(sdk.ErrWorkflowNodeNotFound, "RunFromHook> Unable to find node of hook %d", e.WorkflowNodeHookID)
+	}
+
+	lastWorkflowRun, err := LoadLastRun(db, w.ProjectKey, w.Name)
+	if err != nil {
+		if err != sdk.ErrWorkflowNotFound {
+			return nil, sdk.WrapError(err, "RunFromHook> Unable to load last run")
+		}
+	}
+
+	var number = int64(1)
+	if lastWorkflowRun != nil {
+		number = lastWorkflowRun.Number + 1
+	}
+
+	wr := &sdk.WorkflowRun{
+		Number:          number,
+		Workflow:        *w,
+		WorkflowID:      w.ID,
+		WorkflowVersion: w.Version,
+		Start:           time.Now(),
+		LastModified:    time.Now(),
+		ProjectID:       w.ProjectID,
+	}
+
+	if err := insertWorkflowRun(db, wr); err != nil {
+		return nil, sdk.WrapError(err, "RunFromHook> Unable to run workflow %s/%s from hook", w.ProjectKey, w.Name)
+	}
+
+	var startingFromNode *int64
+	if node.ID != w.Root.ID {
+		startingFromNode = &node.ID
+	}
+	return wr, processWorkflowRun(db, wr, e, nil, startingFromNode)
+}
+
+// HookNode returns the node of a hook
+func HookNode(w *sdk.Workflow, hookID int64) *sdk.WorkflowNode {
+	for _, id := range append([]int64{w.Root.ID}, w.Nodes()...) {
+		n := w.GetNode(id)
+		if n == nil {
+			continue
+		}
+		for _, h := range n.Hooks {
+			if h.ID == hookID {
+				return n
+			}
+		}
+	}
+	return nil
 }
 
 //ManualRunFromNode is the entry point to trigger manually a piece of an existing run workflow
@@ -47,12 +98,13 @@ func ManualRun(db gorp.SqlExecutor, w *sdk.Workflow, e *sdk.WorkflowNodeRunManua
 	}
 
 	wr := &sdk.WorkflowRun{
-		Number:       number,
-		Workflow:     *w,
-		WorkflowID:   w.ID,
-		Start:        time.Now(),
-		LastModified: time.Now(),
-		ProjectID:    w.ProjectID,
+		Number:          number,
+		Workflow:        *w,
+		WorkflowID:      w.ID,
+		WorkflowVersion: w.Version,
+		Start:           time.Now(),
+		LastModified:    time.Now(),
+		ProjectID:       w.ProjectID,
 	}
 
 	if err := insertWorkflowRun(db, wr); err != nil {
@@ -61,3 +113,144 @@ func ManualRun(db gorp.SqlExecutor, w *sdk.Workflow, e *sdk.WorkflowNodeRunManua
 
 	return wr, processWorkflowRun(db, wr, nil, e, nil)
 }
+
+//StopWorkflowRun stops all the waiting and building node runs of a workflow run
+func StopWorkflowRun(db gorp.SqlExecutor, wr *sdk.WorkflowRun, u *sdk.User) (*sdk.WorkflowRun, error) {
+	for _, nodeRuns := range wr.WorkflowNodeRuns {
+		for _, n := range nodeRuns {
+			if n.Status != sdk.StatusWaiting.String() && n.Status != sdk.StatusBuilding.String() {
+				continue
+			}
+			if err := stopWorkflowNodeRun(db, wr, n.ID, u); err != nil {
+				return nil, sdk.WrapError(err, "StopWorkflowRun> Unable to stop node run %d", n.ID)
+			}
+		}
+	}
+
+	if err := updateWorkflowRun(db, wr); err != nil {
+		return nil, sdk.WrapError(err, "StopWorkflowRun> Unable to update workflow run %d", wr.ID)
+	}
+	return loadRunByID(db, wr.ID)
+}
+
+//StopWorkflowNodeRun stops a waiting or building node run. Its downstream nodes are not triggered
+func StopWorkflowNodeRun(db gorp.SqlExecutor, wr *sdk.WorkflowRun, nodeRunID int64, u *sdk.User) (*sdk.WorkflowRun, error) {
+	if err := stopWorkflowNodeRun(db, wr, nodeRunID, u); err != nil {
+		return nil, err
+	}
+
+	if err := updateWorkflowRun(db, wr); err != nil {
+		return nil, sdk.WrapError(err, "StopWorkflowNodeRun> Unable to update workflow run %d", wr.ID)
+	}
+	return loadRunByID(db, wr.ID)
+}
+
+func stopWorkflowNodeRun(db gorp.SqlExecutor, wr *sdk.WorkflowRun, nodeRunID int64, u *sdk.User) error {
+	n, err := LoadAndLockNodeRunByID(db, nodeRunID)
+	if err != nil {
+		return sdk.WrapError(err, "stopWorkflowNodeRun> Unable to lock node run %d", nodeRunID)
+	}
+	if n.WorkflowRunID != wr.ID {
+		return sdk.WrapError(sdk.ErrWorkflowNodeNotFound, "stopWorkflowNodeRun> Node run %d is not in workflow run %d", nodeRunID, wr.ID)
+	}
+	if n.Status != sdk.StatusWaiting.String() && n.Status != sdk.StatusBuilding.String() {
+		return sdk.WrapError(sdk.ErrWrongRequest, "stopWorkflowNodeRun> Node run %d is %s", nodeRunID, n.Status)
+	}
+
+	if err := stopNodeRun(db, n); err != nil {
+		return err
+	}
+	if n.SubWorkflowRunID != 0 {
+		if err := stopSubWorkflowRun(db, n, u); err != nil {
+			return err
+		}
+	}
+
+	AddWorkflowRunInfo(wr, sdk.SpawnMsg{
+		ID:   sdk.MsgWorkflowNodeStop.ID,
+		Args: []interface{}{nodeRunPipelineName(wr, n), u.Username},
+	})
+	return nil
+}
+
+//RestartFailedJobs runs again the failed and stopped jobs of a node run. The node run is copied with a new subnumber,
+//it keeps the results and the artifacts of the successful stages
+func RestartFailedJobs(db gorp.SqlExecutor, wr *sdk.WorkflowRun, nodeRunID int64, u *sdk.User) (*sdk.WorkflowRun, error) {
+	previous, err := LoadNodeRunByID(db, nodeRunID)
+	if err != nil {
+		return nil, sdk.WrapError(err, "RestartFailedJobs> Unable to load node run %d", nodeRunID)
+	}
+	if previous.WorkflowRunID != wr.ID {
+		return nil, sdk.WrapError(sdk.ErrWorkflowNodeNotFound, "RestartFailedJobs> Node run %d is not in workflow run %d", nodeRunID, wr.ID)
+	}
+	if previous.Status != sdk.StatusFail.String() && previous.Status != sdk.StatusStopped.String() {
+		return nil, sdk.WrapError(sdk.ErrWrongRequest, "RestartFailedJobs> Node run %d is %s", nodeRunID, previous.Status)
+	}
+
+	var subnumber int64
+	for _, nodeRuns := range wr.WorkflowNodeRuns {
+		for _, n := range nodeRuns {
+			if n.SubNumber >= subnumber {
+				subnumber = n.SubNumber + 1
+			}
+		}
+	}
+
+	run := *previous
+	run.ID = 0
+	run.SubNumber = subnumber
+	run.Status = sdk.StatusWaiting.String()
+	run.Start = time.Now()
+	run.LastModified = time.Now()
+	run.Done = time.Time{}
+	run.Artifacts = nil
+	run.Tests = nil
+	run.Stages = make([]sdk.Stage, len(previous.Stages))
+	copy(run.Stages, previous.Stages)
+
+	if node := wr.Workflow.GetNode(previous.WorkflowNodeID); node != nil {
+		if err := CheckDeployCapability(node, u); err != nil {
+			return nil, sdk.WrapError(err, "RestartFailedJobs> Unable to restart node run %d", nodeRunID)
+		}
+	}
+
+	if err := insertWorkflowNodeRun(db, &run); err != nil {
+		return nil, sdk.WrapError(err, "RestartFailedJobs> Unable to insert node run")
+	}
+	if err := restartFailedJobs(db, &run); err != nil {
+		return nil, err
+	}
+	if err := copyArtifacts(db, previous.ID, &run); err != nil {
+		return nil, err
+	}
+	if err := UpdateNodeRun(db, &run); err != nil {
+		return nil, sdk.WrapError(err, "RestartFailedJobs> Unable to update node run %d", run.ID)
+	}
+
+	if wr.WorkflowNodeRuns == nil {
+		wr.WorkflowNodeRuns = make(map[int64][]sdk.WorkflowNodeRun)
+	}
+	wr.WorkflowNodeRuns[run.WorkflowNodeID] = append(wr.WorkflowNodeRuns[run.WorkflowNodeID], run)
+	AddWorkflowRunInfo(wr, sdk.SpawnMsg{
+		ID:   sdk.MsgWorkflowNodeRestart.ID,
+		Args: []interface{}{nodeRunPipelineName(wr, &run), u.Username},
+	})
+	if err := updateWorkflowRun(db, wr); err != nil {
+		return nil, sdk.WrapError(err, "RestartFailedJobs> Unable to update workflow run %d", wr.ID)
+	}
+
+	if err := execute(db, &run); err != nil {
+		return nil, sdk.WrapError(err, "RestartFailedJobs> Unable to execute node run %d", run.ID)
+	}
+	return loadRunByID(db, wr.ID)
+}
+
+func nodeRunPipelineName(wr *sdk.WorkflowRun, n *sdk.WorkflowNodeRun) string {
+	if node := wr.Workflow.GetNode(n.WorkflowNodeID); node != nil {
+		if node.SubWorkflow != nil {
+			return node.SubWorkflow.String()
+		}
+		return node.Pipeline.Name
+	}
+	return ""
+}
@@ -8,9 +8,12 @@ import (
 
 	dump "github.com/fsamin/go-dump"
 	"github.com/go-gorp/gorp"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 
 	"github.com/ovh/cds/engine/api/bootstrap"
+	"github.com/ovh/cds/engine/api/environment"
+	"github.com/ovh/cds/engine/api/group"
 	"github.com/ovh/cds/engine/api/pipeline"
 	"github.com/ovh/cds/engine/api/project"
 	"github.com/ovh/cds/engine/api/test"
@@ -462,3 +465,429 @@ func TestManualRun3(t *testing.T) {
 		assert.Equal(t, "job20", jobs[0].Job.Job.Action.Name)
 	}
 }
+
+func TestStopAndRestartWorkflowRun(t *testing.T) {
+	db := test.SetupPG(t, bootstrap.InitiliazeDB)
+	u, _ := assets.InsertAdminUser(db)
+	key := sdk.RandomString(10)
+	proj := assets.InsertTestProject(t, db, key, key, u)
+
+	pip := sdk.Pipeline{
+		ProjectID:  proj.ID,
+		ProjectKey: proj.Key,
+		Name:       "pip1",
+		Type:       sdk.BuildPipeline,
+	}
+	test.NoError(t, pipeline.InsertPipeline(db, proj, &pip, u))
+
+	s := sdk.NewStage("stage 1")
+	s.Enabled = true
+	s.PipelineID = pip.ID
+	pipeline.InsertStage(db, s)
+	j := &sdk.Job{
+		Enabled: true,
+		Action: sdk.Action{
+			Enabled: true,
+		},
+	}
+	pipeline.InsertJob(db, j, s.ID, &pip)
+	s.Jobs = append(s.Jobs, *j)
+	pip.Stages = append(pip.Stages, *s)
+
+	w := sdk.Workflow{
+		Name:       "test_stop",
+		ProjectID:  proj.ID,
+		ProjectKey: proj.Key,
+		Root: &sdk.WorkflowNode{
+			Pipeline: pip,
+		},
+	}
+	test.NoError(t, Insert(db, &w, u))
+	w1, err := Load(db, key, "test_stop", u)
+	test.NoError(t, err)
+
+	wr, err := ManualRun(db, w1, &sdk.WorkflowNodeRunManual{User: *u})
+	test.NoError(t, err)
+	wr, err = LoadRun(db, key, "test_stop", wr.Number)
+	test.NoError(t, err)
+
+	nodeRuns := wr.WorkflowNodeRuns[w1.RootID]
+	assert.Len(t, nodeRuns, 1)
+	nodeRunID := nodeRuns[0].ID
+	assert.Equal(t, sdk.StatusWaiting.String(), nodeRuns[0].Status)
+
+	countJobs := func(id int64) int64 {
+		n, err := db.SelectInt("select count(1) from workflow_node_run_job where workflow_node_run_id = $1", id)
+		test.NoError(t, err)
+		return n
+	}
+	assert.Equal(t, int64(1), countJobs(nodeRunID))
+
+	// Stop the run: the waiting job leaves the queue
+	wr, err = StopWorkflowRun(db, wr, u)
+	test.NoError(t, err)
+	stopped := wr.WorkflowNodeRuns[w1.RootID][0]
+	assert.Equal(t, sdk.StatusStopped.String(), stopped.Status)
+	assert.Equal(t, sdk.StatusStopped, stopped.Stages[0].Status)
+	assert.Equal(t, sdk.StatusStopped.String(), stopped.Stages[0].RunJobs[0].Status)
+	assert.Equal(t, int64(0), countJobs(nodeRunID))
+
+	_, err = StopWorkflowNodeRun(db, wr, nodeRunID, u)
+	assert.Error(t, err)
+
+	// Restart the stopped job in a new node run
+	wr, err = RestartFailedJobs(db, wr, nodeRunID, u)
+	test.NoError(t, err)
+	nodeRuns = wr.WorkflowNodeRuns[w1.RootID]
+	assert.Len(t, nodeRuns, 2)
+	for _, n := range nodeRuns {
+		if n.ID == nodeRunID {
+			continue
+		}
+		assert.Equal(t, int64(1), n.SubNumber)
+		assert.Equal(t, sdk.StatusWaiting.String(), n.Status)
+		assert.Equal(t, sdk.StatusWaiting, n.Stages[0].Status)
+		assert.Equal(t, int64(1), countJobs(n.ID))
+	}
+}
+
+func TestApprovalWorkflowRun(t *testing.T) {
+	db := test.SetupPG(t, bootstrap.InitiliazeDB)
+	u, _ := assets.InsertAdminUser(db)
+	key := sdk.RandomString(10)
+	proj := assets.InsertTestProject(t, db, key, key, u)
+
+	g := &sdk.Group{Name: sdk.RandomString(10)}
+	approver1, _ := assets.InsertLambaUser(db, g)
+	approver2, _ := assets.InsertLambaUser(db)
+	test.NoError(t, group.InsertUserInGroup(db, g.ID, approver2.ID, false))
+	outsider, _ := assets.InsertLambaUser(db)
+
+	newPipeline := func(name string) sdk.Pipeline {
+		pip := sdk.Pipeline{
+			ProjectID:  proj.ID,
+			ProjectKey: proj.Key,
+			Name:       name,
+			Type:       sdk.BuildPipeline,
+		}
+		test.NoError(t, pipeline.InsertPipeline(db, proj, &pip, u))
+		s := sdk.NewStage("stage 1")
+		s.Enabled = true
+		s.PipelineID = pip.ID
+		pipeline.InsertStage(db, s)
+		j := &sdk.Job{Enabled: true, Action: sdk.Action{Enabled: true}}
+		pipeline.InsertJob(db, j, s.ID, &pip)
+		s.Jobs = append(s.Jobs, *j)
+		pip.Stages = append(pip.Stages, *s)
+		return pip
+	}
+
+	w := sdk.Workflow{
+		Name:       "test_approval",
+		ProjectID:  proj.ID,
+		ProjectKey: proj.Key,
+		Root: &sdk.WorkflowNode{
+			Pipeline: newPipeline("build"),
+			Triggers: []sdk.WorkflowNodeTrigger{
+				{
+					Approval:         &sdk.WorkflowTriggerApproval{GroupName: g.Name, Required: 2, Expiry: 3600},
+					WorkflowDestNode: sdk.WorkflowNode{Pipeline: newPipeline("deploy")},
+				},
+			},
+		},
+	}
+	test.NoError(t, Insert(db, &w, u))
+	w1, err := Load(db, key, "test_approval", u)
+	test.NoError(t, err)
+	assert.Equal(t, g.ID, w1.Root.Triggers[0].Approval.GroupID)
+	deployID := w1.Root.Triggers[0].WorkflowDestNode.ID
+
+	wr, err := ManualRun(db, w1, &sdk.WorkflowNodeRunManual{User: *u})
+	test.NoError(t, err)
+
+	// The root node run succeeds: the trigger waits for the approvals
+	_, err = db.Exec("update workflow_node_run set status = $1 where workflow_run_id = $2", sdk.StatusSuccess.String(), wr.ID)
+	test.NoError(t, err)
+	wr, err = LoadRun(db, key, "test_approval", wr.Number)
+	test.NoError(t, err)
+	test.NoError(t, processWorkflowRun(db, wr, nil, nil, nil))
+
+	wr, err = LoadRun(db, key, "test_approval", wr.Number)
+	test.NoError(t, err)
+	assert.Len(t, wr.WorkflowNodeRuns[deployID], 0)
+	as, err := LoadRunApprovals(db, wr.ID)
+	test.NoError(t, err)
+	assert.Len(t, as, 1)
+	assert.Equal(t, sdk.ApprovalStatusWaiting, as[0].Status)
+	assert.NotNil(t, as[0].Expire)
+
+	// Processing the run again does not create another approval
+	test.NoError(t, processWorkflowRun(db, wr, nil, nil, nil))
+	as, err = LoadRunApprovals(db, wr.ID)
+	test.NoError(t, err)
+	assert.Len(t, as, 1)
+
+	_, err = VoteWorkflowRunApproval(db, wr, as[0].ID, outsider, true, "")
+	assert.Error(t, err)
+
+	wr, err = VoteWorkflowRunApproval(db, wr, as[0].ID, approver1, true, "looks good")
+	test.NoError(t, err)
+	assert.Len(t, wr.WorkflowNodeRuns[deployID], 0)
+	_, err = VoteWorkflowRunApproval(db, wr, as[0].ID, approver1, true, "")
+	assert.Error(t, err)
+
+	wr, err = VoteWorkflowRunApproval(db, wr, as[0].ID, approver2, true, "")
+	test.NoError(t, err)
+	assert.Len(t, wr.WorkflowNodeRuns[deployID], 1)
+
+	as, err = LoadRunApprovals(db, wr.ID)
+	test.NoError(t, err)
+	assert.Equal(t, sdk.ApprovalStatusApproved, as[0].Status)
+	assert.Len(t, as[0].Votes, 2)
+	assert.Equal(t, approver1.Username, as[0].Votes[0].User.Username)
+	assert.Equal(t, "looks good", as[0].Votes[0].Comment)
+
+	_, err = VoteWorkflowRunApproval(db, wr, as[0].ID, u, false, "")
+	assert.Error(t, err)
+}
+
+func TestSearchWorkflowRuns(t *testing.T) {
+	db := test.SetupPG(t, bootstrap.InitiliazeDB)
+	u, _ := assets.InsertAdminUser(db)
+	key := sdk.RandomString(10)
+	proj := assets.InsertTestProject(t, db, key, key, u)
+
+	pip := sdk.Pipeline{
+		ProjectID:  proj.ID,
+		ProjectKey: proj.Key,
+		Name:       "pip1",
+		Type:       sdk.BuildPipeline,
+	}
+	test.NoError(t, pipeline.InsertPipeline(db, proj, &pip, u))
+
+	for _, name := range []string{"test_search_1", "test_search_2"} {
+		w := sdk.Workflow{
+			Name:       name,
+			ProjectID:  proj.ID,
+			ProjectKey: proj.Key,
+			Root: &sdk.WorkflowNode{
+				Pipeline: pip,
+			},
+		}
+		test.NoError(t, Insert(db, &w, u))
+	}
+
+	w1, err := Load(db, key, "test_search_1", u)
+	test.NoError(t, err)
+	w2, err := Load(db, key, "test_search_2", u)
+	test.NoError(t, err)
+
+	wr1, err := ManualRun(db, w1, &sdk.WorkflowNodeRunManual{
+		User:    *u,
+		Payload: map[string]string{"git.branch": "master", "git.hash": "abcdef"},
+	})
+	test.NoError(t, err)
+	test.NoError(t, InsertRunTags(db, wr1.ID, sdk.WorkflowRunTag{Tag: "env", Value: "prod"}))
+
+	_, err = ManualRun(db, w1, &sdk.WorkflowNodeRunManual{
+		User:    *u,
+		Payload: map[string]string{"git.branch": "develop"},
+	})
+	test.NoError(t, err)
+
+	_, err = ManualRun(db, w2, &sdk.WorkflowNodeRunManual{
+		User:    *u,
+		Payload: map[string]string{"git.branch": "master"},
+	})
+	test.NoError(t, err)
+
+	// A pipeline without stage succeeds immediately
+	wr1, err = LoadRun(db, key, w1.Name, wr1.Number)
+	test.NoError(t, err)
+	assert.Equal(t, sdk.StatusSuccess.String(), wr1.Status)
+	assert.Equal(t, "master", wr1.VCSBranch)
+	assert.Equal(t, "abcdef", wr1.VCSHash)
+	assert.Equal(t, u.Username, wr1.Username)
+
+	tests := []struct {
+		filter sdk.WorkflowRunFilter
+		count  int
+	}{
+		{sdk.WorkflowRunFilter{}, 3},
+		{sdk.WorkflowRunFilter{Workflow: w1.Name}, 2},
+		{sdk.WorkflowRunFilter{Branch: "master"}, 2},
+		{sdk.WorkflowRunFilter{Workflow: w2.Name, Branch: "master"}, 1},
+		{sdk.WorkflowRunFilter{Hash: "abcdef"}, 1},
+		{sdk.WorkflowRunFilter{Username: u.Username}, 3},
+		{sdk.WorkflowRunFilter{Status: sdk.StatusFail.String()}, 0},
+		{sdk.WorkflowRunFilter{Node: pip.Name}, 3},
+		{sdk.WorkflowRunFilter{Tags: []sdk.WorkflowRunTag{{Tag: "env", Value: "prod"}}}, 1},
+		{sdk.WorkflowRunFilter{Tags: []sdk.WorkflowRunTag{{Tag: "env", Value: "dev"}}}, 0},
+	}
+	for _, tt := range tests {
+		runs, count, err := SearchRuns(db, key, tt.filter)
+		test.NoError(t, err)
+		assert.Equal(t, tt.count, count, "%+v", tt.filter)
+		assert.Len(t, runs, tt.count, "%+v", tt.filter)
+	}
+
+	since := time.Now().Add(time.Hour)
+	_, count, err := SearchRuns(db, key, sdk.WorkflowRunFilter{Since: &since})
+	test.NoError(t, err)
+	assert.Equal(t, 0, count)
+
+	// Pagination
+	runs, count, err := SearchRuns(db, key, sdk.WorkflowRunFilter{Offset: 1, Limit: 1})
+	test.NoError(t, err)
+	assert.Equal(t, 3, count)
+	assert.Len(t, runs, 1)
+}
+
+func TestSubWorkflowRun(t *testing.T) {
+	db := test.SetupPG(t, bootstrap.InitiliazeDB)
+	u, _ := assets.InsertAdminUser(db)
+	key := sdk.RandomString(10)
+	proj := assets.InsertTestProject(t, db, key, key, u)
+
+	pip := sdk.Pipeline{
+		ProjectID:  proj.ID,
+		ProjectKey: proj.Key,
+		Name:       "pip1",
+		Type:       sdk.BuildPipeline,
+	}
+	test.NoError(t, pipeline.InsertPipeline(db, proj, &pip, u))
+
+	s := sdk.NewStage("stage 1")
+	s.Enabled = true
+	s.PipelineID = pip.ID
+	pipeline.InsertStage(db, s)
+	j := &sdk.Job{
+		Enabled: true,
+		Action: sdk.Action{
+			Enabled: true,
+		},
+	}
+	pipeline.InsertJob(db, j, s.ID, &pip)
+	s.Jobs = append(s.Jobs, *j)
+	pip.Stages = append(pip.Stages, *s)
+
+	deploy := sdk.Workflow{
+		Name:       "test_deploy",
+		ProjectID:  proj.ID,
+		ProjectKey: proj.Key,
+		Root: &sdk.WorkflowNode{
+			Pipeline: pip,
+		},
+	}
+	test.NoError(t, Insert(db, &deploy, u))
+
+	w := sdk.Workflow{
+		Name:       "test_main",
+		ProjectID:  proj.ID,
+		ProjectKey: proj.Key,
+		Root: &sdk.WorkflowNode{
+			SubWorkflow: &sdk.WorkflowNodeSubWorkflow{ProjectKey: key, WorkflowName: "test_deploy"},
+			Context: &sdk.WorkflowNodeContext{
+				DefaultPayload: map[string]string{"version": "1.0"},
+			},
+		},
+	}
+	test.NoError(t, Insert(db, &w, u))
+	w1, err := Load(db, key, "test_main", u)
+	test.NoError(t, err)
+	assert.Equal(t, &sdk.WorkflowNodeSubWorkflow{WorkflowID: deploy.ID, ProjectKey: key, WorkflowName: "test_deploy"}, w1.Root.SubWorkflow)
+	assert.Equal(t, "test_deploy", w1.Root.Name)
+
+	// The deploy workflow can't call back the main workflow
+	deploy1, err := Load(db, key, "test_deploy", u)
+	test.NoError(t, err)
+	loop := *deploy1
+	loop.Root = &sdk.WorkflowNode{SubWorkflow: &sdk.WorkflowNodeSubWorkflow{ProjectKey: key, WorkflowName: "test_main"}}
+	assert.Error(t, Update(db, &loop, deploy1, u))
+
+	wr, err := ManualRun(db, w1, &sdk.WorkflowNodeRunManual{User: *u})
+	test.NoError(t, err)
+	wr, err = LoadRun(db, key, "test_main", wr.Number)
+	test.NoError(t, err)
+
+	nodeRun := wr.WorkflowNodeRuns[w1.RootID][0]
+	assert.Equal(t, sdk.StatusBuilding.String(), nodeRun.Status)
+	assert.NotZero(t, nodeRun.SubWorkflowRunID)
+
+	subRun, err := loadRunByID(db, nodeRun.SubWorkflowRunID)
+	test.NoError(t, err)
+	assert.Equal(t, nodeRun.ID, subRun.ParentNodeRunID)
+	assert.Equal(t, deploy.ID, subRun.WorkflowID)
+	subNodeRun := subRun.WorkflowNodeRuns[deploy.RootID][0]
+	assert.Equal(t, "1.0", sdk.ParameterValue(subNodeRun.BuildParameters, "version"))
+
+	// The end of the sub workflow run ends the node run, with the exported variables
+	subNodeRun.Status = sdk.StatusSuccess.String()
+	sdk.AddParameter(&subNodeRun.BuildParameters, "cds.build.version", sdk.StringParameter, "1.0.1")
+	test.NoError(t, UpdateNodeRun(db, &subNodeRun))
+	test.NoError(t, updateWorkflowRun(db, subRun))
+
+	wr, err = LoadRun(db, key, "test_main", wr.Number)
+	test.NoError(t, err)
+	nodeRun = wr.WorkflowNodeRuns[w1.RootID][0]
+	assert.Equal(t, sdk.StatusSuccess.String(), nodeRun.Status)
+	assert.Equal(t, "1.0.1", sdk.ParameterValue(nodeRun.BuildParameters, "cds.build.version"))
+	assert.Equal(t, sdk.StatusSuccess.String(), wr.Status)
+}
+
+func TestManualRunNeedsDeployCapability(t *testing.T) {
+	db := test.SetupPG(t, bootstrap.InitiliazeDB)
+	u, _ := assets.InsertAdminUser(db)
+	key := sdk.RandomString(10)
+	proj := assets.InsertTestProject(t, db, key, key, u)
+
+	pip := sdk.Pipeline{
+		ProjectID:  proj.ID,
+		ProjectKey: proj.Key,
+		Name:       "pip1",
+		Type:       sdk.DeploymentPipeline,
+	}
+	test.NoError(t, pipeline.InsertPipeline(db, proj, &pip, u))
+
+	env := sdk.Environment{
+		Name:       "prod",
+		ProjectID:  proj.ID,
+		ProjectKey: proj.Key,
+	}
+	test.NoError(t, environment.InsertEnvironment(db, &env))
+
+	//A runner can run on the environment, but not deploy
+	runners := &sdk.Group{Name: sdk.RandomString(10)}
+	runner, _ := assets.InsertLambaUser(db, runners)
+	deployers := &sdk.Group{Name: sdk.RandomString(10)}
+	deployer, _ := assets.InsertLambaUser(db, deployers)
+	test.NoError(t, group.InsertGroupsInEnvironment(db, []sdk.GroupPermission{
+		{Group: *runners, Permission: 5, Role: sdk.RoleRunner},
+		{Group: *deployers, Permission: 5, Role: sdk.RoleDeployer},
+	}, env.ID))
+
+	w := sdk.Workflow{
+		Name:       "test_deploy",
+		ProjectID:  proj.ID,
+		ProjectKey: proj.Key,
+		Root: &sdk.WorkflowNode{
+			Pipeline: pip,
+			Context: &sdk.WorkflowNodeContext{
+				Environment: &env,
+			},
+		},
+	}
+	test.NoError(t, Insert(db, &w, u))
+	w1, err := Load(db, key, w.Name, u)
+	test.NoError(t, err)
+
+	runner, err = loadUserEnvironmentPermissions(db, runner.Username)
+	test.NoError(t, err)
+	_, err = ManualRun(db, w1, &sdk.WorkflowNodeRunManual{User: *runner})
+	assert.Equal(t, sdk.ErrNoEnvExecution, errors.Cause(err))
+
+	deployer, err = loadUserEnvironmentPermissions(db, deployer.Username)
+	test.NoError(t, err)
+	_, err = ManualRun(db, w1, &sdk.WorkflowNodeRunManual{User: *deployer})
+	test.NoError(t, err)
+}
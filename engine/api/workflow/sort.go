@@ -76,7 +76,10 @@ func sortNodeTriggers(triggers *[]sdk.WorkflowNodeTrigger) {
 
 func sortConditions(conditions *[]sdk.WorkflowTriggerCondition) {
 	sort.Slice(*conditions, func(i, j int) bool {
-		return strings.Compare((*conditions)[i].Variable, (*conditions)[j].Variable) < 0
+		if (*conditions)[i].Variable != (*conditions)[j].Variable {
+			return strings.Compare((*conditions)[i].Variable, (*conditions)[j].Variable) < 0
+		}
+		return strings.Compare((*conditions)[i].Expression, (*conditions)[j].Expression) < 0
 	})
 }
 
@@ -0,0 +1,222 @@
+package workflow
+
+import (
+	"database/sql"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-gorp/gorp"
+
+	"github.com/ovh/cds/engine/api/permission"
+	"github.com/ovh/cds/sdk"
+	"github.com/ovh/cds/sdk/log"
+)
+
+// checkSubWorkflow resolves the workflow called by a node, and checks that the user is allowed to run it
+// and that it does not call back the workflow of the node
+func checkSubWorkflow(db gorp.SqlExecutor, w *sdk.Workflow, n *sdk.WorkflowNode, u *sdk.User) error {
+	sub := n.SubWorkflow
+	if n.PipelineID != 0 || n.Pipeline.ID != 0 {
+		return sdk.WrapError(sdk.ErrWorkflowInvalid, "checkSubWorkflow> Node %s cannot run both a pipeline and a workflow", n.Name)
+	}
+
+	if sub.WorkflowID == 0 {
+		query := `select workflow.id
+		from workflow
+		join project on project.id = workflow.project_id
+		where project.projectkey = $1 and workflow.name = $2`
+		id, err := db.SelectInt(query, sub.ProjectKey, sub.WorkflowName)
+		if err != nil {
+			return sdk.WrapError(err, "checkSubWorkflow> Unable to load workflow %s", sub)
+		}
+		if id == 0 {
+			return sdk.WrapError(sdk.ErrWorkflowNotFound, "checkSubWorkflow> Workflow %s not found", sub)
+		}
+		sub.WorkflowID = id
+	}
+
+	resolved := loadSubWorkflow(db, sub.WorkflowID)
+	if resolved.WorkflowName == "" {
+		return sdk.WrapError(sdk.ErrWorkflowNotFound, "checkSubWorkflow> Workflow %d not found", sub.WorkflowID)
+	}
+	*sub = *resolved
+
+	if u != nil && permission.ProjectPermission(sub.ProjectKey, u) < permission.PermissionReadExecute {
+		return sdk.WrapError(sdk.ErrForbidden, "checkSubWorkflow> %s is not allowed to run workflow %s", u.Username, sub)
+	}
+
+	//Browse the sub workflows of the sub workflow to detect cycles
+	visited := map[int64]bool{}
+	todo := []int64{sub.WorkflowID}
+	for len(todo) > 0 {
+		id := todo[0]
+		todo = todo[1:]
+		if id == w.ID {
+			return sdk.WrapError(sdk.ErrWorkflowInvalid, "checkSubWorkflow> Workflow %s calls back workflow %s", sub, w.Name)
+		}
+		if visited[id] {
+			continue
+		}
+		visited[id] = true
+
+		var ids []int64
+		if _, err := db.Select(&ids, "select distinct sub_workflow_id from workflow_node where workflow_id = $1 and sub_workflow_id is not null", id); err != nil {
+			return sdk.WrapError(err, "checkSubWorkflow> Unable to load sub workflows of workflow %d", id)
+		}
+		todo = append(todo, ids...)
+	}
+	return nil
+}
+
+// loadSubWorkflow returns the sub workflow of a node. The project key and the name are empty if the workflow has been deleted
+func loadSubWorkflow(db gorp.SqlExecutor, id int64) *sdk.WorkflowNodeSubWorkflow {
+	sub := &sdk.WorkflowNodeSubWorkflow{WorkflowID: id}
+	query := `select project.projectkey, workflow.name
+	from workflow
+	join project on project.id = workflow.project_id
+	where workflow.id = $1`
+	if err := db.QueryRow(query, id).Scan(&sub.ProjectKey, &sub.WorkflowName); err != nil && err != sql.ErrNoRows {
+		log.Warning("loadSubWorkflow> Unable to load workflow %d: %s", id, err)
+	}
+	return sub
+}
+
+// startSubWorkflowRun starts a run of the sub workflow of a node, with the payload and the parameters of the node run.
+// The node run is building until the end of the sub workflow run
+func startSubWorkflowRun(db gorp.SqlExecutor, w *sdk.WorkflowRun, n *sdk.WorkflowNode, run *sdk.WorkflowNodeRun) error {
+	sub, err := LoadByID(db, n.SubWorkflow.WorkflowID, nil)
+	if err != nil {
+		log.Warning("startSubWorkflowRun> Unable to load workflow %s: %s", n.SubWorkflow, err)
+		AddWorkflowRunInfo(w, sdk.SpawnMsg{
+			ID:   sdk.MsgWorkflowError.ID,
+			Args: []interface{}{sdk.WrapError(sdk.ErrWorkflowNotFound, "workflow %s", n.SubWorkflow)},
+		})
+		run.Status = sdk.StatusFail.String()
+		run.Done = time.Now()
+		if err := UpdateNodeRun(db, run); err != nil {
+			return sdk.WrapError(err, "startSubWorkflowRun> Unable to update node run %d", run.ID)
+		}
+		return updateWorkflowRun(db, w)
+	}
+
+	run.Status = sdk.StatusBuilding.String()
+	if err := UpdateNodeRun(db, run); err != nil {
+		return sdk.WrapError(err, "startSubWorkflowRun> Unable to update node run %d", run.ID)
+	}
+
+	lastWorkflowRun, err := LoadLastRun(db, sub.ProjectKey, sub.Name)
+	if err != nil && err != sdk.ErrWorkflowNotFound {
+		return sdk.WrapError(err, "startSubWorkflowRun> Unable to load last run")
+	}
+
+	var number = int64(1)
+	if lastWorkflowRun != nil {
+		number = lastWorkflowRun.Number + 1
+	}
+
+	subRun := &sdk.WorkflowRun{
+		Number:          number,
+		Workflow:        *sub,
+		WorkflowID:      sub.ID,
+		WorkflowVersion: sub.Version,
+		Start:           time.Now(),
+		LastModified:    time.Now(),
+		ProjectID:       sub.ProjectID,
+		ParentNodeRunID: run.ID,
+	}
+	if err := insertWorkflowRun(db, subRun); err != nil {
+		return sdk.WrapError(err, "startSubWorkflowRun> Unable to run workflow %s", n.SubWorkflow)
+	}
+
+	run.SubWorkflowRunID = subRun.ID
+	if err := UpdateNodeRun(db, run); err != nil {
+		return sdk.WrapError(err, "startSubWorkflowRun> Unable to update node run %d", run.ID)
+	}
+
+	AddWorkflowRunInfo(w, sdk.SpawnMsg{
+		ID:   sdk.MsgWorkflowSubWorkflowStarted.ID,
+		Args: []interface{}{sub.ProjectKey, sub.Name, subRun.Number},
+	})
+	if err := updateWorkflowRun(db, w); err != nil {
+		return sdk.WrapError(err, "startSubWorkflowRun> Unable to update workflow run %d", w.ID)
+	}
+
+	manual := &sdk.WorkflowNodeRunManual{
+		Payload:            run.Payload,
+		PipelineParameters: run.PipelineParameters,
+		User:               sdk.User{Username: w.Username},
+	}
+	return processWorkflowRun(db, subRun, nil, manual, nil)
+}
+
+// endSubWorkflowRun reports the end of a sub workflow run to the node run which started it: the node run gets the status
+// and the exported variables (cds.build.*) of the sub workflow run, then the calling workflow run is processed again
+func endSubWorkflowRun(db gorp.SqlExecutor, subRun *sdk.WorkflowRun) error {
+	if subRun.Status != sdk.StatusSuccess.String() && subRun.Status != sdk.StatusFail.String() && subRun.Status != sdk.StatusStopped.String() {
+		return nil
+	}
+
+	run, err := LoadNodeRunByID(db, subRun.ParentNodeRunID)
+	if err != nil {
+		return sdk.WrapError(err, "endSubWorkflowRun> Unable to load node run %d", subRun.ParentNodeRunID)
+	}
+	if run.SubWorkflowRunID != subRun.ID || run.Status != sdk.StatusBuilding.String() {
+		return nil
+	}
+
+	subRun, err = loadRunByID(db, subRun.ID)
+	if err != nil {
+		return sdk.WrapError(err, "endSubWorkflowRun> Unable to reload workflow run")
+	}
+
+	//The variables exported by the last node runs override the previous ones
+	nodeRuns := []sdk.WorkflowNodeRun{}
+	for _, nrs := range subRun.WorkflowNodeRuns {
+		nodeRuns = append(nodeRuns, nrs...)
+	}
+	sort.Slice(nodeRuns, func(i, j int) bool { return nodeRuns[i].ID < nodeRuns[j].ID })
+	for _, nr := range nodeRuns {
+		for _, p := range nr.BuildParameters {
+			if strings.HasPrefix(p.Name, "cds.build.") {
+				sdk.AddParameter(&run.BuildParameters, p.Name, p.Type, p.Value)
+			}
+		}
+	}
+
+	run.Status = subRun.Status
+	run.Done = time.Now()
+	if err := UpdateNodeRun(db, run); err != nil {
+		return sdk.WrapError(err, "endSubWorkflowRun> Unable to update node run %d", run.ID)
+	}
+
+	w, err := loadRunByID(db, run.WorkflowRunID)
+	if err != nil {
+		return sdk.WrapError(err, "endSubWorkflowRun> Unable to load workflow run %d", run.WorkflowRunID)
+	}
+	AddWorkflowRunInfo(w, sdk.SpawnMsg{
+		ID:   sdk.MsgWorkflowSubWorkflowEnded.ID,
+		Args: []interface{}{subRun.Workflow.ProjectKey, subRun.Workflow.Name, subRun.Number, subRun.Status},
+	})
+
+	//The triggers of the node run are checked when it is over, successful or not
+	if run.Status == sdk.StatusSuccess.String() || run.Status == sdk.StatusFail.String() {
+		if err := processWorkflowRun(db, w, nil, nil, nil); err != nil {
+			return sdk.WrapError(err, "endSubWorkflowRun> Unable to process workflow run %d", w.ID)
+		}
+		return nil
+	}
+	return updateWorkflowRun(db, w)
+}
+
+// stopSubWorkflowRun stops the sub workflow run started by a node run
+func stopSubWorkflowRun(db gorp.SqlExecutor, n *sdk.WorkflowNodeRun, u *sdk.User) error {
+	subRun, err := loadRunByID(db, n.SubWorkflowRunID)
+	if err != nil {
+		return sdk.WrapError(err, "stopSubWorkflowRun> Unable to load workflow run %d", n.SubWorkflowRunID)
+	}
+	if _, err := StopWorkflowRun(db, subRun, u); err != nil {
+		return sdk.WrapError(err, "stopSubWorkflowRun> Unable to stop workflow run %d", n.SubWorkflowRunID)
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/fsamin/go-dump"
+	"github.com/go-gorp/gorp"
+	"github.com/gorilla/mux"
+
+	"github.com/ovh/cds/engine/api/businesscontext"
+	"github.com/ovh/cds/engine/api/workflow"
+	"github.com/ovh/cds/engine/api/workflowhook"
+	"github.com/ovh/cds/sdk"
+)
+
+// maxWebHookPayloadSize is the maximum size of the body of a WebHook call
+const maxWebHookPayloadSize = 5 << 20
+
+func getWorkflowHookModelsHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	ms, err := workflow.LoadHookModels(db)
+	if err != nil {
+		return sdk.WrapError(err, "getWorkflowHookModelsHandler> Unable to load hook models")
+	}
+	return WriteJSON(w, r, ms, http.StatusOK)
+}
+
+func getWorkflowHookModelHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	name := mux.Vars(r)["model"]
+	m, err := workflow.LoadHookModelByName(db, name)
+	if err != nil {
+		return sdk.WrapError(err, "getWorkflowHookModelHandler> Unable to load hook model %s", name)
+	}
+	return WriteJSON(w, r, m, http.StatusOK)
+}
+
+// postWorkflowWebHookHandler runs the workflow of a WebHook. The JSON body and the query parameters are used as payload
+func postWorkflowWebHookHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	uuid := mux.Vars(r)["uuid"]
+	h, errh := workflow.LoadHookByUUID(db, uuid)
+	if errh != nil {
+		return sdk.WrapError(errh, "postWorkflowWebHookHandler> Unable to load hook %s", uuid)
+	}
+	if h.WorkflowHookModel.Name != sdk.WebHookModelName {
+		return sdk.WrapError(sdk.ErrNotFound, "postWorkflowWebHookHandler> Hook %s is not a %s", uuid, sdk.WebHookModelName)
+	}
+
+	payload := map[string]string{}
+	data, errr := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxWebHookPayloadSize))
+	if errr != nil {
+		return sdk.WrapError(sdk.ErrWrongRequest, "postWorkflowWebHookHandler> Unable to read body: %s", errr)
+	}
+	if len(data) > 0 {
+		var body map[string]interface{}
+		if err := json.Unmarshal(data, &body); err != nil {
+			return sdk.WrapError(sdk.ErrWrongRequest, "postWorkflowWebHookHandler> Unable to unmarshal body: %s", err)
+		}
+		m, errm := dump.ToMap(body, dump.WithDefaultLowerCaseFormatter())
+		if errm != nil {
+			return sdk.WrapError(sdk.ErrWrongRequest, "postWorkflowWebHookHandler> Unable to read payload: %s", errm)
+		}
+		payload = m
+	}
+	for k, v := range r.URL.Query() {
+		if len(v) > 0 {
+			payload[k] = v[0]
+		}
+	}
+
+	wr, errf := workflowhook.Fire(db, h, payload)
+	if errf != nil {
+		return sdk.WrapError(errf, "postWorkflowWebHookHandler> Unable to run hook %s", uuid)
+	}
+	if wr == nil {
+		w.WriteHeader(http.StatusNoContent)
+		return nil
+	}
+	return WriteJSON(w, r, wr, http.StatusAccepted)
+}
+
+// getWorkflowSchedulesHandler returns the next executions of the Scheduler hooks of a workflow, sorted by date
+func getWorkflowSchedulesHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	vars := mux.Vars(r)
+	key := vars["permProjectKey"]
+	name := vars["workflowName"]
+
+	count := 10
+	if s := r.FormValue("count"); s != "" {
+		var err error
+		count, err = strconv.Atoi(s)
+		if err != nil || count <= 0 || count > 1000 {
+			return sdk.WrapError(sdk.ErrWrongRequest, "getWorkflowSchedulesHandler> Invalid count %s", s)
+		}
+	}
+
+	wf, errl := workflow.Load(db, key, name, c.User)
+	if errl != nil {
+		return sdk.WrapError(errl, "getWorkflowSchedulesHandler> Unable to load workflow %s", name)
+	}
+
+	now := time.Now()
+	execs := []sdk.WorkflowScheduledExecution{}
+	for _, id := range wf.Nodes() {
+		n := wf.GetNode(id)
+		if n == nil {
+			continue
+		}
+		for i := range n.Hooks {
+			h := &n.Hooks[i]
+			if h.WorkflowHookModel.Name != sdk.SchedulerModelName {
+				continue
+			}
+			dates, err := workflowhook.NextExecutions(h, now, count)
+			if err != nil {
+				return sdk.WrapError(err, "getWorkflowSchedulesHandler> Invalid scheduler hook %s", h.UUID)
+			}
+			for _, d := range dates {
+				execs = append(execs, sdk.WorkflowScheduledExecution{
+					HookUUID:       h.UUID,
+					WorkflowNodeID: n.ID,
+					NodeName:       n.Name,
+					Cron:           h.Config[sdk.HookConfigCronExpr],
+					Timezone:       h.Config[sdk.HookConfigTimezone],
+					Date:           d,
+				})
+			}
+		}
+	}
+
+	sort.Slice(execs, func(i, j int) bool { return execs[i].Date.Before(execs[j].Date) })
+	if len(execs) > count {
+		execs = execs[:count]
+	}
+	return WriteJSON(w, r, execs, http.StatusOK)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/go-gorp/gorp"
+	"github.com/gorilla/mux"
+	"gopkg.in/yaml.v2"
+
+	"github.com/ovh/cds/engine/api/businesscontext"
+	"github.com/ovh/cds/engine/api/project"
+	"github.com/ovh/cds/engine/api/workflow"
+	"github.com/ovh/cds/sdk"
+	"github.com/ovh/cds/sdk/exportentities"
+	"github.com/ovh/cds/sdk/log"
+)
+
+// getWorkflowExportHandler exports a workflow in yaml (default) or json
+func getWorkflowExportHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	vars := mux.Vars(r)
+	key := vars["permProjectKey"]
+	name := vars["workflowName"]
+
+	format := r.FormValue("format")
+	if format == "" {
+		format = "yaml"
+	}
+	f, errF := exportentities.GetFormat(format)
+	if errF != nil {
+		return sdk.WrapError(sdk.ErrWrongRequest, "getWorkflowExportHandler> Unable to get format: %s", errF)
+	}
+
+	wf, errL := workflow.Load(db, key, name, c.User)
+	if errL != nil {
+		return sdk.WrapError(errL, "getWorkflowExportHandler> Unable to load workflow %s", name)
+	}
+
+	e, errE := exportentities.NewWorkflow(wf)
+	if errE != nil {
+		return sdk.WrapError(errE, "getWorkflowExportHandler> Unable to export workflow %s", name)
+	}
+
+	btes, errM := exportentities.Marshal(e, f)
+	if errM != nil {
+		return sdk.WrapError(sdk.ErrWrongRequest, "getWorkflowExportHandler> Unable to marshal workflow %s: %s", name, errM)
+	}
+
+	if f == exportentities.FormatJSON {
+		w.Header().Add("Content-Type", "application/json")
+	} else {
+		w.Header().Add("Content-Type", "application/x-yaml")
+	}
+	w.WriteHeader(http.StatusOK)
+	_, err := w.Write(btes)
+	return err
+}
+
+// postWorkflowImportHandler creates a workflow from its yaml or json export. With force=true, an existing workflow is updated
+func postWorkflowImportHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	vars := mux.Vars(r)
+	key := vars["permProjectKey"]
+	force := FormBool(r, "force")
+
+	format := r.FormValue("format")
+	if format == "" {
+		format = "yaml"
+	}
+	f, errF := exportentities.GetFormat(format)
+	if errF != nil {
+		return sdk.WrapError(sdk.ErrWrongRequest, "postWorkflowImportHandler> Unable to get format: %s", errF)
+	}
+
+	data, errRead := ioutil.ReadAll(r.Body)
+	if errRead != nil {
+		return sdk.WrapError(sdk.ErrWrongRequest, "postWorkflowImportHandler> Unable to read body")
+	}
+
+	payload := &exportentities.Workflow{}
+	var errorParse error
+	switch f {
+	case exportentities.FormatJSON:
+		errorParse = json.Unmarshal(data, payload)
+	case exportentities.FormatYAML:
+		errorParse = yaml.Unmarshal(data, payload)
+	default:
+		return sdk.WrapError(sdk.ErrWrongRequest, "postWorkflowImportHandler> Unsupported format %s", format)
+	}
+	if errorParse != nil {
+		log.Warning("postWorkflowImportHandler> Cannot parse workflow: %s", errorParse)
+		return sdk.ErrWrongRequest
+	}
+
+	wf, errW := payload.Workflow()
+	if errW != nil {
+		return sdk.WrapError(errW, "postWorkflowImportHandler> Invalid workflow %s", payload.Name)
+	}
+
+	proj, errP := project.Load(db, key, c.User, project.LoadOptions.WithApplications, project.LoadOptions.WithPipelines, project.LoadOptions.WithEnvironments)
+	if errP != nil {
+		return sdk.WrapError(errP, "postWorkflowImportHandler> Unable to load project %s", key)
+	}
+
+	tx, errT := db.Begin()
+	if errT != nil {
+		return sdk.WrapError(errT, "postWorkflowImportHandler> Cannot start transaction")
+	}
+	defer tx.Rollback()
+
+	if err := workflow.Import(tx, proj, wf, force, c.User); err != nil {
+		return sdk.WrapError(err, "postWorkflowImportHandler> Unable to import workflow %s", wf.Name)
+	}
+
+	if err := project.UpdateLastModified(tx, c.User, proj); err != nil {
+		return sdk.WrapError(err, "postWorkflowImportHandler> Cannot update project last modified date")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return sdk.WrapError(err, "postWorkflowImportHandler> Cannot commit transaction")
+	}
+
+	wf1, errl := workflow.LoadByID(db, wf.ID, c.User)
+	if errl != nil {
+		return sdk.WrapError(errl, "postWorkflowImportHandler> Cannot load workflow")
+	}
+	return WriteJSON(w, r, wf1, http.StatusOK)
+}
@@ -1,9 +1,13 @@
 package main
 
 import (
+	"crypto/sha512"
+	"encoding/hex"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"mime"
+	"mime/multipart"
 	"net/http"
 	"strconv"
 	"time"
@@ -15,7 +19,6 @@ import (
 
 	"github.com/ovh/cds/engine/api/artifact"
 	"github.com/ovh/cds/engine/api/businesscontext"
-	"github.com/ovh/cds/engine/api/objectstore"
 	"github.com/ovh/cds/engine/api/worker"
 	"github.com/ovh/cds/engine/api/workflow"
 	"github.com/ovh/cds/sdk"
@@ -58,6 +61,7 @@ func postTakeWorkflowJobHandler(w http.ResponseWriter, r *http.Request, db *gorp
 		return sdk.WrapError(errBegin, "postTakeWorkflowJobHandler> Cannot start transaction")
 	}
 	defer tx.Rollback()
+	defer workflow.DiscardRunEvents(tx)
 
 	//Load worker model
 	workerModel := c.Worker.Name
@@ -112,6 +116,7 @@ func postTakeWorkflowJobHandler(w http.ResponseWriter, r *http.Request, db *gorp
 	if err := tx.Commit(); err != nil {
 		return sdk.WrapError(err, "postTakeWorkflowJobHandler> Cannot commit transaction")
 	}
+	workflow.PublishRunEvents(tx)
 
 	//Feed the worker
 	pbji := worker.WorkflowNodeJobRunInfo{}
@@ -162,6 +167,7 @@ func postSpawnInfosWorkflowJobHandler(w http.ResponseWriter, r *http.Request, db
 		return sdk.WrapError(errBegin, "postSpawnInfosWorkflowJobHandler> Cannot start transaction")
 	}
 	defer tx.Rollback()
+	defer workflow.DiscardRunEvents(tx)
 
 	if _, err := workflow.AddSpawnInfosNodeJobRun(tx, id, s); err != nil {
 		return sdk.WrapError(err, "postSpawnInfosWorkflowJobHandler> Cannot save job %d", id)
@@ -170,6 +176,7 @@ func postSpawnInfosWorkflowJobHandler(w http.ResponseWriter, r *http.Request, db
 	if err := tx.Commit(); err != nil {
 		return sdk.WrapError(err, "addSpawnInfosPipelineBuildJobHandler> Cannot commit tx")
 	}
+	workflow.PublishRunEvents(tx)
 
 	return WriteJSON(w, r, nil, http.StatusOK)
 }
@@ -197,6 +204,7 @@ func postWorkflowJobResultHandler(w http.ResponseWriter, r *http.Request, db *go
 		return sdk.WrapError(errb, "postWorkflowJobResultHandler> Cannot begin tx")
 	}
 	defer tx.Rollback()
+	defer workflow.DiscardRunEvents(tx)
 
 	//Update worker status
 	if err := worker.UpdateWorkerStatus(tx, c.Worker.ID, sdk.StatusWaiting); err != nil {
@@ -229,6 +237,7 @@ func postWorkflowJobResultHandler(w http.ResponseWriter, r *http.Request, db *go
 	if err := tx.Commit(); err != nil {
 		return sdk.WrapError(err, "postWorkflowJobResultHandler> Cannot commit tx")
 	}
+	workflow.PublishRunEvents(tx)
 
 	return nil
 }
@@ -289,12 +298,17 @@ func postWorkflowJobStepStatusHandler(w http.ResponseWriter, r *http.Request, db
 		return sdk.WrapError(errB, "postWorkflowJobStepStatusHandler> Cannot start transaction")
 	}
 	defer tx.Rollback()
+	defer workflow.DiscardRunEvents(tx)
 
 	if err := workflow.UpdateNodeJobRun(tx, pbJob); err != nil {
 		return sdk.WrapError(err, "postWorkflowJobStepStatusHandler> Error while update job run")
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return sdk.WrapError(err, "postWorkflowJobStepStatusHandler> Cannot commit transaction")
+	}
+	workflow.PublishRunEvents(tx)
+	return nil
 }
 
 func getWorkflowJobQueueHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
@@ -313,6 +327,8 @@ func getWorkflowJobQueueHandler(w http.ResponseWriter, r *http.Request, db *gorp
 		return sdk.WrapError(err, "getWorkflowJobQueueHandler> Unable to load queue")
 	}
 
+	workflow.SetQueueQuotas(jobs)
+
 	return WriteJSON(w, r, jobs, http.StatusOK)
 }
 
@@ -339,6 +355,7 @@ func postWorkflowJobTestsResultsHandler(w http.ResponseWriter, r *http.Request,
 		return sdk.WrapError(errB, "postWorkflowJobTestsResultsHandler> Cannot start transaction")
 	}
 	defer tx.Rollback()
+	defer workflow.DiscardRunEvents(tx)
 
 	wnjr, err := workflow.LoadAndLockNodeRunByID(tx, nodeRunJob.WorkflowNodeRunID)
 	if err != nil {
@@ -380,6 +397,7 @@ func postWorkflowJobTestsResultsHandler(w http.ResponseWriter, r *http.Request,
 	if err := tx.Commit(); err != nil {
 		return sdk.WrapError(err, "postWorkflowJobTestsResultsHandler> Cannot update node run")
 	}
+	workflow.PublishRunEvents(tx)
 	return nil
 }
 
@@ -400,6 +418,7 @@ func postWorkflowJobVariableHandler(w http.ResponseWriter, r *http.Request, db *
 		return sdk.WrapError(errb, "postWorkflowJobVariableHandler> Unable to start tx")
 	}
 	defer tx.Rollback()
+	defer workflow.DiscardRunEvents(tx)
 
 	job, errj := workflow.LoadAndLockNodeJobRun(tx, id)
 	if errj != nil {
@@ -426,6 +445,7 @@ func postWorkflowJobVariableHandler(w http.ResponseWriter, r *http.Request, db *
 	if err := tx.Commit(); err != nil {
 		return sdk.WrapError(err, "postWorkflowJobVariableHandler> Unable to commit tx")
 	}
+	workflow.PublishRunEvents(tx)
 
 	return nil
 }
@@ -498,35 +518,165 @@ func postWorkflowJobArtifactHandler(w http.ResponseWriter, r *http.Request, db *
 	}
 
 	art := sdk.WorkflowNodeRunArtifact{
-		Name:              fileName,
-		Tag:               tag,
-		DownloadHash:      hash,
-		Size:              size,
-		Perm:              uint32(perm),
-		MD5sum:            md5sum,
-		WorkflowNodeRunID: nodeRun.ID,
-		WorkflowID:        nodeRun.WorkflowRunID,
-		Created:           time.Now(),
+		Name:                 fileName,
+		Tag:                  tag,
+		DownloadHash:         hash,
+		Size:                 size,
+		Perm:                 uint32(perm),
+		MD5sum:               md5sum,
+		WorkflowNodeRunID:    nodeRun.ID,
+		WorkflowNodeRunJobID: nodeJobRun.ID,
+		WorkflowID:           nodeRun.WorkflowRunID,
+		Created:              time.Now(),
 	}
 
 	files := m.File[fileName]
+	var file multipart.File
 	if len(files) == 1 {
-		file, err := files[0].Open()
+		var err error
+		file, err = files[0].Open()
 		if err != nil {
 			return sdk.WrapError(err, "postWorkflowJobArtifactHandler> cannot open file")
 
 		}
+		defer file.Close()
 
-		if err := artifact.SaveWorkflowFile(&art, file); err != nil {
-			return sdk.WrapError(err, "postWorkflowJobArtifactHandler> Cannot save artifact in store")
+		//Compute the sha512 digest, the content is stored only if no other artifact has the same one
+		sum := sha512.New()
+		if _, err := io.Copy(sum, file); err != nil {
+			return sdk.WrapError(err, "postWorkflowJobArtifactHandler> Cannot compute sha512sum")
+		}
+		if _, err := file.Seek(0, io.SeekStart); err != nil {
+			return sdk.WrapError(err, "postWorkflowJobArtifactHandler> Cannot read file")
 		}
-		file.Close()
+		art.SHA512sum = hex.EncodeToString(sum.Sum(nil))
 	}
 
-	nodeRun.Artifacts = append(nodeRun.Artifacts, art)
-	if err := workflow.InsertArtifact(db, &art); err != nil {
-		_ = objectstore.DeleteArtifact(&art)
-		return sdk.WrapError(err, "postWorkflowJobArtifactHandler> Cannot update workflow node run")
+	// The reference on the stored object and the artifact are inserted together
+	tx, errT := db.Begin()
+	if errT != nil {
+		return sdk.WrapError(errT, "postWorkflowJobArtifactHandler> Cannot start transaction")
 	}
+	defer tx.Rollback()
+
+	var stored bool
+	if file != nil {
+		exists, err := workflow.AcquireArtifactObject(tx, &art)
+		if err != nil {
+			return sdk.WrapError(err, "postWorkflowJobArtifactHandler> Cannot reference stored object")
+		}
+
+		if exists {
+			log.Debug("postWorkflowJobArtifactHandler> Artifact %s already stored with sha512sum %s", art.Name, art.SHA512sum)
+		} else {
+			if err := artifact.SaveWorkflowFile(&art, file); err != nil {
+				return sdk.WrapError(err, "postWorkflowJobArtifactHandler> Cannot save artifact in store")
+			}
+			stored = true
+			if err := workflow.InsertArtifactObject(tx, &art); err != nil {
+				return deleteArtifactObjectOnError(db, tx, &art, sdk.WrapError(err, "postWorkflowJobArtifactHandler> Cannot reference stored object"))
+			}
+		}
+	}
+
+	if err := workflow.InsertArtifact(tx, &art); err != nil {
+		err = sdk.WrapError(err, "postWorkflowJobArtifactHandler> Cannot update workflow node run")
+		if stored {
+			return deleteArtifactObjectOnError(db, tx, &art, err)
+		}
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		err = sdk.WrapError(err, "postWorkflowJobArtifactHandler> Cannot commit transaction")
+		if stored {
+			return deleteArtifactObjectOnError(db, tx, &art, err)
+		}
+		return err
+	}
+	nodeRun.Artifacts = append(nodeRun.Artifacts, art)
 	return nil
 }
+
+// deleteArtifactObjectOnError rolls back the upload of an artifact and deletes the object it stored, unless another
+// upload of the same content references it. It returns err
+func deleteArtifactObjectOnError(db *gorp.DbMap, tx *gorp.Transaction, art *sdk.WorkflowNodeRunArtifact, err error) error {
+	_ = tx.Rollback()
+	if errD := workflow.DeleteOrphanArtifactObject(db, art); errD != nil {
+		log.Warning("postWorkflowJobArtifactHandler> Cannot delete stored object %s: %s", art.GetName(), errD)
+	}
+	return err
+}
+
+// postWorkflowJobArtifactRefHandler adds an artifact on a job run without uploading its content, referencing
+// an object already stored with the same sha512 digest. It returns 404 if there is no such object.
+func postWorkflowJobArtifactRefHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	id, errI := requestVarInt(r, "permID")
+	if errI != nil {
+		return sdk.WrapError(sdk.ErrInvalidID, "postWorkflowJobArtifactRefHandler> Invalid node job run ID")
+	}
+
+	vars := mux.Vars(r)
+	tag := vars["tag"]
+
+	var ref sdk.WorkflowNodeRunArtifact
+	if err := UnmarshalBody(r, &ref); err != nil {
+		return err
+	}
+	if ref.Name == "" || len(ref.SHA512sum) != sha512.Size*2 {
+		return sdk.WrapError(sdk.ErrWrongRequest, "postWorkflowJobArtifactRefHandler> Invalid artifact")
+	}
+
+	nodeJobRun, errJ := workflow.LoadNodeJobRun(db, id)
+	if errJ != nil {
+		return sdk.WrapError(errJ, "postWorkflowJobArtifactRefHandler> Cannot load node job run")
+	}
+
+	nodeRun, errR := workflow.LoadNodeRunByID(db, nodeJobRun.WorkflowNodeRunID)
+	if errR != nil {
+		return sdk.WrapError(errR, "postWorkflowJobArtifactRefHandler> Cannot load node run")
+	}
+
+	hash, errG := generateHash()
+	if errG != nil {
+		return sdk.WrapError(errG, "postWorkflowJobArtifactRefHandler> Could not generate hash")
+	}
+
+	art := sdk.WorkflowNodeRunArtifact{
+		Name:                 ref.Name,
+		Tag:                  tag,
+		DownloadHash:         hash,
+		Size:                 ref.Size,
+		Perm:                 ref.Perm,
+		MD5sum:               ref.MD5sum,
+		SHA512sum:            ref.SHA512sum,
+		WorkflowNodeRunID:    nodeRun.ID,
+		WorkflowNodeRunJobID: nodeJobRun.ID,
+		WorkflowID:           nodeRun.WorkflowRunID,
+		Created:              time.Now(),
+	}
+
+	tx, errT := db.Begin()
+	if errT != nil {
+		return sdk.WrapError(errT, "postWorkflowJobArtifactRefHandler> Cannot start transaction")
+	}
+	defer tx.Rollback()
+
+	exists, errA := workflow.AcquireArtifactObject(tx, &art)
+	if errA != nil {
+		return sdk.WrapError(errA, "postWorkflowJobArtifactRefHandler> Cannot reference stored object")
+	}
+	if !exists {
+		return sdk.ErrNotFound
+	}
+
+	if err := workflow.InsertArtifact(tx, &art); err != nil {
+		return sdk.WrapError(err, "postWorkflowJobArtifactRefHandler> Cannot insert artifact")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return sdk.WrapError(err, "postWorkflowJobArtifactRefHandler> Cannot commit transaction")
+	}
+
+	return WriteJSON(w, r, art, http.StatusOK)
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/go-gorp/gorp"
+	"github.com/gorilla/mux"
+
+	"github.com/ovh/cds/engine/api/businesscontext"
+	"github.com/ovh/cds/engine/api/project"
+	"github.com/ovh/cds/engine/api/workflow"
+	"github.com/ovh/cds/sdk"
+)
+
+// loadRetentionPolicyTarget returns the project id and the workflow id (0 for the project policy) from the request vars
+func loadRetentionPolicyTarget(db gorp.SqlExecutor, r *http.Request, c *businesscontext.Ctx) (int64, int64, error) {
+	vars := mux.Vars(r)
+	key := vars["permProjectKey"]
+	name := vars["workflowName"]
+
+	if name != "" {
+		wf, err := workflow.Load(db, key, name, c.User)
+		if err != nil {
+			return 0, 0, sdk.WrapError(err, "loadRetentionPolicyTarget> Unable to load workflow %s", name)
+		}
+		return wf.ProjectID, wf.ID, nil
+	}
+
+	proj, err := project.Load(db, key, c.User)
+	if err != nil {
+		return 0, 0, sdk.WrapError(err, "loadRetentionPolicyTarget> Unable to load project %s", key)
+	}
+	return proj.ID, 0, nil
+}
+
+func getRetentionPolicyHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	projectID, workflowID, err := loadRetentionPolicyTarget(db, r, c)
+	if err != nil {
+		return err
+	}
+
+	p, err := workflow.LoadRetentionPolicy(db, projectID, workflowID)
+	if err != nil {
+		return sdk.WrapError(err, "getRetentionPolicyHandler> Unable to load retention policy")
+	}
+	return WriteJSON(w, r, p, http.StatusOK)
+}
+
+func putRetentionPolicyHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	projectID, workflowID, err := loadRetentionPolicyTarget(db, r, c)
+	if err != nil {
+		return err
+	}
+
+	var p sdk.WorkflowRetentionPolicy
+	if err := UnmarshalBody(r, &p); err != nil {
+		return err
+	}
+	p.ProjectID = projectID
+	p.WorkflowID = workflowID
+
+	if err := workflow.UpsertRetentionPolicy(db, &p); err != nil {
+		return sdk.WrapError(err, "putRetentionPolicyHandler> Unable to save retention policy")
+	}
+	return WriteJSON(w, r, p, http.StatusOK)
+}
+
+func deleteRetentionPolicyHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	projectID, workflowID, err := loadRetentionPolicyTarget(db, r, c)
+	if err != nil {
+		return err
+	}
+
+	if err := workflow.DeleteRetentionPolicy(db, projectID, workflowID); err != nil {
+		return sdk.WrapError(err, "deleteRetentionPolicyHandler> Unable to delete retention policy")
+	}
+	return nil
+}
+
+func getRetentionReportHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	projectID, _, err := loadRetentionPolicyTarget(db, r, c)
+	if err != nil {
+		return err
+	}
+
+	report, err := workflow.PurgeArtifactsRun(db, projectID, true)
+	if err != nil {
+		return sdk.WrapError(err, "getRetentionReportHandler> Unable to compute purge report")
+	}
+	return WriteJSON(w, r, report, http.StatusOK)
+}
+
+func getAdminRetentionReportHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	report, err := workflow.PurgeArtifactsRun(db, 0, true)
+	if err != nil {
+		return sdk.WrapError(err, "getAdminRetentionReportHandler> Unable to compute purge report")
+	}
+	return WriteJSON(w, r, report, http.StatusOK)
+}
+
+func postWorkflowRunTagsHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	vars := mux.Vars(r)
+	key := vars["permProjectKey"]
+	name := vars["workflowName"]
+	number, err := requestVarInt(r, "number")
+	if err != nil {
+		return err
+	}
+
+	var tags []sdk.WorkflowRunTag
+	if err := UnmarshalBody(r, &tags); err != nil {
+		return err
+	}
+
+	run, err := workflow.LoadRun(db, key, name, number)
+	if err != nil {
+		return sdk.WrapError(err, "postWorkflowRunTagsHandler> Unable to load workflow run")
+	}
+
+	if err := workflow.InsertRunTags(db, run.ID, tags...); err != nil {
+		return sdk.WrapError(err, "postWorkflowRunTagsHandler> Unable to tag workflow run")
+	}
+
+	run, err = workflow.LoadRun(db, key, name, number)
+	if err != nil {
+		return sdk.WrapError(err, "postWorkflowRunTagsHandler> Unable to load workflow run")
+	}
+	return WriteJSON(w, r, run.Tags, http.StatusOK)
+}
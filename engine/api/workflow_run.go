@@ -11,6 +11,7 @@ import (
 
 	"github.com/ovh/cds/engine/api/artifact"
 	"github.com/ovh/cds/engine/api/businesscontext"
+	"github.com/ovh/cds/engine/api/objectstore"
 	"github.com/ovh/cds/engine/api/workflow"
 	"github.com/ovh/cds/sdk"
 	"github.com/ovh/cds/sdk/log"
@@ -131,6 +132,40 @@ func getWorkflowRunsHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbM
 	return WriteJSON(w, r, runs, code)
 }
 
+func searchWorkflowRunsHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	key := mux.Vars(r)["permProjectKey"]
+	filter, err := sdk.ParseWorkflowRunFilter(r.URL.Query())
+	if err != nil {
+		return sdk.WrapError(sdk.ErrWrongRequest, "searchWorkflowRunsHandler> %s", err)
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultLimit
+	}
+
+	//Maximim range is set to 50
+	w.Header().Add("Accept-Range", "run 50")
+	if filter.Limit > rangeMax {
+		return sdk.WrapError(sdk.ErrWrongRequest, "searchWorkflowRunsHandler> Requested range %d not allowed", filter.Limit)
+	}
+
+	runs, count, err := workflow.SearchRuns(db, key, filter)
+	if err != nil {
+		return sdk.WrapError(err, "searchWorkflowRunsHandler> Unable to search workflow runs")
+	}
+
+	code := http.StatusOK
+	if len(runs) < count {
+		code = http.StatusPartialContent
+	}
+	w.Header().Add("Content-Range", fmt.Sprintf("%d-%d/%d", filter.Offset, filter.Offset+len(runs), count))
+
+	for i := range runs {
+		runs[i].Translate(r.Header.Get("Accept-Language"))
+	}
+
+	return WriteJSON(w, r, runs, code)
+}
+
 func getLatestWorkflowRunHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
 	vars := mux.Vars(r)
 	key := vars["permProjectKey"]
@@ -196,6 +231,7 @@ func postWorkflowRunHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbM
 		return err
 	}
 	defer tx.Rollback()
+	defer workflow.DiscardRunEvents(tx)
 
 	opts := &postWorkflowRunHandlerOption{}
 	if err := UnmarshalBody(r, opts); err != nil {
@@ -219,17 +255,22 @@ func postWorkflowRunHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbM
 
 	//Run from hook
 	if opts.Hook != nil {
+		//The user sending the hook event starts the node of the hook
+		if n := workflow.HookNode(wf, opts.Hook.WorkflowNodeHookID); n != nil {
+			if err := workflow.CheckDeployCapability(n, c.User); err != nil {
+				return sdk.WrapError(err, "postWorkflowRunHandler> Unable to run workflow")
+			}
+		}
 		wr, err = workflow.RunFromHook(tx, wf, opts.Hook)
 		if err != nil {
 			return sdk.WrapError(err, "postWorkflowRunHandler> Unable to run workflow")
 		}
 	} else {
-		//Default manual run
+		//Default manual run. The run is always started by the current user, whose permissions are checked on the nodes
 		if opts.Manual == nil {
-			opts.Manual = &sdk.WorkflowNodeRunManual{
-				User: *c.User,
-			}
+			opts.Manual = &sdk.WorkflowNodeRunManual{}
 		}
+		opts.Manual.User = *c.User
 
 		//If payload is not set, keep the default payload
 		if opts.Manual.Payload == interface{}(nil) {
@@ -278,6 +319,135 @@ func postWorkflowRunHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbM
 	if err := tx.Commit(); err != nil {
 		return sdk.WrapError(err, "postWorkflowRunHandler> Unable to run workflow")
 	}
+	workflow.PublishRunEvents(tx)
+
+	wr.Translate(r.Header.Get("Accept-Language"))
+	return WriteJSON(w, r, wr, http.StatusOK)
+}
+
+func stopWorkflowRunHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	vars := mux.Vars(r)
+	key := vars["permProjectKey"]
+	name := vars["workflowName"]
+	number, err := requestVarInt(r, "number")
+	if err != nil {
+		return err
+	}
+
+	tx, errb := db.Begin()
+	if errb != nil {
+		return sdk.WrapError(errb, "stopWorkflowRunHandler> Unable to start transaction")
+	}
+	defer tx.Rollback()
+	defer workflow.DiscardRunEvents(tx)
+
+	run, errl := workflow.LoadRun(tx, key, name, number)
+	if errl != nil {
+		return sdk.WrapError(errl, "stopWorkflowRunHandler> Unable to load workflow run %d", number)
+	}
+
+	wr, errs := workflow.StopWorkflowRun(tx, run, c.User)
+	if errs != nil {
+		return sdk.WrapError(errs, "stopWorkflowRunHandler> Unable to stop workflow run %d", number)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return sdk.WrapError(err, "stopWorkflowRunHandler> Unable to commit transaction")
+	}
+	workflow.PublishRunEvents(tx)
+
+	wr.Translate(r.Header.Get("Accept-Language"))
+	return WriteJSON(w, r, wr, http.StatusOK)
+}
+
+func stopWorkflowNodeRunHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	return workflowNodeRunAction(w, r, db, c, "stopWorkflowNodeRunHandler", workflow.StopWorkflowNodeRun)
+}
+
+func postWorkflowNodeRunRestartHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	return workflowNodeRunAction(w, r, db, c, "postWorkflowNodeRunRestartHandler", workflow.RestartFailedJobs)
+}
+
+func getWorkflowRunApprovalsHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	vars := mux.Vars(r)
+	key := vars["permProjectKey"]
+	name := vars["workflowName"]
+	number, err := requestVarInt(r, "number")
+	if err != nil {
+		return err
+	}
+
+	run, errl := workflow.LoadRun(db, key, name, number)
+	if errl != nil {
+		return sdk.WrapError(errl, "getWorkflowRunApprovalsHandler> Unable to load workflow run %d", number)
+	}
+
+	as, erra := workflow.LoadRunApprovals(db, run.ID)
+	if erra != nil {
+		return sdk.WrapError(erra, "getWorkflowRunApprovalsHandler> Unable to load approvals of workflow run %d", number)
+	}
+	return WriteJSON(w, r, as, http.StatusOK)
+}
+
+func postWorkflowRunApprovalApproveHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	return workflowRunApprovalVote(w, r, db, c, "postWorkflowRunApprovalApproveHandler", true)
+}
+
+func postWorkflowRunApprovalRejectHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	return workflowRunApprovalVote(w, r, db, c, "postWorkflowRunApprovalRejectHandler", false)
+}
+
+// workflowRunApprovalVote records the vote of the current user on the approval {id} of the workflow run {number}.
+// The body, with an optional comment, may be empty
+func workflowRunApprovalVote(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx, handlerName string, approved bool) error {
+	var req sdk.WorkflowRunApprovalRequest
+	if r.ContentLength != 0 {
+		if err := UnmarshalBody(r, &req); err != nil {
+			return sdk.WrapError(err, "%s> Unable to read body", handlerName)
+		}
+	}
+
+	return workflowNodeRunAction(w, r, db, c, handlerName, func(db gorp.SqlExecutor, wr *sdk.WorkflowRun, id int64, u *sdk.User) (*sdk.WorkflowRun, error) {
+		return workflow.VoteWorkflowRunApproval(db, wr, id, u, approved, req.Comment)
+	})
+}
+
+// workflowNodeRunAction runs an action on the node run {id} of the workflow run {number} in a transaction
+func workflowNodeRunAction(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx, handlerName string,
+	action func(gorp.SqlExecutor, *sdk.WorkflowRun, int64, *sdk.User) (*sdk.WorkflowRun, error)) error {
+	vars := mux.Vars(r)
+	key := vars["permProjectKey"]
+	name := vars["workflowName"]
+	number, err := requestVarInt(r, "number")
+	if err != nil {
+		return err
+	}
+	id, err := requestVarInt(r, "id")
+	if err != nil {
+		return err
+	}
+
+	tx, errb := db.Begin()
+	if errb != nil {
+		return sdk.WrapError(errb, "%s> Unable to start transaction", handlerName)
+	}
+	defer tx.Rollback()
+	defer workflow.DiscardRunEvents(tx)
+
+	run, errl := workflow.LoadRun(tx, key, name, number)
+	if errl != nil {
+		return sdk.WrapError(errl, "%s> Unable to load workflow run %d", handlerName, number)
+	}
+
+	wr, erra := action(tx, run, id, c.User)
+	if erra != nil {
+		return sdk.WrapError(erra, "%s> Unable to process %d", handlerName, id)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return sdk.WrapError(err, "%s> Unable to commit transaction", handlerName)
+	}
+	workflow.PublishRunEvents(tx)
 
 	wr.Translate(r.Header.Get("Accept-Language"))
 	return WriteJSON(w, r, wr, http.StatusOK)
@@ -334,6 +504,34 @@ func getDownloadArtifactHandler(w http.ResponseWriter, r *http.Request, db *gorp
 	return nil
 }
 
+func getDownloadArtifactTemporaryURLHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	vars := mux.Vars(r)
+	key := vars["permProjectKey"]
+	name := vars["workflowName"]
+
+	id, errI := requestVarInt(r, "artifactId")
+	if errI != nil {
+		return sdk.WrapError(sdk.ErrInvalidID, "getDownloadArtifactTemporaryURLHandler> Invalid artifact ID")
+	}
+
+	work, errW := workflow.Load(db, key, name, c.User)
+	if errW != nil {
+		return sdk.WrapError(errW, "getDownloadArtifactTemporaryURLHandler> Cannot load workflow")
+	}
+
+	art, errA := workflow.LoadArtifactByIDs(db, work.ID, id)
+	if errA != nil {
+		return sdk.WrapError(errA, "getDownloadArtifactTemporaryURLHandler> Cannot load artifacts")
+	}
+
+	u, expires, errU := objectstore.FetchTemporaryURL(art, art.Name)
+	if errU != nil {
+		return sdk.WrapError(errU, "getDownloadArtifactTemporaryURLHandler> Cannot get temporary url for artifact %s", art.Name)
+	}
+
+	return WriteJSON(w, r, sdk.ArtifactTemporaryURL{URL: u, Expires: expires}, http.StatusOK)
+}
+
 func getWorkflowRunArtifactsHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
 	vars := mux.Vars(r)
 	key := vars["permProjectKey"]
@@ -0,0 +1,232 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-gorp/gorp"
+	"github.com/gorilla/mux"
+
+	"github.com/ovh/cds/engine/api/businesscontext"
+	"github.com/ovh/cds/engine/api/cache"
+	"github.com/ovh/cds/engine/api/permission"
+	"github.com/ovh/cds/engine/api/sessionstore"
+	"github.com/ovh/cds/engine/api/workflow"
+	"github.com/ovh/cds/sdk"
+	"github.com/ovh/cds/sdk/log"
+)
+
+const (
+	// workflowRunEventsQueueSize is the number of events buffered for a client. Events are dropped for slow clients
+	workflowRunEventsQueueSize = 100
+	// workflowRunEventsPermissionTTL is the delay after which the permissions of a client are reloaded
+	workflowRunEventsPermissionTTL = time.Minute
+)
+
+// WorkflowRunEventsSubscriber is a client of the workflow run events stream
+type WorkflowRunEventsSubscriber struct {
+	UUID         string
+	User         *sdk.User
+	ProjectKey   string
+	WorkflowName string
+	Queue        chan string
+
+	allowed bool
+	checked time.Time
+}
+
+// match returns true if the event concerns the project, and the workflow if any, followed by the subscriber
+func (s *WorkflowRunEventsSubscriber) match(e *sdk.WorkflowRunEvent) bool {
+	if e.ProjectKey != s.ProjectKey {
+		return false
+	}
+	return s.WorkflowName == "" || e.WorkflowName == s.WorkflowName
+}
+
+// hasPermission checks that the subscriber can still read the project. Permissions are reloaded every workflowRunEventsPermissionTTL
+func (s *WorkflowRunEventsSubscriber) hasPermission(db gorp.SqlExecutor) bool {
+	if s.User.Admin {
+		return true
+	}
+	if time.Since(s.checked) < workflowRunEventsPermissionTTL {
+		return s.allowed
+	}
+	if err := loadUserPermissions(db, s.User); err != nil {
+		log.Warning("WorkflowRunEventsBroker> Cannot load user %s permissions: %s", s.User.Username, err)
+		return false
+	}
+	s.allowed = permission.ProjectPermission(s.ProjectKey, s.User) >= permission.PermissionRead
+	s.checked = time.Now()
+	return s.allowed
+}
+
+// WorkflowRunEventsBroker dispatches the workflow run events published in the cache to the connected clients
+type WorkflowRunEventsBroker struct {
+	mutex    sync.Mutex
+	clients  map[string]*WorkflowRunEventsSubscriber
+	messages chan string
+}
+
+var workflowRunEventsBroker *WorkflowRunEventsBroker
+
+// InitWorkflowRunEventsBroker starts the cache subscription and the dispatch of workflow run events
+func InitWorkflowRunEventsBroker(c context.Context, DBFunc func() *gorp.DbMap) {
+	workflowRunEventsBroker = &WorkflowRunEventsBroker{
+		clients:  make(map[string]*WorkflowRunEventsSubscriber),
+		messages: make(chan string),
+	}
+
+	go workflowRunEventsBroker.subscribe(c)
+	go workflowRunEventsBroker.Start(c, DBFunc)
+}
+
+// subscribe pushes the messages of the cache channel in the broker
+func (b *WorkflowRunEventsBroker) subscribe(c context.Context) {
+	pubSub := cache.Subscribe(workflow.RunEventsChannel)
+	for {
+		select {
+		case <-c.Done():
+			if c.Err() != nil {
+				log.Error("WorkflowRunEventsBroker.subscribe> Exiting: %v", c.Err())
+			}
+			return
+		default:
+			msg, err := cache.GetMessageFromSubscription(c, pubSub)
+			if err != nil {
+				log.Warning("WorkflowRunEventsBroker.subscribe> Cannot get message %s: %s", msg, err)
+				time.Sleep(5 * time.Second)
+				continue
+			}
+			if msg == "" {
+				continue
+			}
+			b.messages <- msg
+		}
+	}
+}
+
+// Start dispatches the messages to the clients
+func (b *WorkflowRunEventsBroker) Start(c context.Context, DBFunc func() *gorp.DbMap) {
+	for {
+		select {
+		case <-c.Done():
+			b.mutex.Lock()
+			for id, s := range b.clients {
+				delete(b.clients, id)
+				close(s.Queue)
+			}
+			b.mutex.Unlock()
+			return
+		case msg := <-b.messages:
+			var e sdk.WorkflowRunEvent
+			if err := json.Unmarshal([]byte(msg), &e); err != nil {
+				log.Warning("WorkflowRunEventsBroker.Start> Cannot unmarshal message: %s", msg)
+				continue
+			}
+			b.dispatch(DBFunc(), &e, msg)
+		}
+	}
+}
+
+// dispatch sends the message to the clients following the event and allowed to read its project. The permissions
+// are reloaded without holding the lock, the registrations must not wait for the database
+func (b *WorkflowRunEventsBroker) dispatch(db gorp.SqlExecutor, e *sdk.WorkflowRunEvent, msg string) {
+	b.mutex.Lock()
+	subscribers := make([]*WorkflowRunEventsSubscriber, 0, len(b.clients))
+	for _, s := range b.clients {
+		if s.match(e) {
+			subscribers = append(subscribers, s)
+		}
+	}
+	b.mutex.Unlock()
+
+	allowed := subscribers[:0]
+	for _, s := range subscribers {
+		if s.hasPermission(db) {
+			allowed = append(allowed, s)
+		}
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for _, s := range allowed {
+		//The queue of a client unregistered meanwhile is closed
+		if _, ok := b.clients[s.UUID]; !ok {
+			continue
+		}
+		select {
+		case s.Queue <- msg:
+		default:
+			log.Warning("WorkflowRunEventsBroker.dispatch> Queue of client %s is full, event dropped", s.UUID)
+		}
+	}
+}
+
+func (b *WorkflowRunEventsBroker) register(s *WorkflowRunEventsSubscriber) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.clients[s.UUID] = s
+}
+
+func (b *WorkflowRunEventsBroker) unregister(s *WorkflowRunEventsSubscriber) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if _, ok := b.clients[s.UUID]; ok {
+		delete(b.clients, s.UUID)
+		close(s.Queue)
+	}
+}
+
+// ServeHTTP streams the run events of a project, or of a workflow if workflowName is set
+func (b *WorkflowRunEventsBroker) ServeHTTP(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming unsupported!", http.StatusInternalServerError)
+		return nil
+	}
+
+	vars := mux.Vars(r)
+	uuid, errS := sessionstore.NewSessionKey()
+	if errS != nil {
+		return sdk.WrapError(errS, "WorkflowRunEventsBroker.ServeHTTP> Cannot generate UUID")
+	}
+
+	// The user is copied because its permissions are reloaded by the broker
+	u := *c.User
+	s := &WorkflowRunEventsSubscriber{
+		UUID:         string(uuid),
+		User:         &u,
+		ProjectKey:   vars["permProjectKey"],
+		WorkflowName: vars["workflowName"],
+		Queue:        make(chan string, workflowRunEventsQueueSize),
+		// Permission has been checked by the router
+		allowed: true,
+		checked: time.Now(),
+	}
+	b.register(s)
+	defer b.unregister(s)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	f.Flush()
+
+	closeNotify := w.(http.CloseNotifier).CloseNotify()
+	for {
+		select {
+		case <-closeNotify:
+			return nil
+		case msg, open := <-s.Queue:
+			if !open {
+				return nil
+			}
+			fmt.Fprintf(w, "data: %s\n\n", msg)
+			f.Flush()
+		}
+	}
+}
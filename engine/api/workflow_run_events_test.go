@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ovh/cds/sdk"
+)
+
+func TestWorkflowRunEventsSubscriberMatch(t *testing.T) {
+	project := &WorkflowRunEventsSubscriber{ProjectKey: "KEY"}
+	assert.True(t, project.match(&sdk.WorkflowRunEvent{ProjectKey: "KEY", WorkflowName: "w1"}))
+	assert.True(t, project.match(&sdk.WorkflowRunEvent{ProjectKey: "KEY", WorkflowName: "w2"}))
+	assert.False(t, project.match(&sdk.WorkflowRunEvent{ProjectKey: "OTHER", WorkflowName: "w1"}))
+
+	wf := &WorkflowRunEventsSubscriber{ProjectKey: "KEY", WorkflowName: "w1"}
+	assert.True(t, wf.match(&sdk.WorkflowRunEvent{ProjectKey: "KEY", WorkflowName: "w1"}))
+	assert.False(t, wf.match(&sdk.WorkflowRunEvent{ProjectKey: "KEY", WorkflowName: "w2"}))
+}
+
+func TestWorkflowRunEventsBrokerDispatch(t *testing.T) {
+	b := &WorkflowRunEventsBroker{clients: map[string]*WorkflowRunEventsSubscriber{}}
+
+	allowed := &WorkflowRunEventsSubscriber{UUID: "1", User: &sdk.User{}, ProjectKey: "KEY", Queue: make(chan string, 1), allowed: true, checked: time.Now()}
+	denied := &WorkflowRunEventsSubscriber{UUID: "2", User: &sdk.User{}, ProjectKey: "KEY", Queue: make(chan string, 1), allowed: false, checked: time.Now()}
+	admin := &WorkflowRunEventsSubscriber{UUID: "3", User: &sdk.User{Admin: true}, ProjectKey: "KEY", WorkflowName: "other", Queue: make(chan string, 1)}
+	b.register(allowed)
+	b.register(denied)
+	b.register(admin)
+
+	b.dispatch(nil, &sdk.WorkflowRunEvent{ProjectKey: "KEY", WorkflowName: "w1"}, "msg")
+	assert.Len(t, allowed.Queue, 1)
+	assert.Len(t, denied.Queue, 0)
+	assert.Len(t, admin.Queue, 0)
+
+	// The queue is full, the event is dropped
+	b.dispatch(nil, &sdk.WorkflowRunEvent{ProjectKey: "KEY", WorkflowName: "w1"}, "msg")
+	assert.Len(t, allowed.Queue, 1)
+
+	b.unregister(allowed)
+	_, open := <-allowed.Queue
+	assert.True(t, open)
+	_, open = <-allowed.Queue
+	assert.False(t, open)
+}
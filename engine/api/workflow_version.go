@@ -0,0 +1,132 @@
+package main
+
+import (
+	"net/http"
+
+	"github.com/go-gorp/gorp"
+	"github.com/gorilla/mux"
+
+	"github.com/ovh/cds/engine/api/businesscontext"
+	"github.com/ovh/cds/engine/api/project"
+	"github.com/ovh/cds/engine/api/workflow"
+	"github.com/ovh/cds/sdk"
+)
+
+// getWorkflowVersionsHandler returns the versions of a workflow, without their snapshots
+func getWorkflowVersionsHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	vars := mux.Vars(r)
+	key := vars["permProjectKey"]
+	name := vars["workflowName"]
+
+	wf, err := workflow.Load(db, key, name, c.User)
+	if err != nil {
+		return sdk.WrapError(err, "getWorkflowVersionsHandler> Cannot load workflow %s", name)
+	}
+
+	vs, err := workflow.LoadVersions(db, wf.ID)
+	if err != nil {
+		return sdk.WrapError(err, "getWorkflowVersionsHandler> Cannot load versions of workflow %s", name)
+	}
+	return WriteJSON(w, r, vs, http.StatusOK)
+}
+
+// getWorkflowVersionHandler returns a version of a workflow with its snapshot
+func getWorkflowVersionHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	vars := mux.Vars(r)
+	key := vars["permProjectKey"]
+	name := vars["workflowName"]
+
+	version, err := requestVarInt(r, "version")
+	if err != nil {
+		return err
+	}
+
+	wf, err := workflow.Load(db, key, name, c.User)
+	if err != nil {
+		return sdk.WrapError(err, "getWorkflowVersionHandler> Cannot load workflow %s", name)
+	}
+
+	v, err := workflow.LoadVersion(db, wf.ID, version)
+	if err != nil {
+		return sdk.WrapError(err, "getWorkflowVersionHandler> Cannot load version %d of workflow %s", version, name)
+	}
+	return WriteJSON(w, r, v, http.StatusOK)
+}
+
+// getWorkflowVersionDiffHandler returns node by node the difference between two versions of a workflow
+func getWorkflowVersionDiffHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	vars := mux.Vars(r)
+	key := vars["permProjectKey"]
+	name := vars["workflowName"]
+
+	from, err := requestVarInt(r, "version")
+	if err != nil {
+		return err
+	}
+	to, err := requestVarInt(r, "toVersion")
+	if err != nil {
+		return err
+	}
+
+	wf, err := workflow.Load(db, key, name, c.User)
+	if err != nil {
+		return sdk.WrapError(err, "getWorkflowVersionDiffHandler> Cannot load workflow %s", name)
+	}
+
+	vFrom, err := workflow.LoadVersion(db, wf.ID, from)
+	if err != nil {
+		return sdk.WrapError(err, "getWorkflowVersionDiffHandler> Cannot load version %d of workflow %s", from, name)
+	}
+	vTo, err := workflow.LoadVersion(db, wf.ID, to)
+	if err != nil {
+		return sdk.WrapError(err, "getWorkflowVersionDiffHandler> Cannot load version %d of workflow %s", to, name)
+	}
+
+	return WriteJSON(w, r, sdk.DiffWorkflows(vFrom.Workflow, vTo.Workflow), http.StatusOK)
+}
+
+// postWorkflowVersionRestoreHandler saves a previous version of a workflow as its new version
+func postWorkflowVersionRestoreHandler(w http.ResponseWriter, r *http.Request, db *gorp.DbMap, c *businesscontext.Ctx) error {
+	vars := mux.Vars(r)
+	key := vars["permProjectKey"]
+	name := vars["workflowName"]
+
+	version, err := requestVarInt(r, "version")
+	if err != nil {
+		return err
+	}
+
+	p, errP := project.Load(db, key, c.User)
+	if errP != nil {
+		return sdk.WrapError(errP, "postWorkflowVersionRestoreHandler> Cannot load project %s", key)
+	}
+
+	oldW, errW := workflow.Load(db, key, name, c.User)
+	if errW != nil {
+		return sdk.WrapError(errW, "postWorkflowVersionRestoreHandler> Cannot load workflow %s", name)
+	}
+
+	tx, errT := db.Begin()
+	if errT != nil {
+		return sdk.WrapError(errT, "postWorkflowVersionRestoreHandler> Cannot start transaction")
+	}
+	defer tx.Rollback()
+
+	if _, err := workflow.Restore(tx, oldW, version, c.User); err != nil {
+		return sdk.WrapError(err, "postWorkflowVersionRestoreHandler> Cannot restore workflow %s", name)
+	}
+
+	if err := project.UpdateLastModified(tx, c.User, p); err != nil {
+		return sdk.WrapError(err, "postWorkflowVersionRestoreHandler> Cannot update project last modified date")
+	}
+
+	if err := tx.Commit(); err != nil {
+		return sdk.WrapError(err, "postWorkflowVersionRestoreHandler> Cannot commit transaction")
+	}
+
+	wf, errl := workflow.LoadByID(db, oldW.ID, c.User)
+	if errl != nil {
+		return sdk.WrapError(errl, "postWorkflowVersionRestoreHandler> Cannot load workflow")
+	}
+	return WriteJSON(w, r, wf, http.StatusOK)
+}
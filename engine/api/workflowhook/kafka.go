@@ -0,0 +1,174 @@
+package workflowhook
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/fsamin/go-dump"
+	"github.com/go-gorp/gorp"
+	"gopkg.in/bsm/sarama-cluster.v2"
+
+	"github.com/ovh/cds/engine/api/workflow"
+	"github.com/ovh/cds/sdk"
+	"github.com/ovh/cds/sdk/log"
+)
+
+// kafkaRetryDelay is the delay before firing again a hook whose message failed
+var kafkaRetryDelay = 10 * time.Second
+
+type kafkaListener struct {
+	config   string
+	consumer *cluster.Consumer
+	done     chan struct{}
+}
+
+// stop stops the retries of the current message and closes the consumer
+func (l *kafkaListener) stop() {
+	close(l.done)
+	l.consumer.Close()
+}
+
+// KafkaListeners starts a kafka consumer for each Kafka hook, and stops the consumers of the removed hooks
+func KafkaListeners(c context.Context, DBFunc func() *gorp.DbMap) {
+	listeners := map[string]*kafkaListener{}
+	defer func() {
+		for _, l := range listeners {
+			l.stop()
+		}
+	}()
+
+	tick := time.NewTicker(30 * time.Second).C
+	for {
+		select {
+		case <-c.Done():
+			if c.Err() != nil {
+				log.Error("Exiting workflowhook.KafkaListeners: %v", c.Err())
+			}
+			return
+		case <-tick:
+			db := DBFunc()
+			if db == nil {
+				continue
+			}
+			hooks, err := workflow.LoadHooksByModelName(db, sdk.KafkaHookModelName, true)
+			if err != nil {
+				log.Warning("workflowhook.KafkaListeners> Unable to load kafka hooks: %s", err)
+				continue
+			}
+			reconcileKafkaListeners(DBFunc, listeners, hooks)
+		}
+	}
+}
+
+func reconcileKafkaListeners(DBFunc func() *gorp.DbMap, listeners map[string]*kafkaListener, hooks []sdk.WorkflowNodeHook) {
+	current := map[string]bool{}
+	for i := range hooks {
+		h := hooks[i]
+		current[h.UUID] = true
+
+		btes, _ := json.Marshal(h.Config)
+		config := string(btes)
+		if l, ok := listeners[h.UUID]; ok {
+			if l.config == config {
+				continue
+			}
+			log.Info("workflowhook.KafkaListeners> Configuration of hook %s changed, restarting consumer", h.UUID)
+			l.stop()
+			delete(listeners, h.UUID)
+		}
+
+		consumer, err := newKafkaConsumer(h)
+		if err != nil {
+			log.Warning("workflowhook.KafkaListeners> Unable to start consumer of hook %s: %s", h.UUID, err)
+			continue
+		}
+		l := &kafkaListener{config: config, consumer: consumer, done: make(chan struct{})}
+		listeners[h.UUID] = l
+		go consumeKafka(DBFunc, h, l)
+	}
+
+	for uuid, l := range listeners {
+		if !current[uuid] {
+			log.Info("workflowhook.KafkaListeners> Hook %s removed, stopping consumer", uuid)
+			l.stop()
+			delete(listeners, uuid)
+		}
+	}
+}
+
+func newKafkaConsumer(h sdk.WorkflowNodeHook) (*cluster.Consumer, error) {
+	var config = sarama.NewConfig()
+	config.Version = sarama.V0_10_0_1
+	if user := h.Config[sdk.HookConfigKafkaUser]; user != "" {
+		config.Net.TLS.Enable = true
+		config.Net.SASL.Enable = true
+		config.Net.SASL.User = user
+		config.Net.SASL.Password = h.Config[sdk.HookConfigKafkaPassword]
+		config.ClientID = user
+	}
+
+	clusterConfig := cluster.NewConfig()
+	clusterConfig.Config = *config
+	clusterConfig.Consumer.Return.Errors = true
+
+	group := h.Config[sdk.HookConfigKafkaGroup]
+	if group == "" {
+		group = "cds-" + h.UUID
+	}
+
+	brokers := strings.Split(h.Config[sdk.HookConfigKafkaBrokers], ",")
+	for i := range brokers {
+		brokers[i] = strings.TrimSpace(brokers[i])
+	}
+
+	return cluster.NewConsumer(brokers, group, []string{h.Config[sdk.HookConfigKafkaTopic]}, clusterConfig)
+}
+
+// consumeKafka fires the hook for each message. A message is marked as consumed only once the hook is fired:
+// a failed message is retried until it succeeds or the listener is stopped, so that the next messages don't commit past it
+func consumeKafka(DBFunc func() *gorp.DbMap, h sdk.WorkflowNodeHook, l *kafkaListener) {
+	go func() {
+		for err := range l.consumer.Errors() {
+			log.Warning("workflowhook.consumeKafka> Error on hook %s: %s", h.UUID, err)
+		}
+	}()
+
+	for msg := range l.consumer.Messages() {
+		for !fireKafkaMessage(DBFunc, &h, msg) {
+			select {
+			case <-l.done:
+				return
+			case <-time.After(kafkaRetryDelay):
+			}
+		}
+		l.consumer.MarkOffset(msg, "delivered")
+	}
+}
+
+// fireKafkaMessage runs the workflow of the hook with the message as payload, and returns false if it has to be retried
+func fireKafkaMessage(DBFunc func() *gorp.DbMap, h *sdk.WorkflowNodeHook, msg *sarama.ConsumerMessage) bool {
+	db := DBFunc()
+	if db == nil {
+		log.Warning("workflowhook.consumeKafka> Unable to run hook %s: database unavailable", h.UUID)
+		return false
+	}
+	if _, err := Fire(db, h, kafkaPayload(msg.Value)); err != nil {
+		log.Warning("workflowhook.consumeKafka> Unable to run hook %s: %s", h.UUID, err)
+		return false
+	}
+	return true
+}
+
+// kafkaPayload flattens a JSON object message. Any other message is set as payload key "message"
+func kafkaPayload(value []byte) map[string]string {
+	var obj map[string]interface{}
+	if err := json.Unmarshal(value, &obj); err == nil {
+		if m, err := dump.ToMap(obj, dump.WithDefaultLowerCaseFormatter()); err == nil {
+			return m
+		}
+	}
+	return map[string]string{"message": string(value)}
+}
@@ -0,0 +1,45 @@
+package workflowhook
+
+import (
+	"github.com/go-gorp/gorp"
+
+	"github.com/ovh/cds/engine/api/workflow"
+	"github.com/ovh/cds/sdk"
+	"github.com/ovh/cds/sdk/log"
+)
+
+// ProcessRepositoryEvent runs the workflows of the RepositoryWebHook hooks on nodes whose application is linked to the repository
+func ProcessRepositoryEvent(db *gorp.DbMap, repoFullname, branch, hash, author, message string) error {
+	hooks, err := workflow.LoadHooksByModelName(db, sdk.RepositoryWebHookModelName, false)
+	if err != nil {
+		return sdk.WrapError(err, "ProcessRepositoryEvent> Unable to load repository hooks")
+	}
+
+	payload := map[string]string{
+		"git.repository": repoFullname,
+		"git.branch":     branch,
+		"git.hash":       hash,
+		"git.author":     author,
+		"git.message":    message,
+	}
+
+	for i := range hooks {
+		h := &hooks[i]
+		w, errw := workflow.LoadByNodeID(db, h.WorkflowNodeID, nil)
+		if errw != nil {
+			log.Warning("ProcessRepositoryEvent> Unable to load workflow of hook %s: %s", h.UUID, errw)
+			continue
+		}
+		node := w.GetNode(h.WorkflowNodeID)
+		if node == nil || node.Context == nil || node.Context.Application == nil {
+			continue
+		}
+		if node.Context.Application.RepositoryFullname != repoFullname {
+			continue
+		}
+		if _, err := Fire(db, h, payload); err != nil {
+			log.Warning("ProcessRepositoryEvent> Unable to run hook %s: %s", h.UUID, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,185 @@
+package workflowhook
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-gorp/gorp"
+	"github.com/gorhill/cronexpr"
+
+	"github.com/ovh/cds/engine/api/workflow"
+	"github.com/ovh/cds/sdk"
+	"github.com/ovh/cds/sdk/log"
+)
+
+// maxCatchUpExecutions is the maximum number of missed executions run at once by a Scheduler hook with the "all" catch-up policy
+const maxCatchUpExecutions = 100
+
+// Scheduler runs the workflows of the Scheduler hooks when their cron expression is due
+func Scheduler(c context.Context, DBFunc func() *gorp.DbMap) {
+	tick := time.NewTicker(10 * time.Second).C
+	for {
+		select {
+		case <-c.Done():
+			if c.Err() != nil {
+				log.Error("Exiting workflowhook.Scheduler: %v", c.Err())
+			}
+			return
+		case <-tick:
+			db := DBFunc()
+			if db == nil {
+				continue
+			}
+			if err := schedule(db, time.Now()); err != nil {
+				log.Warning("workflowhook.Scheduler> %s", err)
+			}
+		}
+	}
+}
+
+func schedule(db *gorp.DbMap, now time.Time) error {
+	hooks, err := workflow.LoadHooksByModelName(db, sdk.SchedulerModelName, false)
+	if err != nil {
+		return sdk.WrapError(err, "schedule> Unable to load scheduler hooks")
+	}
+
+	for i := range hooks {
+		h := &hooks[i]
+		executions, errd := dueExecutions(h, now)
+		if errd != nil {
+			log.Warning("schedule> Invalid scheduler hook %s: %s", h.UUID, errd)
+			continue
+		}
+		if len(executions) == 0 {
+			continue
+		}
+
+		// Several API instances run the scheduler, only the first to claim the last due execution runs the workflow
+		claimed, errc := workflow.ClaimHookExecution(db, h.ID, executions[len(executions)-1])
+		if errc != nil {
+			log.Warning("schedule> %s", errc)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		payload, errp := h.SchedulerPayload()
+		if errp != nil {
+			log.Warning("schedule> Invalid payload on hook %s: %s", h.UUID, errp)
+			continue
+		}
+
+		for _, t := range catchUp(h.Config[sdk.HookConfigCatchUp], executions, now) {
+			payload["cds.hook.scheduled"] = t.Format(time.RFC3339)
+			if _, err := Fire(db, h, payload); err != nil {
+				log.Warning("schedule> Unable to run hook %s: %s", h.UUID, err)
+			}
+		}
+	}
+	return nil
+}
+
+// dueExecutions returns the executions of a Scheduler hook planned since its last execution, up to now.
+// A hook which has never been executed only has the execution of the current minute. With the "all" catch-up
+// policy, the oldest missed executions are returned, at most maxCatchUpExecutions: the next ones are returned
+// once they have been run. Otherwise only the last execution is returned
+func dueExecutions(h *sdk.WorkflowNodeHook, now time.Time) ([]time.Time, error) {
+	expr, loc, err := schedulerCron(h)
+	if err != nil {
+		return nil, err
+	}
+
+	since := now.Truncate(time.Minute).Add(-time.Second)
+	if h.LastExecution != nil {
+		since = *h.LastExecution
+	}
+	since = since.In(loc)
+
+	executions := []time.Time{}
+	if h.Config[sdk.HookConfigCatchUp] != sdk.SchedulerCatchUpAll {
+		if t := lastExecution(expr, since, now.In(loc)); !t.IsZero() {
+			executions = append(executions, t)
+		}
+		return executions, nil
+	}
+
+	for t := expr.Next(since); !t.IsZero() && !t.After(now) && len(executions) < maxCatchUpExecutions; t = expr.Next(t) {
+		executions = append(executions, t)
+	}
+	return executions, nil
+}
+
+// lastExecution returns the last execution planned after since and up to now, or the zero time. The cron
+// expression may have a seconds field: the execution is searched without iterating over all the executions
+func lastExecution(expr *cronexpr.Expression, since, now time.Time) time.Time {
+	before := func(from time.Time) bool {
+		t := expr.Next(from)
+		return !t.IsZero() && !t.After(now)
+	}
+	if !before(since) {
+		return time.Time{}
+	}
+
+	// There is an execution after lo, and none after hi. The window before now is doubled until it has one
+	lo, hi := since, now
+	for d := time.Second; now.Add(-d).After(since); d *= 2 {
+		from := now.Add(-d)
+		if before(from) {
+			lo = from
+			break
+		}
+		hi = from
+	}
+	// Then the window is narrowed down to the second of the last execution
+	for hi.Sub(lo) > time.Second {
+		mid := lo.Add(hi.Sub(lo) / 2)
+		if before(mid) {
+			lo = mid
+		} else {
+			hi = mid
+		}
+	}
+	return expr.Next(lo)
+}
+
+// catchUp selects the executions to run according to the catch-up policy
+func catchUp(policy string, executions []time.Time, now time.Time) []time.Time {
+	if len(executions) == 0 {
+		return nil
+	}
+	last := executions[len(executions)-1]
+	switch policy {
+	case sdk.SchedulerCatchUpAll:
+		return executions
+	case sdk.SchedulerCatchUpOnce:
+		return []time.Time{last}
+	default:
+		// Only run the execution of the current minute
+		if last.After(now.Add(-time.Minute)) {
+			return []time.Time{last}
+		}
+		return nil
+	}
+}
+
+// NextExecutions computes the next executions of a Scheduler hook after a date
+func NextExecutions(h *sdk.WorkflowNodeHook, from time.Time, count int) ([]time.Time, error) {
+	expr, loc, err := schedulerCron(h)
+	if err != nil {
+		return nil, err
+	}
+	return expr.NextN(from.In(loc), uint(count)), nil
+}
+
+func schedulerCron(h *sdk.WorkflowNodeHook) (*cronexpr.Expression, *time.Location, error) {
+	expr, err := cronexpr.Parse(h.Config[sdk.HookConfigCronExpr])
+	if err != nil {
+		return nil, nil, err
+	}
+	loc, err := time.LoadLocation(h.Config[sdk.HookConfigTimezone])
+	if err != nil {
+		return nil, nil, err
+	}
+	return expr, loc, nil
+}
@@ -0,0 +1,79 @@
+package workflowhook
+
+import (
+	"github.com/fsamin/go-dump"
+	"github.com/go-gorp/gorp"
+
+	"github.com/ovh/cds/engine/api/workflow"
+	"github.com/ovh/cds/sdk"
+	"github.com/ovh/cds/sdk/log"
+)
+
+// Fire runs the workflow of a hook from its node. The payload overrides the default payload of the node.
+// It returns a nil run if the conditions of the hook are not satisfied
+func Fire(db *gorp.DbMap, h *sdk.WorkflowNodeHook, payload map[string]string) (*sdk.WorkflowRun, error) {
+	w, errw := workflow.LoadByNodeID(db, h.WorkflowNodeID, nil)
+	if errw != nil {
+		return nil, sdk.WrapError(errw, "Fire> Unable to load workflow of hook %s", h.UUID)
+	}
+
+	node := w.GetNode(h.WorkflowNodeID)
+	if node == nil {
+		return nil, sdk.WrapError(sdk.ErrWorkflowNodeNotFound, "Fire> Unable to find node %d of hook %s", h.WorkflowNodeID, h.UUID)
+	}
+
+	e := &sdk.WorkflowNodeRunHookEvent{
+		Payload:            hookPayload(node, payload),
+		WorkflowNodeHookID: h.ID,
+	}
+	if node.Context != nil {
+		e.PipelineParameters = node.Context.DefaultPipelineParameters
+	}
+
+	conditionsOK, errc := sdk.WorkflowCheckConditions(h.Conditions, sdk.ParametersFromMap(e.Payload.(map[string]string)))
+	if errc != nil {
+		return nil, sdk.WrapError(errc, "Fire> Unable to check conditions of hook %s", h.UUID)
+	}
+	if !conditionsOK {
+		log.Debug("Fire> Conditions of hook %s on %s/%s are not satisfied", h.UUID, w.ProjectKey, w.Name)
+		return nil, nil
+	}
+
+	tx, errb := db.Begin()
+	if errb != nil {
+		return nil, sdk.WrapError(errb, "Fire> Unable to start transaction")
+	}
+	defer tx.Rollback()
+	defer workflow.DiscardRunEvents(tx)
+
+	wr, errr := workflow.RunFromHook(tx, w, e)
+	if errr != nil {
+		return nil, sdk.WrapError(errr, "Fire> Unable to run workflow %s/%s from hook %s", w.ProjectKey, w.Name, h.UUID)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, sdk.WrapError(err, "Fire> Unable to commit transaction")
+	}
+	workflow.PublishRunEvents(tx)
+
+	log.Info("Fire> Workflow %s/%s #%d triggered by %s hook %s", w.ProjectKey, w.Name, wr.Number, h.WorkflowHookModel.Name, h.UUID)
+	return wr, nil
+}
+
+// hookPayload merges the default payload of the node with the payload of the hook
+func hookPayload(node *sdk.WorkflowNode, payload map[string]string) map[string]string {
+	res := map[string]string{}
+	if node.Context != nil && node.Context.DefaultPayload != nil {
+		m, err := dump.ToMap(node.Context.DefaultPayload, dump.WithDefaultLowerCaseFormatter())
+		if err != nil {
+			log.Warning("hookPayload> Unable to compute default payload of node %d: %s", node.ID, err)
+		}
+		for k, v := range m {
+			res[k] = v
+		}
+	}
+	for k, v := range payload {
+		res[k] = v
+	}
+	return res
+}
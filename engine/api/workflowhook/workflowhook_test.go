@@ -0,0 +1,134 @@
+package workflowhook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Shopify/sarama"
+	"github.com/go-gorp/gorp"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ovh/cds/sdk"
+)
+
+func schedulerHook(cron, timezone string, last *time.Time) *sdk.WorkflowNodeHook {
+	return &sdk.WorkflowNodeHook{
+		Config: sdk.WorkflowNodeHookConfig{
+			sdk.HookConfigCronExpr: cron,
+			sdk.HookConfigTimezone: timezone,
+		},
+		LastExecution: last,
+	}
+}
+
+func TestDueExecutions(t *testing.T) {
+	now := time.Date(2017, 9, 4, 10, 30, 20, 0, time.UTC)
+
+	// Never executed: only the current minute
+	execs, err := dueExecutions(schedulerHook("30 * * * *", "UTC", nil), now)
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Time{time.Date(2017, 9, 4, 10, 30, 0, 0, time.UTC)}, toUTC(execs))
+
+	execs, err = dueExecutions(schedulerHook("0 * * * *", "UTC", nil), now)
+	assert.NoError(t, err)
+	assert.Len(t, execs, 0)
+
+	// Missed executions since the last one
+	last := time.Date(2017, 9, 4, 7, 30, 0, 0, time.UTC)
+	h := schedulerHook("30 * * * *", "UTC", &last)
+	h.Config[sdk.HookConfigCatchUp] = sdk.SchedulerCatchUpAll
+	execs, err = dueExecutions(h, now)
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Time{
+		time.Date(2017, 9, 4, 8, 30, 0, 0, time.UTC),
+		time.Date(2017, 9, 4, 9, 30, 0, 0, time.UTC),
+		time.Date(2017, 9, 4, 10, 30, 0, 0, time.UTC),
+	}, toUTC(execs))
+
+	// Only the last one without the "all" policy
+	execs, err = dueExecutions(schedulerHook("30 * * * *", "UTC", &last), now)
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Time{time.Date(2017, 9, 4, 10, 30, 0, 0, time.UTC)}, toUTC(execs))
+
+	// Every second since years: the last one is found without iterating, the "all" policy runs the oldest ones
+	last = time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
+	execs, err = dueExecutions(schedulerHook("* * * * * * *", "UTC", &last), now)
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Time{time.Date(2017, 9, 4, 10, 30, 20, 0, time.UTC)}, toUTC(execs))
+
+	execs, err = dueExecutions(schedulerHook("15 * 6 * * * *", "UTC", &last), now)
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Time{time.Date(2017, 9, 4, 6, 59, 15, 0, time.UTC)}, toUTC(execs))
+
+	h = schedulerHook("* * * * * * *", "UTC", &last)
+	h.Config[sdk.HookConfigCatchUp] = sdk.SchedulerCatchUpAll
+	execs, err = dueExecutions(h, now)
+	assert.NoError(t, err)
+	assert.Len(t, execs, maxCatchUpExecutions)
+	assert.Equal(t, time.Date(2010, 1, 1, 0, 0, 1, 0, time.UTC), execs[0].UTC())
+
+	// Nothing planned since the last one
+	last = time.Date(2017, 9, 4, 10, 30, 0, 0, time.UTC)
+	execs, err = dueExecutions(schedulerHook("30 * * * *", "UTC", &last), now)
+	assert.NoError(t, err)
+	assert.Len(t, execs, 0)
+
+	// Timezone: 12:30 in Paris is 10:30 UTC in september
+	execs, err = dueExecutions(schedulerHook("30 12 * * *", "Europe/Paris", nil), now)
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Time{time.Date(2017, 9, 4, 10, 30, 0, 0, time.UTC)}, toUTC(execs))
+
+	_, err = dueExecutions(schedulerHook("not a cron", "UTC", nil), now)
+	assert.Error(t, err)
+	_, err = dueExecutions(schedulerHook("* * * * *", "Not/AZone", nil), now)
+	assert.Error(t, err)
+}
+
+func TestCatchUp(t *testing.T) {
+	now := time.Date(2017, 9, 4, 10, 30, 20, 0, time.UTC)
+	missed := []time.Time{
+		time.Date(2017, 9, 4, 8, 30, 0, 0, time.UTC),
+		time.Date(2017, 9, 4, 9, 30, 0, 0, time.UTC),
+	}
+	current := append(missed, time.Date(2017, 9, 4, 10, 30, 0, 0, time.UTC))
+
+	assert.Len(t, catchUp(sdk.SchedulerCatchUpSkip, missed, now), 0)
+	assert.Equal(t, current[2:], catchUp(sdk.SchedulerCatchUpSkip, current, now))
+	assert.Equal(t, missed[1:], catchUp(sdk.SchedulerCatchUpOnce, missed, now))
+	assert.Equal(t, missed, catchUp(sdk.SchedulerCatchUpAll, missed, now))
+	assert.Len(t, catchUp(sdk.SchedulerCatchUpAll, nil, now), 0)
+}
+
+func TestNextExecutions(t *testing.T) {
+	from := time.Date(2017, 9, 4, 10, 30, 20, 0, time.UTC)
+	execs, err := NextExecutions(schedulerHook("0 9 * * 1-5", "Europe/Paris", nil), from, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, []time.Time{
+		time.Date(2017, 9, 5, 7, 0, 0, 0, time.UTC),
+		time.Date(2017, 9, 6, 7, 0, 0, 0, time.UTC),
+		time.Date(2017, 9, 7, 7, 0, 0, 0, time.UTC),
+	}, toUTC(execs))
+}
+
+func toUTC(ts []time.Time) []time.Time {
+	res := make([]time.Time, len(ts))
+	for i := range ts {
+		res[i] = ts[i].UTC()
+	}
+	return res
+}
+
+func TestKafkaPayload(t *testing.T) {
+	p := kafkaPayload([]byte(`{"version": "1.0.2", "env": "prod"}`))
+	assert.Equal(t, "1.0.2", p["version"])
+	assert.Equal(t, "prod", p["env"])
+
+	p = kafkaPayload([]byte("hello"))
+	assert.Equal(t, map[string]string{"message": "hello"}, p)
+}
+
+func TestFireKafkaMessageWithoutDatabase(t *testing.T) {
+	h := &sdk.WorkflowNodeHook{UUID: "my-hook"}
+	msg := &sarama.ConsumerMessage{Value: []byte("hello")}
+	assert.False(t, fireKafkaMessage(func() *gorp.DbMap { return nil }, h, msg))
+}
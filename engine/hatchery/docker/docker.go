@@ -167,7 +167,7 @@ func (hd *HatcheryDocker) WorkersStartedByModel(model *sdk.Model) int {
 }
 
 // SpawnWorker starts a new worker in a docker container locally
-func (hd *HatcheryDocker) SpawnWorker(wm *sdk.Model, job *sdk.PipelineBuildJob, registerOnly bool, logInfo string) (string, error) {
+func (hd *HatcheryDocker) SpawnWorker(wm *sdk.Model, job *sdk.PipelineBuildJob, isWorkflowJob bool, registerOnly bool, logInfo string) (string, error) {
 	if wm.Type != sdk.Docker {
 		return "", fmt.Errorf("cannot handle %s worker model", wm.Type)
 	}
@@ -216,7 +216,7 @@ func (hd *HatcheryDocker) SpawnWorker(wm *sdk.Model, job *sdk.PipelineBuildJob,
 	}
 
 	if job != nil {
-		args = append(args, "-e", fmt.Sprintf("CDS_BOOKED_JOB_ID=%d", job.ID))
+		args = append(args, "-e", fmt.Sprintf("%s=%d", hatchery.BookedJobEnv(isWorkflowJob), job.ID))
 	}
 
 	if hd.addhost != "" {
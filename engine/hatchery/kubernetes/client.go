@@ -0,0 +1,231 @@
+package kubernetes
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	serviceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	clientTimeout     = 30 * time.Second
+)
+
+// PodClient is the subset of the kubernetes API used by the hatchery
+type PodClient interface {
+	CreatePod(pod *Pod) (*Pod, error)
+	DeletePod(name string) error
+	ListPods(labels map[string]string) ([]Pod, error)
+}
+
+// Pod is a kubernetes pod, only the fields used by the hatchery are declared
+type Pod struct {
+	Metadata ObjectMeta `json:"metadata"`
+	Spec     PodSpec    `json:"spec"`
+	Status   PodStatus  `json:"status,omitempty"`
+}
+
+// ObjectMeta is the metadata of a kubernetes object
+type ObjectMeta struct {
+	Name              string            `json:"name"`
+	Namespace         string            `json:"namespace,omitempty"`
+	Labels            map[string]string `json:"labels,omitempty"`
+	CreationTimestamp time.Time         `json:"creationTimestamp,omitempty"`
+}
+
+// PodSpec describes the containers of a pod
+type PodSpec struct {
+	Containers    []Container `json:"containers"`
+	HostAliases   []HostAlias `json:"hostAliases,omitempty"`
+	RestartPolicy string      `json:"restartPolicy,omitempty"`
+}
+
+// HostAlias adds hostnames in /etc/hosts of the pod
+type HostAlias struct {
+	IP        string   `json:"ip"`
+	Hostnames []string `json:"hostnames"`
+}
+
+// Container is a container of a pod
+type Container struct {
+	Name            string               `json:"name"`
+	Image           string               `json:"image"`
+	Command         []string             `json:"command,omitempty"`
+	Env             []EnvVar             `json:"env,omitempty"`
+	Resources       ResourceRequirements `json:"resources,omitempty"`
+	ImagePullPolicy string               `json:"imagePullPolicy,omitempty"`
+}
+
+// EnvVar is an environment variable of a container
+type EnvVar struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// ResourceRequirements are the resources requests and limits of a container
+type ResourceRequirements struct {
+	Limits   map[string]string `json:"limits,omitempty"`
+	Requests map[string]string `json:"requests,omitempty"`
+}
+
+// PodStatus is the status of a pod
+type PodStatus struct {
+	Phase string `json:"phase,omitempty"`
+}
+
+// Pod phases
+const (
+	PodPending   = "Pending"
+	PodRunning   = "Running"
+	PodSucceeded = "Succeeded"
+	PodFailed    = "Failed"
+)
+
+type podList struct {
+	Items []Pod `json:"items"`
+}
+
+// restClient calls the kubernetes API server
+type restClient struct {
+	host      string
+	namespace string
+	token     string
+	client    *http.Client
+}
+
+// NewClient returns a PodClient on a namespace of the API server. If host is empty, the in-cluster service account is used
+func NewClient(host, namespace, token, caFile string, insecure bool) (PodClient, error) {
+	if host == "" {
+		h, p := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+		if h == "" || p == "" {
+			return nil, fmt.Errorf("NewClient> kubernetes host not provided and hatchery is not running in a cluster")
+		}
+		host = "https://" + h + ":" + p
+		if token == "" {
+			btes, err := ioutil.ReadFile(serviceAccountDir + "/token")
+			if err != nil {
+				return nil, fmt.Errorf("NewClient> unable to read service account token: %s", err)
+			}
+			token = string(btes)
+		}
+		if caFile == "" {
+			caFile = serviceAccountDir + "/ca.crt"
+		}
+	}
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: insecure}
+	if caFile != "" {
+		ca, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("NewClient> unable to read CA file %s: %s", caFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("NewClient> invalid CA file %s", caFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return &restClient{
+		host:      strings.TrimSuffix(host, "/"),
+		namespace: namespace,
+		token:     strings.TrimSpace(token),
+		client: &http.Client{
+			Timeout:   clientTimeout,
+			Transport: &http.Transport{TLSClientConfig: tlsConfig},
+		},
+	}, nil
+}
+
+func (c *restClient) do(method, path string, in, out interface{}) error {
+	var body *bytes.Reader
+	if in != nil {
+		btes, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+		body = bytes.NewReader(btes)
+	} else {
+		body = bytes.NewReader(nil)
+	}
+
+	req, err := http.NewRequest(method, c.host+path, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	btes, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("kubernetes %s %s: %s %s", method, path, resp.Status, string(btes))
+	}
+	if out != nil {
+		return json.Unmarshal(btes, out)
+	}
+	return nil
+}
+
+func (c *restClient) podsPath() string {
+	return "/api/v1/namespaces/" + url.PathEscape(c.namespace) + "/pods"
+}
+
+// CreatePod creates a pod in the namespace
+func (c *restClient) CreatePod(pod *Pod) (*Pod, error) {
+	created := &Pod{}
+	if err := c.do("POST", c.podsPath(), pod, created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+// DeletePod deletes a pod of the namespace
+func (c *restClient) DeletePod(name string) error {
+	return c.do("DELETE", c.podsPath()+"/"+url.PathEscape(name), nil, nil)
+}
+
+// ListPods lists the pods of the namespace matching all the labels
+func (c *restClient) ListPods(labels map[string]string) ([]Pod, error) {
+	path := c.podsPath()
+	if selector := labelSelector(labels); selector != "" {
+		path += "?labelSelector=" + url.QueryEscape(selector)
+	}
+	list := &podList{}
+	if err := c.do("GET", path, nil, list); err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+func labelSelector(labels map[string]string) string {
+	selectors := make([]string, 0, len(labels))
+	for k, v := range labels {
+		selectors = append(selectors, k+"="+v)
+	}
+	sort.Strings(selectors)
+	return strings.Join(selectors, ",")
+}
@@ -0,0 +1,84 @@
+package kubernetes
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/ovh/cds/sdk"
+	"github.com/ovh/cds/sdk/hatchery"
+)
+
+func init() {
+	hatcheryKubernetes = &HatcheryKubernetes{}
+
+	Cmd.Flags().StringVar(&hatcheryKubernetes.kubernetesHost, "kubernetes-host", "", "Kubernetes API server, ie: https://kubernetes.domain:6443. If empty, the service account of the pod running the hatchery is used")
+	viper.BindPFlag("kubernetes-host", Cmd.Flags().Lookup("kubernetes-host"))
+
+	Cmd.Flags().StringVar(&hatcheryKubernetes.kubernetesNamespace, "kubernetes-namespace", "default", "Kubernetes namespace in which workers are spawned")
+	viper.BindPFlag("kubernetes-namespace", Cmd.Flags().Lookup("kubernetes-namespace"))
+
+	Cmd.Flags().StringVar(&hatcheryKubernetes.kubernetesToken, "kubernetes-token", "", "Kubernetes bearer token")
+	viper.BindPFlag("kubernetes-token", Cmd.Flags().Lookup("kubernetes-token"))
+
+	Cmd.Flags().StringVar(&hatcheryKubernetes.kubernetesCAFile, "kubernetes-ca-file", "", "Kubernetes API server certificate authority file")
+	viper.BindPFlag("kubernetes-ca-file", Cmd.Flags().Lookup("kubernetes-ca-file"))
+
+	Cmd.Flags().BoolVar(&hatcheryKubernetes.kubernetesInsecure, "kubernetes-insecure", false, `(SSL) This option explicitly allows hatchery to perform "insecure" SSL connections on Kubernetes API server.`)
+	viper.BindPFlag("kubernetes-insecure", Cmd.Flags().Lookup("kubernetes-insecure"))
+
+	Cmd.Flags().IntVar(&hatcheryKubernetes.defaultMemory, "worker-memory", 1024, "Worker default memory")
+	viper.BindPFlag("worker-memory", Cmd.Flags().Lookup("worker-memory"))
+
+	Cmd.Flags().IntVar(&hatcheryKubernetes.workerTTL, "worker-ttl", 10, "Worker TTL (minutes)")
+	viper.BindPFlag("worker-ttl", Cmd.Flags().Lookup("worker-ttl"))
+
+	Cmd.Flags().IntVar(&hatcheryKubernetes.workerSpawnTimeout, "worker-spawn-timeout", 120, "Worker Timeout Spawning (seconds)")
+	viper.BindPFlag("worker-spawn-timeout", Cmd.Flags().Lookup("worker-spawn-timeout"))
+
+	Cmd.Flags().Int("spawn-threshold-critical", 10, "log critical if spawn take more than this value (in seconds)")
+	viper.BindPFlag("spawn-threshold-critical", Cmd.Flags().Lookup("spawn-threshold-critical"))
+
+	Cmd.Flags().Int("spawn-threshold-warning", 4, "log warning if spawn take more than this value (in seconds)")
+	viper.BindPFlag("spawn-threshold-warning", Cmd.Flags().Lookup("spawn-threshold-warning"))
+}
+
+// Cmd configures comamnd for HatcheryKubernetes
+var Cmd = &cobra.Command{
+	Use:   "kubernetes",
+	Short: "Hatchery kubernetes commands: hatchery kubernetes --help",
+	Long: `Hatchery kubernetes commands: hatchery kubernetes <command>
+Start worker model instances as pods on a kubernetes cluster
+
+$ cds generate token --group shared.infra --expiration persistent
+2706bda13748877c57029598b915d46236988c7c57ea0d3808524a1e1a3adef4
+
+$ hatchery kubernetes --api=https://<api.domain> --token=<token> --kubernetes-namespace=cds
+
+	`,
+	Run: func(cmd *cobra.Command, args []string) {
+		hatchery.Create(hatcheryKubernetes,
+			viper.GetString("api"),
+			viper.GetString("token"),
+			viper.GetInt("max-worker"),
+			viper.GetInt("provision"),
+			viper.GetInt("request-api-timeout"),
+			viper.GetInt("max-failures-heartbeat"),
+			viper.GetBool("insecure"),
+			viper.GetInt("provision-seconds"),
+			viper.GetInt("register-seconds"),
+			viper.GetInt("spawn-threshold-warning"),
+			viper.GetInt("spawn-threshold-critical"),
+			viper.GetInt("grace-time-queued"),
+		)
+	},
+	PreRun: func(cmd *cobra.Command, args []string) {
+		hatcheryKubernetes.token = viper.GetString("token")
+		hatcheryKubernetes.kubernetesNamespace = viper.GetString("kubernetes-namespace")
+
+		client, err := NewClient(viper.GetString("kubernetes-host"), hatcheryKubernetes.kubernetesNamespace, viper.GetString("kubernetes-token"), viper.GetString("kubernetes-ca-file"), viper.GetBool("kubernetes-insecure"))
+		if err != nil {
+			sdk.Exit("Unable to create kubernetes client: %s\n", err)
+		}
+		hatcheryKubernetes.client = client
+	},
+}
@@ -0,0 +1,67 @@
+package kubernetes
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// FakeClient is an in-memory PodClient, used to test the hatchery without a kubernetes cluster
+type FakeClient struct {
+	mutex sync.Mutex
+	Pods  map[string]Pod
+}
+
+// NewFakeClient returns a FakeClient containing the pods
+func NewFakeClient(pods ...Pod) *FakeClient {
+	f := &FakeClient{Pods: map[string]Pod{}}
+	for _, p := range pods {
+		f.Pods[p.Metadata.Name] = p
+	}
+	return f
+}
+
+// CreatePod stores the pod as pending
+func (f *FakeClient) CreatePod(pod *Pod) (*Pod, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if _, ok := f.Pods[pod.Metadata.Name]; ok {
+		return nil, fmt.Errorf("pod %s already exists", pod.Metadata.Name)
+	}
+	p := *pod
+	p.Metadata.CreationTimestamp = time.Now()
+	p.Status.Phase = PodPending
+	f.Pods[p.Metadata.Name] = p
+	return &p, nil
+}
+
+// DeletePod removes the pod
+func (f *FakeClient) DeletePod(name string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if _, ok := f.Pods[name]; !ok {
+		return fmt.Errorf("pod %s not found", name)
+	}
+	delete(f.Pods, name)
+	return nil
+}
+
+// ListPods returns the pods matching all the labels
+func (f *FakeClient) ListPods(labels map[string]string) ([]Pod, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	pods := []Pod{}
+	for _, p := range f.Pods {
+		match := true
+		for k, v := range labels {
+			if p.Metadata.Labels[k] != v {
+				match = false
+				break
+			}
+		}
+		if match {
+			pods = append(pods, p)
+		}
+	}
+	return pods, nil
+}
@@ -0,0 +1,374 @@
+package kubernetes
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/docker/docker/pkg/namesgenerator"
+	"github.com/spf13/viper"
+
+	"github.com/ovh/cds/sdk"
+	"github.com/ovh/cds/sdk/hatchery"
+	"github.com/ovh/cds/sdk/log"
+)
+
+const (
+	labelHatchery   = "cds-hatchery"
+	labelWorkerName = "cds-worker"
+	labelModel      = "cds-worker-model"
+	labelJobID      = "cds-job-id"
+
+	workerContainerName  = "worker"
+	defaultServiceMemory = 1024
+)
+
+var hatcheryKubernetes *HatcheryKubernetes
+
+// HatcheryKubernetes implements HatcheryMode interface for kubernetes mode
+type HatcheryKubernetes struct {
+	hatch *sdk.Hatchery
+	token string
+
+	client PodClient
+
+	kubernetesHost      string
+	kubernetesNamespace string
+	kubernetesToken     string
+	kubernetesCAFile    string
+	kubernetesInsecure  bool
+
+	defaultMemory      int
+	workerTTL          int
+	workerSpawnTimeout int
+}
+
+// ID must returns hatchery id
+func (h *HatcheryKubernetes) ID() int64 {
+	if h.hatch == nil {
+		return 0
+	}
+	return h.hatch.ID
+}
+
+// Hatchery returns hatchery instance
+func (h *HatcheryKubernetes) Hatchery() *sdk.Hatchery {
+	return h.hatch
+}
+
+// ModelType returns type of hatchery
+func (*HatcheryKubernetes) ModelType() string {
+	return sdk.Docker
+}
+
+// KillWorker deletes the pod of the worker
+func (h *HatcheryKubernetes) KillWorker(worker sdk.Worker) error {
+	log.Info("KillWorker> Killing %s", worker.Name)
+	return h.client.DeletePod(worker.Name)
+}
+
+// CanSpawn return wether or not hatchery can spawn model
+func (h *HatcheryKubernetes) CanSpawn(model *sdk.Model, job *sdk.PipelineBuildJob) bool {
+	pods, err := h.listPods(nil)
+	if err != nil {
+		log.Info("CanSpawn> Error on listPods: %s", err)
+		return false
+	}
+	if len(pods) >= viper.GetInt("max-worker") {
+		log.Info("CanSpawn> max number of pods reached, aborting. Current: %d. Max: %d", len(pods), viper.GetInt("max-worker"))
+		return false
+	}
+	return true
+}
+
+// SpawnWorker creates a pod running the worker, and a sidecar container for each service requirement
+func (h *HatcheryKubernetes) SpawnWorker(model *sdk.Model, job *sdk.PipelineBuildJob, isWorkflowJob bool, registerOnly bool, logInfo string) (string, error) {
+	if job != nil {
+		log.Info("SpawnWorker> spawning worker %s (%s) for job %d - %s", model.Name, model.Image, job.ID, logInfo)
+	} else {
+		log.Info("SpawnWorker> spawning worker %s (%s) - %s", model.Name, model.Image, logInfo)
+	}
+
+	pod, err := h.workerPod(model, job, isWorkflowJob, registerOnly)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := h.client.CreatePod(pod); err != nil {
+		return "", fmt.Errorf("SpawnWorker> unable to create pod %s: %s", pod.Metadata.Name, err)
+	}
+	return pod.Metadata.Name, nil
+}
+
+// workerPod returns the pod definition of a worker
+func (h *HatcheryKubernetes) workerPod(model *sdk.Model, job *sdk.PipelineBuildJob, isWorkflowJob bool, registerOnly bool) (*Pod, error) {
+	cmd := "rm -f worker && curl ${CDS_API}/download/worker/$(uname -m) -o worker && chmod +x worker && exec ./worker"
+	if registerOnly {
+		cmd += " register"
+	}
+
+	workerName := dnsName(strings.ToLower(model.Name) + "-" + strings.Replace(namesgenerator.GetRandomName(0), "_", "-", -1))
+	if registerOnly {
+		workerName = dnsName("register-" + workerName)
+	}
+
+	env := map[string]string{
+		"CDS_API":           sdk.Host,
+		"CDS_TOKEN":         h.token,
+		"CDS_NAME":          workerName,
+		"CDS_MODEL":         fmt.Sprintf("%d", model.ID),
+		"CDS_HATCHERY":      fmt.Sprintf("%d", h.hatch.ID),
+		"CDS_HATCHERY_NAME": h.hatch.Name,
+		"CDS_SINGLE_USE":    "1",
+		"CDS_TTL":           fmt.Sprintf("%d", h.workerTTL),
+	}
+	for _, k := range []string{"graylog_host", "graylog_port", "graylog_extra_key", "graylog_extra_value"} {
+		if viper.GetString(k) != "" {
+			env["CDS_"+strings.ToUpper(k)] = viper.GetString(k)
+		}
+	}
+
+	labels := map[string]string{
+		labelHatchery:   labelValue(h.hatch.Name),
+		labelWorkerName: workerName,
+		labelModel:      labelValue(model.Name),
+	}
+
+	memory := h.defaultMemory
+	containers := []Container{}
+	aliases := []string{}
+	if job != nil {
+		env[hatchery.BookedJobEnv(isWorkflowJob)] = fmt.Sprintf("%d", job.ID)
+		labels[labelJobID] = strconv.FormatInt(job.ID, 10)
+
+		for _, r := range job.Job.Action.Requirements {
+			switch r.Type {
+			case sdk.MemoryRequirement:
+				m, err := strconv.Atoi(r.Value)
+				if err != nil {
+					log.Warning("SpawnWorker> for job %d, unable to parse memory requirement %s: %s", job.ID, r.Value, err)
+					return nil, err
+				}
+				memory = m
+			case sdk.ServiceRequirement:
+				c, err := serviceContainer(r)
+				if err != nil {
+					return nil, fmt.Errorf("SpawnWorker> for job %d, %s", job.ID, err)
+				}
+				containers = append(containers, c)
+				aliases = append(aliases, r.Name)
+			}
+		}
+	}
+
+	worker := Container{
+		Name:      workerContainerName,
+		Image:     model.Image,
+		Command:   []string{"sh", "-c", cmd},
+		Env:       envVars(env),
+		Resources: memoryResources(memory),
+	}
+	if strings.HasSuffix(model.Image, ":latest") {
+		worker.ImagePullPolicy = "Always"
+	}
+
+	pod := &Pod{
+		Metadata: ObjectMeta{
+			Name:      workerName,
+			Namespace: h.kubernetesNamespace,
+			Labels:    labels,
+		},
+		Spec: PodSpec{
+			Containers:    append([]Container{worker}, containers...),
+			RestartPolicy: "Never",
+		},
+	}
+	// Containers of a pod share the same network: services are reachable with their requirement name on localhost
+	if len(aliases) > 0 {
+		pod.Spec.HostAliases = []HostAlias{{IP: "127.0.0.1", Hostnames: aliases}}
+	}
+	return pod, nil
+}
+
+// serviceContainer returns the sidecar container of a service requirement
+// name= <alias> => the name of the host put in /etc/hosts of the worker
+// value= "postgres:latest env_1=blabla env_2=blabla" => we can add env variables in requirement name
+func serviceContainer(r sdk.Requirement) (Container, error) {
+	tuple := strings.Fields(r.Value)
+	if len(tuple) == 0 {
+		return Container{}, fmt.Errorf("service requirement %s has no image", r.Name)
+	}
+
+	memory := defaultServiceMemory
+	env := []EnvVar{}
+	for _, e := range tuple[1:] {
+		kv := strings.SplitN(e, "=", 2)
+		if len(kv) != 2 {
+			log.Warning("SpawnWorker> Unable to parse service option %s", e)
+			continue
+		}
+		//option for power user : set the service memory with CDS_SERVICE_MEMORY=1024
+		if kv[0] == "CDS_SERVICE_MEMORY" {
+			m, err := strconv.Atoi(kv[1])
+			if err != nil {
+				log.Warning("SpawnWorker> Unable to parse service option %s: %s", e, err)
+				continue
+			}
+			memory = m
+			continue
+		}
+		env = append(env, EnvVar{Name: kv[0], Value: kv[1]})
+	}
+
+	return Container{
+		Name:      dnsName("service-" + r.Name),
+		Image:     tuple[0],
+		Env:       env,
+		Resources: memoryResources(memory),
+	}, nil
+}
+
+// memoryResources returns the resources of a container needing memory MB
+func memoryResources(memory int) ResourceRequirements {
+	if memory <= 0 {
+		return ResourceRequirements{}
+	}
+	m := fmt.Sprintf("%dMi", memory)
+	return ResourceRequirements{
+		Limits:   map[string]string{"memory": m},
+		Requests: map[string]string{"memory": m},
+	}
+}
+
+func envVars(env map[string]string) []EnvVar {
+	vars := make([]EnvVar, 0, len(env))
+	for k, v := range env {
+		vars = append(vars, EnvVar{Name: k, Value: v})
+	}
+	return vars
+}
+
+var invalidDNSChars = regexp.MustCompile("[^a-z0-9-]+")
+
+// dnsName returns a valid DNS-1123 label, used as pod and container name
+func dnsName(s string) string {
+	s = invalidDNSChars.ReplaceAllString(strings.ToLower(s), "-")
+	if len(s) > 63 {
+		s = s[:63]
+	}
+	return strings.Trim(s, "-")
+}
+
+var invalidLabelChars = regexp.MustCompile("[^A-Za-z0-9_.-]+")
+
+// labelValue returns a valid label value
+func labelValue(s string) string {
+	s = invalidLabelChars.ReplaceAllString(s, "-")
+	if len(s) > 63 {
+		s = s[:63]
+	}
+	return strings.Trim(s, "-_.")
+}
+
+// listPods lists the pods started by the hatchery, with additional labels
+func (h *HatcheryKubernetes) listPods(labels map[string]string) ([]Pod, error) {
+	selector := map[string]string{labelHatchery: labelValue(h.hatch.Name)}
+	for k, v := range labels {
+		selector[k] = v
+	}
+	return h.client.ListPods(selector)
+}
+
+// WorkersStarted returns the number of pods started but
+// not necessarily register on CDS yet
+func (h *HatcheryKubernetes) WorkersStarted() int {
+	pods, err := h.listPods(nil)
+	if err != nil {
+		log.Warning("WorkersStarted> error on list pods: %s", err)
+		return 0
+	}
+	return len(pods)
+}
+
+// WorkersStartedByModel returns the number of pods of given model started but
+// not necessarily register on CDS yet
+func (h *HatcheryKubernetes) WorkersStartedByModel(model *sdk.Model) int {
+	pods, err := h.listPods(map[string]string{labelModel: labelValue(model.Name)})
+	if err != nil {
+		log.Warning("WorkersStartedByModel> error on list pods: %s", err)
+		return 0
+	}
+	return len(pods)
+}
+
+// Init registers the hatchery and starts the garbage collector of pods
+func (h *HatcheryKubernetes) Init() error {
+	h.hatch = &sdk.Hatchery{
+		Name: hatchery.GenerateName("kubernetes", viper.GetString("name")),
+		UID:  viper.GetString("token"),
+	}
+
+	if err := hatchery.Register(h.hatch, viper.GetString("token")); err != nil {
+		log.Warning("Cannot register hatchery: %s", err)
+	}
+
+	go func() {
+		for {
+			time.Sleep(10 * time.Second)
+			workers, err := sdk.GetWorkers()
+			if err != nil {
+				log.Warning("Cannot get workers: %s", err)
+				continue
+			}
+			if err := h.killOrphanPods(workers); err != nil {
+				log.Warning("Cannot kill orphan pods: %s", err)
+			}
+		}
+	}()
+	return nil
+}
+
+// killOrphanPods deletes the pods which are terminated, whose worker is disabled,
+// or whose worker has not registered after the spawn timeout
+func (h *HatcheryKubernetes) killOrphanPods(workers []sdk.Worker) error {
+	pods, err := h.listPods(nil)
+	if err != nil {
+		return err
+	}
+
+	status := map[string]sdk.Status{}
+	for _, w := range workers {
+		status[w.Name] = w.Status
+	}
+
+	for _, p := range pods {
+		s, registered := status[p.Metadata.Name]
+		var reason string
+		switch {
+		case p.Status.Phase == PodSucceeded || p.Status.Phase == PodFailed:
+			reason = "terminated"
+		case registered && s == sdk.StatusDisabled:
+			reason = "disabled"
+		case !registered && time.Since(p.Metadata.CreationTimestamp) > time.Duration(h.workerSpawnTimeout)*time.Second:
+			reason = "awol"
+		default:
+			continue
+		}
+
+		log.Info("killOrphanPods> killing %s worker pod %s", reason, p.Metadata.Name)
+		if err := h.client.DeletePod(p.Metadata.Name); err != nil {
+			log.Warning("killOrphanPods> Error while deleting pod %s: %s", p.Metadata.Name, err)
+		}
+	}
+	return nil
+}
+
+// NeedRegistration return true if worker model need regsitration
+func (h *HatcheryKubernetes) NeedRegistration(wm *sdk.Model) bool {
+	if wm.NeedRegistration || wm.LastRegistration.Unix() < wm.UserLastModified.Unix() {
+		return true
+	}
+	return false
+}
@@ -0,0 +1,137 @@
+package kubernetes
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ovh/cds/sdk"
+)
+
+func newTestHatchery(pods ...Pod) (*HatcheryKubernetes, *FakeClient) {
+	f := NewFakeClient(pods...)
+	return &HatcheryKubernetes{
+		hatch:              &sdk.Hatchery{ID: 1, Name: "my-hatchery-kubernetes"},
+		client:             f,
+		defaultMemory:      1024,
+		workerSpawnTimeout: 120,
+	}, f
+}
+
+func findEnv(env []EnvVar, name string) string {
+	for _, e := range env {
+		if e.Name == name {
+			return e.Value
+		}
+	}
+	return ""
+}
+
+func TestSpawnWorker(t *testing.T) {
+	h, f := newTestHatchery()
+
+	model := &sdk.Model{ID: 42, Name: "Go_Official", Image: "golang:1.8"}
+	job := &sdk.PipelineBuildJob{ID: 666}
+	job.Job.Action.Requirements = []sdk.Requirement{
+		{Name: "mem", Type: sdk.MemoryRequirement, Value: "4096"},
+		{Name: "pg", Type: sdk.ServiceRequirement, Value: "postgres:9.6 POSTGRES_USER=cds CDS_SERVICE_MEMORY=512"},
+	}
+
+	name, err := h.SpawnWorker(model, job, false, false, "test")
+	assert.NoError(t, err)
+	assert.Contains(t, f.Pods, name)
+
+	pod := f.Pods[name]
+	assert.Equal(t, "666", pod.Metadata.Labels[labelJobID])
+	assert.Equal(t, "Go_Official", pod.Metadata.Labels[labelModel])
+	assert.Equal(t, "my-hatchery-kubernetes", pod.Metadata.Labels[labelHatchery])
+	assert.Equal(t, "Never", pod.Spec.RestartPolicy)
+
+	assert.Len(t, pod.Spec.Containers, 2)
+	worker := pod.Spec.Containers[0]
+	assert.Equal(t, "golang:1.8", worker.Image)
+	assert.Equal(t, "4096Mi", worker.Resources.Limits["memory"])
+	assert.Equal(t, "666", findEnv(worker.Env, "CDS_BOOKED_JOB_ID"))
+	assert.Equal(t, name, findEnv(worker.Env, "CDS_NAME"))
+
+	service := pod.Spec.Containers[1]
+	assert.Equal(t, "service-pg", service.Name)
+	assert.Equal(t, "postgres:9.6", service.Image)
+	assert.Equal(t, "512Mi", service.Resources.Limits["memory"])
+	assert.Equal(t, []EnvVar{{Name: "POSTGRES_USER", Value: "cds"}}, service.Env)
+	assert.Equal(t, []HostAlias{{IP: "127.0.0.1", Hostnames: []string{"pg"}}}, pod.Spec.HostAliases)
+
+	assert.Equal(t, 1, h.WorkersStarted())
+	assert.Equal(t, 1, h.WorkersStartedByModel(model))
+	assert.Equal(t, 0, h.WorkersStartedByModel(&sdk.Model{Name: "other"}))
+
+	assert.NoError(t, h.KillWorker(sdk.Worker{Name: name}))
+	assert.Empty(t, f.Pods)
+}
+
+func TestSpawnWorkerForWorkflowJob(t *testing.T) {
+	h, f := newTestHatchery()
+
+	model := &sdk.Model{ID: 42, Name: "Go_Official", Image: "golang:1.8"}
+	name, err := h.SpawnWorker(model, &sdk.PipelineBuildJob{ID: 666}, true, false, "test")
+	assert.NoError(t, err)
+
+	worker := f.Pods[name].Spec.Containers[0]
+	assert.Equal(t, "666", findEnv(worker.Env, "CDS_BOOKED_WORKFLOW_JOB_ID"))
+	assert.Equal(t, "", findEnv(worker.Env, "CDS_BOOKED_JOB_ID"))
+}
+
+func TestCanSpawn(t *testing.T) {
+	h, _ := newTestHatchery()
+	model := &sdk.Model{Name: "docker", Image: "alpine:latest"}
+	job := &sdk.PipelineBuildJob{}
+
+	viper.Set("max-worker", 1)
+	defer viper.Set("max-worker", nil)
+
+	assert.True(t, h.CanSpawn(model, job))
+	_, err := h.SpawnWorker(model, nil, false, true, "test")
+	assert.NoError(t, err)
+	assert.False(t, h.CanSpawn(model, job))
+}
+
+func TestKillOrphanPods(t *testing.T) {
+	labels := map[string]string{labelHatchery: "my-hatchery-kubernetes"}
+	pod := func(name, phase string, age time.Duration) Pod {
+		return Pod{
+			Metadata: ObjectMeta{Name: name, Labels: labels, CreationTimestamp: time.Now().Add(-age)},
+			Status:   PodStatus{Phase: phase},
+		}
+	}
+
+	h, f := newTestHatchery(
+		pod("running", PodRunning, time.Hour),
+		pod("disabled", PodRunning, time.Hour),
+		pod("succeeded", PodSucceeded, time.Minute),
+		pod("starting", PodPending, time.Minute),
+		pod("awol", PodRunning, time.Hour),
+		Pod{Metadata: ObjectMeta{Name: "other", Labels: map[string]string{labelHatchery: "other"}}, Status: PodStatus{Phase: PodFailed}},
+	)
+
+	workers := []sdk.Worker{
+		{Name: "running", Status: sdk.StatusBuilding},
+		{Name: "disabled", Status: sdk.StatusDisabled},
+	}
+	assert.NoError(t, h.killOrphanPods(workers))
+
+	names := []string{}
+	for n := range f.Pods {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	assert.Equal(t, []string{"other", "running", "starting"}, names)
+}
+
+func TestDNSName(t *testing.T) {
+	assert.Equal(t, "go-official-happy-turing", dnsName("Go_Official-happy-turing"))
+	assert.Len(t, dnsName(string(make([]byte, 100))+"a"), 1)
+	assert.Equal(t, "hatchery.kubernetes", labelValue("-hatchery.kubernetes/"))
+}
@@ -77,7 +77,7 @@ func (h *HatcheryLocal) KillWorker(worker sdk.Worker) error {
 }
 
 // SpawnWorker starts a new worker process
-func (h *HatcheryLocal) SpawnWorker(wm *sdk.Model, job *sdk.PipelineBuildJob, registerOnly bool, logInfo string) (string, error) {
+func (h *HatcheryLocal) SpawnWorker(wm *sdk.Model, job *sdk.PipelineBuildJob, isWorkflowJob bool, registerOnly bool, logInfo string) (string, error) {
 	var err error
 
 	if len(h.workers) >= viper.GetInt("max-worker") {
@@ -119,7 +119,9 @@ func (h *HatcheryLocal) SpawnWorker(wm *sdk.Model, job *sdk.PipelineBuildJob, re
 
 	args = append(args, "--single-use")
 
-	if job != nil {
+	if job != nil && isWorkflowJob {
+		args = append(args, fmt.Sprintf("--booked-workflow-job-id=%d", job.ID))
+	} else if job != nil {
 		args = append(args, fmt.Sprintf("--booked-job-id=%d", job.ID))
 	}
 
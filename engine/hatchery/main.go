@@ -8,11 +8,13 @@ import (
 	"github.com/google/gops/agent"
 
 	"github.com/ovh/cds/engine/hatchery/docker"
+	"github.com/ovh/cds/engine/hatchery/kubernetes"
 	"github.com/ovh/cds/engine/hatchery/local"
 	"github.com/ovh/cds/engine/hatchery/marathon"
 	"github.com/ovh/cds/engine/hatchery/openstack"
 	"github.com/ovh/cds/engine/hatchery/swarm"
 	"github.com/ovh/cds/sdk"
+	"github.com/ovh/cds/sdk/hatchery"
 	"github.com/ovh/cds/sdk/log"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -49,6 +51,17 @@ var rootCmd = &cobra.Command{
 			sdk.Exit("Worker token not provided. See help on flag --token\n")
 		}
 
+		weights, errw := hatchery.ParseProjectWeights(viper.GetString("priority-project-weights"))
+		if errw != nil {
+			sdk.Exit("Invalid flag priority-project-weights: %s\n", errw)
+		}
+		hatchery.Priority = hatchery.PriorityConfig{
+			ProjectWeights: weights,
+			ManualWeight:   viper.GetInt("priority-manual-weight"),
+			HookWeight:     viper.GetInt("priority-hook-weight"),
+			AgeWeight:      viper.GetInt("priority-age-weight"),
+		}
+
 		if viper.GetString("remote-debug-url") != "" {
 			log.Info("Starting gops agent on %s", viper.GetString("remote-debug-url"))
 			if err := agent.Listen(&agent.Options{Addr: viper.GetString("remote-debug-url")}); err != nil {
@@ -74,6 +87,7 @@ func addCommands() {
 	rootCmd.AddCommand(marathon.Cmd)
 	rootCmd.AddCommand(swarm.Cmd)
 	rootCmd.AddCommand(openstack.Cmd)
+	rootCmd.AddCommand(kubernetes.Cmd)
 }
 
 // Cannot rely on viper.AutomaticEnv here because of the presence of hyphen '-'
@@ -125,6 +139,18 @@ func addFlags() {
 	rootCmd.PersistentFlags().Int64("grace-time-queued", 4, "if worker is queued less than this value (seconds), hatchery does not take care of it")
 	viper.BindPFlag("grace-time-queued", rootCmd.PersistentFlags().Lookup("grace-time-queued"))
 
+	rootCmd.PersistentFlags().String("priority-project-weights", "", "Weight of projects in the queue priority. Ex: --priority-project-weights=KEY1=100,KEY2=-50")
+	viper.BindPFlag("priority-project-weights", rootCmd.PersistentFlags().Lookup("priority-project-weights"))
+
+	rootCmd.PersistentFlags().Int("priority-manual-weight", 0, "Weight of manually triggered workflow jobs in the queue priority")
+	viper.BindPFlag("priority-manual-weight", rootCmd.PersistentFlags().Lookup("priority-manual-weight"))
+
+	rootCmd.PersistentFlags().Int("priority-hook-weight", 0, "Weight of hook triggered workflow jobs in the queue priority")
+	viper.BindPFlag("priority-hook-weight", rootCmd.PersistentFlags().Lookup("priority-hook-weight"))
+
+	rootCmd.PersistentFlags().Int("priority-age-weight", 1, "Weight of each minute spent in queue in the queue priority")
+	viper.BindPFlag("priority-age-weight", rootCmd.PersistentFlags().Lookup("priority-age-weight"))
+
 	rootCmd.PersistentFlags().String("graylog-protocol", "", "Ex: --graylog-protocol=xxxx-yyyy")
 	viper.BindPFlag("graylog_protocol", rootCmd.PersistentFlags().Lookup("graylog-protocol"))
 
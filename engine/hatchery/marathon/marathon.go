@@ -105,7 +105,7 @@ func (m *HatcheryMarathon) CanSpawn(model *sdk.Model, job *sdk.PipelineBuildJob)
 
 // SpawnWorker creates an application on mesos via marathon
 // requirements services are not supported
-func (m *HatcheryMarathon) SpawnWorker(model *sdk.Model, job *sdk.PipelineBuildJob, registerOnly bool, logInfo string) (string, error) {
+func (m *HatcheryMarathon) SpawnWorker(model *sdk.Model, job *sdk.PipelineBuildJob, isWorkflowJob bool, registerOnly bool, logInfo string) (string, error) {
 	if job != nil {
 		log.Info("spawnWorker> spawning worker %s (%s) for job %d - %s", model.Name, model.Image, job.ID, logInfo)
 	} else {
@@ -156,7 +156,7 @@ func (m *HatcheryMarathon) SpawnWorker(model *sdk.Model, job *sdk.PipelineBuildJ
 	//if there is a service requirement: exit
 	if job != nil {
 		logJob = fmt.Sprintf("for job %d,", job.ID)
-		env["CDS_BOOKED_JOB_ID"] = fmt.Sprintf("%d", job.ID)
+		env[hatchery.BookedJobEnv(isWorkflowJob)] = fmt.Sprintf("%d", job.ID)
 
 		for _, r := range job.Job.Action.Requirements {
 			if r.Name == sdk.ServiceRequirement {
@@ -19,7 +19,7 @@ import (
 
 // SpawnWorker creates a new cloud instances
 // requirements are not supported
-func (h *HatcheryCloud) SpawnWorker(model *sdk.Model, job *sdk.PipelineBuildJob, registerOnly bool, logInfo string) (string, error) {
+func (h *HatcheryCloud) SpawnWorker(model *sdk.Model, job *sdk.PipelineBuildJob, isWorkflowJob bool, registerOnly bool, logInfo string) (string, error) {
 	//generate a pretty cool name
 	name := model.Name + "-" + strings.Replace(namesgenerator.GetRandomName(0), "_", "-", -1)
 	if registerOnly {
@@ -93,6 +93,7 @@ export CDS_MODEL={{.Model}}
 export CDS_HATCHERY={{.Hatchery}}
 export CDS_HATCHERY_NAME={{.HatcheryName}}
 export CDS_BOOKED_JOB_ID={{.JobID}}
+export CDS_BOOKED_WORKFLOW_JOB_ID={{.WorkflowJobID}}
 export CDS_TTL={{.TTL}}
 {{.Graylog}}
 ./worker`
@@ -102,8 +103,10 @@ export CDS_TTL={{.TTL}}
 	}
 	udataEnd += " ; sudo shutdown -h now;"
 
-	var jobID int64
-	if job != nil {
+	var jobID, workflowJobID int64
+	if job != nil && isWorkflowJob {
+		workflowJobID = job.ID
+	} else if job != nil {
 		jobID = job.ID
 	}
 
@@ -117,8 +120,8 @@ export CDS_TTL={{.TTL}}
 			if workerModelName == model.Name {
 				withExistingImage = true
 				var jobInfo string
-				if jobID != 0 {
-					jobInfo = fmt.Sprintf(" job:%d", jobID)
+				if job != nil {
+					jobInfo = fmt.Sprintf(" job:%d", job.ID)
 				}
 				log.Info("spawnWorker> existing image found for worker:%s model:%s img:%s %s %s", name, model.Name, img.ID, jobInfo, logInfo)
 				imageID = img.ID
@@ -132,25 +135,27 @@ export CDS_TTL={{.TTL}}
 		return "", errt
 	}
 	udataParam := struct {
-		API          string
-		Name         string
-		Key          string
-		Model        int64
-		Hatchery     int64
-		HatcheryName string
-		JobID        int64
-		TTL          int
-		Graylog      string
+		API           string
+		Name          string
+		Key           string
+		Model         int64
+		Hatchery      int64
+		HatcheryName  string
+		JobID         int64
+		WorkflowJobID int64
+		TTL           int
+		Graylog       string
 	}{
-		API:          viper.GetString("api"),
-		Name:         name,
-		Key:          viper.GetString("token"),
-		Model:        model.ID,
-		Hatchery:     h.hatch.ID,
-		HatcheryName: h.hatch.Name,
-		JobID:        jobID,
-		TTL:          h.workerTTL,
-		Graylog:      graylog,
+		API:           viper.GetString("api"),
+		Name:          name,
+		Key:           viper.GetString("token"),
+		Model:         model.ID,
+		Hatchery:      h.hatch.ID,
+		HatcheryName:  h.hatch.Name,
+		JobID:         jobID,
+		WorkflowJobID: workflowJobID,
+		TTL:           h.workerTTL,
+		Graylog:       graylog,
 	}
 	var buffer bytes.Buffer
 	if err := tmpl.Execute(&buffer, udataParam); err != nil {
@@ -0,0 +1,28 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS "workflow_run_tag" (
+  workflow_run_id BIGINT NOT NULL,
+  tag VARCHAR(256) NOT NULL,
+  value VARCHAR(256),
+  PRIMARY KEY (workflow_run_id, tag)
+);
+
+SELECT create_foreign_key_idx_cascade('FK_WORKFLOW_RUN_TAG_WORKFLOW_RUN', 'workflow_run_tag', 'workflow_run', 'workflow_run_id', 'id');
+
+CREATE TABLE IF NOT EXISTS "workflow_retention_policy" (
+  id BIGSERIAL PRIMARY KEY,
+  project_id BIGINT NOT NULL,
+  workflow_id BIGINT,
+  keep_last_runs INT NOT NULL DEFAULT 0,
+  keep_days INT NOT NULL DEFAULT 0,
+  keep_tags JSONB
+);
+
+SELECT create_foreign_key_idx_cascade('FK_WORKFLOW_RETENTION_POLICY_PROJECT', 'workflow_retention_policy', 'project', 'project_id', 'id');
+SELECT create_foreign_key_idx_cascade('FK_WORKFLOW_RETENTION_POLICY_WORKFLOW', 'workflow_retention_policy', 'workflow', 'workflow_id', 'id');
+CREATE UNIQUE INDEX IF NOT EXISTS IDX_WORKFLOW_RETENTION_POLICY_UNIQ ON workflow_retention_policy (project_id, COALESCE(workflow_id, 0));
+
+SELECT create_index('workflow_node_run_artifacts', 'IDX_WORKFLOW_ARTIFACT_WORKFLOW_RUN_ID', 'workflow_run_id');
+
+-- +migrate Down
+DROP TABLE workflow_retention_policy;
+DROP TABLE workflow_run_tag;
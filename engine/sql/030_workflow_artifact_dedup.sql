@@ -0,0 +1,14 @@
+-- +migrate Up
+ALTER TABLE workflow_node_run_artifacts ADD COLUMN sha512sum TEXT DEFAULT '';
+
+CREATE TABLE IF NOT EXISTS "workflow_artifact_object" (
+  sha512sum VARCHAR(128) PRIMARY KEY,
+  object_path TEXT,
+  size BIGINT,
+  ref_count INT NOT NULL DEFAULT 0,
+  created TIMESTAMP WITH TIME ZONE DEFAULT LOCALTIMESTAMP
+);
+
+-- +migrate Down
+DROP TABLE workflow_artifact_object;
+ALTER TABLE workflow_node_run_artifacts DROP COLUMN sha512sum;
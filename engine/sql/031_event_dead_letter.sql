@@ -0,0 +1,14 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS "event_dead_letter" (
+  id BIGSERIAL PRIMARY KEY,
+  broker TEXT NOT NULL,
+  event JSONB,
+  error TEXT,
+  attempts INT NOT NULL DEFAULT 0,
+  created TIMESTAMP WITH TIME ZONE DEFAULT LOCALTIMESTAMP
+);
+
+SELECT create_index('event_dead_letter', 'IDX_EVENT_DEAD_LETTER_BROKER', 'broker');
+
+-- +migrate Down
+DROP TABLE event_dead_letter;
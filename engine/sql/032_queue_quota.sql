@@ -0,0 +1,17 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS "queue_quota" (
+  id BIGSERIAL PRIMARY KEY,
+  project_id BIGINT,
+  group_id BIGINT,
+  max_running INT NOT NULL DEFAULT 0,
+  max_queued INT NOT NULL DEFAULT 0,
+  CHECK ((project_id IS NULL) <> (group_id IS NULL))
+);
+
+SELECT create_foreign_key_idx_cascade('FK_QUEUE_QUOTA_PROJECT', 'queue_quota', 'project', 'project_id', 'id');
+SELECT create_foreign_key_idx_cascade('FK_QUEUE_QUOTA_GROUP', 'queue_quota', 'group', 'group_id', 'id');
+CREATE UNIQUE INDEX IF NOT EXISTS IDX_QUEUE_QUOTA_PROJECT_UNIQ ON queue_quota (project_id) WHERE project_id IS NOT NULL;
+CREATE UNIQUE INDEX IF NOT EXISTS IDX_QUEUE_QUOTA_GROUP_UNIQ ON queue_quota (group_id) WHERE group_id IS NOT NULL;
+
+-- +migrate Down
+DROP TABLE queue_quota;
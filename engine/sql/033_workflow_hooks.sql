@@ -0,0 +1,7 @@
+-- +migrate Up
+ALTER TABLE workflow_node_hook ADD COLUMN last_execution TIMESTAMP WITH TIME ZONE;
+CREATE UNIQUE INDEX IF NOT EXISTS IDX_WORKFLOW_HOOK_MODEL_NAME_UNIQ ON workflow_hook_model (name);
+
+-- +migrate Down
+DROP INDEX IF EXISTS IDX_WORKFLOW_HOOK_MODEL_NAME_UNIQ;
+ALTER TABLE workflow_node_hook DROP COLUMN last_execution;
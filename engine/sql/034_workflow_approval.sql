@@ -0,0 +1,26 @@
+-- +migrate Up
+ALTER TABLE workflow_node_trigger ADD COLUMN approval JSONB;
+
+CREATE TABLE IF NOT EXISTS "workflow_run_approval" (
+  id BIGSERIAL PRIMARY KEY,
+  workflow_run_id BIGINT NOT NULL,
+  workflow_node_trigger_id BIGINT NOT NULL,
+  workflow_node_run_id BIGINT NOT NULL,
+  workflow_dest_node_id BIGINT NOT NULL,
+  sub_num BIGINT NOT NULL DEFAULT 0,
+  status VARCHAR(20) NOT NULL,
+  group_id BIGINT NOT NULL,
+  group_name TEXT NOT NULL,
+  required INT NOT NULL,
+  votes JSONB,
+  created TIMESTAMP WITH TIME ZONE NOT NULL,
+  expire TIMESTAMP WITH TIME ZONE
+);
+
+SELECT create_foreign_key_idx_cascade('FK_WORKFLOW_RUN_APPROVAL_WORKFLOW_RUN', 'workflow_run_approval', 'workflow_run', 'workflow_run_id', 'id');
+CREATE UNIQUE INDEX IF NOT EXISTS IDX_WORKFLOW_RUN_APPROVAL_UNIQ ON workflow_run_approval (workflow_run_id, workflow_node_trigger_id, workflow_node_run_id);
+CREATE INDEX IF NOT EXISTS IDX_WORKFLOW_RUN_APPROVAL_EXPIRE ON workflow_run_approval (expire) WHERE status = 'Waiting';
+
+-- +migrate Down
+DROP TABLE workflow_run_approval;
+ALTER TABLE workflow_node_trigger DROP COLUMN approval;
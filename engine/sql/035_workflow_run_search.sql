@@ -0,0 +1,50 @@
+-- +migrate Up
+ALTER TABLE workflow_run ADD COLUMN status VARCHAR(20) NOT NULL DEFAULT '';
+ALTER TABLE workflow_run ADD COLUMN vcs_branch TEXT NOT NULL DEFAULT '';
+ALTER TABLE workflow_run ADD COLUMN vcs_hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE workflow_run ADD COLUMN username VARCHAR(256) NOT NULL DEFAULT '';
+
+UPDATE workflow_run SET
+  vcs_branch = COALESCE((
+    SELECT p->>'value' FROM workflow_node_run, jsonb_array_elements(workflow_node_run.build_parameters) p
+    WHERE workflow_node_run.workflow_run_id = workflow_run.id AND p->>'name' = 'git.branch'
+    ORDER BY workflow_node_run.id LIMIT 1), ''),
+  vcs_hash = COALESCE((
+    SELECT p->>'value' FROM workflow_node_run, jsonb_array_elements(workflow_node_run.build_parameters) p
+    WHERE workflow_node_run.workflow_run_id = workflow_run.id AND p->>'name' = 'git.hash'
+    ORDER BY workflow_node_run.id LIMIT 1), ''),
+  username = COALESCE((
+    SELECT workflow_node_run.manual->'user'->>'username' FROM workflow_node_run
+    WHERE workflow_node_run.workflow_run_id = workflow_run.id AND workflow_node_run.manual IS NOT NULL
+    ORDER BY workflow_node_run.id LIMIT 1), ''),
+  status = COALESCE((
+    SELECT CASE
+      WHEN bool_or(last.status IN ('Waiting', 'Building')) THEN 'Building'
+      WHEN bool_or(last.status = 'Fail') THEN 'Fail'
+      WHEN bool_or(last.status = 'Stopped') THEN 'Stopped'
+      ELSE 'Success' END
+    FROM (
+      SELECT DISTINCT ON (workflow_node_id) status FROM workflow_node_run
+      WHERE workflow_node_run.workflow_run_id = workflow_run.id
+      ORDER BY workflow_node_id, sub_num DESC
+    ) last
+    HAVING count(*) > 0), 'Waiting');
+
+CREATE INDEX IF NOT EXISTS IDX_WORKFLOW_RUN_PROJECT_START ON workflow_run (project_id, start DESC);
+CREATE INDEX IF NOT EXISTS IDX_WORKFLOW_RUN_PROJECT_STATUS ON workflow_run (project_id, status);
+CREATE INDEX IF NOT EXISTS IDX_WORKFLOW_RUN_PROJECT_VCS_BRANCH ON workflow_run (project_id, vcs_branch);
+CREATE INDEX IF NOT EXISTS IDX_WORKFLOW_RUN_PROJECT_VCS_HASH ON workflow_run (project_id, vcs_hash);
+CREATE INDEX IF NOT EXISTS IDX_WORKFLOW_RUN_PROJECT_USERNAME ON workflow_run (project_id, username);
+CREATE INDEX IF NOT EXISTS IDX_WORKFLOW_RUN_TAG_TAG_VALUE ON workflow_run_tag (tag, value);
+
+-- +migrate Down
+DROP INDEX IF EXISTS IDX_WORKFLOW_RUN_TAG_TAG_VALUE;
+DROP INDEX IF EXISTS IDX_WORKFLOW_RUN_PROJECT_USERNAME;
+DROP INDEX IF EXISTS IDX_WORKFLOW_RUN_PROJECT_VCS_HASH;
+DROP INDEX IF EXISTS IDX_WORKFLOW_RUN_PROJECT_VCS_BRANCH;
+DROP INDEX IF EXISTS IDX_WORKFLOW_RUN_PROJECT_STATUS;
+DROP INDEX IF EXISTS IDX_WORKFLOW_RUN_PROJECT_START;
+ALTER TABLE workflow_run DROP COLUMN username;
+ALTER TABLE workflow_run DROP COLUMN vcs_hash;
+ALTER TABLE workflow_run DROP COLUMN vcs_branch;
+ALTER TABLE workflow_run DROP COLUMN status;
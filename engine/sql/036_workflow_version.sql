@@ -0,0 +1,21 @@
+-- +migrate Up
+ALTER TABLE workflow ADD COLUMN version BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE workflow_run ADD COLUMN workflow_version BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS "workflow_version" (
+  id BIGSERIAL PRIMARY KEY,
+  workflow_id BIGINT NOT NULL,
+  version BIGINT NOT NULL,
+  workflow JSONB NOT NULL,
+  author VARCHAR(256) NOT NULL DEFAULT '',
+  comment TEXT NOT NULL DEFAULT '',
+  created TIMESTAMP WITH TIME ZONE NOT NULL
+);
+
+SELECT create_foreign_key_idx_cascade('FK_WORKFLOW_VERSION_WORKFLOW', 'workflow_version', 'workflow', 'workflow_id', 'id');
+CREATE UNIQUE INDEX IF NOT EXISTS IDX_WORKFLOW_VERSION_UNIQ ON workflow_version (workflow_id, version);
+
+-- +migrate Down
+DROP TABLE workflow_version;
+ALTER TABLE workflow_run DROP COLUMN workflow_version;
+ALTER TABLE workflow DROP COLUMN version;
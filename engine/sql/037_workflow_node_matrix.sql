@@ -0,0 +1,7 @@
+-- +migrate Up
+ALTER TABLE workflow_node ADD COLUMN matrix JSONB;
+ALTER TABLE workflow_node_run ADD COLUMN matrix JSONB;
+
+-- +migrate Down
+ALTER TABLE workflow_node_run DROP COLUMN matrix;
+ALTER TABLE workflow_node DROP COLUMN matrix;
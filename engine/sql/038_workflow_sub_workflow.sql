@@ -0,0 +1,14 @@
+-- +migrate Up
+ALTER TABLE workflow_node ALTER COLUMN pipeline_id DROP NOT NULL;
+ALTER TABLE workflow_node ADD COLUMN sub_workflow_id BIGINT;
+SELECT create_index('workflow_node', 'IDX_WORKFLOW_NODE_SUB_WORKFLOW', 'sub_workflow_id');
+
+ALTER TABLE workflow_node_run ADD COLUMN sub_workflow_run_id BIGINT NOT NULL DEFAULT 0;
+ALTER TABLE workflow_run ADD COLUMN parent_node_run_id BIGINT NOT NULL DEFAULT 0;
+
+-- +migrate Down
+ALTER TABLE workflow_run DROP COLUMN parent_node_run_id;
+ALTER TABLE workflow_node_run DROP COLUMN sub_workflow_run_id;
+ALTER TABLE workflow_node DROP COLUMN sub_workflow_id;
+DELETE FROM workflow_node WHERE pipeline_id IS NULL;
+ALTER TABLE workflow_node ALTER COLUMN pipeline_id SET NOT NULL;
@@ -0,0 +1,10 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS "project_vault" (
+    project_id BIGINT PRIMARY KEY,
+    mount TEXT NOT NULL,
+    policy TEXT NOT NULL DEFAULT ''
+);
+SELECT create_foreign_key_idx_cascade('FK_PROJECT_VAULT_PROJECT', 'project_vault', 'project', 'project_id', 'id');
+
+-- +migrate Down
+DROP TABLE project_vault;
@@ -0,0 +1,17 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS "user_access_token" (
+  id BIGSERIAL PRIMARY KEY,
+  user_id BIGINT NOT NULL,
+  token_hash VARCHAR(128) NOT NULL,
+  description TEXT NOT NULL DEFAULT '',
+  scopes JSONB NOT NULL,
+  created TIMESTAMP WITH TIME ZONE NOT NULL,
+  expire_at TIMESTAMP WITH TIME ZONE,
+  last_used TIMESTAMP WITH TIME ZONE
+);
+
+SELECT create_foreign_key_idx_cascade('FK_USER_ACCESS_TOKEN_USER', 'user_access_token', 'user', 'user_id', 'id');
+CREATE UNIQUE INDEX IF NOT EXISTS IDX_USER_ACCESS_TOKEN_HASH ON user_access_token (token_hash);
+
+-- +migrate Down
+DROP TABLE user_access_token;
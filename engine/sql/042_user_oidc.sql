@@ -0,0 +1,12 @@
+-- +migrate Up
+CREATE TABLE IF NOT EXISTS "user_oidc" (
+  user_id BIGINT PRIMARY KEY,
+  issuer TEXT NOT NULL,
+  subject TEXT NOT NULL
+);
+
+SELECT create_foreign_key_idx_cascade('FK_USER_OIDC_USER', 'user_oidc', 'user', 'user_id', 'id');
+select create_unique_index('user_oidc', 'IDX_USER_OIDC_ISSUER_SUBJECT', 'issuer,subject');
+
+-- +migrate Down
+DROP TABLE user_oidc;
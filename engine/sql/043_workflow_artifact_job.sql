@@ -0,0 +1,5 @@
+-- +migrate Up
+ALTER TABLE workflow_node_run_artifacts ADD COLUMN workflow_node_run_job_id BIGINT DEFAULT 0;
+
+-- +migrate Down
+ALTER TABLE workflow_node_run_artifacts DROP COLUMN workflow_node_run_job_id;
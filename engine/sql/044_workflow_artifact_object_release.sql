@@ -0,0 +1,23 @@
+-- +migrate Up
+
+-- +migrate StatementBegin
+CREATE OR REPLACE FUNCTION release_workflow_artifact_object() RETURNS TRIGGER AS $$
+begin
+  if OLD.sha512sum <> '' then
+    UPDATE workflow_artifact_object SET ref_count = ref_count - 1 WHERE sha512sum = OLD.sha512sum;
+  end if;
+  return OLD;
+end;
+$$ LANGUAGE plpgsql;
+-- +migrate StatementEnd
+
+CREATE TRIGGER release_workflow_artifact_object AFTER DELETE ON workflow_node_run_artifacts
+  FOR EACH ROW EXECUTE PROCEDURE release_workflow_artifact_object();
+
+UPDATE workflow_artifact_object SET ref_count = (
+  SELECT COUNT(1) FROM workflow_node_run_artifacts WHERE workflow_node_run_artifacts.sha512sum = workflow_artifact_object.sha512sum
+);
+
+-- +migrate Down
+DROP TRIGGER release_workflow_artifact_object ON workflow_node_run_artifacts;
+DROP FUNCTION release_workflow_artifact_object();
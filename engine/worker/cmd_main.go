@@ -61,9 +61,12 @@ func cmdMain(w *currentWorker) *cobra.Command {
 	flags.Int("ttl", 30, "Worker time to live (minutes)")
 	viper.BindPFlag("ttl", flags.Lookup("ttl"))
 
-	flags.Int64("booked-job-id", 0, "Booked job id")
+	flags.Int64("booked-job-id", 0, "Booked pipeline build job id")
 	viper.BindPFlag("booked_job_id", flags.Lookup("booked-job-id"))
 
+	flags.Int64("booked-workflow-job-id", 0, "Booked workflow job id")
+	viper.BindPFlag("booked_workflow_job_id", flags.Lookup("booked-workflow-job-id"))
+
 	flags.String("grpc-api", "", "CDS GRPC tcp address")
 	viper.BindPFlag("grpc_api", flags.Lookup("grpc-api"))
 
@@ -154,6 +157,9 @@ func mainCommandRun(w *currentWorker) func(cmd *cobra.Command, args []string) {
 		if w.bookedJobID != 0 {
 			w.processBookedJob(pbjobs)
 		}
+		if w.bookedWorkflowJobID != 0 {
+			w.processBookedWorkflowJob(wjobs)
+		}
 
 		go func(ctx context.Context) {
 			if err := w.client.QueuePolling(ctx, wjobs, pbjobs, errs, 2*time.Second); err != nil {
@@ -239,13 +245,13 @@ func mainCommandRun(w *currentWorker) func(cmd *cobra.Command, args []string) {
 				}
 
 			case j := <-wjobs:
-				if j.ID == 0 {
+				if j.ID == 0 || j.Quota != nil {
 					continue
 				}
 
 				requirementsOK, _ := checkRequirements(w, &j.Job.Action)
 				t := ""
-				if j.ID == w.bookedJobID {
+				if j.ID == w.bookedWorkflowJobID {
 					t = ", this was my booked job"
 				}
 
@@ -316,11 +322,35 @@ func (w *currentWorker) processBookedJob(pbjobs chan<- sdk.PipelineBuildJob) {
 	pbjobs <- *j
 }
 
+func (w *currentWorker) processBookedWorkflowJob(wjobs chan<- sdk.WorkflowNodeJobRun) {
+	log.Debug("Try to take the workflow job %d", w.bookedWorkflowJobID)
+	j, err := w.client.QueueJobInfo(w.bookedWorkflowJobID)
+	if err != nil || j == nil {
+		log.Error("Unable to load workflow job %d: %v", w.bookedWorkflowJobID, err)
+		return
+	}
+
+	requirementsOK, errRequirements := checkRequirements(w, &j.Job.Action)
+	if !requirementsOK {
+		var details string
+		for _, r := range errRequirements {
+			details += fmt.Sprintf(" %s(%s)", r.Value, r.Type)
+		}
+		log.Warning("Unable to run workflow job %d, requirements not satisfied:%s", j.ID, details)
+		return
+	}
+
+	// requirementsOK is ok
+	wjobs <- *j
+}
+
 func (w *currentWorker) doRegister() error {
 	if w.id == "" {
 		var info string
 		if w.bookedJobID > 0 {
 			info = fmt.Sprintf(", I was born to work on job %d", w.bookedJobID)
+		} else if w.bookedWorkflowJobID > 0 {
+			info = fmt.Sprintf(", I was born to work on workflow job %d", w.bookedWorkflowJobID)
 		}
 		log.Info("Registering on CDS engine%s", info)
 		form := worker.RegistrationForm{
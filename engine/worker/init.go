@@ -66,6 +66,7 @@ func initViper(w *currentWorker) {
 		w.basedir = os.TempDir()
 	}
 	w.bookedJobID = viper.GetInt64("booked_job_id")
+	w.bookedWorkflowJobID = viper.GetInt64("booked_workflow_job_id")
 
 	w.client = cdsclient.NewWorker(w.apiEndpoint)
 }
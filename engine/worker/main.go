@@ -11,15 +11,16 @@ import (
 )
 
 type currentWorker struct {
-	alive         bool
-	apiEndpoint   string
-	token         string
-	id            string
-	modelID       int64
-	bookedJobID   int64
-	nbActionsDone int
-	basedir       string
-	logger        struct {
+	alive               bool
+	apiEndpoint         string
+	token               string
+	id                  string
+	modelID             int64
+	bookedJobID         int64
+	bookedWorkflowJobID int64
+	nbActionsDone       int
+	basedir             string
+	logger              struct {
 		logChan chan sdk.Log
 		llist   *list.List
 	}
@@ -306,7 +306,8 @@ func (w *currentWorker) processJob(ctx context.Context, jobInfo *worker.Workflow
 	t0 := time.Now()
 	defer func() { log.Info("Process Job Done (%s)", sdk.Round(time.Since(t0), time.Second).String()) }()
 
-	ctx, cancel := context.WithTimeout(context.Background(), 6*time.Hour)
+	// The context is canceled when the job is not building anymore, i.e. when the job has been stopped
+	ctx, cancel := context.WithTimeout(ctx, 6*time.Hour)
 	defer cancel()
 
 	defer w.drainLogsAndCloseLogger(ctx)
@@ -2,8 +2,6 @@ package main
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 	"time"
 
 	"github.com/golang/protobuf/ptypes"
@@ -14,7 +12,7 @@ import (
 )
 
 func (w *currentWorker) takeWorkflowJob(ctx context.Context, job sdk.WorkflowNodeJobRun) error {
-	info, err := w.client.QueueTakeJob(job, w.bookedJobID == job.ID)
+	info, err := w.client.QueueTakeJob(job, w.bookedWorkflowJobID == job.ID)
 	if err != nil {
 		return sdk.WrapError(err, "takeWorkflowJob> Unable to take workflob node run job")
 	}
@@ -29,7 +27,8 @@ func (w *currentWorker) takeWorkflowJob(ctx context.Context, job sdk.WorkflowNod
 
 	start := time.Now()
 
-	//This goroutine try to get the pipeline build job every 5 seconds, if it fails, it cancel the build.
+	//This goroutine gets the job every 5 seconds, and cancels the build when the job is not building anymore.
+	//A failed request is retried on the next tick, it must not abort the build.
 	ctx, cancel := context.WithCancel(ctx)
 	tick := time.NewTicker(5 * time.Second)
 	go func(cancel context.CancelFunc, jobID int64, tick *time.Ticker) {
@@ -41,20 +40,13 @@ func (w *currentWorker) takeWorkflowJob(ctx context.Context, job sdk.WorkflowNod
 				if !ok {
 					return
 				}
-				b, _, err := sdk.Request("GET", fmt.Sprintf("/queue/workflows/%d/infos", jobID), nil)
-				if err != nil {
-					log.Error("Unable to load pipeline build job %d", jobID)
-					cancel()
-					return
-				}
-
-				j := &sdk.WorkflowNodeJobRun{}
-				if err := json.Unmarshal(b, j); err != nil {
-					log.Error("Unable to load job run %d: %v", jobID, err)
-					cancel()
-					return
+				j, err := w.client.QueueJobInfo(jobID)
+				if err != nil || j == nil {
+					log.Warning("Unable to load job run %d, retrying: %v", jobID, err)
+					continue
 				}
 				if j.Status != sdk.StatusBuilding.String() {
+					log.Info("Job run %d is %s, stopping it", jobID, j.Status)
 					cancel()
 					return
 				}
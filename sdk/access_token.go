@@ -0,0 +1,66 @@
+package sdk
+
+import (
+	"strings"
+	"time"
+)
+
+// Scopes of the personal access tokens
+const (
+	// AccessTokenScopeRead allows all the GET requests the user is allowed to do
+	AccessTokenScopeRead = "read"
+	// AccessTokenScopeRun allows the read requests and the executions (run a workflow, a pipeline...)
+	AccessTokenScopeRun = "run"
+	// AccessTokenScopeAdminProjectPrefix allows all the requests on a project: admin:<project key>
+	AccessTokenScopeAdminProjectPrefix = "admin:"
+)
+
+// AccessTokenScopeAdminProject returns the scope allowing all the requests on a project
+func AccessTokenScopeAdminProject(key string) string {
+	return AccessTokenScopeAdminProjectPrefix + key
+}
+
+// AccessToken is a personal access token: it authenticates a user in scripts, with the permissions
+// of the user restricted to Scopes
+type AccessToken struct {
+	ID          int64      `json:"id"`
+	UserID      int64      `json:"user_id"`
+	Description string     `json:"description"`
+	Scopes      []string   `json:"scopes"`
+	Created     time.Time  `json:"created"`
+	ExpireAt    *time.Time `json:"expire_at,omitempty"`
+	LastUsed    *time.Time `json:"last_used,omitempty"`
+	// Token is only returned on creation: only its hash is stored
+	Token string `json:"token,omitempty"`
+}
+
+// IsValid checks the scopes of the token
+func (t *AccessToken) IsValid() error {
+	if len(t.Scopes) == 0 {
+		return ErrInvalidAccessTokenScope
+	}
+	for _, s := range t.Scopes {
+		switch {
+		case s == AccessTokenScopeRead, s == AccessTokenScopeRun:
+		case strings.HasPrefix(s, AccessTokenScopeAdminProjectPrefix) && len(s) > len(AccessTokenScopeAdminProjectPrefix):
+		default:
+			return ErrInvalidAccessTokenScope
+		}
+	}
+	return nil
+}
+
+// IsExpired returns true if the token can't be used anymore
+func (t *AccessToken) IsExpired() bool {
+	return t.ExpireAt != nil && time.Now().After(*t.ExpireAt)
+}
+
+// HasScope returns true if the token has the scope s
+func (t *AccessToken) HasScope(s string) bool {
+	for _, scope := range t.Scopes {
+		if scope == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,43 @@
+package sdk
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAccessTokenIsValid(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes []string
+		valid  bool
+	}{
+		{name: "read", scopes: []string{AccessTokenScopeRead}, valid: true},
+		{name: "run and project admin", scopes: []string{AccessTokenScopeRun, AccessTokenScopeAdminProject("FOO")}, valid: true},
+		{name: "no scope", scopes: nil, valid: false},
+		{name: "unknown scope", scopes: []string{"write"}, valid: false},
+		{name: "project admin without key", scopes: []string{AccessTokenScopeAdminProjectPrefix}, valid: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tok := &AccessToken{Scopes: tt.scopes}
+			if err := tok.IsValid(); (err == nil) != tt.valid {
+				t.Errorf("IsValid() = %v, want valid %v", err, tt.valid)
+			}
+		})
+	}
+}
+
+func TestAccessTokenIsExpired(t *testing.T) {
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+
+	if (&AccessToken{}).IsExpired() {
+		t.Errorf("a token without expiration must not be expired")
+	}
+	if !(&AccessToken{ExpireAt: &past}).IsExpired() {
+		t.Errorf("a token expired a minute ago must be expired")
+	}
+	if (&AccessToken{ExpireAt: &future}).IsExpired() {
+		t.Errorf("a token expiring in an hour must not be expired")
+	}
+}
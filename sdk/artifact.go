@@ -49,6 +49,12 @@ func (a *Artifact) GetPath() string {
 	return container
 }
 
+// ArtifactTemporaryURL is a signed url to download an artifact directly from the storage backend
+type ArtifactTemporaryURL struct {
+	URL     string    `json:"url"`
+	Expires time.Time `json:"expires"`
+}
+
 // Builtin artifact manipulation actions
 const (
 	ArtifactUpload   = "Artifact Upload"
@@ -84,14 +90,20 @@ func download(project, app, pip string, a Artifact, destdir string) error {
 
 	for retry := 5; retry >= 0; retry-- {
 		uri := fmt.Sprintf("/project/%s/application/%s/pipeline/%s/artifact/download/%d", project, app, pip, a.ID)
-		reader, code, err := Stream("GET", uri, nil)
+
+		// Download the artifact directly from the storage backend if possible, else stream it through the API
+		reader, err := streamTemporaryURL(uri + "/url")
 		if err != nil {
-			lasterr = err
-			continue
-		}
-		if code >= 300 {
-			lasterr = fmt.Errorf("HTTP %d", code)
-			continue
+			var code int
+			reader, code, err = Stream("GET", uri, nil)
+			if err != nil {
+				lasterr = err
+				continue
+			}
+			if code >= 300 {
+				lasterr = fmt.Errorf("HTTP %d", code)
+				continue
+			}
 		}
 		destPath := path.Join(destdir, a.Name)
 
@@ -120,6 +132,35 @@ func download(project, app, pip string, a Artifact, destdir string) error {
 	return fmt.Errorf("x5: %s", lasterr)
 }
 
+func streamTemporaryURL(uri string) (io.ReadCloser, error) {
+	data, _, err := Request("GET", uri, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var tmpURL ArtifactTemporaryURL
+	if err := json.Unmarshal(data, &tmpURL); err != nil {
+		return nil, err
+	}
+
+	// No authentication on the storage backend, but the same TLS settings as the API
+	req, err := http.NewRequest("GET", tmpURL.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", agent)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
 // DownloadArtifact downloads a single artifact from API
 func DownloadArtifact(project, app, pip, tag, destdir, env, filename string) error {
 	tag = url.QueryEscape(tag)
@@ -83,6 +83,8 @@ func StatusFromString(in string) Status {
 		return StatusDisabled
 	case StatusSkipped.String():
 		return StatusSkipped
+	case StatusStopped.String():
+		return StatusStopped
 	default:
 		return StatusUnknown
 	}
@@ -103,6 +105,7 @@ const (
 	StatusNeverBuilt Status = "Never Built"
 	StatusUnknown    Status = "Unknown"
 	StatusSkipped    Status = "Skipped"
+	StatusStopped    Status = "Stopped"
 )
 
 // GetBuildQueue retrieves current CDS build in queue
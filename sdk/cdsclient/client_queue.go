@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"crypto/md5"
+	"crypto/sha512"
 	"encoding/hex"
 	"fmt"
 	"io"
@@ -108,7 +109,7 @@ func (c *client) QueueJobInfo(id int64) (*sdk.WorkflowNodeJobRun, error) {
 	var path = fmt.Sprintf("/queue/workflows/%d/infos", id)
 	var job sdk.WorkflowNodeJobRun
 
-	if code, err := c.PostJSON(path, nil, &job); err != nil {
+	if code, err := c.GetJSON(path, &job); err != nil {
 		return nil, err
 	} else if code != http.StatusOK {
 		return nil, nil
@@ -139,22 +140,36 @@ func (c *client) QueueArtifactUpload(id int64, tag, filePath string) error {
 		return errst
 	}
 
-	//Compute md5sum
+	//Compute md5sum and sha512sum
 	hash := md5.New()
-	if _, errcopy := io.Copy(hash, fileForMD5); errcopy != nil {
+	sum512 := sha512.New()
+	if _, errcopy := io.Copy(io.MultiWriter(hash, sum512), fileForMD5); errcopy != nil {
 		return errcopy
 	}
 	hashInBytes := hash.Sum(nil)[:16]
 	md5sumStr := hex.EncodeToString(hashInBytes)
 	fileForMD5.Close()
 
+	_, name := filepath.Split(filePath)
+
+	//If the same content has already been uploaded, only add a reference on it
+	ref := sdk.WorkflowNodeRunArtifact{
+		Name:      name,
+		Size:      stat.Size(),
+		Perm:      uint32(stat.Mode().Perm()),
+		MD5sum:    md5sumStr,
+		SHA512sum: hex.EncodeToString(sum512.Sum(nil)),
+	}
+	if code, err := c.PostJSON(fmt.Sprintf("/queue/workflows/%d/artifact/%s/ref", id, tag), ref, nil); err == nil && code == http.StatusOK {
+		return nil
+	}
+
 	//Reopen the file because we already read it for md5
 	fileReopen, erro := os.Open(filePath)
 	if erro != nil {
 		return erro
 	}
 	defer fileReopen.Close()
-	_, name := filepath.Split(filePath)
 
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
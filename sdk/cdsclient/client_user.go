@@ -38,6 +38,33 @@ func (c *client) UserLogin(username, password string) (bool, string, error) {
 	return true, response.Password, nil
 }
 
+func (c *client) UserLoginOIDCDevice() (*sdk.UserOIDCDeviceAuthorization, error) {
+	device := &sdk.UserOIDCDeviceAuthorization{}
+	code, err := c.PostJSON("/login/oidc/device", nil, device)
+	if err != nil {
+		return nil, err
+	}
+	if code != http.StatusOK {
+		return nil, fmt.Errorf("Error %d", code)
+	}
+	return device, nil
+}
+
+func (c *client) UserLoginOIDCDeviceToken(deviceCode string) (*sdk.UserAPIResponse, error) {
+	response := &sdk.UserAPIResponse{}
+	code, err := c.PostJSON("/login/oidc/device/token", sdk.UserOIDCDeviceAuthorization{DeviceCode: deviceCode}, response)
+	if e, ok := err.(sdk.Error); ok && e.ID == sdk.ErrOIDCAuthorizationPending.ID {
+		return nil, sdk.ErrOIDCAuthorizationPending
+	}
+	if err != nil {
+		return nil, err
+	}
+	if code != http.StatusOK {
+		return nil, fmt.Errorf("Error %d", code)
+	}
+	return response, nil
+}
+
 func (c *client) UserList() ([]sdk.User, error) {
 	res := []sdk.User{}
 	code, err := c.GetJSON("/user", &res)
@@ -130,3 +157,37 @@ func (c *client) UserConfirm(username, token string) (bool, string, error) {
 
 	return true, res.Password, nil
 }
+
+func (c *client) UserAccessTokenList(username string) ([]sdk.AccessToken, error) {
+	res := []sdk.AccessToken{}
+	code, err := c.GetJSON("/user/"+url.QueryEscape(username)+"/token", &res)
+	if err != nil {
+		return nil, err
+	}
+	if code != http.StatusOK {
+		return nil, fmt.Errorf("Error %d", code)
+	}
+	return res, nil
+}
+
+func (c *client) UserAccessTokenCreate(username string, t *sdk.AccessToken) error {
+	code, err := c.PostJSON("/user/"+url.QueryEscape(username)+"/token", t, t)
+	if err != nil {
+		return err
+	}
+	if code != http.StatusCreated {
+		return fmt.Errorf("Error %d", code)
+	}
+	return nil
+}
+
+func (c *client) UserAccessTokenDelete(username string, id int64) error {
+	code, err := c.DeleteJSON(fmt.Sprintf("/user/%s/token/%d", url.QueryEscape(username), id), nil)
+	if err != nil {
+		return err
+	}
+	if code != http.StatusOK {
+		return fmt.Errorf("Error %d", code)
+	}
+	return nil
+}
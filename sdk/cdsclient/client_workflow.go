@@ -1,6 +1,7 @@
 package cdsclient
 
 import (
+	"encoding/json"
 	"io"
 
 	"fmt"
@@ -26,6 +27,55 @@ func (c *client) WorkflowGet(projectKey, name string) (*sdk.Workflow, error) {
 	return w, nil
 }
 
+func (c *client) WorkflowExport(projectKey, name, format string) ([]byte, error) {
+	url := fmt.Sprintf("/project/%s/workflows/%s/export?format=%s", projectKey, name, format)
+	btes, _, err := c.Request("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	return btes, nil
+}
+
+func (c *client) WorkflowImport(projectKey string, content []byte, format string, force bool) (*sdk.Workflow, error) {
+	url := fmt.Sprintf("/project/%s/workflows/import?format=%s&force=%t", projectKey, format, force)
+	btes, _, err := c.Request("POST", url, content)
+	if err != nil {
+		return nil, err
+	}
+	w := &sdk.Workflow{}
+	if err := json.Unmarshal(btes, w); err != nil {
+		return nil, err
+	}
+	return w, nil
+}
+
+func (c *client) WorkflowVersions(projectKey, name string) ([]sdk.WorkflowVersion, error) {
+	url := fmt.Sprintf("/project/%s/workflows/%s/versions", projectKey, name)
+	vs := []sdk.WorkflowVersion{}
+	if _, err := c.GetJSON(url, &vs); err != nil {
+		return nil, err
+	}
+	return vs, nil
+}
+
+func (c *client) WorkflowVersionDiff(projectKey, name string, from, to int64) (*sdk.WorkflowVersionDiff, error) {
+	url := fmt.Sprintf("/project/%s/workflows/%s/versions/%d/diff/%d", projectKey, name, from, to)
+	diff := sdk.WorkflowVersionDiff{}
+	if _, err := c.GetJSON(url, &diff); err != nil {
+		return nil, err
+	}
+	return &diff, nil
+}
+
+func (c *client) WorkflowVersionRestore(projectKey, name string, version int64) (*sdk.Workflow, error) {
+	url := fmt.Sprintf("/project/%s/workflows/%s/versions/%d/restore", projectKey, name, version)
+	w := sdk.Workflow{}
+	if _, err := c.PostJSON(url, nil, &w); err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
 func (c *client) WorkflowRun(projectKey string, name string, number int64) (*sdk.WorkflowRun, error) {
 	url := fmt.Sprintf("/project/%s/workflows/%s/runs/%d", projectKey, name, number)
 	run := sdk.WorkflowRun{}
@@ -35,6 +85,64 @@ func (c *client) WorkflowRun(projectKey string, name string, number int64) (*sdk
 	return &run, nil
 }
 
+func (c *client) WorkflowRunSearch(projectKey string, filter sdk.WorkflowRunFilter) ([]sdk.WorkflowRun, error) {
+	url := fmt.Sprintf("/project/%s/runs?%s", projectKey, filter.Values().Encode())
+	runs := []sdk.WorkflowRun{}
+	if _, err := c.GetJSON(url, &runs); err != nil {
+		return nil, err
+	}
+	return runs, nil
+}
+
+func (c *client) WorkflowRunStop(projectKey string, name string, number int64) (*sdk.WorkflowRun, error) {
+	url := fmt.Sprintf("/project/%s/workflows/%s/runs/%d/stop", projectKey, name, number)
+	run := sdk.WorkflowRun{}
+	if _, err := c.PostJSON(url, nil, &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+func (c *client) WorkflowNodeRunStop(projectKey string, name string, number int64, nodeRunID int64) (*sdk.WorkflowRun, error) {
+	url := fmt.Sprintf("/project/%s/workflows/%s/runs/%d/nodes/%d/stop", projectKey, name, number, nodeRunID)
+	run := sdk.WorkflowRun{}
+	if _, err := c.PostJSON(url, nil, &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+func (c *client) WorkflowNodeRunRestart(projectKey string, name string, number int64, nodeRunID int64) (*sdk.WorkflowRun, error) {
+	url := fmt.Sprintf("/project/%s/workflows/%s/runs/%d/nodes/%d/restart", projectKey, name, number, nodeRunID)
+	run := sdk.WorkflowRun{}
+	if _, err := c.PostJSON(url, nil, &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
+func (c *client) WorkflowRunApprovals(projectKey string, name string, number int64) ([]sdk.WorkflowRunApproval, error) {
+	url := fmt.Sprintf("/project/%s/workflows/%s/runs/%d/approvals", projectKey, name, number)
+	as := []sdk.WorkflowRunApproval{}
+	if _, err := c.GetJSON(url, &as); err != nil {
+		return nil, err
+	}
+	return as, nil
+}
+
+func (c *client) WorkflowRunApprovalVote(projectKey string, name string, number int64, approvalID int64, approved bool, comment string) (*sdk.WorkflowRun, error) {
+	vote := "approve"
+	if !approved {
+		vote = "reject"
+	}
+	url := fmt.Sprintf("/project/%s/workflows/%s/runs/%d/approvals/%d/%s", projectKey, name, number, approvalID, vote)
+	run := sdk.WorkflowRun{}
+	if _, err := c.PostJSON(url, sdk.WorkflowRunApprovalRequest{Comment: comment}, &run); err != nil {
+		return nil, err
+	}
+	return &run, nil
+}
+
 func (c *client) WorkflowRunArtifacts(projectKey string, name string, number int64) ([]sdk.Artifact, error) {
 	url := fmt.Sprintf("/project/%s/workflows/%s/runs/%d/artifacts", projectKey, name, number)
 	arts := []sdk.Artifact{}
@@ -64,9 +172,15 @@ func (c *client) WorkflowNodeRunArtifacts(projectKey string, name string, number
 
 func (c *client) WorkflowNodeRunArtifactDownload(projectKey string, name string, artifactID int64, w io.Writer) error {
 	url := fmt.Sprintf("/project/%s/workflows/%s/artifact/%d", projectKey, name, artifactID)
-	reader, _, err := c.Stream("GET", url, nil)
+
+	// Download the artifact directly from the storage backend if it is able to sign a temporary url,
+	// else stream it through the API
+	reader, err := c.streamTemporaryURL(url + "/url")
 	if err != nil {
-		return err
+		reader, _, err = c.Stream("GET", url, nil)
+		if err != nil {
+			return err
+		}
 	}
 	defer reader.Close()
 	if _, err := io.Copy(w, reader); err != nil {
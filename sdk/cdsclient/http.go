@@ -17,6 +17,8 @@ import (
 const (
 	//SessionTokenHeader is user as HTTP header
 	SessionTokenHeader = "Session-Token"
+	// AccessTokenHeader is used as HTTP header for personal access tokens
+	AccessTokenHeader = "Access-Token"
 	// AuthHeader is used as HTTP header
 	AuthHeader = "X_AUTH_HEADER"
 	// RequestedWithHeader is used as HTTP header
@@ -153,7 +155,9 @@ func (c *client) Stream(method string, path string, args []byte, mods ...Request
 				basedHash := base64.StdEncoding.EncodeToString([]byte(c.config.Hash))
 				req.Header.Set(AuthHeader, basedHash)
 			}
-			if c.config.User != "" && c.config.Token != "" {
+			if c.config.AccessToken != "" {
+				req.Header.Set(AccessTokenHeader, c.config.AccessToken)
+			} else if c.config.User != "" && c.config.Token != "" {
 				req.Header.Add(SessionTokenHeader, c.config.Token)
 				req.SetBasicAuth(c.config.User, c.config.Token)
 			}
@@ -207,6 +211,30 @@ func (c *client) Stream(method string, path string, args []byte, mods ...Request
 	return nil, 0, fmt.Errorf("x%d: %s", c.config.Retry, savederror)
 }
 
+// streamTemporaryURL gets a temporary url from the API on path, then streams it without authentication
+func (c *client) streamTemporaryURL(path string) (io.ReadCloser, error) {
+	var tmpURL sdk.ArtifactTemporaryURL
+	if _, err := c.GetJSON(path, &tmpURL); err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest("GET", tmpURL.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.config.userAgent)
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		resp.Body.Close()
+		return nil, fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	return resp.Body, nil
+}
+
 // UploadMultiPart upload multipart
 func (c *client) UploadMultiPart(method string, path string, body *bytes.Buffer, mods ...RequestModifier) ([]byte, int, error) {
 	var req *http.Request
@@ -230,7 +258,9 @@ func (c *client) UploadMultiPart(method string, path string, body *bytes.Buffer,
 			basedHash := base64.StdEncoding.EncodeToString([]byte(c.config.Hash))
 			req.Header.Set(AuthHeader, basedHash)
 		}
-		if c.config.User != "" && c.config.Token != "" {
+		if c.config.AccessToken != "" {
+			req.Header.Set(AccessTokenHeader, c.config.AccessToken)
+		} else if c.config.User != "" && c.config.Token != "" {
 			req.Header.Add(SessionTokenHeader, c.config.Token)
 			req.SetBasicAuth(c.config.User, c.config.Token)
 		}
@@ -25,17 +25,33 @@ type Interface interface {
 	QueueArtifactUpload(id int64, tag, filePath string) error
 	Requirements() ([]sdk.Requirement, error)
 	UserLogin(username, password string) (bool, string, error)
+	UserLoginOIDCDevice() (*sdk.UserOIDCDeviceAuthorization, error)
+	UserLoginOIDCDeviceToken(deviceCode string) (*sdk.UserAPIResponse, error)
 	UserList() ([]sdk.User, error)
 	UserSignup(username, fullname, email, callback string) error
 	UserGet(username string) (*sdk.User, error)
 	UserGetGroups(username string) (map[string][]sdk.Group, error)
 	UserReset(username, email string) error
 	UserConfirm(username, token string) (bool, string, error)
+	UserAccessTokenList(username string) ([]sdk.AccessToken, error)
+	UserAccessTokenCreate(username string, t *sdk.AccessToken) error
+	UserAccessTokenDelete(username string, id int64) error
 	WorkerRegister(worker.RegistrationForm) (string, bool, error)
 	WorkerSetStatus(sdk.Status) error
 	WorkflowList(projectKey string) ([]sdk.Workflow, error)
 	WorkflowGet(projectKey, name string) (*sdk.Workflow, error)
+	WorkflowExport(projectKey, name, format string) ([]byte, error)
+	WorkflowImport(projectKey string, content []byte, format string, force bool) (*sdk.Workflow, error)
+	WorkflowVersions(projectKey, name string) ([]sdk.WorkflowVersion, error)
+	WorkflowVersionDiff(projectKey, name string, from, to int64) (*sdk.WorkflowVersionDiff, error)
+	WorkflowVersionRestore(projectKey, name string, version int64) (*sdk.Workflow, error)
 	WorkflowRun(projectKey string, name string, number int64) (*sdk.WorkflowRun, error)
+	WorkflowRunSearch(projectKey string, filter sdk.WorkflowRunFilter) ([]sdk.WorkflowRun, error)
+	WorkflowRunStop(projectKey string, name string, number int64) (*sdk.WorkflowRun, error)
+	WorkflowNodeRunStop(projectKey string, name string, number int64, nodeRunID int64) (*sdk.WorkflowRun, error)
+	WorkflowNodeRunRestart(projectKey string, name string, number int64, nodeRunID int64) (*sdk.WorkflowRun, error)
+	WorkflowRunApprovals(projectKey string, name string, number int64) ([]sdk.WorkflowRunApproval, error)
+	WorkflowRunApprovalVote(projectKey string, name string, number int64, approvalID int64, approved bool, comment string) (*sdk.WorkflowRun, error)
 	WorkflowRunArtifacts(projectKey string, name string, number int64) ([]sdk.Artifact, error)
 	WorkflowNodeRun(projectKey string, name string, number int64, nodeRunID int64) (*sdk.WorkflowNodeRun, error)
 	WorkflowNodeRunArtifacts(projectKey string, name string, number int64, nodeRunID int64) ([]sdk.Artifact, error)
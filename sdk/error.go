@@ -113,6 +113,17 @@ var (
 	ErrWorkflowNodeJoinNotFound              = &Error{ID: 97, Status: http.StatusNotFound}
 	ErrInvalidJobRequirement                 = &Error{ID: 98, Status: http.StatusBadRequest}
 	ErrNotImplemented                        = &Error{ID: 99, Status: http.StatusNotImplemented}
+	ErrQueueQuotaExceeded                    = &Error{ID: 100, Status: http.StatusTooManyRequests}
+	ErrWorkflowApprovalNotFound              = &Error{ID: 101, Status: http.StatusNotFound}
+	ErrWorkflowApprovalClosed                = &Error{ID: 102, Status: http.StatusBadRequest}
+	ErrWorkflowApprovalAlreadyVoted          = &Error{ID: 103, Status: http.StatusConflict}
+	ErrWorkflowVersionNotFound               = &Error{ID: 104, Status: http.StatusNotFound}
+	ErrInvalidVaultVariable                  = &Error{ID: 105, Status: http.StatusBadRequest}
+	ErrVaultNotConfigured                    = &Error{ID: 106, Status: http.StatusBadRequest}
+	ErrVaultSecretNotFound                   = &Error{ID: 107, Status: http.StatusNotFound}
+	ErrOIDCAuthorizationPending              = &Error{ID: 108, Status: http.StatusBadRequest}
+	ErrInvalidAccessTokenScope               = &Error{ID: 109, Status: http.StatusBadRequest}
+	ErrInvalidRole                           = &Error{ID: 110, Status: http.StatusBadRequest}
 )
 
 var errorsAmericanEnglish = map[int]string{
@@ -215,6 +226,17 @@ var errorsAmericanEnglish = map[int]string{
 	ErrWorkflowNodeJoinNotFound.ID:              "Workflow node join not found",
 	ErrInvalidJobRequirement.ID:                 "Invalid job requirement",
 	ErrNotImplemented.ID:                        "This functionality isn't implemented",
+	ErrQueueQuotaExceeded.ID:                    "Queue quota exceeded",
+	ErrWorkflowApprovalNotFound.ID:              "Workflow approval not found",
+	ErrWorkflowApprovalClosed.ID:                "This approval is no longer waiting for votes",
+	ErrWorkflowApprovalAlreadyVoted.ID:          "You have already voted on this approval",
+	ErrWorkflowVersionNotFound.ID:               "Workflow version not found",
+	ErrInvalidVaultVariable.ID:                  "Invalid vault variable: the value must be a relative path, optionally followed by #field",
+	ErrVaultNotConfigured.ID:                    "Vault is not configured",
+	ErrVaultSecretNotFound.ID:                   "Vault secret not found",
+	ErrOIDCAuthorizationPending.ID:              "Authorization pending: the user has not logged in yet",
+	ErrInvalidAccessTokenScope.ID:               "Invalid access token scope: expected read, run or admin:<project key>",
+	ErrInvalidRole.ID:                           "Invalid role: expected viewer, runner, deployer, maintainer or owner",
 }
 
 var errorsFrench = map[int]string{
@@ -317,6 +339,17 @@ var errorsFrench = map[int]string{
 	ErrWorkflowNodeJoinNotFound.ID:              "Jointure introuvable",
 	ErrInvalidJobRequirement.ID:                 "Pré-requis de Job invalide",
 	ErrNotImplemented.ID:                        "La fonctionnalité n'est pas implémentée",
+	ErrQueueQuotaExceeded.ID:                    "Quota de la file d'attente dépassé",
+	ErrWorkflowApprovalNotFound.ID:              "Approbation de workflow introuvable",
+	ErrWorkflowApprovalClosed.ID:                "Cette approbation n'attend plus de vote",
+	ErrWorkflowApprovalAlreadyVoted.ID:          "Vous avez déjà voté pour cette approbation",
+	ErrWorkflowVersionNotFound.ID:               "Version de workflow introuvable",
+	ErrInvalidVaultVariable.ID:                  "Variable vault invalide : la valeur doit être un chemin relatif, éventuellement suivi de #champ",
+	ErrVaultNotConfigured.ID:                    "Vault n'est pas configuré",
+	ErrVaultSecretNotFound.ID:                   "Secret vault introuvable",
+	ErrOIDCAuthorizationPending.ID:              "Autorisation en attente : l'utilisateur ne s'est pas encore connecté",
+	ErrInvalidAccessTokenScope.ID:               "Portée du token d'accès invalide : read, run ou admin:<clé du projet> attendu",
+	ErrInvalidRole.ID:                           "Rôle invalide : viewer, runner, deployer, maintainer ou owner attendu",
 }
 
 var errorsLanguages = []map[int]string{
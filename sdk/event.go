@@ -17,6 +17,29 @@ type Event struct {
 	Attempts  int                    `json:"attempt"`
 }
 
+// EventDeadLetter is an event which has not been delivered to a broker after all retries
+type EventDeadLetter struct {
+	ID       int64     `json:"id" db:"id"`
+	Broker   string    `json:"broker" db:"broker"`
+	Event    Event     `json:"event" db:"-"`
+	Error    string    `json:"error" db:"error"`
+	Attempts int       `json:"attempts" db:"attempts"`
+	Created  time.Time `json:"created" db:"created"`
+}
+
+// EventBrokerMetrics contains the delivery metrics of an event broker since the API startup.
+// Lags are the delays between the publication of the events and their delivery, in seconds
+type EventBrokerMetrics struct {
+	Broker       string  `json:"broker"`
+	QueueLength  int     `json:"queue_length"`
+	Sent         int64   `json:"sent"`
+	Retried      int64   `json:"retried"`
+	DeadLettered int64   `json:"dead_lettered"`
+	LastLag      float64 `json:"last_lag"`
+	AverageLag   float64 `json:"average_lag"`
+	MaxLag       float64 `json:"max_lag"`
+}
+
 // EventEngine contains event data for engine
 type EventEngine struct {
 	Message string `json:"message"`
@@ -59,6 +82,21 @@ type EventJob struct {
 	Hash            string `json:"hash,omitempty"`
 }
 
+// EventWorkflowApproval contains event data for a manual approval on a workflow run trigger
+type EventWorkflowApproval struct {
+	ProjectKey   string   `json:"projectKey,omitempty"`
+	WorkflowName string   `json:"workflowName,omitempty"`
+	Number       int64    `json:"number,omitempty"`
+	ApprovalID   int64    `json:"approvalID,omitempty"`
+	PipelineName string   `json:"pipelineName,omitempty"`
+	GroupName    string   `json:"groupName,omitempty"`
+	Status       string   `json:"status,omitempty"`
+	Required     int      `json:"required,omitempty"`
+	Approvals    int      `json:"approvals,omitempty"`
+	Approvers    []string `json:"approvers,omitempty"`
+	Username     string   `json:"username,omitempty"`
+}
+
 // EventNotif contains event data for a job
 type EventNotif struct {
 	Recipients []string `json:"recipients"`
@@ -0,0 +1,405 @@
+package exportentities
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ovh/cds/sdk"
+)
+
+// Workflow is a struct to export sdk.Workflow. Nodes are identified by a reference instead of IDs:
+// a node depending on one node is triggered by it, a node depending on several nodes is triggered by their join
+type Workflow struct {
+	Name        string                  `json:"name" yaml:"name"`
+	Description string                  `json:"description,omitempty" yaml:"description,omitempty"`
+	Root        string                  `json:"root" yaml:"root"`
+	Nodes       map[string]WorkflowNode `json:"nodes" yaml:"nodes"`
+}
+
+// WorkflowNode is a struct to export sdk.WorkflowNode with its context and its incoming trigger
+type WorkflowNode struct {
+	Pipeline    string              `json:"pipeline,omitempty" yaml:"pipeline,omitempty"`
+	Workflow    string              `json:"workflow,omitempty" yaml:"workflow,omitempty"`
+	Application string              `json:"application,omitempty" yaml:"application,omitempty"`
+	Environment string              `json:"environment,omitempty" yaml:"environment,omitempty"`
+	Payload     interface{}         `json:"payload,omitempty" yaml:"payload,omitempty"`
+	Parameters  map[string]string   `json:"parameters,omitempty" yaml:"parameters,omitempty"`
+	Matrix      map[string][]string `json:"matrix,omitempty" yaml:"matrix,omitempty"`
+	DependsOn   []string            `json:"depends_on,omitempty" yaml:"depends_on,omitempty"`
+	Conditions  []WorkflowCondition `json:"conditions,omitempty" yaml:"conditions,omitempty"`
+	Approval    *WorkflowApproval   `json:"approval,omitempty" yaml:"approval,omitempty"`
+	Hooks       []WorkflowHook      `json:"hooks,omitempty" yaml:"hooks,omitempty"`
+}
+
+// WorkflowApproval is a struct to export sdk.WorkflowTriggerApproval. Expiry is in seconds
+type WorkflowApproval struct {
+	Group    string `json:"group" yaml:"group"`
+	Required int    `json:"required" yaml:"required"`
+	Expiry   int64  `json:"expiry,omitempty" yaml:"expiry,omitempty"`
+}
+
+// WorkflowCondition is a struct to export sdk.WorkflowTriggerCondition, either a comparison or an expression
+type WorkflowCondition struct {
+	Variable   string `json:"variable,omitempty" yaml:"variable,omitempty"`
+	Operator   string `json:"operator,omitempty" yaml:"operator,omitempty"`
+	Value      string `json:"value,omitempty" yaml:"value,omitempty"`
+	Expression string `json:"expression,omitempty" yaml:"expression,omitempty"`
+}
+
+// WorkflowHook is a struct to export sdk.WorkflowNodeHook
+type WorkflowHook struct {
+	Model      string              `json:"model" yaml:"model"`
+	Config     map[string]string   `json:"config,omitempty" yaml:"config,omitempty"`
+	Conditions []WorkflowCondition `json:"conditions,omitempty" yaml:"conditions,omitempty"`
+}
+
+//NewWorkflow returns a Workflow from an sdk.Workflow pointer. Node names are used as references
+func NewWorkflow(w *sdk.Workflow) (*Workflow, error) {
+	if w == nil || w.Root == nil {
+		return nil, sdk.ErrWorkflowInvalidRoot
+	}
+
+	e := &Workflow{
+		Name:        w.Name,
+		Description: w.Description,
+		Nodes:       map[string]WorkflowNode{},
+	}
+
+	// The sources of the joins are known by node ID for a loaded workflow, and by node reference for a new one
+	refs := &workflowRefs{byID: map[int64]string{}, byRef: map[string]string{}}
+	e.Root = e.addNode(w.Root, nil, nil, refs)
+
+	for _, j := range w.Joins {
+		sources := []string{}
+		for _, id := range j.SourceNodeIDs {
+			ref, ok := refs.byID[id]
+			if !ok {
+				return nil, sdk.WrapError(sdk.ErrWorkflowNodeRef, "NewWorkflow> Unknown join source node %d", id)
+			}
+			sources = append(sources, ref)
+		}
+		if len(j.SourceNodeIDs) == 0 {
+			for _, r := range j.SourceNodeRefs {
+				ref, ok := refs.byRef[r]
+				if !ok {
+					return nil, sdk.WrapError(sdk.ErrWorkflowNodeRef, "NewWorkflow> Unknown join source node %s", r)
+				}
+				sources = append(sources, ref)
+			}
+		}
+		sort.Strings(sources)
+		for i := range j.Triggers {
+			t := &j.Triggers[i]
+			e.addNode(&t.WorkflowDestNode, sources, t.Conditions, refs)
+		}
+	}
+
+	return e, nil
+}
+
+// workflowRefs contains the exported reference of each node
+type workflowRefs struct {
+	byID  map[int64]string
+	byRef map[string]string
+}
+
+// addNode adds the node and the nodes it triggers, and returns its reference
+func (e *Workflow) addNode(n *sdk.WorkflowNode, dependsOn []string, conditions []sdk.WorkflowTriggerCondition, refs *workflowRefs) string {
+	ref := n.Name
+	if ref == "" && n.SubWorkflow != nil {
+		ref = n.SubWorkflow.WorkflowName
+	}
+	if ref == "" {
+		ref = n.Pipeline.Name
+	}
+	// References must be unique
+	if _, ok := e.Nodes[ref]; ok {
+		i := 2
+		for ; ; i++ {
+			if _, ok := e.Nodes[fmt.Sprintf("%s_%d", ref, i)]; !ok {
+				break
+			}
+		}
+		ref = fmt.Sprintf("%s_%d", ref, i)
+	}
+	if n.ID != 0 {
+		refs.byID[n.ID] = ref
+	}
+	if n.Ref != "" {
+		refs.byRef[n.Ref] = ref
+	}
+
+	node := WorkflowNode{
+		Pipeline:   n.Pipeline.Name,
+		Matrix:     n.Matrix,
+		DependsOn:  dependsOn,
+		Conditions: newWorkflowConditions(conditions),
+	}
+	if n.SubWorkflow != nil {
+		node.Pipeline = ""
+		node.Workflow = n.SubWorkflow.String()
+	}
+	if n.Context != nil {
+		if n.Context.Application != nil {
+			node.Application = n.Context.Application.Name
+		}
+		if n.Context.Environment != nil {
+			node.Environment = n.Context.Environment.Name
+		}
+		node.Payload = n.Context.DefaultPayload
+		if len(n.Context.DefaultPipelineParameters) > 0 {
+			node.Parameters = make(map[string]string, len(n.Context.DefaultPipelineParameters))
+			for _, p := range n.Context.DefaultPipelineParameters {
+				node.Parameters[p.Name] = p.Value
+			}
+		}
+	}
+	for _, h := range n.Hooks {
+		node.Hooks = append(node.Hooks, WorkflowHook{
+			Model:      h.WorkflowHookModel.Name,
+			Config:     h.Config,
+			Conditions: newWorkflowConditions(h.Conditions),
+		})
+	}
+	e.Nodes[ref] = node
+
+	for i := range n.Triggers {
+		t := &n.Triggers[i]
+		dest := e.addNode(&t.WorkflowDestNode, []string{ref}, t.Conditions, refs)
+		if t.Approval != nil {
+			d := e.Nodes[dest]
+			d.Approval = &WorkflowApproval{
+				Group:    t.Approval.GroupName,
+				Required: t.Approval.Required,
+				Expiry:   t.Approval.Expiry,
+			}
+			e.Nodes[dest] = d
+		}
+	}
+	return ref
+}
+
+func newWorkflowConditions(conditions []sdk.WorkflowTriggerCondition) []WorkflowCondition {
+	if len(conditions) == 0 {
+		return nil
+	}
+	res := make([]WorkflowCondition, len(conditions))
+	for i, c := range conditions {
+		res[i] = WorkflowCondition{Variable: c.Variable, Operator: c.Operator, Value: c.Value, Expression: c.Expression}
+	}
+	return res
+}
+
+func workflowConditions(conditions []WorkflowCondition) []sdk.WorkflowTriggerCondition {
+	if len(conditions) == 0 {
+		return nil
+	}
+	res := make([]sdk.WorkflowTriggerCondition, len(conditions))
+	for i, c := range conditions {
+		res[i] = sdk.WorkflowTriggerCondition{Variable: c.Variable, Operator: c.Operator, Value: c.Value, Expression: c.Expression}
+	}
+	return res
+}
+
+// checkDependencies checks that all the dependencies exist and are not cyclic
+func (e *Workflow) checkDependencies() error {
+	if e.Root == "" {
+		return sdk.WrapError(sdk.ErrWorkflowInvalidRoot, "checkDependencies> Root node is mandatory")
+	}
+	root, ok := e.Nodes[e.Root]
+	if !ok {
+		return sdk.WrapError(sdk.ErrWorkflowInvalidRoot, "checkDependencies> Unknown root node %s", e.Root)
+	}
+	if len(root.DependsOn) > 0 {
+		return sdk.WrapError(sdk.ErrWorkflowInvalidRoot, "checkDependencies> Root node %s can't depend on other nodes", e.Root)
+	}
+
+	for ref, n := range e.Nodes {
+		if ref != e.Root && len(n.DependsOn) == 0 {
+			return sdk.WrapError(sdk.ErrWorkflowNodeRef, "checkDependencies> Node %s must depend on another node", ref)
+		}
+		for _, d := range n.DependsOn {
+			if _, ok := e.Nodes[d]; !ok {
+				return sdk.WrapError(sdk.ErrWorkflowNodeRef, "checkDependencies> Node %s depends on unknown node %s", ref, d)
+			}
+		}
+		if (n.Pipeline == "") == (n.Workflow == "") {
+			return sdk.WrapError(sdk.ErrWorkflowInvalid, "checkDependencies> Node %s must run either a pipeline or a workflow", ref)
+		}
+		if n.Workflow != "" {
+			if _, err := sdk.ParseWorkflowNodeSubWorkflow(n.Workflow); err != nil {
+				return sdk.WrapError(sdk.ErrWorkflowInvalid, "checkDependencies> Node %s: %s", ref, err)
+			}
+		}
+		if n.Approval != nil && len(n.DependsOn) != 1 {
+			return sdk.WrapError(sdk.ErrWorkflowInvalid, "checkDependencies> Node %s must depend on a single node to wait for approvals", ref)
+		}
+	}
+
+	// Depth first search of cycles
+	const (
+		visiting = 1
+		visited  = 2
+	)
+	state := map[string]int{}
+	var visit func(ref string) error
+	visit = func(ref string) error {
+		switch state[ref] {
+		case visiting:
+			return sdk.WrapError(sdk.ErrWorkflowNodeRef, "checkDependencies> Cyclic dependency on node %s", ref)
+		case visited:
+			return nil
+		}
+		state[ref] = visiting
+		for _, d := range e.Nodes[ref].DependsOn {
+			if err := visit(d); err != nil {
+				return err
+			}
+		}
+		state[ref] = visited
+		return nil
+	}
+	for ref := range e.Nodes {
+		if err := visit(ref); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//Workflow returns a sdk.Workflow entity. Pipelines, applications, environments and hook models are only set by name
+func (e *Workflow) Workflow() (*sdk.Workflow, error) {
+	if err := e.checkDependencies(); err != nil {
+		return nil, err
+	}
+
+	// Nodes triggered by a single node, and nodes triggered by a join, indexed by the sorted sources of the join
+	children := map[string][]string{}
+	joins := map[string][]string{}
+	for ref, n := range e.Nodes {
+		switch len(n.DependsOn) {
+		case 0:
+		case 1:
+			children[n.DependsOn[0]] = append(children[n.DependsOn[0]], ref)
+		default:
+			sources := append([]string{}, n.DependsOn...)
+			sort.Strings(sources)
+			k := strings.Join(sources, ",")
+			joins[k] = append(joins[k], ref)
+		}
+	}
+
+	w := &sdk.Workflow{
+		Name:        e.Name,
+		Description: e.Description,
+	}
+	root := e.node(e.Root, children)
+	w.Root = &root
+
+	keys := make([]string, 0, len(joins))
+	for k := range joins {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		j := sdk.WorkflowNodeJoin{SourceNodeRefs: strings.Split(k, ",")}
+		dests := joins[k]
+		sort.Strings(dests)
+		for _, ref := range dests {
+			j.Triggers = append(j.Triggers, sdk.WorkflowNodeJoinTrigger{
+				WorkflowDestNode: e.node(ref, children),
+				Conditions:       workflowConditions(e.Nodes[ref].Conditions),
+			})
+		}
+		w.Joins = append(w.Joins, j)
+	}
+
+	return w, nil
+}
+
+// node returns the sdk.WorkflowNode and the nodes it triggers
+func (e *Workflow) node(ref string, children map[string][]string) sdk.WorkflowNode {
+	n := e.Nodes[ref]
+	node := sdk.WorkflowNode{
+		Name:     ref,
+		Ref:      ref,
+		Pipeline: sdk.Pipeline{Name: n.Pipeline},
+		Matrix:   n.Matrix,
+		Context: &sdk.WorkflowNodeContext{
+			DefaultPayload: jsonCompatible(n.Payload),
+		},
+	}
+	if n.Workflow != "" {
+		// The reference has been checked with the dependencies
+		node.SubWorkflow, _ = sdk.ParseWorkflowNodeSubWorkflow(n.Workflow)
+	}
+	if n.Application != "" {
+		node.Context.Application = &sdk.Application{Name: n.Application}
+	}
+	if n.Environment != "" {
+		node.Context.Environment = &sdk.Environment{Name: n.Environment}
+	}
+
+	names := make([]string, 0, len(n.Parameters))
+	for name := range n.Parameters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		node.Context.DefaultPipelineParameters = append(node.Context.DefaultPipelineParameters, sdk.Parameter{
+			Name:  name,
+			Type:  sdk.StringParameter,
+			Value: n.Parameters[name],
+		})
+	}
+
+	for _, h := range n.Hooks {
+		node.Hooks = append(node.Hooks, sdk.WorkflowNodeHook{
+			WorkflowHookModel: sdk.WorkflowHookModel{Name: h.Model},
+			Config:            h.Config,
+			Conditions:        workflowConditions(h.Conditions),
+		})
+	}
+
+	dests := children[ref]
+	sort.Strings(dests)
+	for _, d := range dests {
+		t := sdk.WorkflowNodeTrigger{
+			WorkflowDestNode: e.node(d, children),
+			Conditions:       workflowConditions(e.Nodes[d].Conditions),
+		}
+		if a := e.Nodes[d].Approval; a != nil {
+			t.Approval = &sdk.WorkflowTriggerApproval{
+				GroupName: a.Group,
+				Required:  a.Required,
+				Expiry:    a.Expiry,
+			}
+		}
+		node.Triggers = append(node.Triggers, t)
+	}
+	return node
+}
+
+// jsonCompatible converts the maps decoded from YAML, which can't be marshalled to JSON
+func jsonCompatible(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			m[fmt.Sprintf("%v", k)] = jsonCompatible(v)
+		}
+		return m
+	case map[string]interface{}:
+		for k, v := range t {
+			t[k] = jsonCompatible(v)
+		}
+		return t
+	case []interface{}:
+		for i := range t {
+			t[i] = jsonCompatible(t[i])
+		}
+		return t
+	}
+	return v
+}
@@ -0,0 +1,228 @@
+package exportentities
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+
+	"github.com/ovh/cds/sdk"
+)
+
+func testWorkflow() *sdk.Workflow {
+	conditions := []sdk.WorkflowTriggerCondition{
+		{Variable: "git.branch", Operator: "=", Value: "master"},
+		{Expression: `version >= 1.10 || git.author in ["bot", "admin"]`},
+	}
+	return &sdk.Workflow{
+		Name:        "my-workflow",
+		Description: "build and deploy",
+		Root: &sdk.WorkflowNode{
+			ID:       1,
+			Name:     "build",
+			Pipeline: sdk.Pipeline{Name: "build"},
+			Context: &sdk.WorkflowNodeContext{
+				Application:               &sdk.Application{Name: "my-app"},
+				DefaultPayload:            map[string]interface{}{"git.branch": "master"},
+				DefaultPipelineParameters: []sdk.Parameter{{Name: "image", Type: sdk.StringParameter, Value: "golang"}},
+			},
+			Hooks: []sdk.WorkflowNodeHook{
+				{WorkflowHookModel: sdk.WorkflowHookModel{Name: "Scheduler"}, Config: sdk.WorkflowNodeHookConfig{"cron": "0 * * * *"}},
+			},
+			Triggers: []sdk.WorkflowNodeTrigger{
+				{
+					Approval: &sdk.WorkflowTriggerApproval{GroupID: 7, GroupName: "release-managers", Required: 2, Expiry: 3600},
+					WorkflowDestNode: sdk.WorkflowNode{
+						ID:       2,
+						Name:     "test",
+						Pipeline: sdk.Pipeline{Name: "test"},
+						Matrix:   sdk.WorkflowNodeMatrix{"go_version": {"1.8", "1.9"}, "os": {"debian", "alpine"}},
+						Context:  &sdk.WorkflowNodeContext{Application: &sdk.Application{Name: "my-app"}},
+					},
+				},
+				{
+					Conditions: conditions,
+					WorkflowDestNode: sdk.WorkflowNode{
+						ID:       3,
+						Name:     "lint",
+						Pipeline: sdk.Pipeline{Name: "test"},
+						Context:  &sdk.WorkflowNodeContext{Application: &sdk.Application{Name: "my-app"}},
+					},
+				},
+			},
+		},
+		Joins: []sdk.WorkflowNodeJoin{
+			{
+				SourceNodeIDs: []int64{3, 2},
+				Triggers: []sdk.WorkflowNodeJoinTrigger{
+					{
+						Conditions: conditions,
+						WorkflowDestNode: sdk.WorkflowNode{
+							ID:       4,
+							Name:     "deploy",
+							Pipeline: sdk.Pipeline{Name: "deploy"},
+							Context: &sdk.WorkflowNodeContext{
+								Application: &sdk.Application{Name: "my-app"},
+								Environment: &sdk.Environment{Name: "production"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestNewWorkflow(t *testing.T) {
+	e, err := NewWorkflow(testWorkflow())
+	assert.NoError(t, err)
+	assert.Equal(t, "build", e.Root)
+	assert.Len(t, e.Nodes, 4)
+	assert.Equal(t, "golang", e.Nodes["build"].Parameters["image"])
+	assert.Equal(t, "Scheduler", e.Nodes["build"].Hooks[0].Model)
+	assert.Equal(t, []string{"build"}, e.Nodes["lint"].DependsOn)
+	assert.Equal(t, "master", e.Nodes["lint"].Conditions[0].Value)
+	assert.Equal(t, []string{"lint", "test"}, e.Nodes["deploy"].DependsOn)
+	assert.Equal(t, "production", e.Nodes["deploy"].Environment)
+}
+
+func TestWorkflowRoundTrip(t *testing.T) {
+	e, err := NewWorkflow(testWorkflow())
+	assert.NoError(t, err)
+
+	unmarshallers := map[Format]func([]byte, interface{}) error{
+		FormatJSON: json.Unmarshal,
+		FormatYAML: yaml.Unmarshal,
+	}
+	for f, unmarshal := range unmarshallers {
+		btes, err := Marshal(e, f)
+		assert.NoError(t, err)
+
+		e1 := &Workflow{}
+		assert.NoError(t, unmarshal(btes, e1))
+
+		w, err := e1.Workflow()
+		assert.NoError(t, err)
+		assert.Equal(t, "my-workflow", w.Name)
+		assert.Equal(t, "build and deploy", w.Description)
+
+		root := w.Root
+		assert.Equal(t, "build", root.Ref)
+		assert.Equal(t, "my-app", root.Context.Application.Name)
+		assert.Equal(t, map[string]interface{}{"git.branch": "master"}, root.Context.DefaultPayload)
+		assert.Equal(t, []sdk.Parameter{{Name: "image", Type: sdk.StringParameter, Value: "golang"}}, root.Context.DefaultPipelineParameters)
+		assert.Equal(t, sdk.WorkflowNodeHookConfig{"cron": "0 * * * *"}, root.Hooks[0].Config)
+
+		assert.Len(t, root.Triggers, 2)
+		assert.Equal(t, "lint", root.Triggers[0].WorkflowDestNode.Ref)
+		assert.Equal(t, "test", root.Triggers[0].WorkflowDestNode.Pipeline.Name)
+		assert.Equal(t, "master", root.Triggers[0].Conditions[0].Value)
+		assert.Equal(t, "test", root.Triggers[1].WorkflowDestNode.Ref)
+		assert.Empty(t, root.Triggers[1].Conditions)
+		assert.Equal(t, sdk.WorkflowNodeMatrix{"go_version": {"1.8", "1.9"}, "os": {"debian", "alpine"}}, root.Triggers[1].WorkflowDestNode.Matrix)
+		assert.Empty(t, root.Triggers[0].WorkflowDestNode.Matrix)
+		assert.Nil(t, root.Triggers[0].Approval)
+		assert.Equal(t, &sdk.WorkflowTriggerApproval{GroupName: "release-managers", Required: 2, Expiry: 3600}, root.Triggers[1].Approval)
+
+		assert.Len(t, w.Joins, 1)
+		assert.Equal(t, []string{"lint", "test"}, w.Joins[0].SourceNodeRefs)
+		assert.Len(t, w.Joins[0].Triggers, 1)
+		deploy := w.Joins[0].Triggers[0]
+		assert.Equal(t, "deploy", deploy.WorkflowDestNode.Ref)
+		assert.Equal(t, "production", deploy.WorkflowDestNode.Context.Environment.Name)
+		assert.Equal(t, "=", deploy.Conditions[0].Operator)
+		assert.Equal(t, `version >= 1.10 || git.author in ["bot", "admin"]`, deploy.Conditions[1].Expression)
+
+		// Exporting the imported workflow gives the same result
+		e2, err := NewWorkflow(w)
+		assert.NoError(t, err)
+		btes2, err := Marshal(e2, f)
+		assert.NoError(t, err)
+		assert.Equal(t, string(btes), string(btes2))
+	}
+}
+
+func TestWorkflowDuplicateNames(t *testing.T) {
+	w := testWorkflow()
+	w.Root.Triggers[1].WorkflowDestNode.Name = "test"
+	w.Joins = nil
+
+	e, err := NewWorkflow(w)
+	assert.NoError(t, err)
+	assert.Contains(t, e.Nodes, "test")
+	assert.Contains(t, e.Nodes, "test_2")
+}
+
+func TestWorkflowInvalidDependencies(t *testing.T) {
+	tests := map[string]Workflow{
+		"no root": {
+			Nodes: map[string]WorkflowNode{"build": {Pipeline: "build"}},
+		},
+		"root with dependency": {
+			Root:  "build",
+			Nodes: map[string]WorkflowNode{"build": {Pipeline: "build", DependsOn: []string{"test"}}, "test": {Pipeline: "test"}},
+		},
+		"unknown dependency": {
+			Root:  "build",
+			Nodes: map[string]WorkflowNode{"build": {Pipeline: "build"}, "test": {Pipeline: "test", DependsOn: []string{"lint"}}},
+		},
+		"orphan node": {
+			Root:  "build",
+			Nodes: map[string]WorkflowNode{"build": {Pipeline: "build"}, "test": {Pipeline: "test"}},
+		},
+		"cycle": {
+			Root: "build",
+			Nodes: map[string]WorkflowNode{
+				"build":  {Pipeline: "build"},
+				"test":   {Pipeline: "test", DependsOn: []string{"build", "deploy"}},
+				"deploy": {Pipeline: "deploy", DependsOn: []string{"test"}},
+			},
+		},
+		"approval on a join": {
+			Root: "build",
+			Nodes: map[string]WorkflowNode{
+				"build":  {Pipeline: "build"},
+				"test":   {Pipeline: "test", DependsOn: []string{"build"}},
+				"deploy": {Pipeline: "deploy", DependsOn: []string{"build", "test"}, Approval: &WorkflowApproval{Group: "ops", Required: 1}},
+			},
+		},
+		"pipeline and workflow": {
+			Root:  "build",
+			Nodes: map[string]WorkflowNode{"build": {Pipeline: "build", Workflow: "PLATFORM/deploy"}},
+		},
+		"invalid workflow": {
+			Root:  "build",
+			Nodes: map[string]WorkflowNode{"build": {Pipeline: "build"}, "deploy": {Workflow: "deploy", DependsOn: []string{"build"}}},
+		},
+	}
+	for name, e := range tests {
+		_, err := e.Workflow()
+		assert.Error(t, err, name)
+	}
+}
+
+func TestWorkflowSubWorkflow(t *testing.T) {
+	w := testWorkflow()
+	w.Joins[0].Triggers[0].WorkflowDestNode = sdk.WorkflowNode{
+		ID:          4,
+		SubWorkflow: &sdk.WorkflowNodeSubWorkflow{WorkflowID: 12, ProjectKey: "PLATFORM", WorkflowName: "deploy"},
+		Context: &sdk.WorkflowNodeContext{
+			DefaultPipelineParameters: []sdk.Parameter{{Name: "target", Type: sdk.StringParameter, Value: "production"}},
+		},
+	}
+
+	e, err := NewWorkflow(w)
+	assert.NoError(t, err)
+	deployEntry := e.Nodes["deploy"]
+	assert.Equal(t, "PLATFORM/deploy", deployEntry.Workflow)
+	assert.Empty(t, deployEntry.Pipeline)
+	assert.Equal(t, map[string]string{"target": "production"}, deployEntry.Parameters)
+	assert.Equal(t, []string{"lint", "test"}, deployEntry.DependsOn)
+
+	w1, err := e.Workflow()
+	assert.NoError(t, err)
+	deploy := w1.Joins[0].Triggers[0].WorkflowDestNode
+	assert.Equal(t, &sdk.WorkflowNodeSubWorkflow{ProjectKey: "PLATFORM", WorkflowName: "deploy"}, deploy.SubWorkflow)
+	assert.Empty(t, deploy.Pipeline.Name)
+}
@@ -14,7 +14,7 @@ import (
 type Interface interface {
 	Init() error
 	KillWorker(worker sdk.Worker) error
-	SpawnWorker(model *sdk.Model, job *sdk.PipelineBuildJob, registerOnly bool, logInfo string) (string, error)
+	SpawnWorker(model *sdk.Model, job *sdk.PipelineBuildJob, isWorkflowJob bool, registerOnly bool, logInfo string) (string, error)
 	CanSpawn(model *sdk.Model, job *sdk.PipelineBuildJob) bool
 	WorkersStartedByModel(model *sdk.Model) int
 	WorkersStarted() int
@@ -29,6 +29,15 @@ var (
 	Client sdk.HTTPClient
 )
 
+// BookedJobEnv returns the environment variable giving to a worker the job it was spawned for. Pipeline build
+// jobs and workflow jobs have their own IDs, so they have their own variable
+func BookedJobEnv(isWorkflowJob bool) string {
+	if isWorkflowJob {
+		return "CDS_BOOKED_WORKFLOW_JOB_ID"
+	}
+	return "CDS_BOOKED_JOB_ID"
+}
+
 // CheckRequirement checks binary requirement in path
 func CheckRequirement(r sdk.Requirement) (bool, error) {
 	switch r.Type {
@@ -43,7 +52,7 @@ func CheckRequirement(r sdk.Requirement) (bool, error) {
 	}
 }
 
-func routine(h Interface, maxWorkers, provision int, hostname string, timestamp int64, lastSpawnedIDs []int64, warningSeconds, criticalSeconds, graceSeconds int) ([]int64, error) {
+func routine(h Interface, maxWorkers, provision int, hostname string, timestamp int64, lastSpawnedIDs []string, warningSeconds, criticalSeconds, graceSeconds int) ([]string, error) {
 	defer logTime(fmt.Sprintf("routine> %d", timestamp), time.Now(), warningSeconds, criticalSeconds)
 	log.Debug("routine> %d enter", timestamp)
 
@@ -59,10 +68,9 @@ func routine(h Interface, maxWorkers, provision int, hostname string, timestamp
 	}
 	log.Debug("routine> %d - workers already started:%d", timestamp, workersStarted)
 
-	jobs, errbq := sdk.GetBuildQueue()
-	if errbq != nil {
-		log.Error("routine> %d error on GetBuildQueue:%e", timestamp, errbq)
-		return nil, errbq
+	jobs, errq := getQueue(timestamp)
+	if errq != nil {
+		return nil, errq
 	}
 
 	if len(jobs) == 0 {
@@ -82,7 +90,7 @@ func routine(h Interface, maxWorkers, provision int, hostname string, timestamp
 	}
 	log.Debug("routine> %d - models received: %d", timestamp, len(models))
 
-	spawnedIDs := []int64{}
+	spawnedIDs := []string{}
 	wg := &sync.WaitGroup{}
 
 	nToRun := len(jobs)
@@ -94,12 +102,14 @@ func routine(h Interface, maxWorkers, provision int, hostname string, timestamp
 		log.Debug("routine> %d - work only on %d jobs from queue. queue size:%d workersStarted:%d maxWorkers:%d", timestamp, nToRun, len(jobs), workersStarted, maxWorkers)
 	}
 
+	mutex := &sync.Mutex{}
 	for i := range jobs[:nToRun] {
 		wg.Add(1)
-		go func(job *sdk.PipelineBuildJob) {
+		go func(q *queuedJob) {
+			job := &q.job
 			defer logTime(fmt.Sprintf("routine> %d - job %d>", timestamp, job.ID), time.Now(), warningSeconds, criticalSeconds)
 
-			if sdk.IsInArray(job.ID, lastSpawnedIDs) {
+			if sdk.IsInStringArray(q.key(), lastSpawnedIDs) {
 				log.Debug("routine> %d - job %d already spawned in previous routine", timestamp, job.ID)
 				wg.Done()
 				return
@@ -124,7 +134,7 @@ func routine(h Interface, maxWorkers, provision int, hostname string, timestamp
 
 			for _, model := range models {
 				if canRunJob(h, timestamp, job, &model, hostname) {
-					if err := sdk.BookPipelineBuildJob(job.ID); err != nil {
+					if err := q.book(); err != nil {
 						// perhaps already booked by another hatchery
 						log.Debug("routine> %d - cannot book job %d %s: %s", timestamp, job.ID, model.Name, err)
 						break // go to next job
@@ -138,19 +148,21 @@ func routine(h Interface, maxWorkers, provision int, hostname string, timestamp
 							Message:    sdk.SpawnMsg{ID: sdk.MsgSpawnInfoHatcheryStarts.ID, Args: []interface{}{fmt.Sprintf("%s", h.Hatchery().Name), fmt.Sprintf("%d", h.Hatchery().ID), model.Name}},
 						},
 					}
-					workerName, err := h.SpawnWorker(&model, job, false, "spawn for job")
+					workerName, err := h.SpawnWorker(&model, job, q.workflow, false, "spawn for job")
 					if err != nil {
 						log.Warning("routine> %d - cannot spawn worker %s for job %d: %s", timestamp, model.Name, job.ID, err)
 						infos = append(infos, sdk.SpawnInfo{
 							RemoteTime: time.Now(),
 							Message:    sdk.SpawnMsg{ID: sdk.MsgSpawnInfoHatcheryErrorSpawn.ID, Args: []interface{}{fmt.Sprintf("%s", h.Hatchery().Name), fmt.Sprintf("%d", h.Hatchery().ID), model.Name, sdk.Round(time.Since(start), time.Second).String(), err.Error()}},
 						})
-						if err := sdk.AddSpawnInfosPipelineBuildJob(job.ID, infos); err != nil {
-							log.Warning("routine> %d - cannot record AddSpawnInfosPipelineBuildJob for job (err spawn)%d: %s", timestamp, job.ID, err)
+						if err := q.addSpawnInfos(infos); err != nil {
+							log.Warning("routine> %d - cannot record spawn infos for job (err spawn)%d: %s", timestamp, job.ID, err)
 						}
 						continue // try another model
 					}
-					spawnedIDs = append(spawnedIDs, job.ID)
+					mutex.Lock()
+					spawnedIDs = append(spawnedIDs, q.key())
+					mutex.Unlock()
 
 					infos = append(infos, sdk.SpawnInfo{
 						RemoteTime: time.Now(),
@@ -163,8 +175,8 @@ func routine(h Interface, maxWorkers, provision int, hostname string, timestamp
 						},
 					})
 
-					if err := sdk.AddSpawnInfosPipelineBuildJob(job.ID, infos); err != nil {
-						log.Warning("routine> %d - cannot record AddSpawnInfosPipelineBuildJob for job %d: %s", timestamp, job.ID, err)
+					if err := q.addSpawnInfos(infos); err != nil {
+						log.Warning("routine> %d - cannot record spawn infos for job %d: %s", timestamp, job.ID, err)
 					}
 					break // ok for this job
 				}
@@ -178,6 +190,37 @@ func routine(h Interface, maxWorkers, provision int, hostname string, timestamp
 	return spawnedIDs, nil
 }
 
+// getQueue returns the jobs of the pipeline build queue and of the workflow queue, ordered by priority
+func getQueue(timestamp int64) ([]queuedJob, error) {
+	pipelineJobs, errbq := sdk.GetBuildQueue()
+	if errbq != nil {
+		log.Error("routine> %d error on GetBuildQueue:%e", timestamp, errbq)
+		return nil, errbq
+	}
+
+	workflowJobs, errwq := sdk.GetWorkflowJobQueue()
+	if errwq != nil {
+		log.Error("routine> %d error on GetWorkflowJobQueue:%e", timestamp, errwq)
+		return nil, errwq
+	}
+
+	jobs := make([]queuedJob, 0, len(pipelineJobs)+len(workflowJobs))
+	for _, j := range pipelineJobs {
+		jobs = append(jobs, newPipelineQueuedJob(j))
+	}
+	for _, j := range workflowJobs {
+		// Jobs held by a queue quota can't be taken, don't spawn workers for them
+		if j.Quota != nil {
+			log.Debug("routine> %d - job %d is waiting: %s", timestamp, j.ID, j.Quota)
+			continue
+		}
+		jobs = append(jobs, newWorkflowQueuedJob(j))
+	}
+
+	Priority.sort(jobs)
+	return jobs, nil
+}
+
 func provisioning(h Interface, provision int) {
 	if provision == 0 {
 		log.Debug("provisioning> no provisioning to do")
@@ -195,7 +238,7 @@ func provisioning(h Interface, provision int) {
 			existing := h.WorkersStartedByModel(&models[k])
 			for i := existing; i < provision; i++ {
 				go func(m sdk.Model) {
-					if name, err := h.SpawnWorker(&m, nil, false, "spawn for provision"); err != nil {
+					if name, err := h.SpawnWorker(&m, nil, false, false, "spawn for provision"); err != nil {
 						log.Warning("provisioning> cannot spawn worker %s with model %s for provisioning: %s", name, m.Name, err)
 					}
 				}(models[k])
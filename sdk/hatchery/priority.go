@@ -0,0 +1,124 @@
+package hatchery
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ovh/cds/sdk"
+)
+
+// PriorityConfig configures the order in which the hatchery works on the queued jobs.
+// The score of a job is the weight of its project, plus the weight of its trigger, plus AgeWeight for each minute spent in queue.
+// Jobs with the highest score are spawned first
+type PriorityConfig struct {
+	ProjectWeights map[string]int
+	ManualWeight   int
+	HookWeight     int
+	AgeWeight      int
+}
+
+// Priority is the configuration used to order the queue. By default, the oldest jobs are spawned first
+var Priority = PriorityConfig{AgeWeight: 1}
+
+// ParseProjectWeights parses project weights formatted as KEY1=10,KEY2=-5
+func ParseProjectWeights(s string) (map[string]int, error) {
+	weights := map[string]int{}
+	for _, kv := range strings.Split(s, ",") {
+		kv = strings.TrimSpace(kv)
+		if kv == "" {
+			continue
+		}
+		tuple := strings.SplitN(kv, "=", 2)
+		if len(tuple) != 2 {
+			return nil, fmt.Errorf("invalid project weight %s, expected KEY=weight", kv)
+		}
+		w, err := strconv.Atoi(tuple[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid project weight %s: %s", kv, err)
+		}
+		weights[tuple[0]] = w
+	}
+	return weights, nil
+}
+
+// queuedJob is a job of the pipeline build queue or of the workflow queue
+type queuedJob struct {
+	job        sdk.PipelineBuildJob
+	workflow   bool
+	projectKey string
+	trigger    string
+}
+
+func newPipelineQueuedJob(j sdk.PipelineBuildJob) queuedJob {
+	return queuedJob{
+		job:        j,
+		projectKey: sdk.ParameterValue(j.Parameters, "cds.project"),
+	}
+}
+
+// newWorkflowQueuedJob converts the workflow job, hatcheries only read the ID and the requirements of the job
+func newWorkflowQueuedJob(j sdk.WorkflowNodeJobRun) queuedJob {
+	return queuedJob{
+		job: sdk.PipelineBuildJob{
+			ID:            j.ID,
+			Job:           j.Job,
+			Parameters:    j.Parameters,
+			Status:        j.Status,
+			Queued:        j.Queued,
+			QueuedSeconds: j.QueuedSeconds,
+			Model:         j.Model,
+			BookedBy:      j.BookedBy,
+			SpawnInfos:    j.SpawnInfos,
+		},
+		workflow:   true,
+		projectKey: j.ProjectKey,
+		trigger:    j.Trigger,
+	}
+}
+
+// key identifies the job in both queues
+func (q *queuedJob) key() string {
+	if q.workflow {
+		return fmt.Sprintf("workflow-%d", q.job.ID)
+	}
+	return fmt.Sprintf("pipeline-%d", q.job.ID)
+}
+
+func (q *queuedJob) book() error {
+	if q.workflow {
+		return sdk.BookWorkflowJob(q.job.ID)
+	}
+	return sdk.BookPipelineBuildJob(q.job.ID)
+}
+
+func (q *queuedJob) addSpawnInfos(infos []sdk.SpawnInfo) error {
+	if q.workflow {
+		return sdk.AddSpawnInfosWorkflowJob(q.job.ID, infos)
+	}
+	return sdk.AddSpawnInfosPipelineBuildJob(q.job.ID, infos)
+}
+
+// score returns the priority of the job
+func (c PriorityConfig) score(q *queuedJob) int64 {
+	s := int64(c.ProjectWeights[q.projectKey])
+	switch q.trigger {
+	case sdk.WorkflowJobTriggerManual:
+		s += int64(c.ManualWeight)
+	case sdk.WorkflowJobTriggerHook:
+		s += int64(c.HookWeight)
+	}
+	return s + int64(c.AgeWeight)*q.job.QueuedSeconds/60
+}
+
+// sort orders the jobs by descending score. Jobs with the same score are ordered by age
+func (c PriorityConfig) sort(jobs []queuedJob) {
+	sort.SliceStable(jobs, func(i, j int) bool {
+		si, sj := c.score(&jobs[i]), c.score(&jobs[j])
+		if si != sj {
+			return si > sj
+		}
+		return jobs[i].job.QueuedSeconds > jobs[j].job.QueuedSeconds
+	})
+}
@@ -0,0 +1,51 @@
+package hatchery
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/ovh/cds/sdk"
+)
+
+func TestParseProjectWeights(t *testing.T) {
+	w, err := ParseProjectWeights("KEY1=100, KEY2=-5")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"KEY1": 100, "KEY2": -5}, w)
+
+	w, err = ParseProjectWeights("")
+	assert.NoError(t, err)
+	assert.Empty(t, w)
+
+	_, err = ParseProjectWeights("KEY1")
+	assert.Error(t, err)
+	_, err = ParseProjectWeights("KEY1=high")
+	assert.Error(t, err)
+}
+
+func TestPrioritySort(t *testing.T) {
+	jobs := []queuedJob{
+		newWorkflowQueuedJob(sdk.WorkflowNodeJobRun{ID: 1, ProjectKey: "FLOOD", Trigger: sdk.WorkflowJobTriggerHook, QueuedSeconds: 600}),
+		newWorkflowQueuedJob(sdk.WorkflowNodeJobRun{ID: 2, ProjectKey: "FLOOD", Trigger: sdk.WorkflowJobTriggerHook, QueuedSeconds: 300}),
+		newWorkflowQueuedJob(sdk.WorkflowNodeJobRun{ID: 3, ProjectKey: "RELEASE", Trigger: sdk.WorkflowJobTriggerManual, QueuedSeconds: 60}),
+		newPipelineQueuedJob(sdk.PipelineBuildJob{ID: 3, QueuedSeconds: 120, Parameters: []sdk.Parameter{{Name: "cds.project", Value: "OTHER"}}}),
+	}
+
+	// By default, the oldest jobs first
+	Priority.sort(jobs)
+	ids := []string{}
+	for _, j := range jobs {
+		ids = append(ids, j.key())
+	}
+	assert.Equal(t, []string{"workflow-1", "workflow-2", "pipeline-3", "workflow-3"}, ids)
+
+	// Manual jobs first, then project weights
+	c := PriorityConfig{ManualWeight: 100, AgeWeight: 1, ProjectWeights: map[string]int{"OTHER": 50}}
+	c.sort(jobs)
+	ids = []string{}
+	for _, j := range jobs {
+		ids = append(ids, j.key())
+	}
+	assert.Equal(t, []string{"workflow-3", "pipeline-3", "workflow-1", "workflow-2"}, ids)
+	assert.Equal(t, "OTHER", jobs[1].projectKey)
+}
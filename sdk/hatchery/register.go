@@ -42,7 +42,7 @@ func Create(h Interface, api, token string, maxWorkers, provision int, requestSe
 
 	go hearbeat(h, token, maxFailures)
 
-	var spawnIds []int64
+	var spawnIds []string
 	var errR error
 
 	tickerRoutine := time.NewTicker(2 * time.Second).C
@@ -173,7 +173,7 @@ func workerRegister(h Interface) error {
 		}
 		if h.NeedRegistration(&m) {
 			log.Info("workerRegister> spawn a worker for register worker model %s (%d)", m.Name, m.ID)
-			if _, err := h.SpawnWorker(&m, nil, true, "spawn for register"); err != nil {
+			if _, err := h.SpawnWorker(&m, nil, false, true, "spawn for register"); err != nil {
 				log.Warning("workerRegister> cannot spawn worker for register: %s", m.Name, err)
 			}
 			nRegistered++
@@ -57,9 +57,18 @@ var (
 	MsgSpawnInfoJobTaken                   = &Message{"MsgSpawnInfoJobTaken", trad{FR: "Le job a été pris par le worker %s", EN: "Job was taken by worker %s"}, nil}
 	MsgSpawnInfoWorkerForJob               = &Message{"MsgSpawnInfoWorkerForJob", trad{FR: "Ce worker %s a été créé pour lancer ce job", EN: "This worker %s was created to take this action"}, nil}
 	MsgSpawnInfoWorkerForJobError          = &Message{"MsgSpawnInfoWorkerForJobError", trad{FR: "Ce worker %s a été créé pour lancer ce job, mais ne possède pas tous les pré-requis. Vérifiez que les prérequis suivants:%s", EN: "This worker %s was created to take this action, but does not have all prerequisites. Please verify the following prerequisites:%s"}, nil}
+	MsgSpawnInfoJobInQueueQuota            = &Message{"MsgSpawnInfoJobInQueueQuota", trad{FR: "Le job est en attente : %s", EN: "Job is waiting: %s"}, nil}
 	MsgSpawnInfoJobError                   = &Message{"MsgSpawnInfoJobError", trad{FR: "Impossible de lancer ce job : %s", EN: "Unable to run this job: %s"}, nil}
 	MsgWorkflowStarting                    = &Message{"MsgWorkflowStarting", trad{FR: "Le workflow %s#%s a été démarré", EN: "Workflow %s#%s has been started"}, nil}
 	MsgWorkflowError                       = &Message{"MsgWorkflowError", trad{FR: "Une erreur est survenue: %v", EN: "An error has occured: %v"}, nil}
+	MsgWorkflowNodeStop                    = &Message{"MsgWorkflowNodeStop", trad{FR: "Le pipeline %s a été arrêté par %s", EN: "Pipeline %s has been stopped by %s"}, nil}
+	MsgWorkflowNodeRestart                 = &Message{"MsgWorkflowNodeRestart", trad{FR: "Les jobs en échec du pipeline %s ont été relancés par %s", EN: "Failed jobs of pipeline %s have been restarted by %s"}, nil}
+	MsgWorkflowApprovalWaiting             = &Message{"MsgWorkflowApprovalWaiting", trad{FR: "Le pipeline %s attend %d approbation(s) du groupe %s", EN: "Pipeline %s is waiting for %d approval(s) from group %s"}, nil}
+	MsgWorkflowApprovalVote                = &Message{"MsgWorkflowApprovalVote", trad{FR: "%s a approuvé le lancement du pipeline %s (%d/%d)", EN: "%s approved the start of pipeline %s (%d/%d)"}, nil}
+	MsgWorkflowApprovalRejected            = &Message{"MsgWorkflowApprovalRejected", trad{FR: "%s a refusé le lancement du pipeline %s", EN: "%s rejected the start of pipeline %s"}, nil}
+	MsgWorkflowApprovalExpired             = &Message{"MsgWorkflowApprovalExpired", trad{FR: "L'approbation du lancement du pipeline %s a expiré", EN: "The approval to start pipeline %s has expired"}, nil}
+	MsgWorkflowSubWorkflowStarted          = &Message{"MsgWorkflowSubWorkflowStarted", trad{FR: "Le workflow %s/%s#%d a été démarré", EN: "Workflow %s/%s#%d has been started"}, nil}
+	MsgWorkflowSubWorkflowEnded            = &Message{"MsgWorkflowSubWorkflowEnded", trad{FR: "Le workflow %s/%s#%d est terminé : %s", EN: "Workflow %s/%s#%d is over: %s"}, nil}
 )
 
 // Messages contains all sdk Messages
@@ -102,7 +111,16 @@ var Messages = map[string]*Message{
 	MsgSpawnInfoJobTaken.ID:                   MsgSpawnInfoJobTaken,
 	MsgSpawnInfoWorkerForJob.ID:               MsgSpawnInfoWorkerForJob,
 	MsgSpawnInfoWorkerForJobError.ID:          MsgSpawnInfoWorkerForJobError,
+	MsgSpawnInfoJobInQueueQuota.ID:            MsgSpawnInfoJobInQueueQuota,
 	MsgWorkflowStarting.ID:                    MsgWorkflowStarting,
+	MsgWorkflowNodeStop.ID:                    MsgWorkflowNodeStop,
+	MsgWorkflowNodeRestart.ID:                 MsgWorkflowNodeRestart,
+	MsgWorkflowApprovalWaiting.ID:             MsgWorkflowApprovalWaiting,
+	MsgWorkflowApprovalVote.ID:                MsgWorkflowApprovalVote,
+	MsgWorkflowApprovalRejected.ID:            MsgWorkflowApprovalRejected,
+	MsgWorkflowApprovalExpired.ID:             MsgWorkflowApprovalExpired,
+	MsgWorkflowSubWorkflowStarted.ID:          MsgWorkflowSubWorkflowStarted,
+	MsgWorkflowSubWorkflowEnded.ID:            MsgWorkflowSubWorkflowEnded,
 }
 
 //Message represent a struc format translated messages
@@ -147,7 +147,8 @@ func ParametersFromPipelineParameters(pipParams []Parameter) map[string]string {
 func variablesToParameters(prefix string, variables []Variable) []Parameter {
 	res := []Parameter{}
 	for _, t := range variables {
-		if NeedPlaceholder(t.Type) {
+		// Secrets and vault variables are sent to the worker with the secrets of the job
+		if NeedPlaceholder(t.Type) || t.Type == VaultVariable {
 			continue
 		}
 		t.Name = prefix + "." + t.Name
@@ -38,6 +38,14 @@ type ProjectVariableAudit struct {
 	Author         string    `json:"author" yaml:"-" db:"author"`
 }
 
+// ProjectVault configures how the vault variables of a project are resolved: their paths are relative to Mount,
+// and they are read with a token restricted to Policy. Only CDS admins can set it
+type ProjectVault struct {
+	ProjectID int64  `json:"-" db:"project_id"`
+	Mount     string `json:"mount" db:"mount"`
+	Policy    string `json:"policy" db:"policy"`
+}
+
 // Metadata represents metadata
 type Metadata map[string]string
 
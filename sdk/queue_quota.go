@@ -0,0 +1,67 @@
+package sdk
+
+import "fmt"
+
+// Scopes of a QueueQuota
+const (
+	QueueQuotaScopeProject = "project"
+	QueueQuotaScopeGroup   = "group"
+)
+
+// QueueQuota limits the number of running and waiting workflow jobs of a project, or of the projects of a group.
+// A zero value means no limit
+type QueueQuota struct {
+	ID         int64  `json:"id" db:"id"`
+	GroupID    int64  `json:"group_id,omitempty" db:"-"`
+	GroupName  string `json:"group_name,omitempty" db:"-"`
+	ProjectID  int64  `json:"project_id,omitempty" db:"-"`
+	ProjectKey string `json:"project_key,omitempty" db:"-"`
+	MaxRunning int    `json:"max_running" db:"max_running"`
+	// MaxQueued is the maximum number of waiting jobs: the jobs added to a full queue fail
+	MaxQueued int `json:"max_queued" db:"max_queued"`
+	// Current state, loaded with the quota
+	Running int `json:"running" db:"-"`
+	Queued  int `json:"queued" db:"-"`
+}
+
+// Scope returns QueueQuotaScopeProject or QueueQuotaScopeGroup
+func (q *QueueQuota) Scope() string {
+	if q.ProjectID != 0 || q.ProjectKey != "" {
+		return QueueQuotaScopeProject
+	}
+	return QueueQuotaScopeGroup
+}
+
+// Name returns the project key or the group name of the quota
+func (q *QueueQuota) Name() string {
+	if q.Scope() == QueueQuotaScopeProject {
+		return q.ProjectKey
+	}
+	return q.GroupName
+}
+
+// IsValid checks that the quota has exactly one scope and positive limits
+func (q *QueueQuota) IsValid() error {
+	hasProject := q.ProjectID != 0 || q.ProjectKey != ""
+	hasGroup := q.GroupID != 0 || q.GroupName != ""
+	if hasProject == hasGroup {
+		return ErrWrongRequest
+	}
+	if q.MaxRunning < 0 || q.MaxQueued < 0 {
+		return ErrWrongRequest
+	}
+	return nil
+}
+
+// QueueQuotaStatus explains why a job is held in queue by a quota
+type QueueQuotaStatus struct {
+	QuotaID int64  `json:"quota_id"`
+	Scope   string `json:"scope"`
+	Name    string `json:"name"`
+	Reason  string `json:"reason"`
+}
+
+// String returns a human readable reason
+func (s QueueQuotaStatus) String() string {
+	return fmt.Sprintf("%s (quota of %s %s)", s.Reason, s.Scope, s.Name)
+}
@@ -0,0 +1,28 @@
+package sdk
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQueueQuotaIsValid(t *testing.T) {
+	assert.NoError(t, (&QueueQuota{ProjectKey: "KEY", MaxRunning: 10}).IsValid())
+	assert.NoError(t, (&QueueQuota{GroupName: "group", MaxQueued: 10}).IsValid())
+	assert.Error(t, (&QueueQuota{MaxRunning: 10}).IsValid())
+	assert.Error(t, (&QueueQuota{ProjectKey: "KEY", GroupName: "group"}).IsValid())
+	assert.Error(t, (&QueueQuota{ProjectKey: "KEY", MaxRunning: -1}).IsValid())
+}
+
+func TestQueueQuotaScope(t *testing.T) {
+	q := QueueQuota{ID: 1, ProjectKey: "KEY"}
+	assert.Equal(t, QueueQuotaScopeProject, q.Scope())
+	assert.Equal(t, "KEY", q.Name())
+
+	q = QueueQuota{ID: 2, GroupID: 3, GroupName: "group"}
+	assert.Equal(t, QueueQuotaScopeGroup, q.Scope())
+	assert.Equal(t, "group", q.Name())
+
+	s := QueueQuotaStatus{QuotaID: 2, Scope: q.Scope(), Name: q.Name(), Reason: "max running jobs reached"}
+	assert.Equal(t, "max running jobs reached (quota of group group)", s.String())
+}
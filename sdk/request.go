@@ -34,6 +34,8 @@ var (
 	RequestedWithValue = "X-CDS-SDK"
 	//SessionTokenHeader is user as HTTP header
 	SessionTokenHeader = "Session-Token"
+	// AccessTokenHeader is used as HTTP header for personal access tokens
+	AccessTokenHeader = "Access-Token"
 	// HTTP client
 	client HTTPClient
 	// current agent calling
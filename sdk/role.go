@@ -0,0 +1,138 @@
+package sdk
+
+// Named roles of a group on a project, an application, a pipeline or an environment. A role is a set of
+// capabilities; groups without role have the role matching their permission (see RoleFromPermission)
+const (
+	RoleViewer     = "viewer"
+	RoleRunner     = "runner"
+	RoleDeployer   = "deployer"
+	RoleMaintainer = "maintainer"
+	RoleOwner      = "owner"
+)
+
+// Capabilities granted by the roles
+const (
+	// CapabilityRead allows to read the resource
+	CapabilityRead = "read"
+	// CapabilityRun allows to run workflows and pipelines
+	CapabilityRun = "run"
+	// CapabilityDeploy allows to run pipelines on an environment
+	CapabilityDeploy = "deploy"
+	// CapabilityEdit allows to edit the resource, except its variables and its groups
+	CapabilityEdit = "edit"
+	// CapabilityEditVariables allows to edit the variables and the keys of the resource
+	CapabilityEditVariables = "edit_variables"
+	// CapabilityManage allows to manage the groups of the resource
+	CapabilityManage = "manage"
+)
+
+// Role is a named set of capabilities
+type Role struct {
+	Name         string   `json:"name"`
+	Capabilities []string `json:"capabilities"`
+	// Permission is the permission matching the role, for the APIs and the checks still using permissions
+	Permission int `json:"permission"`
+}
+
+// Roles are all the available roles, from the least to the most privileged
+var Roles = []Role{
+	{Name: RoleViewer, Capabilities: []string{CapabilityRead}, Permission: 4},
+	{Name: RoleRunner, Capabilities: []string{CapabilityRead, CapabilityRun}, Permission: 5},
+	{Name: RoleDeployer, Capabilities: []string{CapabilityRead, CapabilityRun, CapabilityDeploy}, Permission: 5},
+	{Name: RoleMaintainer, Capabilities: []string{CapabilityRead, CapabilityRun, CapabilityDeploy, CapabilityEdit}, Permission: 7},
+	{Name: RoleOwner, Capabilities: []string{CapabilityRead, CapabilityRun, CapabilityDeploy, CapabilityEdit, CapabilityEditVariables, CapabilityManage}, Permission: 7},
+}
+
+// GetRole returns the role named name, or nil
+func GetRole(name string) *Role {
+	for i := range Roles {
+		if Roles[i].Name == name {
+			return &Roles[i]
+		}
+	}
+	return nil
+}
+
+// RoleFromPermission returns the role of a group without named role: read (4) is viewer, read/execute (5) is
+// deployer and read/write/execute (7) is owner
+func RoleFromPermission(permission int) string {
+	switch {
+	case permission >= 7:
+		return RoleOwner
+	case permission >= 5:
+		return RoleDeployer
+	case permission >= 4:
+		return RoleViewer
+	}
+	return ""
+}
+
+// RoleHasCapability returns true if the role grants the capability
+func RoleHasCapability(role, capability string) bool {
+	r := GetRole(role)
+	if r == nil {
+		return false
+	}
+	for _, c := range r.Capabilities {
+		if c == capability {
+			return true
+		}
+	}
+	return false
+}
+
+// InheritedRole returns the role given on the project, the pipelines and the environments of an application
+// to a group added on the application with role: the group can read and run them, and deploy if role allows it
+func InheritedRole(role string) string {
+	switch {
+	case role == "":
+		return ""
+	case !RoleHasCapability(role, CapabilityRun):
+		return RoleViewer
+	case !RoleHasCapability(role, CapabilityDeploy):
+		return RoleRunner
+	}
+	return RoleDeployer
+}
+
+// effectiveRole returns the named role if set, else the role matching the permission
+func effectiveRole(role string, permission int) string {
+	if role != "" {
+		return role
+	}
+	return RoleFromPermission(permission)
+}
+
+// ApplyRole checks the role of the group permission and sets the matching permission. Without role,
+// the permission is kept
+func (gp *GroupPermission) ApplyRole() error {
+	if gp.Role == "" {
+		return nil
+	}
+	r := GetRole(gp.Role)
+	if r == nil {
+		return ErrInvalidRole
+	}
+	gp.Permission = r.Permission
+	return nil
+}
+
+// HasCapability returns true if the group has the capability on the project
+func (pg ProjectGroup) HasCapability(capability string) bool {
+	return RoleHasCapability(effectiveRole(pg.Role, pg.Permission), capability)
+}
+
+// HasCapability returns true if the group has the capability on the application
+func (ag ApplicationGroup) HasCapability(capability string) bool {
+	return RoleHasCapability(effectiveRole(ag.Role, ag.Permission), capability)
+}
+
+// HasCapability returns true if the group has the capability on the pipeline
+func (pg PipelineGroup) HasCapability(capability string) bool {
+	return RoleHasCapability(effectiveRole(pg.Role, pg.Permission), capability)
+}
+
+// HasCapability returns true if the group has the capability on the environment
+func (eg EnvironmentGroup) HasCapability(capability string) bool {
+	return RoleHasCapability(effectiveRole(eg.Role, eg.Permission), capability)
+}
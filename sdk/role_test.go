@@ -0,0 +1,89 @@
+package sdk
+
+import "testing"
+
+func TestRoleFromPermission(t *testing.T) {
+	tests := []struct {
+		permission int
+		want       string
+	}{
+		{permission: 0, want: ""},
+		{permission: 4, want: RoleViewer},
+		{permission: 5, want: RoleDeployer},
+		{permission: 7, want: RoleOwner},
+	}
+	for _, tt := range tests {
+		if got := RoleFromPermission(tt.permission); got != tt.want {
+			t.Errorf("RoleFromPermission(%d) = %q, want %q", tt.permission, got, tt.want)
+		}
+	}
+}
+
+func TestRoleHasCapability(t *testing.T) {
+	tests := []struct {
+		role       string
+		capability string
+		want       bool
+	}{
+		{role: RoleViewer, capability: CapabilityRead, want: true},
+		{role: RoleViewer, capability: CapabilityRun, want: false},
+		{role: RoleRunner, capability: CapabilityRun, want: true},
+		{role: RoleRunner, capability: CapabilityDeploy, want: false},
+		{role: RoleDeployer, capability: CapabilityDeploy, want: true},
+		{role: RoleMaintainer, capability: CapabilityEdit, want: true},
+		{role: RoleMaintainer, capability: CapabilityEditVariables, want: false},
+		{role: RoleMaintainer, capability: CapabilityManage, want: false},
+		{role: RoleOwner, capability: CapabilityManage, want: true},
+		{role: "unknown", capability: CapabilityRead, want: false},
+	}
+	for _, tt := range tests {
+		if got := RoleHasCapability(tt.role, tt.capability); got != tt.want {
+			t.Errorf("RoleHasCapability(%q, %q) = %v, want %v", tt.role, tt.capability, got, tt.want)
+		}
+	}
+}
+
+func TestGroupPermissionApplyRole(t *testing.T) {
+	gp := GroupPermission{Permission: 7, Role: RoleRunner}
+	if err := gp.ApplyRole(); err != nil {
+		t.Fatalf("ApplyRole() = %v", err)
+	}
+	if gp.Permission != 5 {
+		t.Errorf("runner permission = %d, want 5", gp.Permission)
+	}
+
+	gp = GroupPermission{Permission: 4}
+	if err := gp.ApplyRole(); err != nil || gp.Permission != 4 {
+		t.Errorf("ApplyRole() without role = %v, permission %d, want the permission kept", err, gp.Permission)
+	}
+
+	gp = GroupPermission{Role: "admin"}
+	if err := gp.ApplyRole(); err != ErrInvalidRole {
+		t.Errorf("ApplyRole() with unknown role = %v, want %v", err, ErrInvalidRole)
+	}
+}
+
+func TestInheritedRole(t *testing.T) {
+	tests := map[string]string{
+		"":             "",
+		RoleViewer:     RoleViewer,
+		RoleRunner:     RoleRunner,
+		RoleDeployer:   RoleDeployer,
+		RoleMaintainer: RoleDeployer,
+		RoleOwner:      RoleDeployer,
+	}
+	for role, want := range tests {
+		if got := InheritedRole(role); got != want {
+			t.Errorf("InheritedRole(%q) = %q, want %q", role, got, want)
+		}
+	}
+}
+
+func TestEnvironmentGroupHasCapability(t *testing.T) {
+	if !(EnvironmentGroup{Permission: 5}).HasCapability(CapabilityDeploy) {
+		t.Errorf("a group with read/execute permission and without role must deploy")
+	}
+	if (EnvironmentGroup{Permission: 5, Role: RoleRunner}).HasCapability(CapabilityDeploy) {
+		t.Errorf("a runner must not deploy")
+	}
+}
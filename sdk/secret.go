@@ -0,0 +1,16 @@
+package sdk
+
+import "time"
+
+// SecretKeyRotation is the progress of the re-encryption of the ciphered variables with the current cipher key
+type SecretKeyRotation struct {
+	KeyID     string    `json:"key_id"`
+	Running   bool      `json:"running"`
+	Total     int       `json:"total"`
+	Done      int       `json:"done"`
+	Rotated   int       `json:"rotated"`
+	Errors    int       `json:"errors"`
+	LastError string    `json:"last_error,omitempty"`
+	Started   time.Time `json:"started"`
+	Ended     time.Time `json:"ended,omitempty"`
+}
@@ -37,6 +37,30 @@ type UserAPIResponse struct {
 	Token    string `json:"token,omitempty"`
 }
 
+// UserOIDCAuthorization starts an OpenID Connect login from the UI: the user logs in on URL,
+// then the provider redirects to the UI with a code and the state
+type UserOIDCAuthorization struct {
+	URL   string `json:"url"`
+	State string `json:"state"`
+}
+
+// UserOIDCCallback ends an OpenID Connect login from the UI
+type UserOIDCCallback struct {
+	Code  string `json:"code"`
+	State string `json:"state"`
+}
+
+// UserOIDCDeviceAuthorization starts an OpenID Connect login from the CLI: the user logs in on the
+// verification URI with the user code, while the CLI polls the token of the device code
+type UserOIDCDeviceAuthorization struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete,omitempty"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval,omitempty"`
+}
+
 // UserEmailPattern  pattern for user email address
 const UserEmailPattern = "(\\w[-._\\w]*\\w@\\w[-._\\w]*\\w\\.\\w{2,3})"
 
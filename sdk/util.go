@@ -15,6 +15,16 @@ func IsInArray(elt int64, array []int64) bool {
 	return false
 }
 
+// IsInStringArray Check if the element is in the array
+func IsInStringArray(elt string, array []string) bool {
+	for _, item := range array {
+		if item == elt {
+			return true
+		}
+	}
+	return false
+}
+
 // RandomString generate random string containing a-zA-Z0-9
 func RandomString(strlen int) string {
 	rand.Seed(time.Now().UTC().UnixNano())
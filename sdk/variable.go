@@ -1,6 +1,9 @@
 package sdk
 
-import "time"
+import (
+	"strings"
+	"time"
+)
 
 // Variable represent a variable for a project or pipeline
 type Variable struct {
@@ -27,8 +30,12 @@ const (
 	BooleanVariable    = "boolean"
 	NumberVariable     = "number"
 	RepositoryVariable = "repository"
+	VaultVariable      = "vault"
 )
 
+// VaultVariableDefaultField is the field read in the vault secret of a vault variable without field
+const VaultVariableDefaultField = "data"
+
 var (
 	// AvailableVariableType list all existing variable type in CDS
 	AvailableVariableType = []string{
@@ -38,6 +45,7 @@ var (
 		KeyVariable,
 		BooleanVariable,
 		NumberVariable,
+		VaultVariable,
 	}
 )
 
@@ -51,6 +59,24 @@ func NeedPlaceholder(t string) bool {
 	}
 }
 
+// ParseVaultVariable splits the value of a vault variable, path[#field], in the path of the vault secret
+// relative to the vault mount of the project, and the field to read in the secret
+func ParseVaultVariable(value string) (string, string, error) {
+	path, field := value, VaultVariableDefaultField
+	if i := strings.LastIndex(value, "#"); i >= 0 {
+		path, field = value[:i], value[i+1:]
+	}
+	if path == "" || field == "" || strings.HasPrefix(path, "/") {
+		return "", "", ErrInvalidVaultVariable
+	}
+	for _, s := range strings.Split(path, "/") {
+		if s == "" || s == "." || s == ".." {
+			return "", "", ErrInvalidVaultVariable
+		}
+	}
+	return path, field, nil
+}
+
 // VariablerFind return a variable given its name if it exists in array
 func VariablerFind(vars []Variable, s string) *Variable {
 	for _, v := range vars {
@@ -0,0 +1,24 @@
+package sdk
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseVaultVariable(t *testing.T) {
+	path, field, err := ParseVaultVariable("prod/db")
+	assert.NoError(t, err)
+	assert.Equal(t, "prod/db", path)
+	assert.Equal(t, VaultVariableDefaultField, field)
+
+	path, field, err = ParseVaultVariable("prod/db#password")
+	assert.NoError(t, err)
+	assert.Equal(t, "prod/db", path)
+	assert.Equal(t, "password", field)
+
+	for _, v := range []string{"", "#password", "prod/db#", "/prod/db", "prod//db", "prod/../admin", "./prod"} {
+		_, _, err := ParseVaultVariable(v)
+		assert.Equal(t, ErrInvalidVaultVariable, err, v)
+	}
+}
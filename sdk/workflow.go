@@ -3,20 +3,23 @@ package sdk
 import (
 	"encoding/json"
 	"fmt"
+	"strings"
 	"time"
 )
 
 //Workflow represents a pipeline based workflow
 type Workflow struct {
-	ID           int64              `json:"id" db:"id" cli:"-"`
-	Name         string             `json:"name" db:"name" cli:"name,key"`
-	Description  string             `json:"description,omitempty" db:"description" cli:"description"`
-	LastModified time.Time          `json:"last_modified" db:"last_modified"`
-	ProjectID    int64              `json:"project_id,omitempty" db:"project_id" cli:"-"`
-	ProjectKey   string             `json:"project_key" db:"-" cli:"-"`
-	RootID       int64              `json:"root_id,omitempty" db:"root_node_id" cli:"-"`
-	Root         *WorkflowNode      `json:"root" db:"-" cli:"-"`
-	Joins        []WorkflowNodeJoin `json:"joins,omitempty" db:"-" cli:"-"`
+	ID             int64              `json:"id" db:"id" cli:"-"`
+	Name           string             `json:"name" db:"name" cli:"name,key"`
+	Description    string             `json:"description,omitempty" db:"description" cli:"description"`
+	LastModified   time.Time          `json:"last_modified" db:"last_modified"`
+	ProjectID      int64              `json:"project_id,omitempty" db:"project_id" cli:"-"`
+	ProjectKey     string             `json:"project_key" db:"-" cli:"-"`
+	RootID         int64              `json:"root_id,omitempty" db:"root_node_id" cli:"-"`
+	Root           *WorkflowNode      `json:"root" db:"-" cli:"-"`
+	Joins          []WorkflowNodeJoin `json:"joins,omitempty" db:"-" cli:"-"`
+	Version        int64              `json:"version" db:"version" cli:"version"`
+	VersionComment string             `json:"version_comment,omitempty" db:"-" cli:"-"`
 }
 
 //JoinsID returns joins ID
@@ -181,24 +184,54 @@ type WorkflowNodeJoinTrigger struct {
 
 //WorkflowNode represents a node in w workflow tree
 type WorkflowNode struct {
-	ID               int64                 `json:"id" db:"id"`
-	Name             string                `json:"name" db:"name"`
-	Ref              string                `json:"ref,omitempty" db:"-"`
-	WorkflowID       int64                 `json:"workflow_id" db:"workflow_id"`
-	PipelineID       int64                 `json:"pipeline_id" db:"pipeline_id"`
-	Pipeline         Pipeline              `json:"pipeline" db:"-"`
-	Context          *WorkflowNodeContext  `json:"context" db:"-"`
-	TriggerSrcID     int64                 `json:"-" db:"-"`
-	TriggerJoinSrcID int64                 `json:"-" db:"-"`
-	Hooks            []WorkflowNodeHook    `json:"hooks,omitempty" db:"-"`
-	Triggers         []WorkflowNodeTrigger `json:"triggers,omitempty" db:"-"`
-}
-
-// EqualsTo returns true if a node has the same pipeline and context than another
+	ID               int64                    `json:"id" db:"id"`
+	Name             string                   `json:"name" db:"name"`
+	Ref              string                   `json:"ref,omitempty" db:"-"`
+	WorkflowID       int64                    `json:"workflow_id" db:"workflow_id"`
+	PipelineID       int64                    `json:"pipeline_id" db:"pipeline_id"`
+	Pipeline         Pipeline                 `json:"pipeline" db:"-"`
+	Context          *WorkflowNodeContext     `json:"context" db:"-"`
+	TriggerSrcID     int64                    `json:"-" db:"-"`
+	TriggerJoinSrcID int64                    `json:"-" db:"-"`
+	Hooks            []WorkflowNodeHook       `json:"hooks,omitempty" db:"-"`
+	Triggers         []WorkflowNodeTrigger    `json:"triggers,omitempty" db:"-"`
+	Matrix           WorkflowNodeMatrix       `json:"matrix,omitempty" db:"-"`
+	SubWorkflow      *WorkflowNodeSubWorkflow `json:"sub_workflow,omitempty" db:"-"`
+}
+
+// WorkflowNodeSubWorkflow is the workflow run by a node instead of a pipeline.
+// The workflow is set by its ID, or by its project key and its name
+type WorkflowNodeSubWorkflow struct {
+	WorkflowID   int64  `json:"workflow_id,omitempty"`
+	ProjectKey   string `json:"project_key"`
+	WorkflowName string `json:"workflow_name"`
+}
+
+// String returns the full name of the sub workflow: <project key>/<workflow name>
+func (s WorkflowNodeSubWorkflow) String() string {
+	return s.ProjectKey + "/" + s.WorkflowName
+}
+
+// ParseWorkflowNodeSubWorkflow parses the full name of a sub workflow: <project key>/<workflow name>
+func ParseWorkflowNodeSubWorkflow(s string) (*WorkflowNodeSubWorkflow, error) {
+	t := strings.SplitN(s, "/", 2)
+	if len(t) != 2 || t[0] == "" || t[1] == "" {
+		return nil, fmt.Errorf("invalid sub workflow %s, expected <project key>/<workflow name>", s)
+	}
+	return &WorkflowNodeSubWorkflow{ProjectKey: t[0], WorkflowName: t[1]}, nil
+}
+
+// EqualsTo returns true if a node has the same pipeline, or sub workflow, and context than another
 func (n *WorkflowNode) EqualsTo(n1 *WorkflowNode) bool {
 	if n.PipelineID != n1.PipelineID {
 		return false
 	}
+	if (n.SubWorkflow == nil) != (n1.SubWorkflow == nil) {
+		return false
+	}
+	if n.SubWorkflow != nil && n.SubWorkflow.WorkflowID != n1.SubWorkflow.WorkflowID {
+		return false
+	}
 	if n.Context == nil && n1.Context != nil {
 		return false
 	}
@@ -282,7 +315,7 @@ func (n *WorkflowNode) InvolvedApplications() []int64 {
 //InvolvedPipelines returns all pipelines used in the workflow
 func (n *WorkflowNode) InvolvedPipelines() []int64 {
 	res := []int64{}
-	if n.Context != nil {
+	if n.Context != nil && n.SubWorkflow == nil {
 		if n.PipelineID == 0 {
 			n.PipelineID = n.Pipeline.ID
 		}
@@ -296,7 +329,10 @@ func (n *WorkflowNode) InvolvedPipelines() []int64 {
 
 //GetPipelines returns all pipelines used in the workflow
 func (n *WorkflowNode) GetPipelines() []Pipeline {
-	res := []Pipeline{n.Pipeline}
+	res := []Pipeline{}
+	if n.SubWorkflow == nil {
+		res = append(res, n.Pipeline)
+	}
 	for _, t := range n.Triggers {
 		res = append(res, t.WorkflowDestNode.GetPipelines()...)
 	}
@@ -327,13 +363,16 @@ type WorkflowNodeTrigger struct {
 	WorkflowDestNodeID int64                      `json:"workflow_dest_node_id" db:"workflow_dest_node_id"`
 	WorkflowDestNode   WorkflowNode               `json:"workflow_dest_node" db:"-"`
 	Conditions         []WorkflowTriggerCondition `json:"conditions,omitempty" db:"-"`
+	Approval           *WorkflowTriggerApproval   `json:"approval,omitempty" db:"-"`
 }
 
-//WorkflowTriggerCondition represents a condition to trigger ot not a pipeline in a workflow. Operator can be =, !=, regex
+//WorkflowTriggerCondition represents a condition to trigger ot not a pipeline in a workflow. Operator can be =, !=, regex.
+//A condition with an Expression is a boolean expression over the parameters instead of a single comparison
 type WorkflowTriggerCondition struct {
-	Variable string `json:"variable"`
-	Operator string `json:"operator"`
-	Value    string `json:"value"`
+	Variable   string `json:"variable"`
+	Operator   string `json:"operator"`
+	Value      string `json:"value"`
+	Expression string `json:"expression,omitempty"`
 }
 
 //WorkflowNodeContext represents a context attached on a node
@@ -357,6 +396,7 @@ type WorkflowNodeHook struct {
 	WorkflowHookModel   WorkflowHookModel          `json:"model" db:"-"`
 	Conditions          []WorkflowTriggerCondition `json:"conditions,omitempty" db:"-"`
 	Config              WorkflowNodeHookConfig     `json:"config" db:"-"`
+	LastExecution       *time.Time                 `json:"last_execution,omitempty" db:"-"`
 }
 
 //WorkflowNodeHookConfig represents the configguration for a WorkflowNodeHook
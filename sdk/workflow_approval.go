@@ -0,0 +1,88 @@
+package sdk
+
+import (
+	"fmt"
+	"time"
+)
+
+// Workflow run approval statuses
+const (
+	ApprovalStatusWaiting  = "Waiting"
+	ApprovalStatusApproved = "Approved"
+	ApprovalStatusRejected = "Rejected"
+	ApprovalStatusExpired  = "Expired"
+)
+
+// WorkflowTriggerApproval makes a trigger wait for manual approvals from the members of a group before starting its destination node.
+// Expiry is in seconds, 0 means the approval never expires
+type WorkflowTriggerApproval struct {
+	GroupID   int64  `json:"group_id"`
+	GroupName string `json:"group_name"`
+	Required  int    `json:"required"`
+	Expiry    int64  `json:"expiry,omitempty"`
+}
+
+// IsValid checks the approval configuration of a trigger
+func (a *WorkflowTriggerApproval) IsValid() error {
+	if a.GroupID == 0 && a.GroupName == "" {
+		return fmt.Errorf("approval group is mandatory")
+	}
+	if a.Required < 1 {
+		return fmt.Errorf("at least one approval is required")
+	}
+	if a.Expiry < 0 {
+		return fmt.Errorf("invalid approval expiry %d", a.Expiry)
+	}
+	return nil
+}
+
+// WorkflowRunApproval is the approval awaited by a trigger to start its destination node in a workflow run
+type WorkflowRunApproval struct {
+	ID                    int64                     `json:"id" db:"id"`
+	WorkflowRunID         int64                     `json:"workflow_run_id" db:"workflow_run_id"`
+	WorkflowNodeTriggerID int64                     `json:"workflow_node_trigger_id" db:"workflow_node_trigger_id"`
+	WorkflowNodeRunID     int64                     `json:"workflow_node_run_id" db:"workflow_node_run_id"`
+	WorkflowDestNodeID    int64                     `json:"workflow_dest_node_id" db:"workflow_dest_node_id"`
+	SubNumber             int64                     `json:"subnumber" db:"sub_num"`
+	Status                string                    `json:"status" db:"status"`
+	GroupID               int64                     `json:"group_id" db:"group_id"`
+	GroupName             string                    `json:"group_name" db:"group_name"`
+	Required              int                       `json:"required" db:"required"`
+	Votes                 []WorkflowRunApprovalVote `json:"votes" db:"-"`
+	Created               time.Time                 `json:"created" db:"created"`
+	Expire                *time.Time                `json:"expire,omitempty" db:"-"`
+}
+
+// WorkflowRunApprovalVote is the approval or the rejection of a user
+type WorkflowRunApprovalVote struct {
+	User     User      `json:"user"`
+	Approved bool      `json:"approved"`
+	Comment  string    `json:"comment,omitempty"`
+	Date     time.Time `json:"date"`
+}
+
+// WorkflowRunApprovalRequest is the body of the approve and reject requests
+type WorkflowRunApprovalRequest struct {
+	Comment string `json:"comment,omitempty"`
+}
+
+// Approvals returns the number of approving votes
+func (a *WorkflowRunApproval) Approvals() int {
+	var n int
+	for _, v := range a.Votes {
+		if v.Approved {
+			n++
+		}
+	}
+	return n
+}
+
+// HasVoted returns true if the user has already approved or rejected
+func (a *WorkflowRunApproval) HasVoted(username string) bool {
+	for _, v := range a.Votes {
+		if v.User.Username == username {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,26 @@
+package sdk
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkflowTriggerApprovalIsValid(t *testing.T) {
+	assert.NoError(t, (&WorkflowTriggerApproval{GroupName: "ops", Required: 2, Expiry: 3600}).IsValid())
+	assert.NoError(t, (&WorkflowTriggerApproval{GroupID: 1, Required: 1}).IsValid())
+	assert.Error(t, (&WorkflowTriggerApproval{Required: 1}).IsValid())
+	assert.Error(t, (&WorkflowTriggerApproval{GroupName: "ops"}).IsValid())
+	assert.Error(t, (&WorkflowTriggerApproval{GroupName: "ops", Required: 1, Expiry: -1}).IsValid())
+}
+
+func TestWorkflowRunApprovalVotes(t *testing.T) {
+	a := &WorkflowRunApproval{Votes: []WorkflowRunApprovalVote{
+		{User: User{Username: "alice"}, Approved: true},
+		{User: User{Username: "bob"}, Approved: false},
+		{User: User{Username: "carol"}, Approved: true},
+	}}
+	assert.Equal(t, 2, a.Approvals())
+	assert.True(t, a.HasVoted("bob"))
+	assert.False(t, a.HasVoted("dave"))
+}
@@ -43,13 +43,27 @@ func WorkflowCheckConditions(conditions []WorkflowTriggerCondition, params []Par
 
 	var conditionsOK = true
 	for _, cond := range conditions {
+		if cond.Expression != "" {
+			n, err := parseConditionExpression(cond.Expression)
+			if err != nil {
+				return false, fmt.Errorf("Invalid condition expression %s (%v)", cond.Expression, err)
+			}
+			ok, err := n.eval(mapParams)
+			if err != nil {
+				return false, err
+			}
+			conditionsOK = conditionsOK && ok
+			continue
+		}
+
 		var err error
 		cond.Value, err = Interpolate(cond.Value, mapParams)
 		if err != nil {
 			return false, fmt.Errorf("Unable to interpolate %s (%v)", cond.Value, err)
 		}
 
-		switch cond.Operator {
+		op, _ := workflowConditionOperator(cond.Operator)
+		switch op {
 		case WorkflowConditionsOperatorEquals:
 			conditionsOK = conditionsOK && cond.Value == mapParams[cond.Variable]
 
@@ -57,16 +71,16 @@ func WorkflowCheckConditions(conditions []WorkflowTriggerCondition, params []Par
 			conditionsOK = conditionsOK && cond.Value != mapParams[cond.Variable]
 
 		case WorkflowConditionsOperatorLessThan:
-			conditionsOK = conditionsOK && strings.Compare(mapParams[cond.Variable], cond.Value) < 0
+			conditionsOK = conditionsOK && compareConditionValues(mapParams[cond.Variable], cond.Value) < 0
 
 		case WorkflowConditionsOperatorLessOrEqualThan:
-			conditionsOK = conditionsOK && strings.Compare(mapParams[cond.Variable], cond.Value) <= 0
+			conditionsOK = conditionsOK && compareConditionValues(mapParams[cond.Variable], cond.Value) <= 0
 
 		case WorkflowConditionsOperatorGreaterThan:
-			conditionsOK = conditionsOK && strings.Compare(mapParams[cond.Variable], cond.Value) > 0
+			conditionsOK = conditionsOK && compareConditionValues(mapParams[cond.Variable], cond.Value) > 0
 
 		case WorkflowConditionsOperatorGreaterOrEqualThan:
-			conditionsOK = conditionsOK && strings.Compare(mapParams[cond.Variable], cond.Value) >= 0
+			conditionsOK = conditionsOK && compareConditionValues(mapParams[cond.Variable], cond.Value) >= 0
 
 		case WorkflowConditionsOperatorRegex:
 			match, err := regexp.MatchString(cond.Value, mapParams[cond.Variable])
@@ -79,3 +93,46 @@ func WorkflowCheckConditions(conditions []WorkflowTriggerCondition, params []Par
 
 	return conditionsOK, nil
 }
+
+//IsValid checks the operator of a condition, or the syntax of its expression
+func (c WorkflowTriggerCondition) IsValid() error {
+	if c.Expression != "" {
+		if _, err := parseConditionExpression(c.Expression); err != nil {
+			return fmt.Errorf("invalid condition expression %s: %v", c.Expression, err)
+		}
+		return nil
+	}
+	if c.Variable == "" {
+		return fmt.Errorf("condition variable is mandatory")
+	}
+	op, ok := workflowConditionOperator(c.Operator)
+	if !ok {
+		return fmt.Errorf("unknown condition operator %s", c.Operator)
+	}
+	if op == WorkflowConditionsOperatorRegex && !strings.Contains(c.Value, "{{") {
+		if _, err := regexp.Compile(c.Value); err != nil {
+			return fmt.Errorf("invalid condition regex %s: %v", c.Value, err)
+		}
+	}
+	return nil
+}
+
+//WorkflowValidateConditions checks all the conditions of a trigger or a hook
+func WorkflowValidateConditions(conditions []WorkflowTriggerCondition) error {
+	for _, c := range conditions {
+		if err := c.IsValid(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//workflowConditionOperator returns the operator of a condition, which can be set by its name (eq) or its symbol (=)
+func workflowConditionOperator(op string) (string, bool) {
+	for name, symbol := range WorkflowConditionsOperators {
+		if op == name || op == symbol {
+			return name, true
+		}
+	}
+	return "", false
+}
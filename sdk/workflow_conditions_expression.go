@@ -0,0 +1,513 @@
+package sdk
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// A condition expression combines comparisons over the build parameters with and/or/not and parentheses:
+//
+//	git.branch in ["master", "develop"] && (version >= 1.10.0 || not cds.manual)
+//	git.branch glob "feat/*" or git.author =~ "^bot-"
+//
+// Bare words are parameters, except numbers, versions and true/false which are literals like quoted strings.
+// Versions, with three numbers, a v prefix or a pre-release, are compared as versions: 1.10.0 > 1.9.0, v1.10 > 1.9,
+// 1.0.0-rc1 < 1.0.0. Other numbers are compared as numbers, 1.5 > 1.25, and anything else alphabetically. A parameter alone is true unless it is empty, "false" or "0"
+
+type conditionTokenKind int
+
+const (
+	conditionTokenEOF conditionTokenKind = iota
+	conditionTokenWord
+	conditionTokenString
+	conditionTokenSymbol
+)
+
+type conditionToken struct {
+	kind  conditionTokenKind
+	value string
+	pos   int
+}
+
+func (t conditionToken) String() string {
+	switch t.kind {
+	case conditionTokenEOF:
+		return "end of expression"
+	case conditionTokenString:
+		return strconv.Quote(t.value)
+	}
+	return fmt.Sprintf("'%s' at position %d", t.value, t.pos)
+}
+
+// conditionSymbols are sorted to match the longest symbol first
+var conditionSymbols = []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "(", ")", "[", "]", ",", "!", "=", "<", ">"}
+
+func tokenizeConditionExpression(s string) ([]conditionToken, error) {
+	tokens := []conditionToken{}
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"' || c == '\'':
+			value, n, err := readConditionString(s[i:])
+			if err != nil {
+				return nil, fmt.Errorf("%s at position %d", err, i)
+			}
+			tokens = append(tokens, conditionToken{kind: conditionTokenString, value: value, pos: i})
+			i += n
+		default:
+			var symbol string
+			for _, sym := range conditionSymbols {
+				if strings.HasPrefix(s[i:], sym) {
+					symbol = sym
+					break
+				}
+			}
+			if symbol != "" {
+				tokens = append(tokens, conditionToken{kind: conditionTokenSymbol, value: symbol, pos: i})
+				i += len(symbol)
+				continue
+			}
+			j := i
+			for j < len(s) && !unicode.IsSpace(rune(s[j])) && !strings.ContainsRune("()[],!=<>&|\"'", rune(s[j])) {
+				j++
+			}
+			if j == i {
+				return nil, fmt.Errorf("unexpected character '%c' at position %d", c, i)
+			}
+			tokens = append(tokens, conditionToken{kind: conditionTokenWord, value: s[i:j], pos: i})
+			i = j
+		}
+	}
+	return append(tokens, conditionToken{kind: conditionTokenEOF, pos: len(s)}), nil
+}
+
+// readConditionString reads a quoted string, with backslash escapes, and returns its value and its length in s
+func readConditionString(s string) (string, int, error) {
+	quote := s[0]
+	var value []byte
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			if i+1 < len(s) {
+				i++
+				value = append(value, s[i])
+			}
+		case quote:
+			return string(value), i + 1, nil
+		default:
+			value = append(value, s[i])
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+// conditionNode is a node of a parsed condition expression
+type conditionNode interface {
+	eval(params map[string]string) (bool, error)
+}
+
+type conditionOr struct{ left, right conditionNode }
+
+func (n conditionOr) eval(params map[string]string) (bool, error) {
+	ok, err := n.left.eval(params)
+	if err != nil || ok {
+		return ok, err
+	}
+	return n.right.eval(params)
+}
+
+type conditionAnd struct{ left, right conditionNode }
+
+func (n conditionAnd) eval(params map[string]string) (bool, error) {
+	ok, err := n.left.eval(params)
+	if err != nil || !ok {
+		return ok, err
+	}
+	return n.right.eval(params)
+}
+
+type conditionNot struct{ node conditionNode }
+
+func (n conditionNot) eval(params map[string]string) (bool, error) {
+	ok, err := n.node.eval(params)
+	return !ok, err
+}
+
+// conditionOperand is a parameter or a literal value
+type conditionOperand struct {
+	parameter string
+	literal   string
+}
+
+func (o conditionOperand) value(params map[string]string) (string, error) {
+	if o.parameter != "" {
+		return params[o.parameter], nil
+	}
+	v, err := Interpolate(o.literal, params)
+	if err != nil {
+		return "", fmt.Errorf("Unable to interpolate %s (%v)", o.literal, err)
+	}
+	return v, nil
+}
+
+type conditionComparison struct {
+	left     conditionOperand
+	operator string
+	right    conditionOperand
+	list     []conditionOperand
+}
+
+func (n conditionComparison) eval(params map[string]string) (bool, error) {
+	left, err := n.left.value(params)
+	if err != nil {
+		return false, err
+	}
+
+	switch n.operator {
+	case "":
+		return left != "" && left != "false" && left != "0", nil
+	case "in", "not in":
+		var found bool
+		for _, o := range n.list {
+			v, err := o.value(params)
+			if err != nil {
+				return false, err
+			}
+			if v == left {
+				found = true
+				break
+			}
+		}
+		return found == (n.operator == "in"), nil
+	}
+
+	right, err := n.right.value(params)
+	if err != nil {
+		return false, err
+	}
+	switch n.operator {
+	case "==", "=":
+		return left == right, nil
+	case "!=":
+		return left != right, nil
+	case "<":
+		return compareConditionValues(left, right) < 0, nil
+	case "<=":
+		return compareConditionValues(left, right) <= 0, nil
+	case ">":
+		return compareConditionValues(left, right) > 0, nil
+	case ">=":
+		return compareConditionValues(left, right) >= 0, nil
+	case "=~", "matches":
+		match, err := regexp.MatchString(right, left)
+		if err != nil {
+			return false, fmt.Errorf("Unable to match string with regex %s (%v)", right, err)
+		}
+		return match, nil
+	case "glob":
+		match, err := path.Match(right, left)
+		if err != nil {
+			return false, fmt.Errorf("Unable to match string with glob %s (%v)", right, err)
+		}
+		return match, nil
+	}
+	return false, fmt.Errorf("unknown operator %s", n.operator)
+}
+
+type conditionParser struct {
+	tokens []conditionToken
+	pos    int
+}
+
+// parseConditionExpression parses a condition expression. Constant regular expressions and glob patterns are checked
+func parseConditionExpression(s string) (conditionNode, error) {
+	tokens, err := tokenizeConditionExpression(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 1 {
+		return nil, fmt.Errorf("empty expression")
+	}
+	p := &conditionParser{tokens: tokens}
+	n, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != conditionTokenEOF {
+		return nil, fmt.Errorf("unexpected %s", t)
+	}
+	return n, nil
+}
+
+func (p *conditionParser) peek() conditionToken {
+	return p.tokens[p.pos]
+}
+
+func (p *conditionParser) next() conditionToken {
+	t := p.tokens[p.pos]
+	if t.kind != conditionTokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+// accept consumes the next token if it is one of the symbols or keywords
+func (p *conditionParser) accept(values ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != conditionTokenSymbol && t.kind != conditionTokenWord {
+		return "", false
+	}
+	for _, v := range values {
+		if t.value == v {
+			p.next()
+			return v, true
+		}
+	}
+	return "", false
+}
+
+func (p *conditionParser) parseOr() (conditionNode, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("||", "or"); !ok {
+			return left, nil
+		}
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = conditionOr{left, right}
+	}
+}
+
+func (p *conditionParser) parseAnd() (conditionNode, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.accept("&&", "and"); !ok {
+			return left, nil
+		}
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = conditionAnd{left, right}
+	}
+}
+
+func (p *conditionParser) parseUnary() (conditionNode, error) {
+	if _, ok := p.accept("!", "not"); ok {
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return conditionNot{n}, nil
+	}
+	if _, ok := p.accept("("); ok {
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.accept(")"); !ok {
+			return nil, fmt.Errorf("expected ')' instead of %s", p.peek())
+		}
+		return n, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *conditionParser) parseComparison() (conditionNode, error) {
+	left, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	n := conditionComparison{left: left}
+
+	if _, ok := p.accept("in"); ok {
+		n.operator = "in"
+	} else if t := p.peek(); t.kind == conditionTokenWord && t.value == "not" && p.tokens[p.pos+1].kind == conditionTokenWord && p.tokens[p.pos+1].value == "in" {
+		p.next()
+		p.next()
+		n.operator = "not in"
+	}
+	if n.operator != "" {
+		n.list, err = p.parseList()
+		return n, err
+	}
+
+	op, ok := p.accept("==", "=", "!=", "<=", ">=", "<", ">", "=~", "matches", "glob")
+	if !ok {
+		return n, nil
+	}
+	n.operator = op
+	if n.right, err = p.parseOperand(); err != nil {
+		return nil, err
+	}
+
+	// Check the constant patterns
+	if n.right.parameter == "" && !strings.Contains(n.right.literal, "{{") {
+		switch op {
+		case "=~", "matches":
+			if _, err := regexp.Compile(n.right.literal); err != nil {
+				return nil, fmt.Errorf("invalid regex %s: %v", n.right.literal, err)
+			}
+		case "glob":
+			if _, err := path.Match(n.right.literal, ""); err != nil {
+				return nil, fmt.Errorf("invalid glob %s: %v", n.right.literal, err)
+			}
+		}
+	}
+	return n, nil
+}
+
+func (p *conditionParser) parseList() ([]conditionOperand, error) {
+	if _, ok := p.accept("["); !ok {
+		return nil, fmt.Errorf("expected '[' instead of %s", p.peek())
+	}
+	list := []conditionOperand{}
+	if _, ok := p.accept("]"); ok {
+		return list, nil
+	}
+	for {
+		o, err := p.parseOperand()
+		if err != nil {
+			return nil, err
+		}
+		list = append(list, o)
+		if _, ok := p.accept("]"); ok {
+			return list, nil
+		}
+		if _, ok := p.accept(","); !ok {
+			return nil, fmt.Errorf("expected ',' or ']' instead of %s", p.peek())
+		}
+	}
+}
+
+var conditionKeywords = map[string]bool{"and": true, "or": true, "not": true, "in": true, "matches": true, "glob": true}
+
+func (p *conditionParser) parseOperand() (conditionOperand, error) {
+	t := p.next()
+	switch {
+	case t.kind == conditionTokenString:
+		return conditionOperand{literal: t.value}, nil
+	case t.kind == conditionTokenWord && !conditionKeywords[t.value]:
+		if t.value == "true" || t.value == "false" || unicode.IsDigit(rune(t.value[0])) || t.value[0] == '-' || conditionVersionRegexp.MatchString(t.value) {
+			return conditionOperand{literal: t.value}, nil
+		}
+		return conditionOperand{parameter: t.value}, nil
+	}
+	return conditionOperand{}, fmt.Errorf("expected a value instead of %s", t)
+}
+
+var conditionVersionRegexp = regexp.MustCompile(`^v?(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:-([0-9A-Za-z.-]+))?(?:\+[0-9A-Za-z.-]+)?$`)
+
+type conditionVersion struct {
+	numbers    [3]uint64
+	prerelease []string
+	// semver is true if the value can only be a version: it has three numbers, a v prefix or a pre-release
+	semver bool
+}
+
+func parseConditionVersion(s string) (*conditionVersion, bool) {
+	m := conditionVersionRegexp.FindStringSubmatch(s)
+	if m == nil {
+		return nil, false
+	}
+	v := &conditionVersion{semver: s[0] == 'v' || m[3] != "" || m[4] != ""}
+	for i := 0; i < 3; i++ {
+		if m[i+1] == "" {
+			continue
+		}
+		n, err := strconv.ParseUint(m[i+1], 10, 64)
+		if err != nil {
+			return nil, false
+		}
+		v.numbers[i] = n
+	}
+	if m[4] != "" {
+		v.prerelease = strings.Split(m[4], ".")
+	}
+	return v, true
+}
+
+// compare compares versions with the semantic versioning precedence
+func (v *conditionVersion) compare(o *conditionVersion) int {
+	for i := range v.numbers {
+		if v.numbers[i] != o.numbers[i] {
+			if v.numbers[i] < o.numbers[i] {
+				return -1
+			}
+			return 1
+		}
+	}
+	// A pre-release version has a lower precedence than the release
+	switch {
+	case len(v.prerelease) == 0 && len(o.prerelease) == 0:
+		return 0
+	case len(v.prerelease) == 0:
+		return 1
+	case len(o.prerelease) == 0:
+		return -1
+	}
+	for i := 0; i < len(v.prerelease) && i < len(o.prerelease); i++ {
+		a, b := v.prerelease[i], o.prerelease[i]
+		na, erra := strconv.ParseUint(a, 10, 64)
+		nb, errb := strconv.ParseUint(b, 10, 64)
+		switch {
+		case erra == nil && errb == nil:
+			if na != nb {
+				if na < nb {
+					return -1
+				}
+				return 1
+			}
+		case erra == nil:
+			return -1
+		case errb == nil:
+			return 1
+		default:
+			if c := strings.Compare(a, b); c != 0 {
+				return c
+			}
+		}
+	}
+	switch {
+	case len(v.prerelease) < len(o.prerelease):
+		return -1
+	case len(v.prerelease) > len(o.prerelease):
+		return 1
+	}
+	return 0
+}
+
+// compareConditionValues compares two values as versions if one of them is a version, then as numbers, then as strings
+func compareConditionValues(a, b string) int {
+	if va, ok := parseConditionVersion(a); ok {
+		if vb, ok := parseConditionVersion(b); ok && (va.semver || vb.semver) {
+			return va.compare(vb)
+		}
+	}
+	fa, erra := strconv.ParseFloat(a, 64)
+	fb, errb := strconv.ParseFloat(b, 64)
+	if erra == nil && errb == nil {
+		switch {
+		case fa < fb:
+			return -1
+		case fa > fb:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
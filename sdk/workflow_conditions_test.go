@@ -0,0 +1,132 @@
+package sdk
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkflowCheckConditionsExpression(t *testing.T) {
+	params := ParametersFromMap(map[string]string{
+		"git.branch":  "feat/login",
+		"git.author":  "bot-renovate",
+		"version":     "1.10.2",
+		"count":       "12",
+		"cds.manual":  "false",
+		"cds.release": "true",
+	})
+
+	tests := map[string]bool{
+		`git.branch == "feat/login"`:              true,
+		`git.branch = 'master'`:                   false,
+		`git.branch != "master"`:                  true,
+		`version > 1.9`:                           true,
+		`version >= 1.10.2 && version < v2`:       true,
+		`version < 1.10.2-rc1`:                    false,
+		`count > 9`:                               true,
+		`count <= 11.5`:                           false,
+		`1.5 > 1.25`:                              true,
+		`git.branch in ["master", "develop"]`:     false,
+		`git.branch not in ["master", "develop"]`: true,
+		`git.branch glob "feat/*"`:                true,
+		`git.branch glob "feat"`:                  false,
+		`git.author =~ "^bot-"`:                   true,
+		`git.author matches "^human"`:             false,
+		`cds.release`:                             true,
+		`cds.manual`:                              false,
+		`not cds.manual`:                          true,
+		`!cds.release`:                            false,
+		`unknown`:                                 false,
+		`unknown == ""`:                           true,
+		`git.branch == "master" || git.branch glob "feat/*"`:      true,
+		`git.branch == "master" or version > 2`:                   false,
+		`(git.branch == "master" or cds.release) and count > 10`:  true,
+		`git.branch == "master" or cds.release and count > 100`:   false,
+		`not (git.branch == "master" or cds.manual)`:              true,
+		`git.branch == "{{.git.branch}}"`:                         true,
+		`git.branch in [git.author, "feat/login"] && version > 1`: true,
+	}
+	for expr, expected := range tests {
+		ok, err := WorkflowCheckConditions([]WorkflowTriggerCondition{{Expression: expr}}, params)
+		assert.NoError(t, err, expr)
+		assert.Equal(t, expected, ok, expr)
+	}
+
+	// Expressions and tuples are all required
+	ok, err := WorkflowCheckConditions([]WorkflowTriggerCondition{
+		{Expression: `version > 1.9`},
+		{Variable: "git.branch", Operator: WorkflowConditionsOperatorEquals, Value: "master"},
+	}, params)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestWorkflowCheckConditionsTuples(t *testing.T) {
+	params := ParametersFromMap(map[string]string{"git.branch": "master", "version": "v1.10"})
+
+	tests := []struct {
+		condition WorkflowTriggerCondition
+		expected  bool
+	}{
+		{WorkflowTriggerCondition{Variable: "git.branch", Operator: "eq", Value: "master"}, true},
+		{WorkflowTriggerCondition{Variable: "git.branch", Operator: "=", Value: "master"}, true},
+		{WorkflowTriggerCondition{Variable: "git.branch", Operator: "!=", Value: "master"}, false},
+		{WorkflowTriggerCondition{Variable: "version", Operator: "gt", Value: "1.9"}, true},
+		{WorkflowTriggerCondition{Variable: "version", Operator: "<", Value: "1.9"}, false},
+		{WorkflowTriggerCondition{Variable: "git.branch", Operator: "regex", Value: "^mas"}, true},
+	}
+	for _, test := range tests {
+		ok, err := WorkflowCheckConditions([]WorkflowTriggerCondition{test.condition}, params)
+		assert.NoError(t, err)
+		assert.Equal(t, test.expected, ok, "%+v", test.condition)
+	}
+}
+
+func TestWorkflowValidateConditions(t *testing.T) {
+	valid := []WorkflowTriggerCondition{
+		{Variable: "git.branch", Operator: "eq", Value: "master"},
+		{Variable: "git.branch", Operator: "match", Value: "^feat/"},
+		{Expression: `git.branch in ["master"] && (version > 1.2 || !cds.manual)`},
+		{Expression: `git.branch =~ "{{.cds.pattern}}"`},
+	}
+	for _, c := range valid {
+		assert.NoError(t, c.IsValid(), "%+v", c)
+	}
+	assert.NoError(t, WorkflowValidateConditions(valid))
+
+	invalid := []WorkflowTriggerCondition{
+		{Variable: "git.branch", Operator: "like", Value: "master"},
+		{Operator: "eq", Value: "master"},
+		{Variable: "git.branch", Operator: "regex", Value: "("},
+		{Expression: `git.branch ==`},
+		{Expression: `(git.branch == "master"`},
+		{Expression: `git.branch == "master")`},
+		{Expression: `git.branch in "master"`},
+		{Expression: `git.branch in ["master"`},
+		{Expression: `git.branch == "master`},
+		{Expression: `git.branch =~ "("`},
+		{Expression: `git.branch glob "[a"`},
+		{Expression: `and`},
+		{Expression: `a b`},
+		{Expression: `a & b`},
+	}
+	for _, c := range invalid {
+		assert.Error(t, c.IsValid(), "%+v", c)
+	}
+	assert.Error(t, WorkflowValidateConditions(append(valid, invalid[0])))
+}
+
+func TestCompareConditionValues(t *testing.T) {
+	assert.True(t, compareConditionValues("1.10.0", "1.9.0") > 0)
+	assert.True(t, compareConditionValues("v1.10", "1.9") > 0)
+	assert.True(t, compareConditionValues("1.5", "1.25") > 0)
+	assert.True(t, compareConditionValues("1.10", "1.9") < 0)
+	assert.True(t, compareConditionValues("v1.2.3", "1.2.3") == 0)
+	assert.True(t, compareConditionValues("1.0.0-rc.1", "1.0.0") < 0)
+	assert.True(t, compareConditionValues("1.0.0-rc.2", "1.0.0-rc.10") < 0)
+	assert.True(t, compareConditionValues("1.0.0-alpha", "1.0.0-1") > 0)
+	assert.True(t, compareConditionValues("1.0.0-alpha", "1.0.0-alpha.1") < 0)
+	assert.True(t, compareConditionValues("10", "9") > 0)
+	assert.True(t, compareConditionValues("-1.5", "2") < 0)
+	assert.True(t, compareConditionValues("abc", "abd") < 0)
+}
@@ -0,0 +1,135 @@
+package sdk
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/gorhill/cronexpr"
+)
+
+// Types of WorkflowHookModel
+const (
+	WorkflowHookModelBuiltin = "builtin"
+)
+
+// Names of the builtin WorkflowHookModel
+const (
+	WebHookModelName           = "WebHook"
+	SchedulerModelName         = "Scheduler"
+	RepositoryWebHookModelName = "RepositoryWebHook"
+	KafkaHookModelName         = "Kafka"
+)
+
+// Configuration keys of the builtin hooks
+const (
+	HookConfigCronExpr      = "cron"
+	HookConfigTimezone      = "timezone"
+	HookConfigPayload       = "payload"
+	HookConfigCatchUp       = "catchup"
+	HookConfigKafkaBrokers  = "brokers"
+	HookConfigKafkaTopic    = "topic"
+	HookConfigKafkaGroup    = "consumer_group"
+	HookConfigKafkaUser     = "user"
+	HookConfigKafkaPassword = "password"
+)
+
+// HookSecretConfigKeys are the configuration keys holding secrets, by builtin hook model. They are stored encrypted
+// and returned with the password placeholder
+var HookSecretConfigKeys = map[string][]string{
+	KafkaHookModelName: {HookConfigKafkaPassword},
+}
+
+// Catch-up policies of the Scheduler hooks, applied to the executions missed while CDS was not running
+const (
+	// SchedulerCatchUpSkip drops the missed executions
+	SchedulerCatchUpSkip = "skip"
+	// SchedulerCatchUpOnce runs the workflow once for all the missed executions
+	SchedulerCatchUpOnce = "once"
+	// SchedulerCatchUpAll runs the workflow for each missed execution
+	SchedulerCatchUpAll = "all"
+)
+
+// WorkflowScheduledExecution is an upcoming execution of a Scheduler hook
+type WorkflowScheduledExecution struct {
+	HookUUID       string    `json:"hook_uuid"`
+	WorkflowNodeID int64     `json:"workflow_node_id"`
+	NodeName       string    `json:"node_name"`
+	Cron           string    `json:"cron"`
+	Timezone       string    `json:"timezone"`
+	Date           time.Time `json:"date"`
+}
+
+// BuiltinHookModels are the hook models available on every CDS
+var BuiltinHookModels = []*WorkflowHookModel{
+	{
+		Name:          WebHookModelName,
+		Type:          WorkflowHookModelBuiltin,
+		DefaultConfig: WorkflowNodeHookConfig{},
+	},
+	{
+		Name: SchedulerModelName,
+		Type: WorkflowHookModelBuiltin,
+		DefaultConfig: WorkflowNodeHookConfig{
+			HookConfigCronExpr: "0 * * * *",
+		},
+	},
+	{
+		Name:          RepositoryWebHookModelName,
+		Type:          WorkflowHookModelBuiltin,
+		DefaultConfig: WorkflowNodeHookConfig{},
+	},
+	{
+		Name: KafkaHookModelName,
+		Type: WorkflowHookModelBuiltin,
+		DefaultConfig: WorkflowNodeHookConfig{
+			HookConfigKafkaBrokers:  "",
+			HookConfigKafkaTopic:    "",
+			HookConfigKafkaGroup:    "",
+			HookConfigKafkaUser:     "",
+			HookConfigKafkaPassword: "",
+		},
+	},
+}
+
+// IsValid checks the configuration of a hook using a builtin model
+func (h *WorkflowNodeHook) IsValid() error {
+	switch h.WorkflowHookModel.Name {
+	case SchedulerModelName:
+		if strings.TrimSpace(h.Config[HookConfigCronExpr]) == "" {
+			return WrapError(ErrWrongRequest, "WorkflowNodeHook.IsValid> %s is mandatory", HookConfigCronExpr)
+		}
+		if _, err := cronexpr.Parse(h.Config[HookConfigCronExpr]); err != nil {
+			return WrapError(ErrWrongRequest, "WorkflowNodeHook.IsValid> Invalid %s: %s", HookConfigCronExpr, err)
+		}
+		if _, err := time.LoadLocation(h.Config[HookConfigTimezone]); err != nil {
+			return WrapError(ErrWrongRequest, "WorkflowNodeHook.IsValid> Invalid %s: %s", HookConfigTimezone, err)
+		}
+		switch h.Config[HookConfigCatchUp] {
+		case "", SchedulerCatchUpSkip, SchedulerCatchUpOnce, SchedulerCatchUpAll:
+		default:
+			return WrapError(ErrWrongRequest, "WorkflowNodeHook.IsValid> Invalid %s: %s", HookConfigCatchUp, h.Config[HookConfigCatchUp])
+		}
+		if _, err := h.SchedulerPayload(); err != nil {
+			return WrapError(ErrWrongRequest, "WorkflowNodeHook.IsValid> Invalid %s: %s", HookConfigPayload, err)
+		}
+	case KafkaHookModelName:
+		for _, k := range []string{HookConfigKafkaBrokers, HookConfigKafkaTopic} {
+			if strings.TrimSpace(h.Config[k]) == "" {
+				return WrapError(ErrWrongRequest, "WorkflowNodeHook.IsValid> %s is mandatory", k)
+			}
+		}
+	}
+	return nil
+}
+
+// SchedulerPayload returns the payload configured on a Scheduler hook
+func (h *WorkflowNodeHook) SchedulerPayload() (map[string]string, error) {
+	payload := map[string]string{}
+	if p := strings.TrimSpace(h.Config[HookConfigPayload]); p != "" {
+		if err := json.Unmarshal([]byte(p), &payload); err != nil {
+			return nil, err
+		}
+	}
+	return payload, nil
+}
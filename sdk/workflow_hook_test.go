@@ -0,0 +1,33 @@
+package sdk
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkflowNodeHookIsValid(t *testing.T) {
+	scheduler := func(config WorkflowNodeHookConfig) *WorkflowNodeHook {
+		return &WorkflowNodeHook{WorkflowHookModel: WorkflowHookModel{Name: SchedulerModelName}, Config: config}
+	}
+
+	assert.NoError(t, scheduler(WorkflowNodeHookConfig{HookConfigCronExpr: "0 9 * * 1-5"}).IsValid())
+	assert.NoError(t, scheduler(WorkflowNodeHookConfig{
+		HookConfigCronExpr: "*/5 * * * *",
+		HookConfigTimezone: "Europe/Paris",
+		HookConfigCatchUp:  SchedulerCatchUpAll,
+		HookConfigPayload:  `{"env": "prod"}`,
+	}).IsValid())
+
+	assert.Error(t, scheduler(WorkflowNodeHookConfig{}).IsValid())
+	assert.Error(t, scheduler(WorkflowNodeHookConfig{HookConfigCronExpr: "every day"}).IsValid())
+	assert.Error(t, scheduler(WorkflowNodeHookConfig{HookConfigCronExpr: "0 * * * *", HookConfigTimezone: "Not/AZone"}).IsValid())
+	assert.Error(t, scheduler(WorkflowNodeHookConfig{HookConfigCronExpr: "0 * * * *", HookConfigCatchUp: "sometimes"}).IsValid())
+	assert.Error(t, scheduler(WorkflowNodeHookConfig{HookConfigCronExpr: "0 * * * *", HookConfigPayload: "[1, 2]"}).IsValid())
+
+	kafka := &WorkflowNodeHook{WorkflowHookModel: WorkflowHookModel{Name: KafkaHookModelName}, Config: WorkflowNodeHookConfig{}}
+	assert.Error(t, kafka.IsValid())
+	kafka.Config[HookConfigKafkaBrokers] = "localhost:9092"
+	kafka.Config[HookConfigKafkaTopic] = "cds"
+	assert.NoError(t, kafka.IsValid())
+}
@@ -0,0 +1,71 @@
+package sdk
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+)
+
+// WorkflowNodeMatrixMaxCombinations is the maximum number of node runs a matrix can start
+const WorkflowNodeMatrixMaxCombinations = 64
+
+var workflowNodeMatrixKeyRegexp = regexp.MustCompile(`^[a-zA-Z0-9_\-]+$`)
+
+// WorkflowNodeMatrix runs the pipeline of a node once per combination of its values.
+// Each node run gets the values of its combination as cds.matrix.<key> build parameters
+type WorkflowNodeMatrix map[string][]string
+
+// IsValid checks the keys and the values of a matrix
+func (m WorkflowNodeMatrix) IsValid() error {
+	combinations := 1
+	for k, values := range m {
+		if !workflowNodeMatrixKeyRegexp.MatchString(k) {
+			return fmt.Errorf("invalid matrix key %s", k)
+		}
+		if len(values) == 0 {
+			return fmt.Errorf("matrix key %s has no value", k)
+		}
+		seen := map[string]bool{}
+		for _, v := range values {
+			if seen[v] {
+				return fmt.Errorf("duplicate value %s for matrix key %s", v, k)
+			}
+			seen[v] = true
+		}
+		combinations *= len(values)
+		if combinations > WorkflowNodeMatrixMaxCombinations {
+			return fmt.Errorf("matrix has more than %d combinations", WorkflowNodeMatrixMaxCombinations)
+		}
+	}
+	return nil
+}
+
+// Combinations returns all the combinations of the values of the matrix, ordered by key then by value index.
+// It returns nil for an empty matrix
+func (m WorkflowNodeMatrix) Combinations() []map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	res := []map[string]string{{}}
+	for _, k := range keys {
+		next := make([]map[string]string, 0, len(res)*len(m[k]))
+		for _, c := range res {
+			for _, v := range m[k] {
+				combination := make(map[string]string, len(c)+1)
+				for ck, cv := range c {
+					combination[ck] = cv
+				}
+				combination[k] = v
+				next = append(next, combination)
+			}
+		}
+		res = next
+	}
+	return res
+}
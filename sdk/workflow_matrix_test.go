@@ -0,0 +1,39 @@
+package sdk
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkflowNodeMatrixCombinations(t *testing.T) {
+	m := WorkflowNodeMatrix{
+		"os":         {"debian", "alpine"},
+		"go_version": {"1.8", "1.9"},
+	}
+	assert.NoError(t, m.IsValid())
+	assert.Equal(t, []map[string]string{
+		{"go_version": "1.8", "os": "debian"},
+		{"go_version": "1.8", "os": "alpine"},
+		{"go_version": "1.9", "os": "debian"},
+		{"go_version": "1.9", "os": "alpine"},
+	}, m.Combinations())
+
+	assert.Nil(t, WorkflowNodeMatrix{}.Combinations())
+	assert.Nil(t, WorkflowNodeMatrix(nil).Combinations())
+}
+
+func TestWorkflowNodeMatrixIsValid(t *testing.T) {
+	assert.NoError(t, WorkflowNodeMatrix(nil).IsValid())
+	assert.Error(t, WorkflowNodeMatrix{"go version": {"1.8"}}.IsValid())
+	assert.Error(t, WorkflowNodeMatrix{"os": {}}.IsValid())
+	assert.Error(t, WorkflowNodeMatrix{"os": {"debian", "debian"}}.IsValid())
+
+	tooLarge := WorkflowNodeMatrix{}
+	for _, k := range []string{"a", "b", "c", "d", "e", "f", "g"} {
+		tooLarge[k] = []string{"1", "2"}
+	}
+	assert.Error(t, tooLarge.IsValid())
+	delete(tooLarge, "g")
+	assert.NoError(t, tooLarge.IsValid())
+}
@@ -0,0 +1,71 @@
+package sdk
+
+import (
+	"time"
+)
+
+// WorkflowRetentionPolicy defines which workflow runs keep their artifacts. A project policy applies to
+// all the workflows of the project, unless the workflow has its own policy.
+// A run keeps its artifacts if it matches at least one of the rules, a rule set to zero is disabled.
+type WorkflowRetentionPolicy struct {
+	ID           int64            `json:"id" db:"id"`
+	ProjectID    int64            `json:"project_id" db:"project_id"`
+	WorkflowID   int64            `json:"workflow_id,omitempty" db:"-"`
+	KeepLastRuns int              `json:"keep_last_runs" db:"keep_last_runs"`
+	KeepDays     int              `json:"keep_days" db:"keep_days"`
+	KeepTags     []WorkflowRunTag `json:"keep_tags,omitempty" db:"-"`
+}
+
+// IsValid checks that the policy has at least one rule
+func (p WorkflowRetentionPolicy) IsValid() error {
+	if p.KeepLastRuns < 0 || p.KeepDays < 0 {
+		return ErrWrongRequest
+	}
+	if p.KeepLastRuns == 0 && p.KeepDays == 0 && len(p.KeepTags) == 0 {
+		return ErrWrongRequest
+	}
+	for _, t := range p.KeepTags {
+		if t.Tag == "" {
+			return ErrWrongRequest
+		}
+	}
+	return nil
+}
+
+// Keep returns true if the run keeps its artifacts. index is the position of the run
+// among all the runs of the workflow, sorted by number descending.
+func (p WorkflowRetentionPolicy) Keep(run WorkflowRun, index int, now time.Time) bool {
+	if p.KeepLastRuns > 0 && index < p.KeepLastRuns {
+		return true
+	}
+	if p.KeepDays > 0 && run.Start.After(now.AddDate(0, 0, -p.KeepDays)) {
+		return true
+	}
+	for _, keep := range p.KeepTags {
+		for _, t := range run.Tags {
+			if keep.Tag == t.Tag && (keep.Value == "" || keep.Value == t.Value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// WorkflowPurgeReport lists the artifacts deleted by the purge routine, or to be deleted in dry run mode
+type WorkflowPurgeReport struct {
+	DryRun      bool                     `json:"dry_run"`
+	Runs        []WorkflowPurgeReportRun `json:"runs"`
+	NbArtifacts int                      `json:"nb_artifacts"`
+	Size        int64                    `json:"size"`
+}
+
+// WorkflowPurgeReportRun lists the artifacts of a run deleted by the purge routine
+type WorkflowPurgeReportRun struct {
+	ProjectKey   string                    `json:"project_key"`
+	WorkflowName string                    `json:"workflow_name"`
+	RunID        int64                     `json:"run_id"`
+	Number       int64                     `json:"num"`
+	Start        time.Time                 `json:"start"`
+	Tags         []WorkflowRunTag          `json:"tags,omitempty"`
+	Artifacts    []WorkflowNodeRunArtifact `json:"artifacts"`
+}
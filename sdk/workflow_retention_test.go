@@ -0,0 +1,33 @@
+package sdk
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkflowRetentionPolicyKeep(t *testing.T) {
+	now := time.Now()
+	p := WorkflowRetentionPolicy{
+		KeepLastRuns: 2,
+		KeepDays:     7,
+		KeepTags:     []WorkflowRunTag{{Tag: "release"}, {Tag: "env", Value: "prod"}},
+	}
+	assert.NoError(t, p.IsValid())
+
+	old := now.AddDate(0, 0, -30)
+	assert.True(t, p.Keep(WorkflowRun{Start: old}, 1, now), "last runs should be kept")
+	assert.True(t, p.Keep(WorkflowRun{Start: now.AddDate(0, 0, -3)}, 5, now), "recent runs should be kept")
+	assert.True(t, p.Keep(WorkflowRun{Start: old, Tags: []WorkflowRunTag{{Tag: "release", Value: "1.0"}}}, 5, now))
+	assert.True(t, p.Keep(WorkflowRun{Start: old, Tags: []WorkflowRunTag{{Tag: "env", Value: "prod"}}}, 5, now))
+	assert.False(t, p.Keep(WorkflowRun{Start: old, Tags: []WorkflowRunTag{{Tag: "env", Value: "dev"}}}, 5, now))
+	assert.False(t, p.Keep(WorkflowRun{Start: old}, 2, now))
+}
+
+func TestWorkflowRetentionPolicyIsValid(t *testing.T) {
+	assert.Error(t, WorkflowRetentionPolicy{}.IsValid())
+	assert.Error(t, WorkflowRetentionPolicy{KeepLastRuns: -1}.IsValid())
+	assert.Error(t, WorkflowRetentionPolicy{KeepTags: []WorkflowRunTag{{Value: "foo"}}}.IsValid())
+	assert.NoError(t, WorkflowRetentionPolicy{KeepDays: 30}.IsValid())
+}
@@ -1,8 +1,11 @@
 package sdk
 
 import (
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -15,9 +18,15 @@ type WorkflowRun struct {
 	Number           int64                       `json:"num" db:"num"`
 	ProjectID        int64                       `json:"project_id,omitempty" db:"project_id"`
 	WorkflowID       int64                       `json:"workflow_id" db:"workflow_id"`
+	WorkflowVersion  int64                       `json:"workflow_version" db:"workflow_version"`
 	Workflow         Workflow                    `json:"workflow" db:"-"`
 	Start            time.Time                   `json:"start" db:"start"`
 	LastModified     time.Time                   `json:"last_modified" db:"last_modified"`
+	Status           string                      `json:"status" db:"status"`
+	VCSBranch        string                      `json:"vcs_branch,omitempty" db:"vcs_branch"`
+	VCSHash          string                      `json:"vcs_hash,omitempty" db:"vcs_hash"`
+	Username         string                      `json:"username,omitempty" db:"username"`
+	ParentNodeRunID  int64                       `json:"parent_node_run_id,omitempty" db:"parent_node_run_id"`
 	WorkflowNodeRuns map[int64][]WorkflowNodeRun `json:"nodes" db:"-"`
 	Infos            []WorkflowRunInfo           `json:"infos" db:"-"`
 	Tags             []WorkflowRunTag            `json:"tags" db:"-"`
@@ -45,6 +54,98 @@ type WorkflowRunTag struct {
 	Value string `json:"value" db:"value"`
 }
 
+//WorkflowRunFilter filters the workflow runs of a project. Empty criteria are ignored, tags are all required
+type WorkflowRunFilter struct {
+	Workflow string
+	Status   string
+	Branch   string
+	Hash     string
+	Username string
+	Node     string
+	Tags     []WorkflowRunTag
+	Since    *time.Time
+	Until    *time.Time
+	Offset   int
+	Limit    int
+}
+
+//Values returns the filter as query parameters
+func (f WorkflowRunFilter) Values() url.Values {
+	v := url.Values{}
+	for k, s := range map[string]string{
+		"workflow": f.Workflow,
+		"status":   f.Status,
+		"branch":   f.Branch,
+		"hash":     f.Hash,
+		"username": f.Username,
+		"node":     f.Node,
+	} {
+		if s != "" {
+			v.Set(k, s)
+		}
+	}
+	for _, t := range f.Tags {
+		v.Add("tag", t.Tag+":"+t.Value)
+	}
+	if f.Since != nil {
+		v.Set("since", f.Since.Format(time.RFC3339))
+	}
+	if f.Until != nil {
+		v.Set("until", f.Until.Format(time.RFC3339))
+	}
+	if f.Offset > 0 {
+		v.Set("offset", fmt.Sprintf("%d", f.Offset))
+	}
+	if f.Limit > 0 {
+		v.Set("limit", fmt.Sprintf("%d", f.Limit))
+	}
+	return v
+}
+
+//ParseWorkflowRunFilter reads a filter from query parameters. Tags are given as tag=name:value
+func ParseWorkflowRunFilter(v url.Values) (WorkflowRunFilter, error) {
+	f := WorkflowRunFilter{
+		Workflow: v.Get("workflow"),
+		Status:   v.Get("status"),
+		Branch:   v.Get("branch"),
+		Hash:     v.Get("hash"),
+		Username: v.Get("username"),
+		Node:     v.Get("node"),
+	}
+	for _, t := range v["tag"] {
+		tuple := strings.SplitN(t, ":", 2)
+		if tuple[0] == "" {
+			return f, fmt.Errorf("invalid tag %s", t)
+		}
+		tag := WorkflowRunTag{Tag: tuple[0]}
+		if len(tuple) == 2 {
+			tag.Value = tuple[1]
+		}
+		f.Tags = append(f.Tags, tag)
+	}
+	for k, t := range map[string]**time.Time{"since": &f.Since, "until": &f.Until} {
+		if v.Get(k) == "" {
+			continue
+		}
+		d, err := time.Parse(time.RFC3339, v.Get(k))
+		if err != nil {
+			return f, fmt.Errorf("invalid %s date %s", k, v.Get(k))
+		}
+		*t = &d
+	}
+	for k, i := range map[string]*int{"offset": &f.Offset, "limit": &f.Limit} {
+		if v.Get(k) == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v.Get(k))
+		if err != nil || n < 0 {
+			return f, fmt.Errorf("invalid %s %s", k, v.Get(k))
+		}
+		*i = n
+	}
+	return f, nil
+}
+
 //WorkflowNodeRun is as execution instance of a node
 type WorkflowNodeRun struct {
 	WorkflowRunID      int64                     `json:"workflow_run_id" db:"workflow_run_id"`
@@ -66,6 +167,8 @@ type WorkflowNodeRun struct {
 	Artifacts          []WorkflowNodeRunArtifact `json:"artifacts,omitempty" db:"-"`
 	Tests              *venom.Tests              `json:"tests,omitempty" db:"-"`
 	Commits            []VCSCommit               `json:"commits,omitempty" db:"-"`
+	Matrix             map[string]string         `json:"matrix,omitempty" db:"-"`
+	SubWorkflowRunID   int64                     `json:"sub_workflow_run_id,omitempty" db:"sub_workflow_run_id"`
 }
 
 // Translate translates messages in WorkflowNodeRun
@@ -79,35 +182,46 @@ func (nr *WorkflowNodeRun) Translate(lang string) {
 
 //WorkflowNodeRunArtifact represents tests list
 type WorkflowNodeRunArtifact struct {
-	WorkflowID        int64     `json:"workflow_id" db:"workflow_run_id"`
-	WorkflowNodeRunID int64     `json:"workflow_node_run_id" db:"workflow_node_run_id"`
-	ID                int64     `json:"id" db:"id"`
-	Name              string    `json:"name" db:"name"`
-	Tag               string    `json:"tag" db:"tag"`
-	DownloadHash      string    `json:"download_hash" db:"download_hash"`
-	Size              int64     `json:"size,omitempty" db:"size"`
-	Perm              uint32    `json:"perm,omitempty" db:"perm"`
-	MD5sum            string    `json:"md5sum,omitempty" db:"md5sum"`
-	ObjectPath        string    `json:"object_path,omitempty" db:"object_path"`
-	Created           time.Time `json:"created,omitempty" db:"created"`
+	WorkflowID           int64     `json:"workflow_id" db:"workflow_run_id"`
+	WorkflowNodeRunID    int64     `json:"workflow_node_run_id" db:"workflow_node_run_id"`
+	WorkflowNodeRunJobID int64     `json:"workflow_node_run_job_id,omitempty" db:"workflow_node_run_job_id"`
+	ID                   int64     `json:"id" db:"id"`
+	Name                 string    `json:"name" db:"name"`
+	Tag                  string    `json:"tag" db:"tag"`
+	DownloadHash         string    `json:"download_hash" db:"download_hash"`
+	Size                 int64     `json:"size,omitempty" db:"size"`
+	Perm                 uint32    `json:"perm,omitempty" db:"perm"`
+	MD5sum               string    `json:"md5sum,omitempty" db:"md5sum"`
+	SHA512sum            string    `json:"sha512sum,omitempty" db:"sha512sum"`
+	ObjectPath           string    `json:"object_path,omitempty" db:"object_path"`
+	Created              time.Time `json:"created,omitempty" db:"created"`
 }
 
 //WorkflowNodeJobRun represents an job to be run
 type WorkflowNodeJobRun struct {
-	ID                int64       `json:"id" db:"id"`
-	WorkflowNodeRunID int64       `json:"workflow_node_run_id,omitempty" db:"workflow_node_run_id"`
-	Job               ExecutedJob `json:"job" db:"-"`
-	Parameters        []Parameter `json:"parameters,omitempty" db:"-"`
-	Status            string      `json:"status"  db:"status"`
-	Queued            time.Time   `json:"queued,omitempty" db:"queued"`
-	QueuedSeconds     int64       `json:"queued_seconds,omitempty" db:"-"`
-	Start             time.Time   `json:"start,omitempty" db:"start"`
-	Done              time.Time   `json:"done,omitempty" db:"done"`
-	Model             string      `json:"model,omitempty" db:"model"`
-	BookedBy          Hatchery    `json:"bookedby" db:"-"`
-	SpawnInfos        []SpawnInfo `json:"spawninfos" db:"-"`
+	ID                int64             `json:"id" db:"id"`
+	WorkflowNodeRunID int64             `json:"workflow_node_run_id,omitempty" db:"workflow_node_run_id"`
+	Job               ExecutedJob       `json:"job" db:"-"`
+	Parameters        []Parameter       `json:"parameters,omitempty" db:"-"`
+	Status            string            `json:"status"  db:"status"`
+	Queued            time.Time         `json:"queued,omitempty" db:"queued"`
+	QueuedSeconds     int64             `json:"queued_seconds,omitempty" db:"-"`
+	Start             time.Time         `json:"start,omitempty" db:"start"`
+	Done              time.Time         `json:"done,omitempty" db:"done"`
+	Model             string            `json:"model,omitempty" db:"model"`
+	BookedBy          Hatchery          `json:"bookedby" db:"-"`
+	SpawnInfos        []SpawnInfo       `json:"spawninfos" db:"-"`
+	ProjectKey        string            `json:"project_key,omitempty" db:"-"`
+	Trigger           string            `json:"trigger,omitempty" db:"-"`
+	Quota             *QueueQuotaStatus `json:"quota,omitempty" db:"-"`
 }
 
+// Triggers of a WorkflowNodeJobRun, used by hatcheries to prioritize the queue
+const (
+	WorkflowJobTriggerManual = "manual"
+	WorkflowJobTriggerHook   = "hook"
+)
+
 // Translate translates messages in WorkflowNodeJobRun
 func (njr *WorkflowNodeJobRun) Translate(lang string) {
 	for ki, info := range njr.SpawnInfos {
@@ -131,15 +245,98 @@ type WorkflowNodeRunManual struct {
 	User               User        `json:"user" db:"-"`
 }
 
-//GetName returns the name the artifact
+// WorkflowArtifactObjectPath is the path of the artifacts stored by content
+const WorkflowArtifactObjectPath = "workflow-artifacts-sha512"
+
+//GetName returns the name the artifact. Artifacts with a sha512 digest are stored by content,
+//so that runs uploading the same file share the same object
 func (a *WorkflowNodeRunArtifact) GetName() string {
+	if a.SHA512sum != "" {
+		return a.SHA512sum
+	}
 	return a.Name
 }
 
 //GetPath returns the path of the artifact
 func (a *WorkflowNodeRunArtifact) GetPath() string {
+	if a.SHA512sum != "" {
+		return WorkflowArtifactObjectPath
+	}
 	container := fmt.Sprintf("%d-%d-%s", a.WorkflowID, a.WorkflowNodeRunID, a.Tag)
 	container = url.QueryEscape(container)
 	container = strings.Replace(container, "/", "-", -1)
 	return container
 }
+
+// Types of WorkflowRunEvent
+const (
+	WorkflowRunEventRun       = "run"
+	WorkflowRunEventNodeRun   = "node_run"
+	WorkflowRunEventJobRun    = "job_run"
+	WorkflowRunEventSpawnInfo = "spawn_info"
+	WorkflowRunEventLog       = "log"
+)
+
+// WorkflowRunEvent is sent on the workflow runs events stream when a run, a node run or a job run is updated
+type WorkflowRunEvent struct {
+	Type          string      `json:"type"`
+	ProjectKey    string      `json:"project_key"`
+	WorkflowName  string      `json:"workflow_name"`
+	WorkflowRunID int64       `json:"workflow_run_id"`
+	Number        int64       `json:"num"`
+	NodeRunID     int64       `json:"node_run_id,omitempty"`
+	JobRunID      int64       `json:"job_run_id,omitempty"`
+	Status        string      `json:"status,omitempty"`
+	SpawnInfos    []SpawnInfo `json:"spawninfos,omitempty"`
+	Log           *Log        `json:"log,omitempty"`
+	Timestamp     time.Time   `json:"timestamp"`
+}
+
+// GetWorkflowJobQueue returns the waiting workflow node job runs
+func GetWorkflowJobQueue() ([]WorkflowNodeJobRun, error) {
+	var q []WorkflowNodeJobRun
+
+	data, code, err := Request("GET", "/queue/workflows", nil)
+	if err != nil {
+		return nil, err
+	}
+	if code >= 300 {
+		return nil, fmt.Errorf("HTTP %d", code)
+	}
+
+	if err := json.Unmarshal(data, &q); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// BookWorkflowJob books a workflow node job run for a Hatchery
+func BookWorkflowJob(id int64) error {
+	path := fmt.Sprintf("/queue/workflows/%d/book", id)
+	data, code, err := Request("POST", path, nil)
+	if err != nil {
+		return fmt.Errorf("HTTP %d err:%s", code, err)
+	}
+	if code != http.StatusOK {
+		return fmt.Errorf("HTTP %d body:%s", code, string(data))
+	}
+	return nil
+}
+
+// AddSpawnInfosWorkflowJob adds spawn infos on a workflow node job run
+func AddSpawnInfosWorkflowJob(id int64, infos []SpawnInfo) error {
+	data, errm := json.Marshal(infos)
+	if errm != nil {
+		return errm
+	}
+
+	path := fmt.Sprintf("/queue/workflows/%d/spawn/infos", id)
+	out, code, err := Request("POST", path, data)
+	if err != nil {
+		return fmt.Errorf("HTTP %d err:%s", code, err)
+	}
+	if code != http.StatusOK {
+		return fmt.Errorf("HTTP %d body:%s", code, string(out))
+	}
+	return nil
+}
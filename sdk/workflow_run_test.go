@@ -0,0 +1,45 @@
+package sdk
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWorkflowRunFilterValues(t *testing.T) {
+	since := time.Date(2017, 10, 1, 12, 0, 0, 0, time.UTC)
+	f := WorkflowRunFilter{
+		Workflow: "build",
+		Status:   "Fail",
+		Branch:   "master",
+		Username: "john",
+		Tags:     []WorkflowRunTag{{Tag: "env", Value: "prod"}, {Tag: "version", Value: "1:2"}},
+		Since:    &since,
+		Offset:   10,
+		Limit:    20,
+	}
+
+	parsed, err := ParseWorkflowRunFilter(f.Values())
+	assert.NoError(t, err)
+	assert.Equal(t, f.Workflow, parsed.Workflow)
+	assert.Equal(t, f.Status, parsed.Status)
+	assert.Equal(t, f.Branch, parsed.Branch)
+	assert.Equal(t, f.Username, parsed.Username)
+	assert.Equal(t, f.Tags, parsed.Tags)
+	assert.True(t, since.Equal(*parsed.Since))
+	assert.Nil(t, parsed.Until)
+	assert.Equal(t, 10, parsed.Offset)
+	assert.Equal(t, 20, parsed.Limit)
+
+	for _, v := range []url.Values{
+		{"tag": {":prod"}},
+		{"since": {"yesterday"}},
+		{"limit": {"-1"}},
+		{"offset": {"ten"}},
+	} {
+		_, err := ParseWorkflowRunFilter(v)
+		assert.Error(t, err, "%v", v)
+	}
+}
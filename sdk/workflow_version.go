@@ -0,0 +1,202 @@
+package sdk
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Workflow node diff statuses
+const (
+	WorkflowDiffAdded   = "added"
+	WorkflowDiffRemoved = "removed"
+	WorkflowDiffUpdated = "updated"
+)
+
+// WorkflowVersion is an immutable snapshot of a workflow, recorded each time the workflow is saved
+type WorkflowVersion struct {
+	ID         int64     `json:"id" db:"id" cli:"-"`
+	WorkflowID int64     `json:"workflow_id" db:"workflow_id" cli:"-"`
+	Version    int64     `json:"version" db:"version" cli:"version,key"`
+	Workflow   *Workflow `json:"workflow,omitempty" db:"-" cli:"-"`
+	Author     string    `json:"author" db:"author" cli:"author"`
+	Comment    string    `json:"comment,omitempty" db:"comment" cli:"comment"`
+	Created    time.Time `json:"created" db:"created" cli:"created"`
+}
+
+// WorkflowVersionDiff is the difference between two versions of a workflow
+type WorkflowVersionDiff struct {
+	From    int64               `json:"from"`
+	To      int64               `json:"to"`
+	Changes []WorkflowFieldDiff `json:"changes,omitempty"`
+	Nodes   []WorkflowNodeDiff  `json:"nodes,omitempty"`
+}
+
+// WorkflowNodeDiff is the difference of a node between two versions of a workflow.
+// Nodes are identified by their name
+type WorkflowNodeDiff struct {
+	Name    string              `json:"name"`
+	Status  string              `json:"status"`
+	Changes []WorkflowFieldDiff `json:"changes,omitempty"`
+}
+
+// WorkflowFieldDiff is the difference of a field between two versions of a workflow
+type WorkflowFieldDiff struct {
+	Field  string `json:"field"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+// DiffWorkflows computes node by node the difference between two workflows
+func DiffWorkflows(from, to *Workflow) WorkflowVersionDiff {
+	diff := WorkflowVersionDiff{From: from.Version, To: to.Version}
+	diff.Changes = diffFields(
+		map[string]string{"name": from.Name, "description": from.Description},
+		map[string]string{"name": to.Name, "description": to.Description},
+	)
+
+	before := workflowNodeFields(from)
+	after := workflowNodeFields(to)
+	for name, fields := range before {
+		if _, ok := after[name]; !ok {
+			diff.Nodes = append(diff.Nodes, WorkflowNodeDiff{Name: name, Status: WorkflowDiffRemoved, Changes: diffFields(fields, nil)})
+		}
+	}
+	for name, fields := range after {
+		old, ok := before[name]
+		if !ok {
+			diff.Nodes = append(diff.Nodes, WorkflowNodeDiff{Name: name, Status: WorkflowDiffAdded, Changes: diffFields(nil, fields)})
+			continue
+		}
+		if changes := diffFields(old, fields); len(changes) > 0 {
+			diff.Nodes = append(diff.Nodes, WorkflowNodeDiff{Name: name, Status: WorkflowDiffUpdated, Changes: changes})
+		}
+	}
+	sort.Slice(diff.Nodes, func(i, j int) bool { return diff.Nodes[i].Name < diff.Nodes[j].Name })
+	return diff
+}
+
+func diffFields(before, after map[string]string) []WorkflowFieldDiff {
+	keys := map[string]struct{}{}
+	for k := range before {
+		keys[k] = struct{}{}
+	}
+	for k := range after {
+		keys[k] = struct{}{}
+	}
+
+	changes := []WorkflowFieldDiff{}
+	for k := range keys {
+		if before[k] != after[k] {
+			changes = append(changes, WorkflowFieldDiff{Field: k, Before: before[k], After: after[k]})
+		}
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Field < changes[j].Field })
+	return changes
+}
+
+// workflowNodeFields flattens the nodes of a workflow, with the trigger which starts them, as comparable fields.
+// A name used by several nodes is suffixed by its occurrence
+func workflowNodeFields(w *Workflow) map[string]map[string]string {
+	nodes := map[string]map[string]string{}
+	names := map[int64]string{}
+
+	var name = func(n *WorkflowNode) string {
+		base := n.Name
+		if base == "" && n.SubWorkflow != nil {
+			base = n.SubWorkflow.WorkflowName
+		}
+		if base == "" {
+			base = n.Pipeline.Name
+		}
+		res := base
+		for i := 2; ; i++ {
+			if _, ok := nodes[res]; !ok {
+				break
+			}
+			res = fmt.Sprintf("%s#%d", base, i)
+		}
+		names[n.ID] = res
+		return res
+	}
+
+	var walk func(n *WorkflowNode, parent string, conditions []WorkflowTriggerCondition, approval *WorkflowTriggerApproval)
+	walk = func(n *WorkflowNode, parent string, conditions []WorkflowTriggerCondition, approval *WorkflowTriggerApproval) {
+		fields := map[string]string{
+			"pipeline": n.Pipeline.Name,
+			"parent":   parent,
+		}
+		if len(conditions) > 0 {
+			fields["conditions"] = diffJSON(conditions)
+		}
+		if approval != nil {
+			fields["approval"] = diffJSON(approval)
+		}
+		if n.SubWorkflow != nil {
+			fields["workflow"] = n.SubWorkflow.String()
+		}
+		if len(n.Matrix) > 0 {
+			fields["matrix"] = diffJSON(n.Matrix)
+		}
+		if n.Context != nil {
+			if n.Context.Application != nil {
+				fields["application"] = n.Context.Application.Name
+			} else if n.Context.ApplicationID != 0 {
+				fields["application"] = fmt.Sprintf("%d", n.Context.ApplicationID)
+			}
+			if n.Context.Environment != nil {
+				fields["environment"] = n.Context.Environment.Name
+			} else if n.Context.EnvironmentID != 0 {
+				fields["environment"] = fmt.Sprintf("%d", n.Context.EnvironmentID)
+			}
+			if n.Context.DefaultPayload != nil {
+				fields["default_payload"] = diffJSON(n.Context.DefaultPayload)
+			}
+			for _, p := range n.Context.DefaultPipelineParameters {
+				fields["parameter."+p.Name] = p.Value
+			}
+		}
+		for _, h := range n.Hooks {
+			hook := h.WorkflowHookModel.Name
+			if len(h.Conditions) > 0 {
+				fields["hook."+hook+".conditions"] = diffJSON(h.Conditions)
+			}
+			for k, v := range h.Config {
+				fields["hook."+hook+"."+k] = v
+			}
+		}
+
+		nodeName := name(n)
+		nodes[nodeName] = fields
+		for i := range n.Triggers {
+			t := &n.Triggers[i]
+			walk(&t.WorkflowDestNode, nodeName, t.Conditions, t.Approval)
+		}
+	}
+
+	if w.Root == nil {
+		return nodes
+	}
+	walk(w.Root, "", nil, nil)
+
+	for _, j := range w.Joins {
+		sources := make([]string, 0, len(j.SourceNodeIDs))
+		for _, id := range j.SourceNodeIDs {
+			sources = append(sources, names[id])
+		}
+		sort.Strings(sources)
+		parent := "join(" + strings.Join(sources, ",") + ")"
+		for i := range j.Triggers {
+			t := &j.Triggers[i]
+			walk(&t.WorkflowDestNode, parent, t.Conditions, nil)
+		}
+	}
+	return nodes
+}
+
+func diffJSON(i interface{}) string {
+	btes, _ := json.Marshal(i)
+	return string(btes)
+}
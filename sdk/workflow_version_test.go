@@ -0,0 +1,116 @@
+package sdk
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffWorkflows(t *testing.T) {
+	from := &Workflow{
+		Name:    "build",
+		Version: 1,
+		Root: &WorkflowNode{
+			ID:       1,
+			Name:     "compile",
+			Pipeline: Pipeline{Name: "compile"},
+			Context:  &WorkflowNodeContext{Application: &Application{Name: "app"}},
+			Triggers: []WorkflowNodeTrigger{
+				{
+					Conditions: []WorkflowTriggerCondition{{Variable: "git.branch", Operator: "eq", Value: "master"}},
+					WorkflowDestNode: WorkflowNode{
+						ID:       2,
+						Name:     "deploy",
+						Pipeline: Pipeline{Name: "deploy"},
+					},
+				},
+				{
+					WorkflowDestNode: WorkflowNode{
+						ID:       3,
+						Name:     "lint",
+						Pipeline: Pipeline{Name: "lint"},
+					},
+				},
+			},
+		},
+		Joins: []WorkflowNodeJoin{
+			{
+				SourceNodeIDs: []int64{2, 3},
+				Triggers: []WorkflowNodeJoinTrigger{
+					{WorkflowDestNode: WorkflowNode{ID: 4, Name: "notify", Pipeline: Pipeline{Name: "notify"}}},
+				},
+			},
+		},
+	}
+
+	to := &Workflow{
+		Name:        "build",
+		Description: "Build and deploy",
+		Version:     2,
+		Root: &WorkflowNode{
+			ID:       10,
+			Name:     "compile",
+			Pipeline: Pipeline{Name: "compile"},
+			Context:  &WorkflowNodeContext{Application: &Application{Name: "app"}},
+			Triggers: []WorkflowNodeTrigger{
+				{
+					Conditions: []WorkflowTriggerCondition{{Expression: `git.branch in ["master", "release"]`}},
+					WorkflowDestNode: WorkflowNode{
+						ID:       11,
+						Name:     "deploy",
+						Pipeline: Pipeline{Name: "deploy"},
+						Context:  &WorkflowNodeContext{Environment: &Environment{Name: "prod"}},
+					},
+				},
+				{
+					WorkflowDestNode: WorkflowNode{
+						ID:       12,
+						Name:     "test",
+						Pipeline: Pipeline{Name: "test"},
+					},
+				},
+			},
+		},
+		Joins: []WorkflowNodeJoin{
+			{
+				SourceNodeIDs: []int64{12, 11},
+				Triggers: []WorkflowNodeJoinTrigger{
+					{WorkflowDestNode: WorkflowNode{ID: 13, Name: "notify", Pipeline: Pipeline{Name: "notify"}}},
+				},
+			},
+		},
+	}
+
+	diff := DiffWorkflows(from, to)
+	assert.Equal(t, int64(1), diff.From)
+	assert.Equal(t, int64(2), diff.To)
+	assert.Equal(t, []WorkflowFieldDiff{{Field: "description", After: "Build and deploy"}}, diff.Changes)
+
+	assert.Len(t, diff.Nodes, 4)
+	byName := map[string]WorkflowNodeDiff{}
+	for _, n := range diff.Nodes {
+		byName[n.Name] = n
+	}
+
+	assert.Equal(t, WorkflowDiffUpdated, byName["deploy"].Status)
+	assert.Equal(t, []WorkflowFieldDiff{
+		{
+			Field:  "conditions",
+			Before: `[{"variable":"git.branch","operator":"eq","value":"master"}]`,
+			After:  `[{"variable":"","operator":"","value":"","expression":"git.branch in [\"master\", \"release\"]"}]`,
+		},
+		{Field: "environment", After: "prod"},
+	}, byName["deploy"].Changes)
+
+	assert.Equal(t, WorkflowDiffRemoved, byName["lint"].Status)
+	assert.Equal(t, WorkflowDiffAdded, byName["test"].Status)
+	assert.Equal(t, WorkflowDiffUpdated, byName["notify"].Status)
+	assert.Equal(t, []WorkflowFieldDiff{{Field: "parent", Before: "join(deploy,lint)", After: "join(deploy,test)"}}, byName["notify"].Changes)
+	_, ok := byName["compile"]
+	assert.False(t, ok)
+
+	// Same workflow, no change
+	diff = DiffWorkflows(to, to)
+	assert.Empty(t, diff.Changes)
+	assert.Empty(t, diff.Nodes)
+}
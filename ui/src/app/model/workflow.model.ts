@@ -85,6 +85,13 @@ export class WorkflowNodeJoinTrigger {
     }
 }
 
+// WorkflowNodeSubWorkflow represents the workflow run by a node instead of a pipeline
+export class WorkflowNodeSubWorkflow {
+    workflow_id: number;
+    project_key: string;
+    workflow_name: string;
+}
+
 // WorkflowNode represents a node in w workflow tree
 export class WorkflowNode {
     id: number;
@@ -95,6 +102,8 @@ export class WorkflowNode {
     context: WorkflowNodeContext;
     hooks: Array<WorkflowNodeHook>;
     triggers: Array<WorkflowNodeTrigger>;
+    matrix: {[key: string]: Array<string>; };
+    sub_workflow: WorkflowNodeSubWorkflow;
 
 
     static getNodeByID(node: WorkflowNode, id: number) {
@@ -16,6 +16,7 @@ export class WorkflowRun {
     workflow: Workflow;
     start: string;
     last_modified: string;
+    parent_node_run_id: number;
     nodes: {[key: string]: Array<WorkflowNodeRun>; };
 }
 
@@ -26,6 +27,8 @@ export class WorkflowNodeRun {
     workflow_node_id: number;
     num: number;
     subnumber: number;
+    matrix: {[key: string]: string; };
+    sub_workflow_run_id: number;
     status: string;
     stages: Array<Stage>;
     start: string;
@@ -8,6 +8,8 @@ import {AuthentificationStore} from '../auth/authentification.store';
 @Injectable()
 export class UserService {
 
+    sessionStorageOIDCStateKey = 'CDS-OIDC-STATE';
+
     constructor(private _http: Http, private _authStore: AuthentificationStore) {
     }
 
@@ -45,6 +47,43 @@ export class UserService {
         });
     }
 
+    /**
+     * Start an OpenID Connect login
+     * @returns {Observable<string>} URL of the OpenID Connect provider, where the user must be redirected
+     */
+    loginOIDC(): Observable<string> {
+        return this._http.get('/login/oidc').map(res => {
+            sessionStorage.setItem(this.sessionStorageOIDCStateKey, res.json().state);
+            return res.json().url;
+        });
+    }
+
+    /**
+     * Check that the state sent back by the OpenID Connect provider is the one of the login started in this browser
+     * @param state State sent by the provider
+     * @returns {boolean}
+     */
+    checkOIDCState(state: string): boolean {
+        let expected = sessionStorage.getItem(this.sessionStorageOIDCStateKey);
+        sessionStorage.removeItem(this.sessionStorageOIDCStateKey);
+        return !!expected && expected === state;
+    }
+
+    /**
+     * End an OpenID Connect login, when the OpenID Connect provider redirects the user to CDS
+     * @param code Authorization code sent by the provider
+     * @param state State sent by the provider
+     * @returns {Observable<User>}
+     */
+    loginOIDCCallback(code: string, state: string): Observable<User> {
+        return this._http.post('/login/oidc/callback', { code: code, state: state }).map(res => {
+            let u = res.json().user;
+            u.token = res.headers.get(this._authStore.localStorageSessionKey);
+            this._authStore.addUser(u, true);
+            return u;
+        });
+    }
+
     resetPassword(user: User, href: string) {
         let request = {
             user: user,
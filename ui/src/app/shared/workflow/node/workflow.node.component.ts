@@ -50,6 +50,7 @@ export class WorkflowNodeComponent implements AfterViewInit, OnInit {
 
     zone: NgZone;
     currentNodeRun: WorkflowNodeRun;
+    matrixNodeRuns: Array<WorkflowNodeRun>;
     pipelineStatus = PipelineStatus;
 
     loading = false;
@@ -66,6 +67,8 @@ export class WorkflowNodeComponent implements AfterViewInit, OnInit {
                 let wr = <WorkflowRun>JSON.parse(wrString);
                 if (wr.nodes[this.node.id] && wr.nodes[this.node.id].length > 0) {
                     this.currentNodeRun = wr.nodes[this.node.id][0];
+                    // All the instances of a matrix share the subnumber of the current node run
+                    this.matrixNodeRuns = wr.nodes[this.node.id].filter(nr => nr.matrix && nr.subnumber === this.currentNodeRun.subnumber);
                 }
             });
         } else {
@@ -227,14 +230,21 @@ export class WorkflowNodeComponent implements AfterViewInit, OnInit {
         this.linkJoinEvent.emit(this.node);
     }
 
-    goToNodeRun(): void {
-        if (!this.webworker || !this.currentNodeRun) {
+    goToNodeRun(nodeRun?: WorkflowNodeRun): void {
+        if (!nodeRun) {
+            nodeRun = this.currentNodeRun;
+        }
+        if (!this.webworker || !nodeRun) {
             return;
         }
         this._router.navigate([
             '/project', this.project.key,
             'workflow', this.workflow.name,
-            'run', this.currentNodeRun.num,
-            'node', this.currentNodeRun.id]);
+            'run', nodeRun.num,
+            'node', nodeRun.id]);
+    }
+
+    matrixLabel(nodeRun: WorkflowNodeRun): string {
+        return Object.keys(nodeRun.matrix).sort().map(k => nodeRun.matrix[k]).join(' / ');
     }
 }
@@ -4,6 +4,7 @@ import {accountRouting} from './account.routing';
 import {PasswordComponent} from './password/password.component';
 import {SignUpComponent} from './signup/signup.component';
 import {VerifyComponent} from './verify/verify.component';
+import {OIDCComponent} from './oidc/oidc.component';
 import {SharedModule} from '../../shared/shared.module';
 
 
@@ -15,6 +16,7 @@ import {SharedModule} from '../../shared/shared.module';
         PasswordComponent,
         SignUpComponent,
         VerifyComponent,
+        OIDCComponent,
     ],
     imports: [
         SharedModule,
@@ -35,6 +35,12 @@ export class LoginComponent extends AccountComponent {
         });
     }
 
+    signInSSO() {
+        this._userService.loginOIDC().subscribe(url => {
+            window.location.href = url;
+        });
+    }
+
     navigateToSignUp() {
         this._router.navigate(['/account/signup']);
     }
@@ -0,0 +1,95 @@
+/* tslint:disable:no-unused-variable */
+
+import {TestBed, fakeAsync, tick, inject} from '@angular/core/testing';
+import {APP_BASE_HREF} from '@angular/common';
+import {RouterTestingModule} from '@angular/router/testing';
+import {MockBackend} from '@angular/http/testing';
+import {XHRBackend, Response, ResponseOptions, Headers} from '@angular/http';
+
+import {UserService} from '../../../service/user/user.service';
+import {AuthentificationStore} from '../../../service/auth/authentification.store';
+import {AppModule} from '../../../app.module';
+import {Router, ActivatedRoute} from '@angular/router';
+import {OIDCComponent} from './oidc.component';
+import {AccountModule} from '../account.module';
+
+describe('CDS: OIDCComponent', () => {
+
+    beforeEach(() => {
+        TestBed.configureTestingModule({
+            declarations: [],
+            providers: [
+                { provide: APP_BASE_HREF, useValue: '/' },
+                { provide: XHRBackend, useClass: MockBackend },
+                UserService,
+                AuthentificationStore,
+                { provide: Router, useClass: MockRouter},
+                { provide: ActivatedRoute, useClass: MockActivatedRoutes}
+            ],
+            imports : [
+                AppModule,
+                RouterTestingModule.withRoutes([]),
+                AccountModule
+            ]
+        });
+    });
+
+    it('Callback OK', fakeAsync( inject([XHRBackend, AuthentificationStore], (backend: MockBackend,
+        authStore: AuthentificationStore) => {
+        sessionStorage.setItem('CDS-OIDC-STATE', 'myState');
+
+        // Create component
+        let fixture = TestBed.createComponent(OIDCComponent);
+        let component = fixture.debugElement.componentInstance;
+        expect(component).toBeTruthy();
+
+        // Mock Http callback request
+        backend.connections.subscribe(connection => {
+            let headers = new Headers();
+            headers.append(authStore.localStorageSessionKey, 'mySession');
+            connection.mockRespond(new Response(new ResponseOptions({ body : '{ "user": { "username": "foo" }}',
+                headers: headers})));
+        });
+
+        fixture.detectChanges();
+        tick(50);
+
+        expect(backend.connectionsArray.length).toBe(1);
+        expect(backend.connectionsArray[0].request.url).toBe('foo.bar/login/oidc/callback',
+            'API OpenID Connect callback handler must be call');
+        let body = JSON.parse(backend.connectionsArray[0].request.getBody());
+        expect(body.code).toBe('myCode');
+        expect(body.state).toBe('myState');
+        expect(fixture.componentInstance.showErrorMessage).toBeFalsy('We must not show error message if login is ok');
+        expect(sessionStorage.getItem('CDS-OIDC-STATE')).toBeNull('State must be used only once');
+    })));
+
+    it('Callback with an unknown state', fakeAsync( inject([XHRBackend], (backend: MockBackend) => {
+        sessionStorage.setItem('CDS-OIDC-STATE', 'anotherState');
+
+        // Create component
+        let fixture = TestBed.createComponent(OIDCComponent);
+        let component = fixture.debugElement.componentInstance;
+        expect(component).toBeTruthy();
+
+        fixture.detectChanges();
+        tick(50);
+
+        expect(backend.connectionsArray.length).toBe(0, 'API OpenID Connect callback handler must not be call');
+        expect(fixture.componentInstance.showErrorMessage).toBeTruthy('We must show error message if state is unknown');
+    })));
+});
+
+export class MockRouter {
+    public navigate() {
+    }
+}
+
+export class MockActivatedRoutes {
+    snapshot = {
+        queryParams: {
+            'code': 'myCode',
+            'state': 'myState'
+        }
+    };
+}
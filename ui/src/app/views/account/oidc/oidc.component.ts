@@ -0,0 +1,33 @@
+import {ActivatedRoute, Params, Router} from '@angular/router';
+import {UserService} from '../../../service/user/user.service';
+import {Component, OnInit} from '@angular/core';
+import {AccountComponent} from '../account.component';
+import {AuthentificationStore} from '../../../service/auth/authentification.store';
+
+@Component({
+    selector: 'app-account-oidc',
+    templateUrl: './oidc.html',
+    styleUrls: ['./oidc.scss']
+})
+export class OIDCComponent extends AccountComponent implements OnInit  {
+
+    showErrorMessage = false;
+
+    constructor(private _userService: UserService, private _router: Router,
+        private _activatedRoute: ActivatedRoute, private _authStore: AuthentificationStore) {
+        super(_authStore);
+    }
+
+    ngOnInit(): void {
+        let params: Params = this._activatedRoute.snapshot.queryParams;
+        if (!params['code'] || !params['state'] || !this._userService.checkOIDCState(params['state'])) {
+            this.showErrorMessage = true;
+            return;
+        }
+        this._userService.loginOIDCCallback(params['code'], params['state']).subscribe(() => {
+            this._router.navigate(['home']);
+        }, () => {
+            this.showErrorMessage = true;
+        });
+    }
+}